@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	goredis "github.com/redis/go-redis/v9"
 	"github.com/storacha/go-libstoracha/ipnipublisher/notifier"
@@ -12,9 +13,11 @@ import (
 	"github.com/storacha/go-libstoracha/metadata"
 	userver "github.com/storacha/go-ucanto/server"
 	"github.com/storacha/indexing-service/pkg/aws"
+	"github.com/storacha/indexing-service/pkg/construct"
 	"github.com/storacha/indexing-service/pkg/principalresolver"
 	"github.com/storacha/indexing-service/pkg/redis"
 	"github.com/storacha/indexing-service/pkg/server"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 	"github.com/storacha/indexing-service/pkg/service/providercacher"
 	"github.com/storacha/indexing-service/pkg/service/providerindex/remotesyncer"
 	"github.com/storacha/indexing-service/pkg/telemetry"
@@ -25,6 +28,10 @@ import (
 var awsCmd = &cli.Command{
 	Name:  "aws",
 	Usage: "Run the indexing service as a containerized server in AWS",
+	Subcommands: []*cli.Command{
+		migrateLegacyCmd,
+		republishCmd,
+	},
 	Flags: []cli.Flag{
 		&cli.IntFlag{
 			Name:    "port",
@@ -45,12 +52,11 @@ var awsCmd = &cli.Command{
 			return fmt.Errorf("creating principal resolver: %w", err)
 		}
 
-		srvOpts = append(
-			srvOpts,
-			server.WithContentClaimsOptions(
-				userver.WithPrincipalResolver(presolv.ResolveDIDKey),
-			),
-		)
+		claimsOpts := []userver.Option{userver.WithPrincipalResolver(presolv.ResolveDIDKey)}
+		if len(cfg.ClaimAuthorities) > 0 {
+			claimsOpts = append(claimsOpts, userver.WithCanIssue(contentclaims.NewAuthorityPolicy(cfg.ClaimAuthorities).CanIssue))
+		}
+		srvOpts = append(srvOpts, server.WithContentClaimsOptions(claimsOpts...))
 
 		ipniSrvOpts, err := ipniOpts(cfg.IPNIFormatPeerID, cfg.IPNIFormatEndpoint)
 		if err != nil {
@@ -72,7 +78,47 @@ var awsCmd = &cli.Command{
 			srvOpts = append(srvOpts, server.WithTelemetry())
 		}
 
-		indexer, err := aws.Construct(cfg)
+		if cfg.MetricsEnabled {
+			metricsHandler, metricsShutdown, err := telemetry.SetupMetrics()
+			if err != nil {
+				return fmt.Errorf("setting up metrics: %w", err)
+			}
+			defer metricsShutdown(cCtx.Context)
+			srvOpts = append(srvOpts, server.WithMetrics(metricsHandler))
+		}
+
+		publishLimiter, queryLimiter, err := aws.ConstructRateLimiters(cfg)
+		if err != nil {
+			return fmt.Errorf("setting up rate limits: %w", err)
+		}
+		srvOpts = append(srvOpts, server.WithPublishRateLimit(publishLimiter), server.WithQueryRateLimit(queryLimiter), server.WithTrustedProxies(cfg.TrustedProxies))
+
+		if cfg.AccessLogEnabled {
+			srvOpts = append(srvOpts, server.WithAccessLog(server.NewAccessLogger(os.Stdout, cfg.AccessLogSampleRate)))
+		}
+
+		publicAddrInfo, err := construct.PublicAddrInfo(cfg.ServiceConfig)
+		if err != nil {
+			return fmt.Errorf("building public address info: %w", err)
+		}
+		srvOpts = append(srvOpts, server.WithIPNIFind(publicAddrInfo))
+
+		// share the registry between the service, the server and the block
+		// proxy, so that admin changes invalidate the cache used by the service
+		registry := aws.ConstructProviderRegistry(cfg)
+		if registry != nil {
+			srvOpts = append(srvOpts, server.WithProviderRegistry(registry))
+			if cfg.AdminToken != "" {
+				srvOpts = append(srvOpts, server.WithProviderAdmin(registry, cfg.AdminToken))
+			}
+		}
+
+		if cfg.BlockProxyEnabled {
+			proxy := server.NewBlockProxy(construct.DefaultHTTPClient(), registry, cfg.BlockProxyAllowedHosts)
+			srvOpts = append(srvOpts, server.WithBlockProxy(proxy))
+		}
+
+		indexer, err := aws.Construct(cfg, registry)
 		if err != nil {
 			return err
 		}
@@ -84,13 +130,21 @@ var awsCmd = &cli.Command{
 		notifier.Start(cCtx.Context)
 		defer notifier.Stop()
 
-		cacher, err := setupProviderCacher(cfg)
+		cacher, cachingQueue, err := setupProviderCacher(cfg)
 		if err != nil {
 			return err
 		}
 		cacher.Start()
 		defer cacher.Stop()
 
+		if cfg.MetricsEnabled {
+			depth, err := providercacher.RegisterQueueDepth(cachingQueue)
+			if err != nil {
+				return fmt.Errorf("registering provider caching queue depth: %w", err)
+			}
+			defer depth.Unregister()
+		}
+
 		publisher, advertisementPublisher, err := setupIPNIPublisher(cfg)
 		if err != nil {
 			return err
@@ -127,17 +181,29 @@ func setupIPNIPipeline(cfg aws.Config) (*notifier.Notifier, error) {
 	return notifier, nil
 }
 
-func setupProviderCacher(cfg aws.Config) (*providercacher.CachingQueuePoller, error) {
+func setupProviderCacher(cfg aws.Config) (*providercacher.CachingQueuePoller, *aws.SQSCachingQueue, error) {
 	cachingQueue := aws.NewSQSCachingQueue(cfg.Config, cfg.SQSCachingQueueID, cfg.CachingBucket)
 
 	providersRedis := goredis.NewClusterClient(&cfg.ProvidersRedis)
 	if cfg.TelemetryEnabled {
 		providersRedis = telemetry.InstrumentRedisClient(providersRedis)
 	}
-	providerStore := redis.NewProviderStore(redis.NewClusterClientAdapter(providersRedis))
-	providerCacher := providercacher.NewSimpleProviderCacher(providerStore)
+	providersClient := redis.NewClusterClientAdapter(providersRedis)
+	providerStore := redis.NewProviderStore(providersClient)
+	providerCacher, err := providercacher.NewIncrementalProviderCacher(
+		providerStore,
+		redis.NewCachedIndexStore(providersClient),
+		providercacher.WithCheckpoints(redis.NewCachingCheckpointStore(providersClient)),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return providercacher.NewCachingQueuePoller(cachingQueue, providerCacher)
+	poller, err := providercacher.NewBatchCachingQueuePoller(cachingQueue, providerCacher)
+	if err != nil {
+		return nil, nil, err
+	}
+	return poller, cachingQueue, nil
 }
 
 func setupIPNIPublisherStore(cfg aws.Config) *store.AdStore {
@@ -12,7 +12,7 @@ func main() {
 }
 
 func makeHandler(cfg aws.Config) any {
-	service, err := aws.Construct(cfg)
+	service, err := aws.Construct(cfg, aws.ConstructProviderRegistry(cfg))
 	if err != nil {
 		panic(err)
 	}
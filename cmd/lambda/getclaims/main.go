@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 	"github.com/storacha/indexing-service/cmd/lambda"
 	"github.com/storacha/indexing-service/pkg/aws"
@@ -12,12 +14,22 @@ func main() {
 }
 
 func makeHandler(cfg aws.Config) any {
-	service, err := aws.Construct(cfg)
+	service, err := aws.Construct(cfg, aws.ConstructProviderRegistry(cfg))
 	if err != nil {
 		panic(err)
 	}
 
-	handler := httpadapter.NewV2(server.GetClaimsHandler(service)).ProxyWithContext
+	_, queryLimiter, err := aws.ConstructRateLimiters(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	var accessLogger *server.AccessLogger
+	if cfg.AccessLogEnabled {
+		accessLogger = server.NewAccessLogger(os.Stdout, cfg.AccessLogSampleRate)
+	}
+
+	handler := httpadapter.NewV2(accessLogger.Wrap("GET /claims", server.WithRateLimit(queryLimiter, cfg.TrustedProxies, server.GetClaimsHandler(service)))).ProxyWithContext
 
 	return handler
 }
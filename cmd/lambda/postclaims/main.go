@@ -8,7 +8,9 @@ import (
 	"github.com/storacha/indexing-service/cmd/lambda"
 	"github.com/storacha/indexing-service/pkg/aws"
 	"github.com/storacha/indexing-service/pkg/principalresolver"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/server"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 )
 
 func main() {
@@ -16,17 +18,30 @@ func main() {
 }
 
 func makeHandler(cfg aws.Config) any {
-	service, err := aws.Construct(cfg)
+	service, err := aws.Construct(cfg, aws.ConstructProviderRegistry(cfg))
 	if err != nil {
 		panic(err)
 	}
 
+	publishLimiter, _, err := aws.ConstructRateLimiters(cfg)
+	if err != nil {
+		panic(err)
+	}
+	if publishLimiter != nil {
+		service = ratelimit.NewService(service, publishLimiter)
+	}
+
 	presolv, err := principalresolver.New(cfg.PrincipalMapping)
 	if err != nil {
 		panic(fmt.Errorf("creating principal resolver: %w", err))
 	}
 
-	handler := httpadapter.NewV2(server.PostClaimsHandler(cfg.Signer, service, ucanserver.WithPrincipalResolver(presolv.ResolveDIDKey))).ProxyWithContext
+	claimsOpts := []ucanserver.Option{ucanserver.WithPrincipalResolver(presolv.ResolveDIDKey)}
+	if len(cfg.ClaimAuthorities) > 0 {
+		claimsOpts = append(claimsOpts, ucanserver.WithCanIssue(contentclaims.NewAuthorityPolicy(cfg.ClaimAuthorities).CanIssue))
+	}
+
+	handler := httpadapter.NewV2(server.PostClaimsHandler(cfg.Signer, service, claimsOpts...)).ProxyWithContext
 
 	return handler
 }
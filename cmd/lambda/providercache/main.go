@@ -30,8 +30,16 @@ func makeHandler(cfg aws.Config) any {
 	if cfg.TelemetryEnabled {
 		providersRedis = telemetry.InstrumentRedisClient(providersRedis)
 	}
-	providerStore := redis.NewProviderStore(redis.NewClusterClientAdapter(providersRedis))
-	providerCacher := providercacher.NewSimpleProviderCacher(providerStore)
+	providersClient := redis.NewClusterClientAdapter(providersRedis)
+	providerStore := redis.NewProviderStore(providersClient)
+	providerCacher, err := providercacher.NewIncrementalProviderCacher(
+		providerStore,
+		redis.NewCachedIndexStore(providersClient),
+		providercacher.WithCheckpoints(redis.NewCachingCheckpointStore(providersClient)),
+	)
+	if err != nil {
+		panic(err)
+	}
 	sqsCachingDecoder := aws.NewSQSCachingDecoder(cfg.Config, cfg.CachingBucket)
 
 	return func(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
@@ -86,8 +94,15 @@ func handleMessage(ctx context.Context, sqsCachingDecoder *aws.SQSCachingDecoder
 		return err
 	}
 	err = providerCacher.CacheProviderForIndexRecords(ctx, job.Job.Provider, job.Job.Index)
-	// Do not hold up the queue by re-attempting a cache job that times out. It is
-	// probably a big DAG and retrying is unlikely to subsequently succeed.
+	// A job interrupted after checkpointing its progress is retried, and resumes
+	// from the checkpoint.
+	if errors.Is(err, providercacher.ErrCachingInterrupted) {
+		log.Warnf("cache provider job for: %s interrupted, will resume: %s", job.Job.Index.Content(), err)
+		return err
+	}
+	// Do not hold up the queue by re-attempting a cache job that times out
+	// without making progress. It is probably a big DAG and retrying is unlikely
+	// to subsequently succeed.
 	if errors.Is(err, context.DeadlineExceeded) {
 		log.Warnf("not retrying cache provider job for: %s error: %s", job.Job.Index.Content(), err)
 		return nil
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/storacha/indexing-service/pkg/aws"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+)
+
+var migrateLegacyCmd = &cli.Command{
+	Name:  "migrate-legacy",
+	Usage: "Republish the claims in legacy tables to IPNI, resuming from the recorded progress",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "table",
+			Usage: "legacy table to migrate ['claims' | 'block-index'], can be specified multiple times (default: all tables)",
+		},
+		&cli.BoolFlag{
+			Name:  "status",
+			Usage: "print the recorded progress of the migrations instead of migrating",
+		},
+	},
+	Action: func(cCtx *cli.Context) error {
+		cfg := aws.FromEnv(cCtx.Context)
+		migrator, migrations, err := aws.ConstructLegacyMigration(cfg)
+		if err != nil {
+			return fmt.Errorf("setting up legacy migration: %w", err)
+		}
+
+		tables := cCtx.StringSlice("table")
+		for _, t := range tables {
+			if !slices.ContainsFunc(migrations, func(m aws.LegacyMigration) bool { return m.Mapper.Name() == t }) {
+				return fmt.Errorf("unknown legacy table: %s", t)
+			}
+		}
+
+		for _, m := range migrations {
+			if len(tables) > 0 && !slices.Contains(tables, m.Mapper.Name()) {
+				continue
+			}
+			var progress legacy.MigrationProgress
+			if cCtx.Bool("status") {
+				progress, err = migrator.Progress(cCtx.Context, m.Mapper)
+			} else {
+				log.Infof("migrating legacy %s table", m.Mapper.Name())
+				progress, err = migrator.Walk(cCtx.Context, m.Mapper, m.Walker)
+			}
+			if err != nil {
+				return fmt.Errorf("migrating legacy %s table: %w", m.Mapper.Name(), err)
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(progress); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
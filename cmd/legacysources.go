@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/urfave/cli/v2"
+
+	"github.com/storacha/indexing-service/pkg/aws"
+	"github.com/storacha/indexing-service/pkg/carindex"
+	"github.com/storacha/indexing-service/pkg/construct"
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/service"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/sqlstore"
+)
+
+// legacySourceOpts configures the service to synthesize location claims from
+// the legacy block index sources enabled on the command line: a SQL database
+// and a directory of CARv2 files and CSV exports, consulted in that order. It returns a
+// function releasing the sources, to be called when the service stops.
+// Names of the legacy mappers of local sources, identifying them in traces,
+// metrics and migration progress.
+const (
+	sqlBlockIndexMapperName = "sql-block-index"
+	carBlockIndexMapperName = "car-block-index"
+)
+
+func legacySourceOpts(cCtx *cli.Context, id principal.Signer, publicURL string) ([]construct.Option, func() error, error) {
+	closer := func() error { return nil }
+	// allow synthesized claims to live longer after they are expired in the
+	// claims cache, so that the service doesn't return cached but expired
+	// delegations
+	claimExp := redis.DefaultExpire + time.Hour
+
+	var mappers []legacy.ContentToClaimsMapper
+	if dsn := cCtx.String("legacy-sql-dsn"); dsn != "" {
+		db, err := sql.Open(cCtx.String("legacy-sql-driver"), dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening legacy SQL database: %w", err)
+		}
+		if err := db.PingContext(cCtx.Context); err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("connecting to legacy SQL database: %w", err)
+		}
+		closer = db.Close
+
+		allocations := sqlstore.NewAllocationsTable(db, cCtx.String("legacy-sql-allocations-table"))
+		mapper, err := aws.NewBlockIndexTableMapper(
+			id,
+			sqlstore.NewBlockIndexTable(db, cCtx.String("legacy-sql-block-index-table")),
+			sqlstore.NewMigratedShardChecker(db, cCtx.String("legacy-sql-store-table"), cCtx.String("legacy-sql-blob-registry-table"), allocations),
+			cCtx.String("legacy-data-bucket-url"),
+			claimExp,
+			cCtx.StringSlice("legacy-dot-storage-bucket-prefix"),
+		)
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("creating legacy SQL block index mapper: %w", err)
+		}
+		mappers = append(mappers, legacy.Named(sqlBlockIndexMapperName, mapper))
+	}
+
+	if dir := cCtx.String("legacy-car-dir"); dir != "" {
+		store, err := carindex.Open(cCtx.Context, dir, cCtx.String("legacy-car-url"), cCtx.String("legacy-car-index-db"))
+		if err != nil {
+			closer()
+			return nil, nil, fmt.Errorf("loading legacy CAR indexes: %w", err)
+		}
+		closeSQL := closer
+		closer = func() error {
+			return errors.Join(store.Close(), closeSQL())
+		}
+		// CAR paths of CAR files are URLs, while those of CSV exports may be
+		// region/bucket/key paths in the legacy data bucket
+		mapper, err := aws.NewBlockIndexTableMapper(
+			id,
+			store,
+			store,
+			cCtx.String("legacy-data-bucket-url"),
+			claimExp,
+			cCtx.StringSlice("legacy-dot-storage-bucket-prefix"),
+		)
+		if err != nil {
+			closer()
+			return nil, nil, fmt.Errorf("creating legacy CAR block index mapper: %w", err)
+		}
+		mappers = append(mappers, legacy.Named(carBlockIndexMapperName, mapper))
+	}
+
+	if len(mappers) == 0 {
+		return nil, closer, nil
+	}
+
+	// synthesized claims are identity CIDs, so they are never fetched from
+	// the legacy claims store
+	claimsStore := contentclaims.NewStoreFromDatastore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	claimsURL := fmt.Sprintf("%s/claim/%s", strings.TrimSuffix(publicURL, "/"), service.ClaimUrlPlaceholder)
+	return []construct.Option{construct.WithLegacyClaims(mappers, claimsStore, claimsURL)}, closer, nil
+}
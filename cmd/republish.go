@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/urfave/cli/v2"
+
+	"github.com/storacha/indexing-service/pkg/aws"
+)
+
+// republisher republishes previously published claims scoped to a space.
+type republisher interface {
+	Republish(ctx context.Context, claim ipld.Link, space did.DID) error
+}
+
+var republishCmd = &cli.Command{
+	Name:      "republish",
+	Usage:     "Republish index and equals claims with space aware context IDs",
+	ArgsUsage: "[file]",
+	Description: "Reads lines of \"<claim CID> <space DID>\" from the passed file, or stdin if no file is passed,\n" +
+		"and republishes each claim scoped to the space. Blank lines and lines starting with # are ignored.",
+	Action: func(cCtx *cli.Context) error {
+		in := io.Reader(os.Stdin)
+		if cCtx.Args().Present() {
+			f, err := os.Open(cCtx.Args().First())
+			if err != nil {
+				return fmt.Errorf("opening claims file: %w", err)
+			}
+			defer f.Close()
+			in = f
+		}
+
+		cfg := aws.FromEnv(cCtx.Context)
+		svc, err := aws.Construct(cfg, aws.ConstructProviderRegistry(cfg))
+		if err != nil {
+			return fmt.Errorf("constructing service: %w", err)
+		}
+		r, ok := svc.(republisher)
+		if !ok {
+			return fmt.Errorf("service does not support republishing")
+		}
+		return republish(cCtx.Context, r, in)
+	},
+}
+
+// republish republishes the claims listed in the input, continuing past
+// claims that fail so that a single bad line does not stop the migration.
+func republish(ctx context.Context, r republisher, in io.Reader) error {
+	var total, failed int
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		total++
+
+		claim, space, err := parseRepublishLine(text)
+		if err != nil {
+			log.Errorf("line %d: %s", line, err)
+			failed++
+			continue
+		}
+		if err := r.Republish(ctx, claim, space); err != nil {
+			log.Errorf("republishing claim %s for space %s: %s", claim, space, err)
+			failed++
+			continue
+		}
+		log.Infof("republished claim %s for space %s", claim, space)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading claims: %w", err)
+	}
+	log.Infof("republished %d of %d claims", total-failed, total)
+	if failed > 0 {
+		return fmt.Errorf("failed to republish %d of %d claims", failed, total)
+	}
+	return nil
+}
+
+func parseRepublishLine(text string) (ipld.Link, did.DID, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return nil, did.Undef, fmt.Errorf("expected \"<claim CID> <space DID>\", got %q", text)
+	}
+	c, err := cid.Parse(fields[0])
+	if err != nil {
+		return nil, did.Undef, fmt.Errorf("parsing claim CID: %w", err)
+	}
+	space, err := did.Parse(fields[1])
+	if err != nil {
+		return nil, did.Undef, fmt.Errorf("parsing space DID: %w", err)
+	}
+	return cidlink.Link{Cid: c}, space, nil
+}
@@ -9,8 +9,9 @@ import (
 	"io"
 	"net/url"
 	"os"
-	"time"
 
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipni/go-libipni/maurl"
 	"github.com/ipni/go-libipni/metadata"
@@ -29,8 +30,15 @@ import (
 
 	"github.com/storacha/indexing-service/pkg/construct"
 	"github.com/storacha/indexing-service/pkg/presets"
+	isresolver "github.com/storacha/indexing-service/pkg/principalresolver"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/redis"
 	"github.com/storacha/indexing-service/pkg/server"
+	"github.com/storacha/indexing-service/pkg/service"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
 )
 
 var serverCmd = &cli.Command{
@@ -85,6 +93,41 @@ var serverCmd = &cli.Command{
 					Value: `["https://cid2.contact"]`,
 					Usage: "JSON array of IPNI node URLs to use as fallback endpoints.",
 				},
+				&cli.BoolFlag{
+					Name:    "ipni-reader-privacy",
+					EnvVars: []string{"IPNI_READER_PRIVACY"},
+					Usage:   "Use double hashed lookups when querying IPNI so that queried multihashes are not revealed to IPNI nodes.",
+				},
+				&cli.BoolFlag{
+					Name:    "ipni-merge-results",
+					EnvVars: []string{"IPNI_MERGE_RESULTS"},
+					Usage:   "Query the main and fallback IPNI endpoints in parallel and merge their results.",
+				},
+				&cli.BoolFlag{
+					Name:    "ipni-streaming-find",
+					EnvVars: []string{"IPNI_STREAMING_FIND"},
+					Usage:   "Request streaming NDJSON find responses from IPNI, stopping early once enough matching results are found.",
+				},
+				&cli.IntFlag{
+					Name:    "ipni-stream-result-limit",
+					EnvVars: []string{"IPNI_STREAM_RESULT_LIMIT"},
+					Usage:   "Number of matching results per claim type after which a streaming IPNI find is stopped (used with --ipni-streaming-find).",
+				},
+				&cli.BoolFlag{
+					Name:    "strict-space-filtering",
+					EnvVars: []string{"STRICT_SPACE_FILTERING"},
+					Usage:   "Exclude index and equals claims published without a space from query results filtered by space.",
+				},
+				&cli.StringFlag{
+					Name:    "data-path",
+					EnvVars: []string{"DATA_PATH"},
+					Usage:   "Path to a directory to persist local data in. If not set, data is kept in memory.",
+				},
+				&cli.BoolFlag{
+					Name:    "durable-caching-queue",
+					EnvVars: []string{"DURABLE_CACHING_QUEUE"},
+					Usage:   "Store provider caching jobs in the local datastore so they survive restarts (requires --data-path to be durable).",
+				},
 				&cli.StringFlag{
 					Name:  "ipni-announce-urls",
 					Value: `["https://cid.contact/announce"]`,
@@ -108,11 +151,168 @@ var serverCmd = &cli.Command{
 					EnvVars: []string{"RESOLVE_DID_WEB"},
 					Usage:   "did:web DIDs to resolve via HTTP (fetches /.well-known/did.json). Can be specified multiple times or comma-separated in env var.",
 				},
+				&cli.StringSliceFlag{
+					Name:    "private-space",
+					EnvVars: []string{"PRIVATE_SPACES"},
+					Usage:   "DID of a private space, whose claims are only returned to queries carrying a valid delegation for the space. Can be specified multiple times or comma-separated in env var.",
+				},
+				&cli.BoolFlag{
+					Name:    "metrics",
+					EnvVars: []string{"METRICS_ENABLED"},
+					Usage:   "Expose Prometheus metrics on GET /metrics.",
+				},
+				&cli.StringFlag{
+					Name:    "access-log",
+					EnvVars: []string{"ACCESS_LOG"},
+					Usage:   "Write a JSON access log line per request to \"stdout\" or the given file path. Disabled if not set.",
+				},
+				&cli.Float64Flag{
+					Name:    "access-log-sample-rate",
+					EnvVars: []string{"ACCESS_LOG_SAMPLE_RATE"},
+					Value:   1,
+					Usage:   "Fraction of requests (0 to 1) written to the access log, used with --access-log",
+				},
+				&cli.BoolFlag{
+					Name:    "block-proxy",
+					EnvVars: []string{"BLOCK_PROXY"},
+					Usage:   "Fetch and verify blocks requested on GET /block/{cid} that are stored within larger resources, instead of redirecting to the resources holding them with the byte range in the X-Block-Range header.",
+				},
+				&cli.StringSliceFlag{
+					Name:    "block-proxy-allowed-host",
+					EnvVars: []string{"BLOCK_PROXY_ALLOWED_HOSTS"},
+					Usage:   "Host (or host:port) blocks may be fetched from with --block-proxy, in addition to the endpoints of the providers in --provider-registry. Can be specified multiple times or comma-separated in env var.",
+				},
+				&cli.StringFlag{
+					Name:    "claim-authorities",
+					EnvVars: []string{"CLAIM_AUTHORITIES"},
+					Usage:   "JSON object mapping abilities to the DIDs allowed to issue or delegate them, e.g. {\"assert/index\": [\"did:web:up.storacha.network\"]}. The service DID is always allowed. Abilities not listed may be self-issued by anyone.",
+				},
+				&cli.Float64Flag{
+					Name:    "publish-rate-limit",
+					EnvVars: []string{"PUBLISH_RATE_LIMIT"},
+					Usage:   "Claims per second each issuer DID may publish or cache. Unlimited if not set.",
+				},
+				&cli.IntFlag{
+					Name:    "publish-rate-limit-burst",
+					EnvVars: []string{"PUBLISH_RATE_LIMIT_BURST"},
+					Value:   10,
+					Usage:   "Maximum burst of publishes per issuer DID, used with --publish-rate-limit",
+				},
+				&cli.Float64Flag{
+					Name:    "query-rate-limit",
+					EnvVars: []string{"QUERY_RATE_LIMIT"},
+					Usage:   "Queries per second each client IP may make. Unlimited if not set.",
+				},
+				&cli.IntFlag{
+					Name:    "query-rate-limit-burst",
+					EnvVars: []string{"QUERY_RATE_LIMIT_BURST"},
+					Value:   100,
+					Usage:   "Maximum burst of queries per client IP, used with --query-rate-limit",
+				},
+				&cli.IntFlag{
+					Name:    "trusted-proxy-hops",
+					EnvVars: []string{"TRUSTED_PROXY_HOPS"},
+					Usage:   "Number of reverse proxies in front of the server that append to X-Forwarded-For. Queries are limited by the client IP they report.",
+				},
+				&cli.StringFlag{
+					Name:    "rate-limit-overrides",
+					EnvVars: []string{"RATE_LIMIT_OVERRIDES"},
+					Usage:   "JSON object mapping DIDs or IPs to limits, e.g. {\"did:key:...\": {\"rate\": 10, \"burst\": 100}}. A rate of 0 disables limits.",
+				},
+				&cli.StringFlag{
+					Name:    "provider-registry",
+					EnvVars: []string{"PROVIDER_REGISTRY"},
+					Usage:   "Path to a JSON file listing the storage providers allowed to cache claims, e.g. [{\"did\": \"did:key:...\", \"peerIds\": [\"12D3...\"], \"status\": \"active\"}]. Any provider may cache claims if not set.",
+				},
+				&cli.StringFlag{
+					Name:    "admin-token",
+					EnvVars: []string{"ADMIN_TOKEN"},
+					Usage:   "Bearer token for the storage provider registry admin API at /admin/providers, used with --provider-registry. Changes made through the API are not saved to the file.",
+				},
 				&cli.BoolFlag{
 					Name:    "insecure-did-resolution",
 					EnvVars: []string{"INSECURE_DID_RESOLUTION"},
 					Usage:   "Use HTTP instead of HTTPS for did:web resolution (for local development), used with --resolve-did-web",
 				},
+				&cli.DurationFlag{
+					Name:    "did-resolution-refresh-interval",
+					EnvVars: []string{"DID_RESOLUTION_REFRESH_INTERVAL"},
+					Value:   isresolver.DefaultRefreshInterval,
+					Usage:   "How long a did:web resolution is cached before the DID document is fetched again, used with --resolve-did-web",
+				},
+				&cli.DurationFlag{
+					Name:    "did-resolution-failure-ttl",
+					EnvVars: []string{"DID_RESOLUTION_FAILURE_TTL"},
+					Value:   isresolver.DefaultFailureTTL,
+					Usage:   "How long a failed did:web resolution is remembered before the DID document is fetched again, used with --resolve-did-web",
+				},
+				&cli.DurationFlag{
+					Name:    "did-resolution-max-stale-age",
+					EnvVars: []string{"DID_RESOLUTION_MAX_STALE_AGE"},
+					Value:   isresolver.DefaultMaxStaleAge,
+					Usage:   "How old a did:web resolution may be and still be used when fetching the DID document fails, used with --resolve-did-web",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-dsn",
+					EnvVars: []string{"LEGACY_SQL_DSN"},
+					Usage:   "Connection string of a SQL database holding legacy block index, allocations, store and blob registry tables to synthesize location claims from. Disabled if not set.",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-driver",
+					EnvVars: []string{"LEGACY_SQL_DRIVER"},
+					Value:   "pgx",
+					Usage:   "database/sql driver used with --legacy-sql-dsn. The PostgreSQL \"pgx\" driver is built in.",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-block-index-table",
+					EnvVars: []string{"LEGACY_SQL_BLOCK_INDEX_TABLE"},
+					Value:   "blocks_cars_position",
+					Usage:   "Name of the legacy block index table, used with --legacy-sql-dsn",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-allocations-table",
+					EnvVars: []string{"LEGACY_SQL_ALLOCATIONS_TABLE"},
+					Value:   "allocations",
+					Usage:   "Name of the legacy allocations table, used with --legacy-sql-dsn",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-store-table",
+					EnvVars: []string{"LEGACY_SQL_STORE_TABLE"},
+					Value:   "store",
+					Usage:   "Name of the legacy store table, used with --legacy-sql-dsn",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-sql-blob-registry-table",
+					EnvVars: []string{"LEGACY_SQL_BLOB_REGISTRY_TABLE"},
+					Value:   "blob_registry",
+					Usage:   "Name of the legacy blob registry table, used with --legacy-sql-dsn",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-data-bucket-url",
+					EnvVars: []string{"LEGACY_DATA_BUCKET_URL"},
+					Usage:   "URL of the bucket serving legacy shards referenced by region/bucket/key CAR paths in the legacy block index table, used with --legacy-sql-dsn and CSV files in --legacy-car-dir",
+				},
+				&cli.StringSliceFlag{
+					Name:    "legacy-dot-storage-bucket-prefix",
+					EnvVars: []string{"LEGACY_DOT_STORAGE_BUCKET_PREFIXES"},
+					Usage:   "region/bucket prefix of a legacy .storage bucket, whose shards are only used once migrated, e.g. us-west-2/dotstorage-prod-1. Can be specified multiple times or comma-separated in env var.",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-car-dir",
+					EnvVars: []string{"LEGACY_CAR_DIR"},
+					Usage:   "Directory of CARv2 files with embedded indexes and CSV exports of the legacy block index table to synthesize location claims from. Disabled if not set.",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-car-url",
+					EnvVars: []string{"LEGACY_CAR_URL"},
+					Usage:   "Base URL the CAR files in --legacy-car-dir are served from, under the same relative paths. Required if the directory holds CAR files.",
+				},
+				&cli.StringFlag{
+					Name:    "legacy-car-index-db",
+					EnvVars: []string{"LEGACY_CAR_INDEX_DB"},
+					Value:   "legacy-car-index.db",
+					Usage:   "Path of the SQLite database the files in --legacy-car-dir are indexed in. Kept between runs, so that only new and changed files are indexed again.",
+				},
 			},
 			Action: func(cCtx *cli.Context) error {
 				if cCtx.IsSet("private-key") && cCtx.IsSet("key-file") {
@@ -180,10 +380,12 @@ var serverCmd = &cli.Command{
 					if err != nil {
 						return fmt.Errorf("creating HTTP principal resolver: %w", err)
 					}
-					presolv, err = principalresolver.NewCachedResolver(httpResolver, 24*time.Hour)
-					if err != nil {
-						return fmt.Errorf("creating cached resolver: %w", err)
-					}
+					presolv = isresolver.NewRefreshingResolver(
+						httpResolver,
+						isresolver.WithRefreshInterval(cCtx.Duration("did-resolution-refresh-interval")),
+						isresolver.WithFailureTTL(cCtx.Duration("did-resolution-failure-ttl")),
+						isresolver.WithMaxStaleAge(cCtx.Duration("did-resolution-max-stale-age")),
+					)
 				} else {
 					// Fall back to static mapping from presets
 					staticResolver, err := principalresolver.NewMapResolver(presets.PrincipalMapping)
@@ -192,21 +394,52 @@ var serverCmd = &cli.Command{
 					}
 					presolv = staticResolver
 				}
-				opts = append(
-					opts,
-					server.WithContentClaimsOptions(
-						userver.WithPrincipalResolver(presolv.ResolveDIDKey),
-					),
-				)
+				claimsOpts := []userver.Option{userver.WithPrincipalResolver(presolv.ResolveDIDKey)}
+				if cCtx.String("claim-authorities") != "" {
+					authorities, err := contentclaims.ParseAuthorities([]byte(cCtx.String("claim-authorities")), id.DID())
+					if err != nil {
+						return fmt.Errorf("parsing claim authorities: %w", err)
+					}
+					claimsOpts = append(claimsOpts, userver.WithCanIssue(contentclaims.NewAuthorityPolicy(authorities).CanIssue))
+				}
+				opts = append(opts, server.WithContentClaimsOptions(claimsOpts...))
 
 				ipniSrvOpts, err := ipniOpts(cCtx.String("ipni-format-peer-id"), cCtx.String("ipni-format-endpoint"))
 				if err != nil {
 					return fmt.Errorf("setting up IPNI options: %w", err)
 				}
 				opts = append(opts, ipniSrvOpts...)
+
+				if cCtx.Bool("metrics") {
+					metricsHandler, metricsShutdown, err := telemetry.SetupMetrics()
+					if err != nil {
+						return fmt.Errorf("setting up metrics: %w", err)
+					}
+					defer metricsShutdown(cCtx.Context)
+					opts = append(opts, server.WithMetrics(metricsHandler))
+				}
+
+				if dest := cCtx.String("access-log"); dest != "" {
+					out := os.Stdout
+					if dest != "stdout" {
+						out, err = os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+						if err != nil {
+							return fmt.Errorf("opening access log: %w", err)
+						}
+						defer out.Close()
+					}
+					opts = append(opts, server.WithAccessLog(server.NewAccessLogger(out, cCtx.Float64("access-log-sample-rate"))))
+				}
+
 				var sc construct.ServiceConfig
 				sc.ID = id
 				sc.IPNIFindURL = cCtx.String("ipni-endpoint")
+				sc.IPNIReaderPrivacy = cCtx.Bool("ipni-reader-privacy")
+				sc.IPNIFindMergeResults = cCtx.Bool("ipni-merge-results")
+				sc.IPNIStreamingFind = cCtx.Bool("ipni-streaming-find")
+				sc.IPNIStreamResultLimit = cCtx.Int("ipni-stream-result-limit")
+				sc.StrictSpaceFiltering = cCtx.Bool("strict-space-filtering")
+				sc.DurableCachingQueue = cCtx.Bool("durable-caching-queue")
 				sc.PublicURL = cCtx.StringSlice("public-url")
 
 				// Create standalone Redis client for local development
@@ -217,6 +450,32 @@ var serverCmd = &cli.Command{
 				redisClient := goredis.NewClient(redisOpts)
 				clientAdapter := redis.NewClientAdapter(redisClient)
 
+				var rateLimitOverrides map[string]types.RateLimit
+				if cCtx.String("rate-limit-overrides") != "" {
+					err := json.Unmarshal([]byte(cCtx.String("rate-limit-overrides")), &rateLimitOverrides)
+					if err != nil {
+						return fmt.Errorf("parsing rate limit overrides JSON: %w", err)
+					}
+				}
+				rateLimitStore := redis.NewRateLimitStore(redisClient)
+				if rate := cCtx.Float64("publish-rate-limit"); rate > 0 {
+					limit := types.RateLimit{Rate: rate, Burst: cCtx.Int("publish-rate-limit-burst")}
+					limiter, err := ratelimit.NewLimiter("publish", rateLimitStore, limit, ratelimit.WithOverrides(rateLimitOverrides))
+					if err != nil {
+						return fmt.Errorf("creating publish rate limiter: %w", err)
+					}
+					opts = append(opts, server.WithPublishRateLimit(limiter))
+				}
+				if rate := cCtx.Float64("query-rate-limit"); rate > 0 {
+					limit := types.RateLimit{Rate: rate, Burst: cCtx.Int("query-rate-limit-burst")}
+					limiter, err := ratelimit.NewLimiter("query", rateLimitStore, limit, ratelimit.WithOverrides(rateLimitOverrides))
+					if err != nil {
+						return fmt.Errorf("creating query rate limiter: %w", err)
+					}
+					opts = append(opts, server.WithQueryRateLimit(limiter))
+				}
+				opts = append(opts, server.WithTrustedProxies(cCtx.Int("trusted-proxy-hops")))
+
 				if cCtx.String("ipni-fallback-endpoints") != "" {
 					var urls []string
 					err := json.Unmarshal([]byte(cCtx.String("ipni-fallback-endpoints")), &urls)
@@ -243,13 +502,65 @@ var serverCmd = &cli.Command{
 				}
 				sc.PrivateKey = privKey
 
-				logging.SetAllLoggers(logging.LevelInfo)
-				indexer, err := construct.Construct(sc,
+				publicAddrInfo, err := construct.PublicAddrInfo(sc)
+				if err != nil {
+					return fmt.Errorf("building public address info: %w", err)
+				}
+				opts = append(opts, server.WithIPNIFind(publicAddrInfo))
+
+				constructOpts := []construct.Option{
 					construct.WithProvidersClient(clientAdapter),
 					construct.WithNoProvidersClient(redisClient),
 					construct.WithClaimsClient(redisClient),
 					construct.WithIndexesClient(redisClient),
-				)
+					construct.WithServiceOptions(service.WithPrincipalResolver(presolv)),
+				}
+				if cCtx.String("data-path") != "" {
+					constructOpts = append(constructOpts, construct.WithDataPath(cCtx.String("data-path")))
+				}
+				if cCtx.IsSet("private-space") {
+					var privateSpaces []did.DID
+					for _, s := range cCtx.StringSlice("private-space") {
+						space, err := did.Parse(s)
+						if err != nil {
+							return fmt.Errorf("parsing private space DID %s: %w", s, err)
+						}
+						privateSpaces = append(privateSpaces, space)
+					}
+					constructOpts = append(constructOpts, construct.WithServiceOptions(service.WithPrivateSpaces(privateSpaces, presolv)))
+				}
+
+				var registry types.ProviderRegistry
+				if path := cCtx.String("provider-registry"); path != "" {
+					registry = providerregistry.NewDatastoreRegistry(dssync.MutexWrap(datastore.NewMapDatastore()))
+					if err := providerregistry.LoadFile(cCtx.Context, path, registry); err != nil {
+						return fmt.Errorf("loading provider registry: %w", err)
+					}
+					constructOpts = append(constructOpts, construct.WithServiceOptions(service.WithProviderRegistry(registry)))
+					opts = append(opts, server.WithProviderRegistry(registry))
+					if token := cCtx.String("admin-token"); token != "" {
+						opts = append(opts, server.WithProviderAdmin(registry, token))
+					}
+				}
+
+				if cCtx.Bool("block-proxy") {
+					proxy := server.NewBlockProxy(construct.DefaultHTTPClient(), registry, cCtx.StringSlice("block-proxy-allowed-host"))
+					opts = append(opts, server.WithBlockProxy(proxy))
+				}
+
+				publicURL := fmt.Sprintf("http://localhost:%d", cCtx.Int("port"))
+				if len(sc.PublicURL) > 0 {
+					publicURL = sc.PublicURL[0]
+				}
+				legacyOpts, closeLegacy, err := legacySourceOpts(cCtx, id, publicURL)
+				if err != nil {
+					return fmt.Errorf("setting up legacy sources: %w", err)
+				}
+				defer closeLegacy()
+				constructOpts = append(constructOpts, legacyOpts...)
+
+				logging.SetAllLoggers(logging.LevelInfo)
+				indexer, err := construct.Construct(sc, constructOpts...)
 				if err != nil {
 					return err
 				}
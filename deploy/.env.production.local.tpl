@@ -77,6 +77,12 @@ CLAIMS_CACHE_EXPIRATION_SECONDS=<%= $CLAIMS_CACHE_EXPIRATION_SECONDS %>
 
 IPNI_ENDPOINT=<%= ${IPNI_ENDPOINT:-""} %>
 IPNI_FALLBACK_ENDPOINTS=<%= ${IPNI_FALLBACK_ENDPOINTS:-""} %>
+IPNI_READER_PRIVACY=<%= ${IPNI_READER_PRIVACY:-""} %>
+IPNI_MERGE_RESULTS=<%= ${IPNI_MERGE_RESULTS:-""} %>
+IPNI_STREAMING_FIND=<%= ${IPNI_STREAMING_FIND:-""} %>
+IPNI_STREAM_RESULT_LIMIT=<%= ${IPNI_STREAM_RESULT_LIMIT:-""} %>
+STRICT_SPACE_FILTERING=<%= ${STRICT_SPACE_FILTERING:-""} %>
+PRIVATE_SPACES=<%= ${PRIVATE_SPACES:-""} %>
 IPNI_ANNOUNCE_URLS=<%= ${IPNI_ANNOUNCE_URLS:-""} %>
 IPNI_FORMAT_PEER_ID=<%= ${IPNI_FORMAT_PEER_ID:-""} %>
 IPNI_FORMAT_ENDPOINT=<%= ${IPNI_FORMAT_ENDPOINT:-""} %>
@@ -94,10 +100,29 @@ LEGACY_STORE_TABLE_NAME=<%= $LEGACY_STORE_TABLE_NAME %>
 LEGACY_STORE_TABLE_REGION=<%= $LEGACY_STORE_TABLE_REGION %>
 LEGACY_BLOB_REGISTRY_TABLE_NAME=<%= $LEGACY_BLOB_REGISTRY_TABLE_NAME %>
 LEGACY_BLOB_REGISTRY_TABLE_REGION=<%= $LEGACY_BLOB_REGISTRY_TABLE_REGION %>
+LEGACY_MIGRATION_TABLE_NAME=<%= ${LEGACY_MIGRATION_TABLE_NAME:-""} %>
+LEGACY_MERGE_STRATEGY=<%= ${LEGACY_MERGE_STRATEGY:-""} %>
+LEGACY_MAPPER_TIMEOUT=<%= ${LEGACY_MAPPER_TIMEOUT:-""} %>
+LEGACY_INDEX_BUCKET_URL=<%= ${LEGACY_INDEX_BUCKET_URL:-""} %>
+LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME=<%= ${LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME:-""} %>
 
 GOLOG_LOG_LEVEL=<%= $GOLOG_LOG_LEVEL %>
 
 TELEMETRY_DISABLED=<%= ${TELEMETRY_DISABLED:-""} %>
+METRICS_ENABLED=<%= ${METRICS_ENABLED:-""} %>
+ACCESS_LOG_ENABLED=<%= ${ACCESS_LOG_ENABLED:-""} %>
+ACCESS_LOG_SAMPLE_RATE=<%= ${ACCESS_LOG_SAMPLE_RATE:-""} %>
+BLOCK_PROXY_ENABLED=<%= ${BLOCK_PROXY_ENABLED:-""} %>
+BLOCK_PROXY_ALLOWED_HOSTS=<%= ${BLOCK_PROXY_ALLOWED_HOSTS:-""} %>
+PUBLISH_RATE_LIMIT=<%= ${PUBLISH_RATE_LIMIT:-""} %>
+PUBLISH_RATE_LIMIT_BURST=<%= ${PUBLISH_RATE_LIMIT_BURST:-""} %>
+QUERY_RATE_LIMIT=<%= ${QUERY_RATE_LIMIT:-""} %>
+QUERY_RATE_LIMIT_BURST=<%= ${QUERY_RATE_LIMIT_BURST:-""} %>
+TRUSTED_PROXY_HOPS=<%= ${TRUSTED_PROXY_HOPS:-""} %>
+RATE_LIMIT_OVERRIDES=<%= ${RATE_LIMIT_OVERRIDES:-""} %>
+CLAIM_AUTHORITIES=<%= ${CLAIM_AUTHORITIES:-""} %>
+PROVIDER_REGISTRY_TABLE_NAME=<%= ${PROVIDER_REGISTRY_TABLE_NAME:-""} %>
+ADMIN_TOKEN=<%= ${ADMIN_TOKEN:-""} %>
 OTEL_SERVICE_NAME=<%= ${OTEL_SERVICE_NAME:-""} %>
 OTEL_EXPORTER_OTLP_ENDPOINT=<%= ${OTEL_EXPORTER_OTLP_ENDPOINT:-""} %>
 OTEL_EXPORTER_OTLP_HEADERS=<%= ${OTEL_EXPORTER_OTLP_HEADERS:-""} %>
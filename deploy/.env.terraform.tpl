@@ -10,11 +10,36 @@ TF_VAR_region=us-west-2
 TF_VAR_cloudflare_zone_id=37783d6f032b78cd97ce37ab6fd42848
 CLOUDFLARE_API_TOKEN= # enter a cloudflare api token
 TELEMETRY_DISABLED= # optional - set to any value to disable telemetry
+METRICS_ENABLED= # optional - set to true to expose Prometheus metrics on GET /metrics
+ACCESS_LOG_ENABLED= # optional - set to true to write a JSON access log line per request to stdout
+ACCESS_LOG_SAMPLE_RATE= # optional - fraction of requests (0 to 1) written to the access log, defaults to 1
+BLOCK_PROXY_ENABLED= # optional - set to true to fetch and verify blocks requested on GET /block/{cid} that are stored within larger resources, instead of redirecting to them with the byte range in the X-Block-Range header
+BLOCK_PROXY_ALLOWED_HOSTS= # optional - JSON array of hosts (or host:port) the block proxy may fetch blocks from, in addition to the endpoints of registered storage providers
+PUBLISH_RATE_LIMIT= # optional - claims per second each issuer DID may publish or cache, unlimited if not set
+PUBLISH_RATE_LIMIT_BURST= # required if PUBLISH_RATE_LIMIT is set - maximum burst of publishes per issuer DID
+QUERY_RATE_LIMIT= # optional - queries per second each client IP may make, unlimited if not set
+QUERY_RATE_LIMIT_BURST= # required if QUERY_RATE_LIMIT is set - maximum burst of queries per client IP
+TRUSTED_PROXY_HOPS= # optional - number of reverse proxies (e.g. load balancers) appending to X-Forwarded-For in front of the service, used to find the client IP
+RATE_LIMIT_OVERRIDES= # optional - JSON object mapping DIDs or IPs to limits, e.g. {"did:key:...": {"rate": 10, "burst": 100}}; a rate of 0 disables limits
+CLAIM_AUTHORITIES= # optional - JSON object mapping abilities to DIDs allowed to issue or delegate them, e.g. {"assert/index": ["did:web:up.storacha.network"]}
+PROVIDER_REGISTRY_TABLE_NAME= # optional - DynamoDB table (keyed by "provider" DID) of storage providers allowed to cache claims; any provider may cache claims if not set
+ADMIN_TOKEN= # optional - bearer token for the storage provider registry admin API at /admin/providers
+LEGACY_MIGRATION_TABLE_NAME= # optional - DynamoDB table (keyed by "mapper") recording the progress of `indexing-service aws migrate-legacy`, which republishes legacy claims to IPNI
+LEGACY_MERGE_STRATEGY= # optional - how results from legacy claims mappers are combined ['first-hit' | 'union' | 'union-per-claim-type'], defaults to first-hit
+LEGACY_MAPPER_TIMEOUT= # optional - time each legacy claims mapper is given to respond (e.g. 2s), no timeout if not set
+LEGACY_INDEX_BUCKET_URL= # optional - public URL of the claim store bucket; if set, indexes are synthesized for DAGs rooted in legacy CARs and stored in that bucket. Requires LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME
+LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME= # optional - global secondary index of the legacy block index table keyed by carpath, used to list the blocks of legacy CARs when synthesizing indexes
 HONEYCOMB_API_KEY= # optional - if you want telemetry data sent to Honeycomb, set this to your Honeycomb API key
 SENTRY_DSN= # optional - Sentry DSN for error reporting. Obtain from sentry.io. Leave blank to disable error reporting.
 SENTRY_ENVIRONMENT= # optional - Sentry environment to use for error reporting. Defaults to the terraform workspace being used if not set.
 IPNI_ENDPOINT= # optional - if you want to find data on a custom IPNI node, defaults to https://cid.contact
 IPNI_FALLBACK_ENDPOINTS= # optional - set to a JSON array of IPNI endpoints to use as fallback nodes for provider discovery (e.g. ["https://cid2.contact"])
+IPNI_READER_PRIVACY= # optional - set to true to use double hashed IPNI lookups so queried multihashes are not revealed to IPNI nodes
+IPNI_MERGE_RESULTS= # optional - set to true to query the main and fallback IPNI endpoints in parallel and merge their results
+IPNI_STREAMING_FIND= # optional - set to true to request streaming NDJSON find responses from IPNI, stopping early once enough matching results are found
+IPNI_STREAM_RESULT_LIMIT= # optional - number of matching results per claim type after which a streaming IPNI find is stopped, defaults to 100
+STRICT_SPACE_FILTERING= # optional - set to true to exclude index and equals claims published without a space from results filtered by space (enable once old claims have been republished with `indexing-service aws republish`)
+PRIVATE_SPACES= # optional - JSON array of space DIDs whose claims are only returned to queries carrying a valid space/content/retrieve delegation for the space
 IPNI_ANNOUNCE_URLS= # optional - JSON array of IPNI announce URLs, defaults to ["https://cid.contact/announce"]
 IPNI_FORMAT_PEER_ID= # optional - When set along with IPNI_FORMAT_ENDPOINT, enables mimicking IPNI on /cid/<cid> requests
 IPNI_FORMAT_ENDPOINT= # optional - When set along with IPNI_FORMAT_PEER_ID, enables mimicking IPNI on /cid/<cid> requests
@@ -20,26 +20,13 @@ import (
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/principal"
 	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/types"
 )
 
-type BlockIndexStore interface {
-	Query(ctx context.Context, digest multihash.Multihash) ([]BlockIndexRecord, error)
-}
-
-type MigratedShardChecker interface {
-	ShardMigrated(ctx context.Context, shard ipld.Link) (bool, error)
-}
-
-type BlockIndexRecord struct {
-	CarPath string
-	Offset  uint64
-	Length  uint64
-}
-
 type blockIndexTableMapper struct {
 	id                   principal.Signer
-	blockIndexStore      BlockIndexStore
-	migratedShardChecker MigratedShardChecker
+	blockIndexStore      types.BlockIndexStore
+	migratedShardChecker types.MigratedShardChecker
 	bucketURL            url.URL
 	claimExp             time.Duration
 	bucketPrefixes       []string // e.g. "us-west-2/dotstorage-prod-1"
@@ -54,8 +41,9 @@ var _ legacy.ContentToClaimsMapper = blockIndexTableMapper{}
 // instance does the work of mapping old bucket keys to URLs, where the base URL is the passed bucketURL param.
 //
 // Using the data in the blockIndexStore, the service will materialize content claims using the id param as the
-// signing key. Claims will be set to expire in the amount of time given by the claimExpiration parameter.
-func NewBlockIndexTableMapper(id principal.Signer, blockIndexStore BlockIndexStore, migratedShardChecker MigratedShardChecker, bucketURL string, claimExpiration time.Duration, bucketPrefixes []string) (blockIndexTableMapper, error) {
+// signing key. Claims will be set to expire in the amount of time given by the claimExpiration parameter, or never
+// expire if it is zero, as is needed when they are migrated to IPNI.
+func NewBlockIndexTableMapper(id principal.Signer, blockIndexStore types.BlockIndexStore, migratedShardChecker types.MigratedShardChecker, bucketURL string, claimExpiration time.Duration, bucketPrefixes []string) (blockIndexTableMapper, error) {
 	burl, err := url.Parse(bucketURL)
 	if err != nil {
 		return blockIndexTableMapper{}, fmt.Errorf("parsing bucket URL: %w", err)
@@ -74,7 +62,36 @@ func NewBlockIndexTableMapper(id principal.Signer, blockIndexStore BlockIndexSto
 // GetClaims implements providerindex.ContentToClaimsMapper.
 // Although it returns a list of CIDs, they are identity CIDs, so they contain the actual claims the refer to.
 func (bit blockIndexTableMapper) GetClaims(ctx context.Context, contentHash multihash.Multihash) ([]cid.Cid, error) {
-	var locs []cassert.LocationCaveats
+	locs, err := bit.locations(ctx, contentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	claimCids := make([]cid.Cid, 0, len(locs))
+	for _, loc := range locs {
+		c, err := bit.locationClaim(loc.caveats)
+		if err != nil {
+			continue
+		}
+		claimCids = append(claimCids, c)
+	}
+
+	return claimCids, nil
+}
+
+// blockLocation is a block index record resolved to the location the block
+// can be retrieved from.
+type blockLocation struct {
+	record types.BlockIndexRecord
+	// shard is the CAR holding the block, or nil if its path does not name it.
+	shard   ipld.Link
+	caveats cassert.LocationCaveats
+}
+
+// locations resolves the records in the block index store to the locations
+// the content can be retrieved from, skipping shards that are not available.
+func (bit blockIndexTableMapper) locations(ctx context.Context, contentHash multihash.Multihash) ([]blockLocation, error) {
+	var locs []blockLocation
 
 	// lets see if we can materialize some location claims
 	content := ctypes.FromHash(contentHash)
@@ -84,13 +101,14 @@ func (bit blockIndexTableMapper) GetClaims(ctx context.Context, contentHash mult
 	}
 
 	for _, r := range records {
+		var shard ipld.Link
 		u, err := url.Parse(r.CarPath)
 		if err != nil || !u.IsAbs() {
 			// non-URL is legacy region/bucket/key format
 			// e.g. us-west-2/dotstorage-prod-1/raw/bafy...
 			parts := strings.Split(r.CarPath, "/")
 			key := strings.Join(parts[2:], "/")
-			shard, err := bucketKeyToShardLink(key)
+			shard, err = bucketKeyToShardLink(key)
 			if err != nil {
 				continue
 			}
@@ -107,52 +125,60 @@ func (bit blockIndexTableMapper) GetClaims(ctx context.Context, contentHash mult
 				}
 			}
 			u = bit.bucketURL.JoinPath(fmt.Sprintf("/%s/%s.car", shard.String(), shard.String()))
-			locs = append(locs, cassert.LocationCaveats{
-				Content:  content,
-				Location: []url.URL{*u},
-				Range:    &cassert.Range{Offset: r.Offset, Length: &r.Length},
-			})
-		} else {
-			locs = append(locs, cassert.LocationCaveats{
+		} else if s, err := bucketKeyToShardLink(u.Path); err == nil {
+			shard = s
+		}
+		locs = append(locs, blockLocation{
+			record: r,
+			shard:  shard,
+			caveats: cassert.LocationCaveats{
 				Content:  content,
 				Location: []url.URL{*u},
 				Range:    &cassert.Range{Offset: r.Offset, Length: &r.Length},
-			})
-		}
+			},
+		})
 	}
 
-	claimCids := make([]cid.Cid, 0, len(locs))
-	for _, loc := range locs {
-		claim, err := cassert.Location.Delegate(
-			bit.id,
-			bit.id,
-			bit.id.DID().String(),
-			loc,
-			delegation.WithExpiration(int(time.Now().Add(bit.claimExp).Unix())),
-		)
-		if err != nil {
-			continue
-		}
+	return locs, nil
+}
 
-		claimData, err := io.ReadAll(claim.Archive())
-		if err != nil {
-			continue
-		}
+// delegationOptions returns the options used to sign synthesized claims.
+func (bit blockIndexTableMapper) delegationOptions() []delegation.Option {
+	if bit.claimExp > 0 {
+		return []delegation.Option{delegation.WithExpiration(int(time.Now().Add(bit.claimExp).Unix()))}
+	}
+	return []delegation.Option{delegation.WithNoExpiration()}
+}
 
-		c, err := cid.Prefix{
-			Version:  1,
-			Codec:    uint64(multicodec.Car),
-			MhType:   multihash.IDENTITY,
-			MhLength: len(claimData),
-		}.Sum(claimData)
-		if err != nil {
-			continue
-		}
+// locationClaim signs a location claim and returns its identity CID.
+func (bit blockIndexTableMapper) locationClaim(loc cassert.LocationCaveats) (cid.Cid, error) {
+	claim, err := cassert.Location.Delegate(
+		bit.id,
+		bit.id,
+		bit.id.DID().String(),
+		loc,
+		bit.delegationOptions()...,
+	)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return toIdentityCID(claim)
+}
 
-		claimCids = append(claimCids, c)
+// toIdentityCID returns an identity CID embedding the archived claim, so that
+// it never has to be fetched from a claims store.
+func toIdentityCID(claim delegation.Delegation) (cid.Cid, error) {
+	claimData, err := io.ReadAll(claim.Archive())
+	if err != nil {
+		return cid.Undef, err
 	}
 
-	return claimCids, nil
+	return cid.Prefix{
+		Version:  1,
+		Codec:    uint64(multicodec.Car),
+		MhType:   multihash.IDENTITY,
+		MhLength: len(claimData),
+	}.Sum(claimData)
 }
 
 func isLegacyStorage(parts []string, bucketPrefixes []string) bool {
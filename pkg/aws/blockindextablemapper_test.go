@@ -31,7 +31,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 	fixtures := []struct {
 		name                string
 		digest              multihash.Multihash
-		record              BlockIndexRecord
+		record              types.BlockIndexRecord
 		migratedShardCheck  ipld.Link
 		migratedShardResult result.Result[bool, error]
 		// the expected location URL in the materlized claim
@@ -42,7 +42,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 multihash key, non-storage bucket",
 			digest: testutil.Must(digestutil.Parse("zQmNUfyG3ynAkCzPFLsijsJwEFpPXqJZF1CJpT9GLYmgBBd"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/nftstorage-prod-1/raw/bafyreifvbqc4e5qphijgpj43qxk5ndw2vbfbhkzuuuvpo4cturr2dfk45e/315318734258474846/ciqd7nsjnsrsi6pqulv5j46qel7gw6oeo644o5ef3zopne37xad5oui.car",
 				Offset:  128844,
 				Length:  200,
@@ -52,7 +52,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 multihash key, dot storage bucket, not migrated, nft prefix",
 			digest: testutil.Must(digestutil.Parse("zQmNUfyG3ynAkCzPFLsijsJwEFpPXqJZF1CJpT9GLYmgBBd"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/dotstorage-prod-1/raw/bafyreifvbqc4e5qphijgpj43qxk5ndw2vbfbhkzuuuvpo4cturr2dfk45e/nft-315318734258474846/ciqd7nsjnsrsi6pqulv5j46qel7gw6oeo644o5ef3zopne37xad5oui.car",
 				Offset:  128844,
 				Length:  200,
@@ -65,7 +65,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 multihash key, dot storage, migrated",
 			digest: testutil.Must(digestutil.Parse("zQmNUfyG3ynAkCzPFLsijsJwEFpPXqJZF1CJpT9GLYmgBBd"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/dotstorage-prod-1/raw/bafyreifvbqc4e5qphijgpj43qxk5ndw2vbfbhkzuuuvpo4cturr2dfk45e/315318734258474846/ciqd7nsjnsrsi6pqulv5j46qel7gw6oeo644o5ef3zopne37xad5oui.car",
 				Offset:  128844,
 				Length:  200,
@@ -77,7 +77,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 multihash key, dot storage, not migrated",
 			digest: testutil.Must(digestutil.Parse("zQmNUfyG3ynAkCzPFLsijsJwEFpPXqJZF1CJpT9GLYmgBBd"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/dotstorage-prod-1/raw/bafyreifvbqc4e5qphijgpj43qxk5ndw2vbfbhkzuuuvpo4cturr2dfk45e/315318734258474846/ciqd7nsjnsrsi6pqulv5j46qel7gw6oeo644o5ef3zopne37xad5oui.car",
 				Offset:  128844,
 				Length:  200,
@@ -90,7 +90,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 multihash key, dot storage, not migrated",
 			digest: testutil.Must(digestutil.Parse("zQmNUfyG3ynAkCzPFLsijsJwEFpPXqJZF1CJpT9GLYmgBBd"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-east-2/dotstorage-prod-0/raw/bafyreifvbqc4e5qphijgpj43qxk5ndw2vbfbhkzuuuvpo4cturr2dfk45e/315318734258474846/ciqd7nsjnsrsi6pqulv5j46qel7gw6oeo644o5ef3zopne37xad5oui.car",
 				Offset:  128844,
 				Length:  200,
@@ -103,7 +103,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 CAR CID key",
 			digest: testutil.Must(digestutil.Parse("zQmNVL7AESETquhed2Sv7VRq8ujqiL8NiPVmTBMCoKioZXh"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/carpark-prod-0/bagbaieras4pzdxrc6rxlqfu73a4g4zmbtn54e77gwxyq4lvwqouwkknndquq/bagbaieras4pzdxrc6rxlqfu73a4g4zmbtn54e77gwxyq4lvwqouwkknndquq.car",
 				Offset:  9196818,
 				Length:  262144,
@@ -113,7 +113,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b32 root CID key",
 			digest: testutil.Must(digestutil.Parse("zQmPc8FCfDtjgC5xB2EXArnuYs2d53vT5kbH7HJejkYwCz4"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "us-west-2/dotstorage-prod-1/complete/bafybeihya44jmfali7ret42wvhasnkacg6s5pfuxt4ydszdyp5ib4knzjm.car",
 				Offset:  8029928,
 				Length:  58,
@@ -124,7 +124,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		{
 			name:   "b58 multihash URL",
 			digest: testutil.Must(digestutil.Parse("zQmaRyqqRHaGmqdRBAWTsbC1cezEgtbCmVftcNVyXFcJ4n6"))(t),
-			record: BlockIndexRecord{
+			record: types.BlockIndexRecord{
 				CarPath: "https://carpark-prod-0.r2.w3s.link/zQmRYBmBVN28FpKprXj8FiRxE8KLSkQ96gNsBu8LtnK7sEe/zQmRYBmBVN28FpKprXj8FiRxE8KLSkQ96gNsBu8LtnK7sEe.blob",
 				Offset:  5401120,
 				Length:  36876,
@@ -136,7 +136,7 @@ func TestBlockIndexTableMapper(t *testing.T) {
 	for _, f := range fixtures {
 		t.Run(f.name, func(t *testing.T) {
 			mockStore := newMockBlockIndexStore()
-			mockStore.data.Set(f.digest, []BlockIndexRecord{f.record})
+			mockStore.data.Set(f.digest, []types.BlockIndexRecord{f.record})
 			mockMigratedShardChecker := newMockMigratedShardChecker()
 			if f.migratedShardCheck != nil {
 				mockMigratedShardChecker.data[f.migratedShardCheck] = f.migratedShardResult
@@ -183,13 +183,31 @@ func TestBlockIndexTableMapper(t *testing.T) {
 		_, err = bitMapper.GetClaims(context.Background(), testutil.RandomMultihash(t))
 		require.ErrorIs(t, err, types.ErrKeyNotFound)
 	})
+
+	t.Run("claims do not expire when expiration is zero", func(t *testing.T) {
+		f := fixtures[0]
+		mockStore := newMockBlockIndexStore()
+		mockStore.data.Set(f.digest, []types.BlockIndexRecord{f.record})
+		bitMapper, err := NewBlockIndexTableMapper(id, mockStore, newMockMigratedShardChecker(), bucketURL.String(), 0, dotStorageBuckets)
+		require.NoError(t, err)
+
+		claimCids, err := bitMapper.GetClaims(context.Background(), f.digest)
+		require.NoError(t, err)
+		require.Len(t, claimCids, 1)
+
+		dh, err := multihash.Decode(claimCids[0].Hash())
+		require.NoError(t, err)
+		claim, err := delegation.Extract(dh.Digest)
+		require.NoError(t, err)
+		require.Nil(t, claim.Expiration())
+	})
 }
 
 type mockBlockIndexStore struct {
-	data bytemap.ByteMap[multihash.Multihash, []BlockIndexRecord]
+	data bytemap.ByteMap[multihash.Multihash, []types.BlockIndexRecord]
 }
 
-func (bs *mockBlockIndexStore) Query(ctx context.Context, digest multihash.Multihash) ([]BlockIndexRecord, error) {
+func (bs *mockBlockIndexStore) Query(ctx context.Context, digest multihash.Multihash) ([]types.BlockIndexRecord, error) {
 	records := bs.data.Get(digest)
 	if len(records) == 0 {
 		return nil, types.ErrKeyNotFound
@@ -199,7 +217,7 @@ func (bs *mockBlockIndexStore) Query(ctx context.Context, digest multihash.Multi
 
 func newMockBlockIndexStore() *mockBlockIndexStore {
 	return &mockBlockIndexStore{
-		data: bytemap.NewByteMap[multihash.Multihash, []BlockIndexRecord](1),
+		data: bytemap.NewByteMap[multihash.Multihash, []types.BlockIndexRecord](1),
 	}
 }
 
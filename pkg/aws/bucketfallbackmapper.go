@@ -22,15 +22,11 @@ type BucketFallbackMapper struct {
 	id               principal.Signer
 	httpClient       *http.Client
 	bucketURL        *url.URL
-	allocationsStore AllocationsStore
+	allocationsStore types.AllocationsStore
 	getOpts          func() []delegation.Option
 }
 
-type AllocationsStore interface {
-	Has(ctx context.Context, digest multihash.Multihash) (bool, error)
-}
-
-func NewBucketFallbackMapper(id principal.Signer, httpClient *http.Client, bucketURL *url.URL, allocationsStore AllocationsStore, getOpts func() []delegation.Option) BucketFallbackMapper {
+func NewBucketFallbackMapper(id principal.Signer, httpClient *http.Client, bucketURL *url.URL, allocationsStore types.AllocationsStore, getOpts func() []delegation.Option) BucketFallbackMapper {
 	return BucketFallbackMapper{
 		id:               id,
 		httpClient:       httpClient,
@@ -30,7 +30,7 @@ type blockIndexItem struct {
 	Length  uint64 `dynamodbav:"length"`
 }
 
-func (d *DynamoProviderBlockIndexTable) Query(ctx context.Context, digest multihash.Multihash) ([]BlockIndexRecord, error) {
+func (d *DynamoProviderBlockIndexTable) Query(ctx context.Context, digest multihash.Multihash) ([]types.BlockIndexRecord, error) {
 	digestAttr, err := attributevalue.Marshal(digestutil.Format(digest))
 	if err != nil {
 		return nil, err
@@ -42,7 +42,7 @@ func (d *DynamoProviderBlockIndexTable) Query(ctx context.Context, digest multih
 		return nil, err
 	}
 
-	records := []BlockIndexRecord{}
+	records := []types.BlockIndexRecord{}
 
 	queryPaginator := dynamodb.NewQueryPaginator(d.client, &dynamodb.QueryInput{
 		TableName:                 aws.String(d.tableName),
@@ -66,7 +66,7 @@ func (d *DynamoProviderBlockIndexTable) Query(ctx context.Context, digest multih
 		}
 
 		for _, item := range items {
-			records = append(records, BlockIndexRecord(item))
+			records = append(records, types.BlockIndexRecord(item))
 
 			if len(records) >= blockIndexQueryLimit {
 				return records, nil
@@ -81,8 +81,74 @@ func (d *DynamoProviderBlockIndexTable) Query(ctx context.Context, digest multih
 	return records, nil
 }
 
-var _ BlockIndexStore = (*DynamoProviderBlockIndexTable)(nil)
+var _ types.BlockIndexStore = (*DynamoProviderBlockIndexTable)(nil)
 
 func NewDynamoProviderBlockIndexTable(client dynamodb.QueryAPIClient, tableName string) *DynamoProviderBlockIndexTable {
 	return &DynamoProviderBlockIndexTable{client, tableName}
 }
+
+// DynamoShardBlockIndexTable lists the blocks of legacy CAR shards through a
+// global secondary index of the block index table keyed by carpath, which
+// projects the offset and length attributes.
+type DynamoShardBlockIndexTable struct {
+	client    dynamodb.QueryAPIClient
+	tableName string
+	indexName string
+}
+
+type shardBlockIndexItem struct {
+	BlockMultihash string `dynamodbav:"blockmultihash"`
+	Offset         uint64 `dynamodbav:"offset"`
+	Length         uint64 `dynamodbav:"length"`
+}
+
+func (d *DynamoShardBlockIndexTable) QueryShard(ctx context.Context, carPath string) ([]types.ShardBlockRecord, error) {
+	keyEx := expression.Key("carpath").Equal(expression.Value(carPath))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyEx).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []types.ShardBlockRecord{}
+
+	queryPaginator := dynamodb.NewQueryPaginator(d.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.tableName),
+		IndexName:                 aws.String(d.indexName),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+	})
+
+	for queryPaginator.HasMorePages() {
+		response, err := queryPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		items := []shardBlockIndexItem{}
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &items)
+		if err != nil {
+			return nil, fmt.Errorf("deserializing items: %w", err)
+		}
+
+		for _, item := range items {
+			digest, err := digestutil.Parse(item.BlockMultihash)
+			if err != nil {
+				return nil, fmt.Errorf("parsing block multihash %q: %w", item.BlockMultihash, err)
+			}
+			records = append(records, types.ShardBlockRecord{Digest: digest, Offset: item.Offset, Length: item.Length})
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+
+	return records, nil
+}
+
+var _ types.ShardBlockIndexStore = (*DynamoShardBlockIndexTable)(nil)
+
+func NewDynamoShardBlockIndexTable(client dynamodb.QueryAPIClient, tableName string, indexName string) *DynamoShardBlockIndexTable {
+	return &DynamoShardBlockIndexTable{client, tableName, indexName}
+}
@@ -106,7 +106,7 @@ func TestDynamoProviderBlockIndexTable(t *testing.T) {
 		require.Equal(t, len(items), len(results))
 
 		for _, i := range items {
-			require.True(t, slices.ContainsFunc(results, func(r BlockIndexRecord) bool {
+			require.True(t, slices.ContainsFunc(results, func(r istypes.BlockIndexRecord) bool {
 				return r.CarPath == i.path && r.Offset == uint64(i.offset) && r.Length == uint64(i.length)
 			}))
 		}
@@ -142,6 +142,48 @@ func TestDynamoProviderBlockIndexTable(t *testing.T) {
 	})
 }
 
+func TestDynamoShardBlockIndexTable(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	endpoint := createDynamo(t)
+	dynamoClient := newDynamoClient(t, endpoint)
+
+	tableName := "blocks-cars-position-" + uuid.NewString()
+	createBlockIndexTable(t, dynamoClient, tableName)
+	store := NewDynamoShardBlockIndexTable(dynamoClient, tableName, blockIndexCarPathIndex)
+
+	t.Run("query existing shard", func(t *testing.T) {
+		path := "us-west-2/dotstorage-prod-1/raw/" + testutil.RandomCID(t).String() + ".car"
+		var expected []istypes.ShardBlockRecord
+		for i := range 3 {
+			digest := testutil.RandomMultihash(t)
+			_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName: aws.String(tableName),
+				Item: map[string]types.AttributeValue{
+					"blockmultihash": &types.AttributeValueMemberS{Value: digestutil.Format(digest)},
+					"carpath":        &types.AttributeValueMemberS{Value: path},
+					"offset":         &types.AttributeValueMemberN{Value: fmt.Sprint(i * 100)},
+					"length":         &types.AttributeValueMemberN{Value: "100"},
+				},
+			})
+			require.NoError(t, err)
+			expected = append(expected, istypes.ShardBlockRecord{Digest: digest, Offset: uint64(i * 100), Length: 100})
+		}
+
+		records, err := store.QueryShard(ctx, path)
+		require.NoError(t, err)
+		require.ElementsMatch(t, expected, records)
+	})
+
+	t.Run("query not found", func(t *testing.T) {
+		_, err := store.QueryShard(ctx, "us-west-2/dotstorage-prod-1/raw/missing.car")
+		require.ErrorIs(t, err, istypes.ErrKeyNotFound)
+	})
+}
+
 func createDynamo(t *testing.T) *url.URL {
 	ctx := context.Background()
 	container, err := tcdynamodb.Run(ctx, "amazon/dynamodb-local:latest")
@@ -177,6 +219,10 @@ func newDynamoClient(t *testing.T, endpoint *url.URL) *dynamodb.Client {
 	})
 }
 
+// blockIndexCarPathIndex is the name of the global secondary index of the test
+// block index tables keyed by carpath.
+const blockIndexCarPathIndex = "carpath"
+
 func createBlockIndexTable(t *testing.T, c *dynamodb.Client, tableName string) {
 	_, err := c.CreateTable(context.Background(), &dynamodb.CreateTableInput{
 		TableName:   aws.String(tableName),
@@ -201,6 +247,18 @@ func createBlockIndexTable(t *testing.T, c *dynamodb.Client, tableName string) {
 				KeyType:       types.KeyTypeRange,
 			},
 		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(blockIndexCarPathIndex),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("carpath"),
+						KeyType:       types.KeyTypeHash,
+					},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
 	})
 	require.NoError(t, err)
 }
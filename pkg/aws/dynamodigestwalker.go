@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+)
+
+// DynamoDigestWalker walks the content hashes of a legacy DynamoDB table
+// whose partition key is a base58btc encoded multihash, such as the block
+// index table ("blockmultihash") or the claims table ("content").
+//
+// The cursor is the JSON encoded exclusive start key of the page of the scan
+// the content hash was found in, so a resumed walk revisits at most one page.
+// Only tables whose key attributes are strings are supported.
+type DynamoDigestWalker struct {
+	client     dynamodb.ScanAPIClient
+	tableName  string
+	digestAttr string
+	pageSize   int32
+}
+
+var _ legacy.DigestWalker = (*DynamoDigestWalker)(nil)
+
+// NewDynamoDigestWalker creates a walker for the content hashes stored in the
+// digestAttr attribute of the passed table.
+func NewDynamoDigestWalker(client dynamodb.ScanAPIClient, tableName string, digestAttr string) *DynamoDigestWalker {
+	return &DynamoDigestWalker{
+		client:     client,
+		tableName:  tableName,
+		digestAttr: digestAttr,
+		pageSize:   1000,
+	}
+}
+
+// Walk implements legacy.DigestWalker.
+func (d *DynamoDigestWalker) Walk(ctx context.Context, cursor string, fn func(digest multihash.Multihash, cursor string) error) error {
+	startKey, err := decodeScanCursor(cursor)
+	if err != nil {
+		return err
+	}
+	for {
+		response, err := d.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:            aws.String(d.tableName),
+			ExclusiveStartKey:    startKey,
+			ProjectionExpression: aws.String("#digest"),
+			ExpressionAttributeNames: map[string]string{
+				"#digest": d.digestAttr,
+			},
+			Limit: aws.Int32(d.pageSize),
+		})
+		if err != nil {
+			return fmt.Errorf("scanning items: %w", err)
+		}
+		for _, item := range response.Items {
+			var s string
+			if err := attributevalue.Unmarshal(item[d.digestAttr], &s); err != nil {
+				return fmt.Errorf("deserializing %s: %w", d.digestAttr, err)
+			}
+			digest, err := digestutil.Parse(s)
+			if err != nil {
+				return fmt.Errorf("parsing %s %q: %w", d.digestAttr, s, err)
+			}
+			if err := fn(digest, cursor); err != nil {
+				return err
+			}
+		}
+		if len(response.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = response.LastEvaluatedKey
+		cursor, err = encodeScanCursor(startKey)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func encodeScanCursor(key map[string]dynamotypes.AttributeValue) (string, error) {
+	var values map[string]string
+	if err := attributevalue.UnmarshalMap(key, &values); err != nil {
+		return "", fmt.Errorf("deserializing scan key: %w", err)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("encoding scan cursor: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeScanCursor(cursor string) (map[string]dynamotypes.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(cursor), &values); err != nil {
+		return nil, fmt.Errorf("decoding scan cursor: %w", err)
+	}
+	key, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		return nil, fmt.Errorf("serializing scan key: %w", err)
+	}
+	return key, nil
+}
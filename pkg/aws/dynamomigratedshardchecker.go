@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/indexing-service/pkg/types"
 )
 
 type DynamoMigratedShardChecker struct {
@@ -18,7 +19,7 @@ type DynamoMigratedShardChecker struct {
 	blobRegistryTableClient dynamodb.QueryAPIClient
 	blobRegistryTableName   string
 	storeTableName          string
-	allocationsStore        AllocationsStore
+	allocationsStore        types.AllocationsStore
 }
 
 func (d *DynamoMigratedShardChecker) storeTableShardMigrated(ctx context.Context, shard ipld.Link) (bool, error) {
@@ -85,7 +86,7 @@ func (d *DynamoMigratedShardChecker) ShardMigrated(ctx context.Context, shard ip
 	return d.allocationsStore.Has(ctx, shard.(cidlink.Link).Cid.Hash())
 }
 
-func NewDynamoMigratedShardChecker(storeTableName string, storeTableClient dynamodb.QueryAPIClient, blobRegistryTableName string, blobRegistryTableClient dynamodb.QueryAPIClient, allocationsStore AllocationsStore) *DynamoMigratedShardChecker {
+func NewDynamoMigratedShardChecker(storeTableName string, storeTableClient dynamodb.QueryAPIClient, blobRegistryTableName string, blobRegistryTableClient dynamodb.QueryAPIClient, allocationsStore types.AllocationsStore) *DynamoMigratedShardChecker {
 	return &DynamoMigratedShardChecker{
 		storeTableName:          storeTableName,
 		storeTableClient:        storeTableClient,
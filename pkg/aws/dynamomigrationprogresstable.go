@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// DynamoMigrationProgressAPI is the subset of the DynamoDB client used by
+// [DynamoMigrationProgressTable].
+type DynamoMigrationProgressAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoMigrationProgressTable implements the legacy.ProgressStore interface
+// on a DynamoDB table keyed by mapper name.
+type DynamoMigrationProgressTable struct {
+	client    DynamoMigrationProgressAPI
+	tableName string
+}
+
+var _ legacy.ProgressStore = (*DynamoMigrationProgressTable)(nil)
+
+// NewDynamoMigrationProgressTable returns a ProgressStore connected to a AWS
+// DynamoDB table.
+func NewDynamoMigrationProgressTable(client DynamoMigrationProgressAPI, tableName string) *DynamoMigrationProgressTable {
+	return &DynamoMigrationProgressTable{client, tableName}
+}
+
+type migrationProgressItem struct {
+	Mapper   string `dynamodbav:"mapper"`
+	Progress string `dynamodbav:"progress"`
+}
+
+// Get implements legacy.ProgressStore.
+func (d *DynamoMigrationProgressTable) Get(ctx context.Context, mapper string) (legacy.MigrationProgress, error) {
+	key, err := attributevalue.Marshal(mapper)
+	if err != nil {
+		return legacy.MigrationProgress{}, err
+	}
+	response, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key:       map[string]dynamotypes.AttributeValue{"mapper": key},
+	})
+	if err != nil {
+		return legacy.MigrationProgress{}, fmt.Errorf("retrieving item: %w", err)
+	}
+	if response.Item == nil {
+		return legacy.MigrationProgress{}, types.ErrKeyNotFound
+	}
+	var item migrationProgressItem
+	if err := attributevalue.UnmarshalMap(response.Item, &item); err != nil {
+		return legacy.MigrationProgress{}, fmt.Errorf("deserializing item: %w", err)
+	}
+	var progress legacy.MigrationProgress
+	if err := json.Unmarshal([]byte(item.Progress), &progress); err != nil {
+		return legacy.MigrationProgress{}, fmt.Errorf("deserializing migration progress: %w", err)
+	}
+	return progress, nil
+}
+
+// Put implements legacy.ProgressStore.
+func (d *DynamoMigrationProgressTable) Put(ctx context.Context, progress legacy.MigrationProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("serializing migration progress: %w", err)
+	}
+	item, err := attributevalue.MarshalMap(migrationProgressItem{
+		Mapper:   progress.Mapper,
+		Progress: string(data),
+	})
+	if err != nil {
+		return fmt.Errorf("serializing item: %w", err)
+	}
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName), Item: item,
+	})
+	if err != nil {
+		return fmt.Errorf("storing item: %w", err)
+	}
+	return nil
+}
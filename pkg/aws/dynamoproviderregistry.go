@@ -0,0 +1,225 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// DynamoProviderRegistryAPI is the subset of the DynamoDB client used by
+// [DynamoProviderRegistry].
+type DynamoProviderRegistryAPI interface {
+	dynamodb.ScanAPIClient
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// peerKeyPrefix prefixes the keys of the items mapping peer IDs to providers.
+const peerKeyPrefix = "peer/"
+
+// DynamoProviderRegistry implements the types.ProviderRegistry interface on a
+// DynamoDB table keyed by provider DID. Each peer ID of a provider also has an
+// item keyed by the peer ID and referencing the provider, written in the same
+// transaction, so that providers are found by peer ID without a scan. Wrap it
+// with a providerregistry.CachedRegistry to use it on the query path.
+type DynamoProviderRegistry struct {
+	client    DynamoProviderRegistryAPI
+	tableName string
+}
+
+var _ types.ProviderRegistry = (*DynamoProviderRegistry)(nil)
+
+// NewDynamoProviderRegistry returns a ProviderRegistry connected to a AWS
+// DynamoDB table.
+func NewDynamoProviderRegistry(client DynamoProviderRegistryAPI, tableName string) *DynamoProviderRegistry {
+	return &DynamoProviderRegistry{client, tableName}
+}
+
+// Get implements types.ProviderRegistry.
+func (d *DynamoProviderRegistry) Get(ctx context.Context, provider did.DID) (types.StorageProvider, error) {
+	item, err := d.getItem(ctx, provider.String())
+	if err != nil {
+		return types.StorageProvider{}, err
+	}
+	return unmarshalProviderItem(item)
+}
+
+// Find implements types.ProviderRegistry.
+func (d *DynamoProviderRegistry) Find(ctx context.Context, peerID peer.ID) (types.StorageProvider, error) {
+	item, err := d.getItem(ctx, peerKeyPrefix+peerID.String())
+	if err != nil {
+		return types.StorageProvider{}, err
+	}
+	var pi peerItem
+	if err := attributevalue.UnmarshalMap(item, &pi); err != nil {
+		return types.StorageProvider{}, fmt.Errorf("deserializing peer item: %w", err)
+	}
+	provider, err := did.Parse(pi.Owner)
+	if err != nil {
+		return types.StorageProvider{}, fmt.Errorf("parsing provider DID: %w", err)
+	}
+	return d.Get(ctx, provider)
+}
+
+func (d *DynamoProviderRegistry) getItem(ctx context.Context, key string) (map[string]dynamotypes.AttributeValue, error) {
+	response, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key:       map[string]dynamotypes.AttributeValue{"provider": &dynamotypes.AttributeValueMemberS{Value: key}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("retrieving item: %w", err)
+	}
+	if response.Item == nil {
+		return nil, types.ErrKeyNotFound
+	}
+	return response.Item, nil
+}
+
+// List implements types.ProviderRegistry.
+func (d *DynamoProviderRegistry) List(ctx context.Context) ([]types.StorageProvider, error) {
+	scanPaginator := dynamodb.NewScanPaginator(d.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(d.tableName),
+		FilterExpression:          aws.String("NOT begins_with(provider, :peer)"),
+		ExpressionAttributeValues: map[string]dynamotypes.AttributeValue{":peer": &dynamotypes.AttributeValueMemberS{Value: peerKeyPrefix}},
+	})
+	var providers []types.StorageProvider
+	for scanPaginator.HasMorePages() {
+		response, err := scanPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("scanning items: %w", err)
+		}
+		for _, item := range response.Items {
+			provider, err := unmarshalProviderItem(item)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		}
+	}
+	return providers, nil
+}
+
+// Put implements types.ProviderRegistry. The items of peer IDs the provider no
+// longer has are deleted in the same transaction.
+func (d *DynamoProviderRegistry) Put(ctx context.Context, provider types.StorageProvider) error {
+	if err := providerregistry.Validate(provider); err != nil {
+		return err
+	}
+	previous, err := d.Get(ctx, provider.DID)
+	if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+		return err
+	}
+
+	peerIDs := make([]string, 0, len(provider.PeerIDs))
+	for _, id := range provider.PeerIDs {
+		peerIDs = append(peerIDs, id.String())
+	}
+	item, err := attributevalue.MarshalMap(providerItem{
+		Provider:  provider.DID.String(),
+		PeerIDs:   peerIDs,
+		Endpoints: provider.Endpoints,
+		Status:    string(provider.Status),
+	})
+	if err != nil {
+		return fmt.Errorf("serializing item: %w", err)
+	}
+	writes := []dynamotypes.TransactWriteItem{{Put: &dynamotypes.Put{TableName: aws.String(d.tableName), Item: item}}}
+	for _, id := range provider.PeerIDs {
+		item, err := attributevalue.MarshalMap(peerItem{Key: peerKeyPrefix + id.String(), Owner: provider.DID.String()})
+		if err != nil {
+			return fmt.Errorf("serializing peer item: %w", err)
+		}
+		writes = append(writes, dynamotypes.TransactWriteItem{Put: &dynamotypes.Put{TableName: aws.String(d.tableName), Item: item}})
+	}
+	for _, id := range previous.PeerIDs {
+		if !slices.Contains(provider.PeerIDs, id) {
+			writes = append(writes, d.deletePeerItem(id))
+		}
+	}
+
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	if err != nil {
+		return fmt.Errorf("storing items: %w", err)
+	}
+	return nil
+}
+
+// Delete implements types.ProviderRegistry.
+func (d *DynamoProviderRegistry) Delete(ctx context.Context, provider did.DID) error {
+	previous, err := d.Get(ctx, provider)
+	if err != nil {
+		if errors.Is(err, types.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}
+	writes := []dynamotypes.TransactWriteItem{{Delete: &dynamotypes.Delete{
+		TableName: aws.String(d.tableName),
+		Key:       map[string]dynamotypes.AttributeValue{"provider": &dynamotypes.AttributeValueMemberS{Value: provider.String()}},
+	}}}
+	for _, id := range previous.PeerIDs {
+		writes = append(writes, d.deletePeerItem(id))
+	}
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	if err != nil {
+		return fmt.Errorf("deleting items: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoProviderRegistry) deletePeerItem(id peer.ID) dynamotypes.TransactWriteItem {
+	return dynamotypes.TransactWriteItem{Delete: &dynamotypes.Delete{
+		TableName: aws.String(d.tableName),
+		Key:       map[string]dynamotypes.AttributeValue{"provider": &dynamotypes.AttributeValueMemberS{Value: peerKeyPrefix + id.String()}},
+	}}
+}
+
+// peerItem maps a peer ID to the DID of the provider it belongs to.
+type peerItem struct {
+	Key   string `dynamodbav:"provider"`
+	Owner string `dynamodbav:"owner"`
+}
+
+type providerItem struct {
+	Provider  string   `dynamodbav:"provider"`
+	PeerIDs   []string `dynamodbav:"peerIDs"`
+	Endpoints []string `dynamodbav:"endpoints,omitempty"`
+	Status    string   `dynamodbav:"status"`
+}
+
+func unmarshalProviderItem(item map[string]dynamotypes.AttributeValue) (types.StorageProvider, error) {
+	var pi providerItem
+	if err := attributevalue.UnmarshalMap(item, &pi); err != nil {
+		return types.StorageProvider{}, fmt.Errorf("deserializing item: %w", err)
+	}
+	provider, err := did.Parse(pi.Provider)
+	if err != nil {
+		return types.StorageProvider{}, fmt.Errorf("parsing provider DID: %w", err)
+	}
+	peerIDs := make([]peer.ID, 0, len(pi.PeerIDs))
+	for _, s := range pi.PeerIDs {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return types.StorageProvider{}, fmt.Errorf("parsing provider peer ID: %w", err)
+		}
+		peerIDs = append(peerIDs, id)
+	}
+	return types.StorageProvider{
+		DID:       provider,
+		PeerIDs:   peerIDs,
+		Endpoints: pi.Endpoints,
+		Status:    types.ProviderStatus(pi.Status),
+	}, nil
+}
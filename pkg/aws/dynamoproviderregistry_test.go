@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-libstoracha/testutil"
+	itypes "github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamoProviderRegistry(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	endpoint := createDynamo(t)
+	dynamoClient := newDynamoClient(t, endpoint)
+
+	tableName := "provider-registry"
+	createProviderRegistryTable(t, dynamoClient, tableName)
+	registry := NewDynamoProviderRegistry(dynamoClient, tableName)
+
+	provider := itypes.StorageProvider{
+		DID:       testutil.RandomDID(t),
+		PeerIDs:   []peer.ID{testutil.RandomPeer(t)},
+		Endpoints: []string{testutil.RandomMultiaddr(t).String()},
+		Status:    itypes.ProviderStatusActive,
+	}
+
+	t.Run("get missing provider", func(t *testing.T) {
+		_, err := registry.Get(ctx, testutil.RandomDID(t))
+		require.ErrorIs(t, err, itypes.ErrKeyNotFound)
+	})
+
+	t.Run("put, get, find and list provider", func(t *testing.T) {
+		require.NoError(t, registry.Put(ctx, provider))
+
+		got, err := registry.Get(ctx, provider.DID)
+		require.NoError(t, err)
+		require.Equal(t, provider, got)
+
+		got, err = registry.Find(ctx, provider.PeerIDs[0])
+		require.NoError(t, err)
+		require.Equal(t, provider, got)
+
+		providers, err := registry.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []itypes.StorageProvider{provider}, providers)
+	})
+
+	t.Run("replaces peer IDs", func(t *testing.T) {
+		previous := provider.PeerIDs[0]
+		provider.PeerIDs = []peer.ID{testutil.RandomPeer(t)}
+		require.NoError(t, registry.Put(ctx, provider))
+
+		_, err := registry.Find(ctx, previous)
+		require.ErrorIs(t, err, itypes.ErrKeyNotFound)
+
+		got, err := registry.Find(ctx, provider.PeerIDs[0])
+		require.NoError(t, err)
+		require.Equal(t, provider, got)
+
+		providers, err := registry.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []itypes.StorageProvider{provider}, providers)
+	})
+
+	t.Run("delete provider", func(t *testing.T) {
+		require.NoError(t, registry.Delete(ctx, provider.DID))
+		_, err := registry.Find(ctx, provider.PeerIDs[0])
+		require.ErrorIs(t, err, itypes.ErrKeyNotFound)
+	})
+}
+
+func createProviderRegistryTable(t *testing.T, c *dynamodb.Client, tableName string) {
+	_, err := c.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("provider"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("provider"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,290 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	cassert "github.com/storacha/go-libstoracha/capabilities/assert"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/ipnipublisher/store"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+type legacyIndexMapper struct {
+	blockIndex  blockIndexTableMapper
+	shardBlocks types.ShardBlockIndexStore
+	indexStore  store.SimpleStore
+	indexURL    url.URL
+}
+
+var _ legacy.ContentToClaimsMapper = legacyIndexMapper{}
+
+// NewLegacyIndexMapper creates a ContentToClaimsMapper that synthesizes index claims for DAGs stored in legacy CARs,
+// so that they can be served like native uploads instead of with a location claim per block.
+//
+// When the blockIndex mapper finds the content in CARs whose path names it as their root, as in the
+// raw/<root>/<uploader>/<shard>.car keys of legacy buckets, a sharded DAG index is built from the positions of the
+// blocks of the CARs listed by shardBlocks. The index is stored in indexStore, under the same <digest>/<digest>.blob
+// keys as blobs in a carpark bucket, and must be publicly available under indexURL. Indexes are built once per root
+// and reused afterwards. The returned claims are an index claim for the content, location claims for the CARs and the
+// location claims for the content block synthesized by the blockIndex mapper. Location claims for the stored indexes
+// are synthesized when they are queried.
+//
+// Only the CARs holding the root block are indexed, so blocks of DAGs spread over several CARs are still found through
+// their own location claims.
+func NewLegacyIndexMapper(blockIndex blockIndexTableMapper, shardBlocks types.ShardBlockIndexStore, indexStore store.SimpleStore, indexURL string) (legacyIndexMapper, error) {
+	iurl, err := url.Parse(indexURL)
+	if err != nil {
+		return legacyIndexMapper{}, fmt.Errorf("parsing index URL: %w", err)
+	}
+
+	return legacyIndexMapper{
+		blockIndex:  blockIndex,
+		shardBlocks: shardBlocks,
+		indexStore:  indexStore,
+		indexURL:    *iurl,
+	}, nil
+}
+
+// GetClaims implements providerindex.ContentToClaimsMapper.
+// Like blockIndexTableMapper, it returns identity CIDs embedding the synthesized claims.
+func (lim legacyIndexMapper) GetClaims(ctx context.Context, contentHash multihash.Multihash) ([]cid.Cid, error) {
+	locs, err := lim.blockIndex.locations(ctx, contentHash)
+	if err != nil {
+		if errors.Is(err, types.ErrKeyNotFound) {
+			// synthesized indexes are not in the block index
+			return lim.indexLocationClaims(ctx, contentHash)
+		}
+		return nil, err
+	}
+
+	claimCids, err := lim.indexClaims(ctx, contentHash, locs)
+	if err != nil {
+		// the content can still be retrieved with its block location claims
+		log.Warnf("synthesizing index for %s: %s", contentHash.B58String(), err)
+	}
+
+	for _, loc := range locs {
+		c, err := lim.blockIndex.locationClaim(loc.caveats)
+		if err != nil {
+			continue
+		}
+		claimCids = append(claimCids, c)
+	}
+
+	return claimCids, nil
+}
+
+// legacyIndexRecord describes a synthesized index. It is stored under the
+// digest of the root the index was built for, and under the digest of the
+// index itself.
+type legacyIndexRecord struct {
+	Index string `json:"index"`
+	Size  uint64 `json:"size"`
+}
+
+func legacyIndexRootKey(root multihash.Multihash) string {
+	return "legacy-index/roots/" + digestutil.Format(root)
+}
+
+func legacyIndexKey(index multihash.Multihash) string {
+	return "legacy-index/indexes/" + digestutil.Format(index)
+}
+
+// getIndexRecord returns the index record stored under key, or
+// types.ErrKeyNotFound if there is none.
+func (lim legacyIndexMapper) getIndexRecord(ctx context.Context, key string) (multihash.Multihash, uint64, error) {
+	r, err := lim.indexStore.Get(ctx, key)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return nil, 0, types.ErrKeyNotFound
+		}
+		return nil, 0, fmt.Errorf("getting index record: %w", err)
+	}
+	defer r.Close()
+
+	var record legacyIndexRecord
+	if err := json.NewDecoder(r).Decode(&record); err != nil {
+		return nil, 0, fmt.Errorf("decoding index record: %w", err)
+	}
+	indexHash, err := digestutil.Parse(record.Index)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing index record digest: %w", err)
+	}
+	return indexHash, record.Size, nil
+}
+
+func (lim legacyIndexMapper) putIndexRecord(ctx context.Context, key string, indexHash multihash.Multihash, size uint64) error {
+	data, err := json.Marshal(legacyIndexRecord{Index: digestutil.Format(indexHash), Size: size})
+	if err != nil {
+		return fmt.Errorf("encoding index record: %w", err)
+	}
+	if err := lim.indexStore.Put(ctx, key, uint64(len(data)), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("storing index record: %w", err)
+	}
+	return nil
+}
+
+// indexLocationClaims returns a location claim for the index with the given
+// digest if it was previously synthesized.
+func (lim legacyIndexMapper) indexLocationClaims(ctx context.Context, indexHash multihash.Multihash) ([]cid.Cid, error) {
+	_, size, err := lim.getIndexRecord(ctx, legacyIndexKey(indexHash))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := lim.blockIndex.locationClaim(cassert.LocationCaveats{
+		Content:  ctypes.FromHash(indexHash),
+		Location: []url.URL{*lim.indexURL.JoinPath(toBlobKey(indexHash))},
+		Range:    &cassert.Range{Offset: 0, Length: &size},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generating index location claim: %w", err)
+	}
+	return []cid.Cid{c}, nil
+}
+
+// legacyShard is a CAR that has the queried content as its root.
+type legacyShard struct {
+	root ipld.Link
+	loc  blockLocation
+}
+
+// indexClaims returns the index claim for the content if any of the CARs at
+// the given locations has it as a root, building and storing the index if it
+// was not built before, and the location claims of the CARs.
+func (lim legacyIndexMapper) indexClaims(ctx context.Context, contentHash multihash.Multihash, locs []blockLocation) ([]cid.Cid, error) {
+	var shards []legacyShard
+	seen := map[string]struct{}{}
+	for _, loc := range locs {
+		if loc.shard == nil {
+			continue
+		}
+		root, ok := carPathRoot(loc.record.CarPath, contentHash)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[loc.shard.String()]; ok {
+			continue
+		}
+		seen[loc.shard.String()] = struct{}{}
+		shards = append(shards, legacyShard{root: root, loc: loc})
+	}
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	indexHash, _, err := lim.getIndexRecord(ctx, legacyIndexRootKey(contentHash))
+	if errors.Is(err, types.ErrKeyNotFound) {
+		indexHash, err = lim.buildIndex(ctx, contentHash, shards)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bit := lim.blockIndex
+	indexClaim, err := cassert.Index.Delegate(
+		bit.id,
+		bit.id,
+		bit.id.DID().String(),
+		cassert.IndexCaveats{
+			Content: shards[0].root,
+			Index:   cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.Car), indexHash)},
+		},
+		bit.delegationOptions()...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("generating index claim: %w", err)
+	}
+	c, err := toIdentityCID(indexClaim)
+	if err != nil {
+		return nil, fmt.Errorf("generating index claim identity CID: %w", err)
+	}
+
+	claimCids := []cid.Cid{c}
+	for _, shard := range shards {
+		// the size of the CAR is not in the block index, so the location
+		// covers the whole file
+		c, err := bit.locationClaim(cassert.LocationCaveats{
+			Content:  ctypes.FromHash(shard.loc.shard.(cidlink.Link).Cid.Hash()),
+			Location: shard.loc.caveats.Location,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("generating shard location claim: %w", err)
+		}
+		claimCids = append(claimCids, c)
+	}
+	return claimCids, nil
+}
+
+// buildIndex builds a sharded DAG index of the blocks of the shards from the
+// block index, stores it with its records and returns its digest.
+func (lim legacyIndexMapper) buildIndex(ctx context.Context, contentHash multihash.Multihash, shards []legacyShard) (multihash.Multihash, error) {
+	index := blobindex.NewShardedDagIndexView(shards[0].root, len(shards))
+	for _, shard := range shards {
+		records, err := lim.shardBlocks.QueryShard(ctx, shard.loc.record.CarPath)
+		if err != nil {
+			return nil, fmt.Errorf("listing blocks of %s: %w", shard.loc.record.CarPath, err)
+		}
+		shardHash := shard.loc.shard.(cidlink.Link).Cid.Hash()
+		for _, r := range records {
+			index.SetSlice(shardHash, r.Digest, blobindex.Position{Offset: r.Offset, Length: r.Length})
+		}
+	}
+	archive, err := index.Archive()
+	if err != nil {
+		return nil, fmt.Errorf("archiving index: %w", err)
+	}
+	indexData, err := io.ReadAll(archive)
+	if err != nil {
+		return nil, fmt.Errorf("reading index archive: %w", err)
+	}
+	indexHash, err := multihash.Sum(indexData, multihash.SHA2_256, -1)
+	if err != nil {
+		return nil, fmt.Errorf("hashing index: %w", err)
+	}
+	size := uint64(len(indexData))
+	if err := lim.indexStore.Put(ctx, toBlobKey(indexHash), size, bytes.NewReader(indexData)); err != nil {
+		return nil, fmt.Errorf("storing index: %w", err)
+	}
+	// the root record is stored last, so that indexes are rebuilt if storing
+	// fails midway
+	if err := lim.putIndexRecord(ctx, legacyIndexKey(indexHash), indexHash, size); err != nil {
+		return nil, err
+	}
+	if err := lim.putIndexRecord(ctx, legacyIndexRootKey(contentHash), indexHash, size); err != nil {
+		return nil, err
+	}
+	return indexHash, nil
+}
+
+// carPathRoot returns the root named by a CAR path, as in the
+// raw/<root>/<uploader>/<shard>.car keys of legacy buckets, if it is the
+// content with the given digest.
+func carPathRoot(carPath string, contentHash multihash.Multihash) (ipld.Link, bool) {
+	if u, err := url.Parse(carPath); err == nil && u.IsAbs() {
+		carPath = u.Path
+	}
+	parts := strings.Split(carPath, "/")
+	for _, part := range parts[:len(parts)-1] {
+		c, err := cid.Parse(part)
+		if err == nil && bytes.Equal(c.Hash(), contentHash) {
+			return cidlink.Link{Cid: c}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,218 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	cassert "github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/ipnipublisher/store"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/car"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/core/ipld/block"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyIndexMapper(t *testing.T) {
+	id := testutil.Service
+	blocks := randomRawBlocks(t, 3)
+	root := blocks[0].Link()
+	carData := encodeCAR(t, root, blocks)
+	shard := cid.NewCidV1(uint64(multicodec.Car), testutil.Must(multihash.Sum(carData, multihash.SHA2_256, -1))(t))
+
+	// the index the mapper should build from the block index
+	expected, err := blobindex.FromShardArchives(root, [][]byte{carData})
+	require.NoError(t, err)
+	expectedData := testutil.Must(io.ReadAll(testutil.Must(expected.Archive())(t)))(t)
+	expectedHash := testutil.Must(multihash.Sum(expectedData, multihash.SHA2_256, -1))(t)
+	positions := expected.Shards().Get(shard.Hash())
+	require.NotNil(t, positions)
+
+	newMapper := func(t *testing.T, carPath string) (legacyIndexMapper, *mockShardBlockIndexStore, store.SimpleStore) {
+		blockIndexStore := newMockBlockIndexStore()
+		shardBlocks := newMockShardBlockIndexStore()
+		for digest, pos := range positions.Iterator() {
+			blockIndexStore.data.Set(digest, []types.BlockIndexRecord{{CarPath: carPath, Offset: pos.Offset, Length: pos.Length}})
+			shardBlocks.data[carPath] = append(shardBlocks.data[carPath], types.ShardBlockRecord{Digest: digest, Offset: pos.Offset, Length: pos.Length})
+		}
+		bitMapper, err := NewBlockIndexTableMapper(id, blockIndexStore, newMockMigratedShardChecker(), "https://test.bucket.example.com", time.Hour, nil)
+		require.NoError(t, err)
+		indexStore := store.SimpleStoreFromDatastore(datastore.NewMapDatastore())
+		mapper, err := NewLegacyIndexMapper(bitMapper, shardBlocks, indexStore, "https://indexes.example.com/")
+		require.NoError(t, err)
+		return mapper, shardBlocks, indexStore
+	}
+
+	for _, f := range []struct {
+		name     string
+		carPath  string
+		location string
+	}{
+		{
+			name:     "bucket key",
+			carPath:  fmt.Sprintf("us-west-2/carpark-prod-0/raw/%s/315318734258474846/%s.car", root, shard),
+			location: fmt.Sprintf("https://test.bucket.example.com/%s/%s.car", shard, shard),
+		},
+		{
+			name:     "URL",
+			carPath:  fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard),
+			location: fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard),
+		},
+	} {
+		t.Run("synthesizes an index for a CAR root, "+f.name, func(t *testing.T) {
+			mapper, _, indexStore := newMapper(t, f.carPath)
+			claimCids, err := mapper.GetClaims(context.Background(), root.(cidlink.Link).Cid.Hash())
+			require.NoError(t, err)
+			require.Len(t, claimCids, 3)
+
+			// index claim for the root
+			indexClaim := extractIdentityClaim(t, claimCids[0])
+			require.Equal(t, cassert.IndexAbility, indexClaim.Capabilities()[0].Can())
+			require.NotNil(t, indexClaim.Expiration())
+			inb, err := cassert.IndexCaveatsReader.Read(indexClaim.Capabilities()[0].Nb())
+			require.NoError(t, err)
+			require.Equal(t, root.String(), inb.Content.String())
+			require.Equal(t, uint64(multicodec.Car), inb.Index.(cidlink.Link).Cid.Prefix().Codec)
+
+			// the stored index matches one built from the CAR
+			indexHash := inb.Index.(cidlink.Link).Cid.Hash()
+			require.Equal(t, expectedHash, indexHash)
+			r, err := indexStore.Get(context.Background(), toBlobKey(indexHash))
+			require.NoError(t, err)
+			require.Equal(t, expectedData, testutil.Must(io.ReadAll(r))(t))
+
+			// location claim for the whole CAR
+			shardClaim := extractIdentityClaim(t, claimCids[1])
+			snb, err := cassert.LocationCaveatsReader.Read(shardClaim.Capabilities()[0].Nb())
+			require.NoError(t, err)
+			require.Equal(t, shard.Hash(), snb.Content.Hash())
+			require.Equal(t, f.location, snb.Location[0].String())
+			require.Nil(t, snb.Range)
+
+			// location claim for the root block
+			blockClaim := extractIdentityClaim(t, claimCids[2])
+			bnb, err := cassert.LocationCaveatsReader.Read(blockClaim.Capabilities()[0].Nb())
+			require.NoError(t, err)
+			require.Equal(t, root.(cidlink.Link).Cid.Hash(), bnb.Content.Hash())
+
+			// the stored index can then be located
+			claimCids, err = mapper.GetClaims(context.Background(), indexHash)
+			require.NoError(t, err)
+			require.Len(t, claimCids, 1)
+			indexLocClaim := extractIdentityClaim(t, claimCids[0])
+			lnb, err := cassert.LocationCaveatsReader.Read(indexLocClaim.Capabilities()[0].Nb())
+			require.NoError(t, err)
+			require.Equal(t, indexHash, lnb.Content.Hash())
+			require.Equal(t, "https://indexes.example.com/"+toBlobKey(indexHash), lnb.Location[0].String())
+			require.Equal(t, uint64(len(expectedData)), *lnb.Range.Length)
+		})
+	}
+
+	t.Run("builds the index once", func(t *testing.T) {
+		mapper, shardBlocks, _ := newMapper(t, fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard))
+		for range 2 {
+			claimCids, err := mapper.GetClaims(context.Background(), root.(cidlink.Link).Cid.Hash())
+			require.NoError(t, err)
+			require.Len(t, claimCids, 3)
+		}
+		require.Equal(t, 1, shardBlocks.queries)
+	})
+
+	t.Run("returns block location claims for blocks that are not roots", func(t *testing.T) {
+		mapper, _, _ := newMapper(t, fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard))
+		claimCids, err := mapper.GetClaims(context.Background(), blocks[1].Link().(cidlink.Link).Cid.Hash())
+		require.NoError(t, err)
+		require.Len(t, claimCids, 1)
+		claim := extractIdentityClaim(t, claimCids[0])
+		require.Equal(t, cassert.LocationAbility, claim.Capabilities()[0].Can())
+	})
+
+	t.Run("returns block location claims when the CAR path does not name the root", func(t *testing.T) {
+		mapper, shardBlocks, _ := newMapper(t, fmt.Sprintf("https://cars.example.com/%s.car", shard))
+		claimCids, err := mapper.GetClaims(context.Background(), root.(cidlink.Link).Cid.Hash())
+		require.NoError(t, err)
+		require.Len(t, claimCids, 1)
+		claim := extractIdentityClaim(t, claimCids[0])
+		require.Equal(t, cassert.LocationAbility, claim.Capabilities()[0].Can())
+		require.Zero(t, shardBlocks.queries)
+	})
+
+	t.Run("returns block location claims when the blocks of the CAR cannot be listed", func(t *testing.T) {
+		mapper, shardBlocks, _ := newMapper(t, fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard))
+		shardBlocks.err = errors.New("table unavailable")
+		claimCids, err := mapper.GetClaims(context.Background(), root.(cidlink.Link).Cid.Hash())
+		require.NoError(t, err)
+		require.Len(t, claimCids, 1)
+		claim := extractIdentityClaim(t, claimCids[0])
+		require.Equal(t, cassert.LocationAbility, claim.Capabilities()[0].Can())
+	})
+
+	t.Run("returns ErrKeyNotFound for unknown content", func(t *testing.T) {
+		mapper, _, _ := newMapper(t, fmt.Sprintf("https://cars.example.com/raw/%s/%s.car", root, shard))
+		_, err := mapper.GetClaims(context.Background(), testutil.RandomMultihash(t))
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+}
+
+type mockShardBlockIndexStore struct {
+	data    map[string][]types.ShardBlockRecord
+	err     error
+	queries int
+}
+
+func (s *mockShardBlockIndexStore) QueryShard(ctx context.Context, carPath string) ([]types.ShardBlockRecord, error) {
+	s.queries++
+	if s.err != nil {
+		return nil, s.err
+	}
+	records := s.data[carPath]
+	if len(records) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return records, nil
+}
+
+func newMockShardBlockIndexStore() *mockShardBlockIndexStore {
+	return &mockShardBlockIndexStore{data: map[string][]types.ShardBlockRecord{}}
+}
+
+func randomRawBlocks(t *testing.T, n int) []ipld.Block {
+	var blocks []ipld.Block
+	for range n {
+		data := testutil.RandomBytes(t, 128)
+		digest := testutil.Must(multihash.Sum(data, multihash.SHA2_256, -1))(t)
+		blocks = append(blocks, block.NewBlock(cidlink.Link{Cid: cid.NewCidV1(cid.Raw, digest)}, data))
+	}
+	return blocks
+}
+
+func encodeCAR(t *testing.T, root ipld.Link, blocks []ipld.Block) []byte {
+	r := car.Encode([]ipld.Link{root}, func(yield func(ipld.Block, error) bool) {
+		for _, b := range blocks {
+			if !yield(b, nil) {
+				return
+			}
+		}
+	})
+	return testutil.Must(io.ReadAll(r))(t)
+}
+
+func extractIdentityClaim(t *testing.T, c cid.Cid) delegation.Delegation {
+	dh, err := multihash.Decode(c.Hash())
+	require.NoError(t, err)
+	claim, err := delegation.Extract(dh.Digest)
+	require.NoError(t, err)
+	return claim
+}
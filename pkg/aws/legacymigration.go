@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/libp2p/go-libp2p/core/peer"
+	goredis "github.com/redis/go-redis/v9"
+	publisherqueue "github.com/storacha/go-libstoracha/ipnipublisher/queue"
+	awspublisherqueue "github.com/storacha/go-libstoracha/ipnipublisher/queue/aws"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/construct"
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/service/providerindex"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// Names of the legacy mappers, identifying them in traces, metrics and
+// migration progress.
+const (
+	LegacyClaimsMapperName         = "claims"
+	LegacyBucketFallbackMapperName = "bucket-fallback"
+	LegacyBlockIndexMapperName     = "block-index"
+	LegacyIndexMapperName          = "legacy-index"
+)
+
+type legacyMappers struct {
+	claims         DynamoContentToClaimsMapper
+	bucketFallback BucketFallbackMapper
+	blockIndex     blockIndexTableMapper
+}
+
+// newLegacyMappers creates the content to claims mappers for the legacy
+// tables. Claims synthesized from the block index table expire after
+// blockIndexClaimExp, or never if it is zero.
+func newLegacyMappers(cfg Config, httpClient *http.Client, blockIndexClaimExp time.Duration) (legacyMappers, error) {
+	legacyDataBucketURL, err := url.Parse(cfg.LegacyDataBucketURL)
+	if err != nil {
+		return legacyMappers{}, fmt.Errorf("parsing carpark url: %s", err)
+	}
+	// legacy claims mapper
+	legacyClaimsCfg := cfg.Config.Copy()
+	legacyClaimsCfg.Region = cfg.LegacyClaimsTableRegion
+	legacyClaimsMapper := NewDynamoContentToClaimsMapper(dynamodb.NewFromConfig(legacyClaimsCfg), cfg.LegacyClaimsTableName)
+
+	// bucket fallback mapper
+	allocationsCfg := cfg.Config.Copy()
+	allocationsCfg.Region = cfg.LegacyAllocationsTableRegion
+	legacyAllocationsStore := NewDynamoAllocationsTable(dynamodb.NewFromConfig(allocationsCfg), cfg.LegacyAllocationsTableName)
+	bucketFallbackMapper := NewBucketFallbackMapper(
+		cfg.Signer,
+		httpClient,
+		legacyDataBucketURL,
+		legacyAllocationsStore,
+		func() []delegation.Option {
+			return []delegation.Option{delegation.WithExpiration(int(time.Now().Add(time.Hour).Unix()))}
+		},
+	)
+
+	// block index table mapper
+	blockIndexCfg := cfg.Config.Copy()
+	blockIndexCfg.Region = cfg.LegacyBlockIndexTableRegion
+	legacyBlockIndexStore := NewDynamoProviderBlockIndexTable(dynamodb.NewFromConfig(blockIndexCfg), cfg.LegacyBlockIndexTableName)
+	storeTableCfg := cfg.Config.Copy()
+	storeTableCfg.Region = cfg.LegacyStoreTableRegion
+	blobRegistryTableCfg := cfg.Config.Copy()
+	blobRegistryTableCfg.Region = cfg.LegacyBlobRegistryTableRegion
+	legacyMigratedShardChecker := NewDynamoMigratedShardChecker(
+		cfg.LegacyStoreTableName,
+		dynamodb.NewFromConfig(storeTableCfg),
+		cfg.LegacyBlobRegistryTableName,
+		dynamodb.NewFromConfig(blobRegistryTableCfg),
+		legacyAllocationsStore,
+	)
+	blockIndexTableMapper, err := NewBlockIndexTableMapper(cfg.Signer, legacyBlockIndexStore, legacyMigratedShardChecker, cfg.LegacyDataBucketURL, blockIndexClaimExp, cfg.LegacyDotStorageBucketPrefixes)
+	if err != nil {
+		return legacyMappers{}, fmt.Errorf("creating block index table mapper: %w", err)
+	}
+
+	return legacyMappers{
+		claims:         legacyClaimsMapper,
+		bucketFallback: bucketFallbackMapper,
+		blockIndex:     blockIndexTableMapper,
+	}, nil
+}
+
+func newLegacyClaimsBucket(cfg Config) types.ContentClaimsStore {
+	legacyClaimsCfg := cfg.Config.Copy()
+	legacyClaimsCfg.Region = cfg.LegacyClaimsTableRegion
+	return contentclaims.NewStoreFromBucket(NewS3Store(legacyClaimsCfg, cfg.LegacyClaimsBucket, ""))
+}
+
+func legacyClaimsURL(cfg Config) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/{claim}/{claim}.car", cfg.LegacyClaimsBucket, cfg.Config.Region)
+}
+
+// LegacyMigration is a legacy table whose claims can be migrated to IPNI.
+type LegacyMigration struct {
+	// Mapper finds the claims of the table. Its name identifies the table on
+	// the command line.
+	Mapper legacy.NamedMapper
+	Walker legacy.DigestWalker
+}
+
+// ConstructLegacyMigration constructs a migrator that republishes the claims
+// in the legacy claims and block index tables to IPNI through the provider
+// index, along with the tables it can walk. Progress is recorded in the
+// LEGACY_MIGRATION_TABLE_NAME table. Claims synthesized from the block index
+// table for migration do not expire.
+//
+// The bucket fallback mapper has no table to walk, so it is not migrated.
+func ConstructLegacyMigration(cfg Config) (*legacy.Migrator, []LegacyMigration, error) {
+	if !cfg.SupportLegacyServices {
+		return nil, nil, fmt.Errorf("legacy services are not enabled")
+	}
+	if cfg.LegacyMigrationTableName == "" {
+		return nil, nil, fmt.Errorf("legacy migration table name is not configured")
+	}
+
+	httpClient := construct.DefaultHTTPClient()
+	mappers, err := newLegacyMappers(cfg, httpClient, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	legacyFinder := contentclaims.WithIdentityCids(contentclaims.WithStore(contentclaims.NewNotFoundFinder(), newLegacyClaimsBucket(cfg)))
+	claims, err := legacy.NewClaimsStore([]legacy.ContentToClaimsMapper{mappers.claims, mappers.blockIndex}, legacyFinder, legacyClaimsURL(cfg))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating legacy claims store: %w", err)
+	}
+
+	findClient, err := ipnifind.New(cfg.IPNIFindURL, ipnifind.WithClient(httpClient))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating IPNI find client: %w", err)
+	}
+	publishingQueue := awspublisherqueue.NewSQSPublishingQueue(cfg.Config, cfg.SQSPublishingQueueID, cfg.PublishingBucket)
+	providerIndex := providerindex.New(
+		redis.NewProviderStore(redis.NewClusterClientAdapter(goredis.NewClusterClient(&cfg.ProvidersRedis))),
+		redis.NewNoProviderStore(goredis.NewClusterClient(&cfg.NoProviderRedis)),
+		findClient,
+		publisherqueue.NewQueuePublisher(publishingQueue),
+		legacy.NewNoResultsClaimsFinder(),
+	)
+
+	peerID, err := peer.IDFromPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating peer ID: %w", err)
+	}
+
+	progress := NewDynamoMigrationProgressTable(dynamodb.NewFromConfig(cfg.Config), cfg.LegacyMigrationTableName)
+	migrator := legacy.NewMigrator(claims, providerIndex, peerID, progress)
+
+	claimsTableCfg := cfg.Config.Copy()
+	claimsTableCfg.Region = cfg.LegacyClaimsTableRegion
+	blockIndexCfg := cfg.Config.Copy()
+	blockIndexCfg.Region = cfg.LegacyBlockIndexTableRegion
+	migrations := []LegacyMigration{
+		{
+			Mapper: legacy.Named(LegacyClaimsMapperName, mappers.claims),
+			Walker: NewDynamoDigestWalker(dynamodb.NewFromConfig(claimsTableCfg), cfg.LegacyClaimsTableName, "content"),
+		},
+		{
+			Mapper: legacy.Named(LegacyBlockIndexMapperName, mappers.blockIndex),
+			Walker: NewDynamoDigestWalker(dynamodb.NewFromConfig(blockIndexCfg), cfg.LegacyBlockIndexTableName, "blockmultihash"),
+		},
+	}
+	return migrator, migrations, nil
+}
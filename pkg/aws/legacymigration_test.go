@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	itypes "github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamoDigestWalker(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	endpoint := createDynamo(t)
+	dynamoClient := newDynamoClient(t, endpoint)
+
+	tableName := "blocks-cars-position-" + uuid.NewString()
+	createBlockIndexTable(t, dynamoClient, tableName)
+
+	digests := map[string]struct{}{}
+	for i := range 5 {
+		digest := testutil.RandomMultihash(t)
+		digests[digestutil.Format(digest)] = struct{}{}
+		_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(tableName),
+			Item: map[string]types.AttributeValue{
+				"blockmultihash": &types.AttributeValueMemberS{Value: digestutil.Format(digest)},
+				"carpath":        &types.AttributeValueMemberS{Value: fmt.Sprintf("http://test.example.com/%d.car", i)},
+				"offset":         &types.AttributeValueMemberN{Value: "0"},
+				"length":         &types.AttributeValueMemberN{Value: "1"},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	walker := NewDynamoDigestWalker(dynamoClient, tableName, "blockmultihash")
+	walker.pageSize = 2
+
+	visited := map[string]struct{}{}
+	cursors := map[string]struct{}{}
+	err := walker.Walk(ctx, "", func(digest multihash.Multihash, cursor string) error {
+		visited[digestutil.Format(digest)] = struct{}{}
+		cursors[cursor] = struct{}{}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, digests, visited)
+	require.Len(t, cursors, 3)
+
+	// resuming from a cursor visits the remaining pages
+	var resumeFrom string
+	for c := range cursors {
+		if c != "" {
+			resumeFrom = c
+			break
+		}
+	}
+	count := 0
+	err = walker.Walk(ctx, resumeFrom, func(digest multihash.Multihash, cursor string) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Less(t, count, len(digests))
+}
+
+func TestDynamoMigrationProgressTable(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	endpoint := createDynamo(t)
+	dynamoClient := newDynamoClient(t, endpoint)
+
+	tableName := "legacy-migration-" + uuid.NewString()
+	_, err := dynamoClient.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("mapper"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("mapper"), KeyType: types.KeyTypeHash},
+		},
+	})
+	require.NoError(t, err)
+
+	table := NewDynamoMigrationProgressTable(dynamoClient, tableName)
+
+	_, err = table.Get(ctx, "aws.blockIndexTableMapper")
+	require.ErrorIs(t, err, itypes.ErrKeyNotFound)
+
+	progress := legacy.MigrationProgress{
+		Mapper:    "aws.blockIndexTableMapper",
+		Cursor:    `{"blockmultihash":"zQm"}`,
+		Digests:   10,
+		Published: 8,
+		Failed:    1,
+	}
+	require.NoError(t, table.Put(ctx, progress))
+
+	got, err := table.Get(ctx, progress.Mapper)
+	require.NoError(t, err)
+	require.Equal(t, progress, got)
+}
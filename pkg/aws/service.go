@@ -24,15 +24,19 @@ import (
 	awspublisherqueue "github.com/storacha/go-libstoracha/ipnipublisher/queue/aws"
 	"github.com/storacha/go-libstoracha/ipnipublisher/store"
 	"github.com/storacha/go-libstoracha/metadata"
-	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/go-ucanto/principal"
 	ed25519 "github.com/storacha/go-ucanto/principal/ed25519/signer"
 	"github.com/storacha/go-ucanto/principal/signer"
+	"github.com/storacha/go-ucanto/ucan"
 	"github.com/storacha/indexing-service/pkg/build"
 	"github.com/storacha/indexing-service/pkg/construct"
 	"github.com/storacha/indexing-service/pkg/presets"
+	"github.com/storacha/indexing-service/pkg/principalresolver"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/service"
 	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
 	"github.com/storacha/indexing-service/pkg/telemetry"
@@ -82,6 +86,11 @@ type LegacyConfig struct {
 	LegacyAllocationsTableRegion   string
 	LegacyDotStorageBucketPrefixes []string // legacy .storage buckets
 	LegacyDataBucketURL            string
+	LegacyMigrationTableName       string // optional, progress of migrating legacy claims to IPNI
+	LegacyMergeStrategy            legacy.MergeStrategy
+	LegacyMapperTimeout            time.Duration // optional, no timeout if zero
+	LegacyIndexBucketURL           string        // optional, public URL of the claim store bucket, enables synthesizing indexes for legacy CARs
+	LegacyBlockIndexCarPathIndex   string        // global secondary index of the block index table keyed by carpath, required with LegacyIndexBucketURL
 }
 
 func readLegacyConfig() LegacyConfig {
@@ -90,6 +99,20 @@ func readLegacyConfig() LegacyConfig {
 	if err != nil {
 		panic(fmt.Errorf("parsing legacy dot storage bucket prefixes JSON: %w", err))
 	}
+	legacyMergeStrategy := legacy.MergeFirstHit
+	if os.Getenv("LEGACY_MERGE_STRATEGY") != "" {
+		legacyMergeStrategy, err = legacy.ParseMergeStrategy(os.Getenv("LEGACY_MERGE_STRATEGY"))
+		if err != nil {
+			panic(fmt.Errorf("parsing legacy merge strategy: %w", err))
+		}
+	}
+	var legacyMapperTimeout time.Duration
+	if os.Getenv("LEGACY_MAPPER_TIMEOUT") != "" {
+		legacyMapperTimeout, err = time.ParseDuration(os.Getenv("LEGACY_MAPPER_TIMEOUT"))
+		if err != nil {
+			panic(fmt.Errorf("parsing legacy mapper timeout: %w", err))
+		}
+	}
 	return LegacyConfig{
 		LegacyClaimsTableName:          mustGetEnv("LEGACY_CLAIMS_TABLE_NAME"),
 		LegacyClaimsTableRegion:        mustGetEnv("LEGACY_CLAIMS_TABLE_REGION"),
@@ -104,6 +127,11 @@ func readLegacyConfig() LegacyConfig {
 		LegacyAllocationsTableRegion:   mustGetEnv("LEGACY_ALLOCATIONS_TABLE_REGION"),
 		LegacyDataBucketURL:            mustGetEnv("LEGACY_DATA_BUCKET_URL"),
 		LegacyDotStorageBucketPrefixes: legacyDotStorageBucketPrefixes,
+		LegacyMigrationTableName:       os.Getenv("LEGACY_MIGRATION_TABLE_NAME"),
+		LegacyMergeStrategy:            legacyMergeStrategy,
+		LegacyMapperTimeout:            legacyMapperTimeout,
+		LegacyIndexBucketURL:           os.Getenv("LEGACY_INDEX_BUCKET_URL"),
+		LegacyBlockIndexCarPathIndex:   os.Getenv("LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME"),
 	}
 }
 
@@ -135,7 +163,20 @@ type Config struct {
 	SentryDSN                         string
 	SentryEnvironment                 string
 	TelemetryEnabled                  bool
+	MetricsEnabled                    bool
+	AccessLogEnabled                  bool
+	AccessLogSampleRate               float64
+	BlockProxyEnabled                 bool
+	BlockProxyAllowedHosts            []string
+	PublishRateLimit                  types.RateLimit
+	QueryRateLimit                    types.RateLimit
+	RateLimitOverrides                map[string]types.RateLimit
+	TrustedProxies                    int
 	PrincipalMapping                  map[string]string
+	ClaimAuthorities                  map[ucan.Ability][]did.DID
+	PrivateSpaces                     []did.DID
+	ProviderRegistryTableName         string
+	AdminToken                        string
 	IPNIFormatPeerID                  string
 	IPNIFormatEndpoint                string
 	principal.Signer
@@ -190,6 +231,14 @@ func FromEnv(ctx context.Context) Config {
 		principalMapping = presets.PrincipalMapping
 	}
 
+	var claimAuthorities map[ucan.Ability][]did.DID
+	if os.Getenv("CLAIM_AUTHORITIES") != "" {
+		claimAuthorities, err = contentclaims.ParseAuthorities([]byte(os.Getenv("CLAIM_AUTHORITIES")), id.DID())
+		if err != nil {
+			panic(fmt.Errorf("parsing claim authorities: %w", err))
+		}
+	}
+
 	ipniFindURL := os.Getenv("IPNI_ENDPOINT")
 	if ipniFindURL == "" {
 		ipniFindURL = presets.IPNIFindURL
@@ -203,6 +252,60 @@ func FromEnv(ctx context.Context) Config {
 		}
 	}
 
+	var blockProxyAllowedHosts []string
+	if os.Getenv("BLOCK_PROXY_ALLOWED_HOSTS") != "" {
+		err := json.Unmarshal([]byte(os.Getenv("BLOCK_PROXY_ALLOWED_HOSTS")), &blockProxyAllowedHosts)
+		if err != nil {
+			panic(fmt.Errorf("parsing block proxy allowed hosts JSON: %w", err))
+		}
+	}
+
+	accessLogSampleRate := 1.0
+	if os.Getenv("ACCESS_LOG_SAMPLE_RATE") != "" {
+		accessLogSampleRate = mustGetFloat("ACCESS_LOG_SAMPLE_RATE")
+	}
+
+	var privateSpaces []did.DID
+	if os.Getenv("PRIVATE_SPACES") != "" {
+		var spaces []string
+		err := json.Unmarshal([]byte(os.Getenv("PRIVATE_SPACES")), &spaces)
+		if err != nil {
+			panic(fmt.Errorf("parsing private spaces JSON: %w", err))
+		}
+		for _, s := range spaces {
+			space, err := did.Parse(s)
+			if err != nil {
+				panic(fmt.Errorf("parsing private space DID: %w", err))
+			}
+			privateSpaces = append(privateSpaces, space)
+		}
+	}
+
+	var publishRateLimit, queryRateLimit types.RateLimit
+	if os.Getenv("PUBLISH_RATE_LIMIT") != "" {
+		publishRateLimit = types.RateLimit{Rate: mustGetFloat("PUBLISH_RATE_LIMIT"), Burst: int(mustGetInt("PUBLISH_RATE_LIMIT_BURST"))}
+	}
+	if os.Getenv("QUERY_RATE_LIMIT") != "" {
+		queryRateLimit = types.RateLimit{Rate: mustGetFloat("QUERY_RATE_LIMIT"), Burst: int(mustGetInt("QUERY_RATE_LIMIT_BURST"))}
+	}
+	var rateLimitOverrides map[string]types.RateLimit
+	if os.Getenv("RATE_LIMIT_OVERRIDES") != "" {
+		err := json.Unmarshal([]byte(os.Getenv("RATE_LIMIT_OVERRIDES")), &rateLimitOverrides)
+		if err != nil {
+			panic(fmt.Errorf("parsing rate limit overrides JSON: %w", err))
+		}
+	}
+
+	var trustedProxies int
+	if os.Getenv("TRUSTED_PROXY_HOPS") != "" {
+		trustedProxies = int(mustGetInt("TRUSTED_PROXY_HOPS"))
+	}
+
+	var ipniStreamResultLimit int
+	if os.Getenv("IPNI_STREAM_RESULT_LIMIT") != "" {
+		ipniStreamResultLimit = int(mustGetInt("IPNI_STREAM_RESULT_LIMIT"))
+	}
+
 	var ipniPublisherDirectAnnounceURLs []string
 	if os.Getenv("IPNI_ANNOUNCE_URLS") != "" {
 		err := json.Unmarshal([]byte(os.Getenv("IPNI_ANNOUNCE_URLS")), &ipniPublisherDirectAnnounceURLs)
@@ -266,6 +369,11 @@ func FromEnv(ctx context.Context) Config {
 			},
 			IPNIFindURL:            ipniFindURL,
 			IPNIFindFallbackURLs:   ipniFindFallbackURLs,
+			IPNIReaderPrivacy:      os.Getenv("IPNI_READER_PRIVACY") == "true",
+			IPNIFindMergeResults:   os.Getenv("IPNI_MERGE_RESULTS") == "true",
+			IPNIStreamingFind:      os.Getenv("IPNI_STREAMING_FIND") == "true",
+			IPNIStreamResultLimit:  ipniStreamResultLimit,
+			StrictSpaceFiltering:   os.Getenv("STRICT_SPACE_FILTERING") == "true",
 			IPNIAnnounceAddrs:      []string{ipniPublisherAnnounceAddress},
 			IPNIDirectAnnounceURLs: ipniPublisherDirectAnnounceURLs,
 		},
@@ -289,14 +397,71 @@ func FromEnv(ctx context.Context) Config {
 		SentryDSN:                         os.Getenv("SENTRY_DSN"),
 		SentryEnvironment:                 os.Getenv("SENTRY_ENVIRONMENT"),
 		TelemetryEnabled:                  os.Getenv("TELEMETRY_DISABLED") == "",
+		MetricsEnabled:                    os.Getenv("METRICS_ENABLED") == "true",
+		AccessLogEnabled:                  os.Getenv("ACCESS_LOG_ENABLED") == "true",
+		AccessLogSampleRate:               accessLogSampleRate,
+		BlockProxyEnabled:                 os.Getenv("BLOCK_PROXY_ENABLED") == "true",
+		BlockProxyAllowedHosts:            blockProxyAllowedHosts,
+		PublishRateLimit:                  publishRateLimit,
+		QueryRateLimit:                    queryRateLimit,
+		RateLimitOverrides:                rateLimitOverrides,
+		TrustedProxies:                    trustedProxies,
 		IPNIFormatPeerID:                  os.Getenv("IPNI_FORMAT_PEER_ID"),
 		IPNIFormatEndpoint:                os.Getenv("IPNI_FORMAT_ENDPOINT"),
 		PrincipalMapping:                  principalMapping,
+		ClaimAuthorities:                  claimAuthorities,
+		PrivateSpaces:                     privateSpaces,
+		ProviderRegistryTableName:         os.Getenv("PROVIDER_REGISTRY_TABLE_NAME"),
+		AdminToken:                        os.Getenv("ADMIN_TOKEN"),
 	}
 }
 
-// Construct constructs types.Service from AWS deps for Lamda functions
-func Construct(cfg Config) (types.Service, error) {
+// ConstructProviderRegistry constructs the storage provider registry backed
+// by DynamoDB and cached in memory, or returns nil if a table is not
+// configured. Writes made through the returned registry invalidate its cache,
+// so it should be built once and shared by the service, passed to
+// [Construct], and the admin API.
+func ConstructProviderRegistry(cfg Config) types.ProviderRegistry {
+	if cfg.ProviderRegistryTableName == "" {
+		return nil
+	}
+	return providerregistry.NewCachedRegistry(NewDynamoProviderRegistry(dynamodb.NewFromConfig(cfg.Config), cfg.ProviderRegistryTableName))
+}
+
+// ConstructRateLimiters constructs the limiters for publishes and queries,
+// storing token buckets in the providers Redis cluster. A limiter is nil if
+// its limit is not configured.
+func ConstructRateLimiters(cfg Config) (publish *ratelimit.Limiter, query *ratelimit.Limiter, err error) {
+	if cfg.PublishRateLimit.Rate <= 0 && cfg.QueryRateLimit.Rate <= 0 {
+		return nil, nil, nil
+	}
+	client := goredis.NewClusterClient(&cfg.ProvidersRedis)
+	if cfg.TelemetryEnabled {
+		client = telemetry.InstrumentRedisClient(client)
+	}
+	store := redis.NewRateLimitStore(client)
+	if cfg.PublishRateLimit.Rate > 0 {
+		publish, err = ratelimit.NewLimiter("publish", store, cfg.PublishRateLimit, ratelimit.WithOverrides(cfg.RateLimitOverrides))
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating publish rate limiter: %w", err)
+		}
+	}
+	if cfg.QueryRateLimit.Rate > 0 {
+		query, err = ratelimit.NewLimiter("query", store, cfg.QueryRateLimit, ratelimit.WithOverrides(cfg.RateLimitOverrides))
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating query rate limiter: %w", err)
+		}
+	}
+	return publish, query, nil
+}
+
+// Construct constructs types.Service from AWS deps for Lamda functions. The
+// passed options are applied after the ones derived from the config.
+//
+// The service uses the passed provider registry, usually built with
+// [ConstructProviderRegistry], so that callers can share it with the admin
+// API. A nil registry leaves storage providers unrestricted.
+func Construct(cfg Config, registry types.ProviderRegistry, extraOpts ...construct.Option) (types.Service, error) {
 	httpClient := construct.DefaultHTTPClient()
 	providersClient := goredis.NewClusterClient(&cfg.ProvidersRedis)
 	noProvidersClient := goredis.NewClusterClient(&cfg.NoProviderRedis)
@@ -354,61 +519,68 @@ func Construct(cfg Config) (types.Service, error) {
 		construct.WithProviderIndexLogger(provIndexLog),
 	}
 
-	if cfg.SupportLegacyServices {
-		legacyDataBucketURL, err := url.Parse(cfg.LegacyDataBucketURL)
-		if err != nil {
-			return nil, fmt.Errorf("parsing carpark url: %s", err)
-		}
-		// legacy claims mapper
-		legacyClaimsCfg := cfg.Config.Copy()
-		legacyClaimsCfg.Region = cfg.LegacyClaimsTableRegion
-		legacyClaimsMapper := NewDynamoContentToClaimsMapper(dynamodb.NewFromConfig(legacyClaimsCfg), cfg.LegacyClaimsTableName)
-
-		// bucket fallback mapper
-		allocationsCfg := cfg.Config.Copy()
-		allocationsCfg.Region = cfg.LegacyAllocationsTableRegion
-		legacyAllocationsStore := NewDynamoAllocationsTable(dynamodb.NewFromConfig(allocationsCfg), cfg.LegacyAllocationsTableName)
-		bucketFallbackMapper := NewBucketFallbackMapper(
-			cfg.Signer,
-			httpClient,
-			legacyDataBucketURL,
-			legacyAllocationsStore,
-			func() []delegation.Option {
-				return []delegation.Option{delegation.WithExpiration(int(time.Now().Add(time.Hour).Unix()))}
-			},
-		)
+	presolv, err := principalresolver.New(cfg.PrincipalMapping)
+	if err != nil {
+		return nil, fmt.Errorf("creating principal resolver: %w", err)
+	}
+	opts = append(opts, construct.WithServiceOptions(service.WithPrincipalResolver(presolv)))
 
-		// block index table mapper
-		blockIndexCfg := cfg.Config.Copy()
-		blockIndexCfg.Region = cfg.LegacyBlockIndexTableRegion
-		legacyBlockIndexStore := NewDynamoProviderBlockIndexTable(dynamodb.NewFromConfig(blockIndexCfg), cfg.LegacyBlockIndexTableName)
-		storeTableCfg := cfg.Config.Copy()
-		storeTableCfg.Region = cfg.LegacyStoreTableRegion
-		blobRegistryTableCfg := cfg.Config.Copy()
-		blobRegistryTableCfg.Region = cfg.LegacyBlobRegistryTableRegion
-		legacyMigratedShardChecker := NewDynamoMigratedShardChecker(
-			cfg.LegacyStoreTableName,
-			dynamodb.NewFromConfig(storeTableCfg),
-			cfg.LegacyBlobRegistryTableName,
-			dynamodb.NewFromConfig(blobRegistryTableCfg),
-			legacyAllocationsStore,
-		)
+	if len(cfg.PrivateSpaces) > 0 {
+		opts = append(opts, construct.WithServiceOptions(service.WithPrivateSpaces(cfg.PrivateSpaces, presolv)))
+	}
+
+	if registry != nil {
+		opts = append(opts, construct.WithServiceOptions(service.WithProviderRegistry(registry)))
+	}
+
+	if cfg.SupportLegacyServices {
 		// allow claims synthethized from the block index table to live longer after they are expired in the cache
 		// so that the service doesn't return cached but expired delegations
 		synthetizedClaimExp := time.Duration(cfg.ClaimsCacheExpirationSeconds)*time.Second + 1*time.Hour
-		blockIndexTableMapper, err := NewBlockIndexTableMapper(cfg.Signer, legacyBlockIndexStore, legacyMigratedShardChecker, cfg.LegacyDataBucketURL, synthetizedClaimExp, cfg.LegacyDotStorageBucketPrefixes)
+		mappers, err := newLegacyMappers(cfg, httpClient, synthetizedClaimExp)
 		if err != nil {
-			return nil, fmt.Errorf("creating block index table mapper: %w", err)
+			return nil, err
 		}
-
-		legacyClaimsBucket := contentclaims.NewStoreFromBucket(NewS3Store(legacyClaimsCfg, cfg.LegacyClaimsBucket, ""))
-		legacyClaimsURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/{claim}/{claim}.car", cfg.LegacyClaimsBucket, cfg.Config.Region)
-		opts = append(opts, construct.WithLegacyClaims([]legacy.ContentToClaimsMapper{legacyClaimsMapper, bucketFallbackMapper, blockIndexTableMapper}, legacyClaimsBucket, legacyClaimsURL))
+		blockIndexMapper := legacy.Named(LegacyBlockIndexMapperName, mappers.blockIndex)
+		if cfg.LegacyIndexBucketURL != "" {
+			// synthesized indexes are stored alongside the claims
+			indexStore := NewS3Store(cfg.Config, cfg.ClaimStoreBucket, cfg.ClaimStorePrefix)
+			if cfg.LegacyBlockIndexCarPathIndex == "" {
+				return nil, fmt.Errorf("LEGACY_BLOCK_INDEX_CARPATH_INDEX_NAME is required with LEGACY_INDEX_BUCKET_URL")
+			}
+			blockIndexCfg := cfg.Config.Copy()
+			blockIndexCfg.Region = cfg.LegacyBlockIndexTableRegion
+			shardBlocks := NewDynamoShardBlockIndexTable(dynamodb.NewFromConfig(blockIndexCfg), cfg.LegacyBlockIndexTableName, cfg.LegacyBlockIndexCarPathIndex)
+			indexMapper, err := NewLegacyIndexMapper(mappers.blockIndex, shardBlocks, indexStore, cfg.LegacyIndexBucketURL)
+			if err != nil {
+				return nil, fmt.Errorf("creating legacy index mapper: %w", err)
+			}
+			// the index claims it synthesizes are not migrated, so it is not retired
+			// with the block index table
+			blockIndexMapper = legacy.Named(LegacyIndexMapperName, indexMapper)
+		}
+		legacyMappers := []legacy.ContentToClaimsMapper{
+			legacy.Named(LegacyClaimsMapperName, mappers.claims),
+			legacy.Named(LegacyBucketFallbackMapperName, mappers.bucketFallback),
+			blockIndexMapper,
+		}
+		if cfg.LegacyMigrationTableName != "" {
+			// mappers whose claims have been migrated to IPNI are retired
+			progress := NewDynamoMigrationProgressTable(dynamodb.NewFromConfig(cfg.Config), cfg.LegacyMigrationTableName)
+			legacyMappers, err = legacy.ActiveMappers(context.Background(), progress, legacyMappers)
+			if err != nil {
+				return nil, fmt.Errorf("retiring migrated legacy mappers: %w", err)
+			}
+		}
+		opts = append(opts,
+			construct.WithLegacyClaims(legacyMappers, newLegacyClaimsBucket(cfg), legacyClaimsURL(cfg)),
+			construct.WithLegacyClaimsOptions(legacy.WithMergeStrategy(cfg.LegacyMergeStrategy), legacy.WithMapperTimeout(cfg.LegacyMapperTimeout)),
+		)
 	}
 
 	service, err := construct.Construct(
 		cfg.ServiceConfig,
-		opts...,
+		append(opts, extraOpts...)...,
 	)
 	if err != nil {
 		return nil, err
@@ -7,10 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/google/uuid"
 	"github.com/ipni/go-libipni/find/model"
 	"github.com/storacha/go-libstoracha/blobindex"
@@ -22,9 +25,13 @@ import (
 type cachingQueueMessage struct {
 	JobID    uuid.UUID            `json:"JobID,omitempty"`
 	Provider model.ProviderResult `json:"Provider,omitempty"`
+	QueuedAt int64                `json:"QueuedAt,omitempty"`
 }
 
-var _ providercacher.CachingQueue = (*SQSCachingQueue)(nil)
+var (
+	_ providercacher.CachingQueue  = (*SQSCachingQueue)(nil)
+	_ providercacher.QueueLengther = (*SQSCachingQueue)(nil)
+)
 
 // SQSCachingQueue implements the providercacher.CachingQueue interface using SQS
 type SQSCachingQueue struct {
@@ -66,9 +73,14 @@ func (s *SQSCachingQueue) Queue(ctx context.Context, job providercacher.Provider
 	if err != nil {
 		return fmt.Errorf("saving index CAR to S3: %w", err)
 	}
+	queuedAt := job.QueuedAt
+	if queuedAt.IsZero() {
+		queuedAt = time.Now()
+	}
 	err = s.sendMessage(ctx, cachingQueueMessage{
 		JobID:    uuid,
 		Provider: job.Provider,
+		QueuedAt: queuedAt.UnixMilli(),
 	})
 	if err != nil {
 		// error sending message so cleanup queue
@@ -148,6 +160,33 @@ func (s *SQSCachingQueue) Delete(ctx context.Context, jobID string) error {
 	return err
 }
 
+// Len returns the approximate number of jobs in the SQS queue, including jobs
+// that are being processed.
+func (s *SQSCachingQueue) Len(ctx context.Context) (int, error) {
+	out, err := s.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(s.queueID),
+		AttributeNames: []sqstypes.QueueAttributeName{
+			sqstypes.QueueAttributeNameApproximateNumberOfMessages,
+			sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("getting queue attributes: %w", err)
+	}
+	n := 0
+	for _, name := range []sqstypes.QueueAttributeName{
+		sqstypes.QueueAttributeNameApproximateNumberOfMessages,
+		sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+	} {
+		v, err := strconv.Atoi(out.Attributes[string(name)])
+		if err != nil {
+			return 0, fmt.Errorf("parsing queue attribute %s: %w", name, err)
+		}
+		n += v
+	}
+	return n, nil
+}
+
 // SQSCachingDecoder provides interfaces for working with caching jobs received over SQS
 type SQSCachingDecoder struct {
 	bucket   string
@@ -181,11 +220,16 @@ func (s *SQSCachingDecoder) DecodeMessage(ctx context.Context, receiptHandle str
 	if err != nil {
 		return queuepoller.WithID[providercacher.ProviderCachingJob]{}, fmt.Errorf("deserializing index: %w", err)
 	}
+	var queuedAt time.Time
+	if msg.QueuedAt != 0 {
+		queuedAt = time.UnixMilli(msg.QueuedAt)
+	}
 	return queuepoller.WithID[providercacher.ProviderCachingJob]{
 		ID: receiptHandle,
 		Job: providercacher.ProviderCachingJob{
 			Provider: msg.Provider,
 			Index:    index,
+			QueuedAt: queuedAt,
 		},
 	}, nil
 }
@@ -0,0 +1,473 @@
+// Package carindex implements the stores used by the legacy claims mappers on
+// top of a local directory of CARv2 files with embedded multihash sorted
+// indexes, such as legacy shards converted with `car index`, and of CSV
+// exports of the legacy block index table.
+//
+// The positions of the blocks are imported into a SQLite database, which is
+// kept between runs so that only new and changed files are imported again.
+// CAR files named after their shard CID (e.g. bagbaiera....car) are also
+// registered as shards, so they are reported as allocated and migrated.
+package carindex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-libstoracha/digestutil"
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+
+	"github.com/storacha/indexing-service/pkg/internal/carv2"
+	"github.com/storacha/indexing-service/pkg/sqlstore"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// maxSectionHeaderSize bounds the size of the length prefix and CID at the
+// start of a CARv1 section.
+const maxSectionHeaderSize = 256
+
+// blockIndexTable is the table holding block positions, with the layout read
+// by sqlstore.BlockIndexTable and the file each position was imported from.
+const blockIndexTable = "blocks_cars_position"
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS blocks_cars_position (
+		blockmultihash TEXT NOT NULL,
+		carpath TEXT NOT NULL,
+		"offset" BIGINT NOT NULL,
+		length BIGINT NOT NULL,
+		source TEXT NOT NULL,
+		PRIMARY KEY (blockmultihash, carpath)
+	)`,
+	`CREATE INDEX IF NOT EXISTS blocks_cars_position_source ON blocks_cars_position (source)`,
+	`CREATE INDEX IF NOT EXISTS blocks_cars_position_carpath ON blocks_cars_position (carpath)`,
+	`CREATE TABLE IF NOT EXISTS shards (multihash TEXT PRIMARY KEY, source TEXT NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS files (name TEXT PRIMARY KEY, size BIGINT NOT NULL, modtime BIGINT NOT NULL)`,
+}
+
+// csvColumns are the columns of CSV block index exports.
+var csvColumns = []string{"blockmultihash", "carpath", "offset", "length"}
+
+// Store indexes the blocks and shards in a directory of CARv2 files and CSV
+// block index exports. Block index records point to the blocks in the CARv2
+// files served from a base URL, with offsets relative to the start of the
+// files, or are the records of the CSV files as is.
+type Store struct {
+	db      *sql.DB
+	baseURL *url.URL
+	blocks  *sqlstore.BlockIndexTable
+}
+
+var (
+	_ types.BlockIndexStore      = (*Store)(nil)
+	_ types.ShardBlockIndexStore = (*Store)(nil)
+	_ types.AllocationsStore     = (*Store)(nil)
+	_ types.MigratedShardChecker = (*Store)(nil)
+)
+
+// Open imports the CARv2 files (with a .car extension) and CSV block index
+// exports (with a .csv extension) in dir and its subdirectories into the
+// SQLite database at dbPath, which is created if it does not exist. Files
+// that were imported before and have not changed since are skipped, and the
+// records of files that were removed are deleted.
+//
+// CAR files are expected to be served from baseURL, under the same relative
+// paths they have in dir. The base URL is only required if dir holds CAR
+// files. CSV files must have a header row naming the blockmultihash, carpath,
+// offset and length columns, with base58btc encoded block multihashes, as in
+// exports of the legacy block index table.
+func Open(ctx context.Context, dir string, baseURL string, dbPath string) (*Store, error) {
+	var burl *url.URL
+	if baseURL != "" {
+		var err error
+		burl, err = url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing base URL: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening index database: %w", err)
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating index database schema: %w", err)
+		}
+	}
+
+	s := &Store{
+		db:      db,
+		baseURL: burl,
+		blocks:  sqlstore.NewBlockIndexTable(db, blockIndexTable),
+	}
+	if err := s.sync(ctx, dir); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the index database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// sync imports the new and changed files in dir and removes the records of
+// files that no longer exist.
+func (s *Store) sync(ctx context.Context, dir string) error {
+	seen := map[string]struct{}{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if d.IsDir() || (ext != ".car" && ext != ".csv") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		seen[name] = struct{}{}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var size, modtime int64
+		err = s.db.QueryRowContext(ctx, `SELECT size, modtime FROM files WHERE name = $1`, name).Scan(&size, &modtime)
+		if err == nil && size == info.Size() && modtime == info.ModTime().UnixNano() {
+			return nil
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reading import state of %s: %w", path, err)
+		}
+
+		if err := s.importFile(ctx, path, name, info); err != nil {
+			return fmt.Errorf("importing %s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM files`)
+	if err != nil {
+		return fmt.Errorf("listing imported files: %w", err)
+	}
+	var removed []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("listing imported files: %w", err)
+		}
+		if _, ok := seen[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("listing imported files: %w", err)
+	}
+	for _, name := range removed {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			return deleteSource(ctx, tx, name)
+		})
+		if err != nil {
+			return fmt.Errorf("removing records of %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// importFile replaces the records imported from a file in a single
+// transaction.
+func (s *Store) importFile(ctx context.Context, path string, name string, info fs.FileInfo) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := deleteSource(ctx, tx, name); err != nil {
+			return err
+		}
+		insert, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO blocks_cars_position (blockmultihash, carpath, "offset", length, source) VALUES ($1, $2, $3, $4, $5)`)
+		if err != nil {
+			return err
+		}
+		defer insert.Close()
+		put := func(digest multihash.Multihash, record types.BlockIndexRecord) error {
+			_, err := insert.ExecContext(ctx, digestutil.Format(digest), record.CarPath, record.Offset, record.Length, name)
+			return err
+		}
+
+		if filepath.Ext(path) == ".csv" {
+			err = readCSV(f, put)
+		} else {
+			err = s.readCAR(ctx, tx, f, name, put)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO files (name, size, modtime) VALUES ($1, $2, $3)`, name, info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+}
+
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteSource(ctx context.Context, tx *sql.Tx, name string) error {
+	for _, stmt := range []string{
+		`DELETE FROM blocks_cars_position WHERE source = $1`,
+		`DELETE FROM shards WHERE source = $1`,
+		`DELETE FROM files WHERE name = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCAR reads the index of a CARv2 file, calling put with the position of
+// each indexed block, and registers the file as a shard if it is named after
+// its CID.
+func (s *Store) readCAR(ctx context.Context, tx *sql.Tx, f *os.File, name string, put func(multihash.Multihash, types.BlockIndexRecord) error) error {
+	if s.baseURL == nil {
+		return errors.New("a base URL is required to serve CAR files")
+	}
+	header, err := carv2.ReadHeader(f)
+	if err != nil {
+		return err
+	}
+	if header.IndexOffset == 0 {
+		return errors.New("CAR has no index")
+	}
+	if _, err := f.Seek(int64(header.IndexOffset), io.SeekStart); err != nil {
+		return fmt.Errorf("seeking index: %w", err)
+	}
+
+	carPath := s.baseURL.JoinPath(name).String()
+	err = readIndex(bufio.NewReader(f), func(digest multihash.Multihash, offset uint64) error {
+		record, err := readRecord(f, digest, header.DataOffset+offset)
+		if err != nil {
+			return fmt.Errorf("reading block %s: %w", digest.B58String(), err)
+		}
+		record.CarPath = carPath
+		return put(digest, record)
+	})
+	if err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+
+	// recent buckets name CARs after their CID
+	if shard, err := cid.Parse(strings.TrimSuffix(filepath.Base(name), ".car")); err == nil && shard.Prefix().Codec == uint64(multicodec.Car) {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO shards (multihash, source) VALUES ($1, $2)`, digestutil.Format(shard.Hash()), name)
+		if err != nil {
+			return fmt.Errorf("registering shard: %w", err)
+		}
+	}
+	return nil
+}
+
+// readIndex reads a multihash sorted CARv2 index, calling fn with the
+// multihash and section offset of each indexed block.
+func readIndex(r *bufio.Reader, fn func(digest multihash.Multihash, offset uint64) error) error {
+	codec, err := varint.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if multicodec.Code(codec) != multicodec.CarMultihashIndexSorted {
+		return fmt.Errorf("unsupported index type: %s", multicodec.Code(codec))
+	}
+
+	var codes int32
+	if err := binary.Read(r, binary.LittleEndian, &codes); err != nil {
+		return err
+	}
+	for range codes {
+		var code uint64
+		if err := binary.Read(r, binary.LittleEndian, &code); err != nil {
+			return err
+		}
+		var widths int32
+		if err := binary.Read(r, binary.LittleEndian, &widths); err != nil {
+			return err
+		}
+		for range widths {
+			var width uint32
+			if err := binary.Read(r, binary.LittleEndian, &width); err != nil {
+				return err
+			}
+			var size uint64
+			if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+				return err
+			}
+			if width <= 8 || size%uint64(width) != 0 {
+				return fmt.Errorf("malformed index bucket: width %d, size %d", width, size)
+			}
+			record := make([]byte, width)
+			for range size / uint64(width) {
+				if _, err := io.ReadFull(r, record); err != nil {
+					return err
+				}
+				digest, err := multihash.Encode(record[:width-8], code)
+				if err != nil {
+					return err
+				}
+				if err := fn(digest, binary.LittleEndian.Uint64(record[width-8:])); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readRecord reads the header of the section at the given offset of a CAR
+// file to find the offset and length of the block data in the file.
+func readRecord(f *os.File, digest multihash.Multihash, sectionOffset uint64) (types.BlockIndexRecord, error) {
+	buf := make([]byte, maxSectionHeaderSize)
+	n, err := f.ReadAt(buf, int64(sectionOffset))
+	if err != nil && !(errors.Is(err, io.EOF) && n > 0) {
+		return types.BlockIndexRecord{}, err
+	}
+	buf = buf[:n]
+
+	sectionLength, prefixLength, err := varint.FromUvarint(buf)
+	if err != nil {
+		return types.BlockIndexRecord{}, fmt.Errorf("reading section length: %w", err)
+	}
+	cidLength, c, err := cid.CidFromBytes(buf[prefixLength:])
+	if err != nil {
+		return types.BlockIndexRecord{}, fmt.Errorf("reading section CID: %w", err)
+	}
+	if !bytes.Equal(c.Hash(), digest) {
+		return types.BlockIndexRecord{}, fmt.Errorf("index points to block %s", c)
+	}
+	if sectionLength < uint64(cidLength) {
+		return types.BlockIndexRecord{}, fmt.Errorf("malformed section length %d", sectionLength)
+	}
+
+	return types.BlockIndexRecord{
+		Offset: sectionOffset + uint64(prefixLength) + uint64(cidLength),
+		Length: sectionLength - uint64(cidLength),
+	}, nil
+}
+
+// readCSV reads a CSV block index export, calling put with each record.
+func readCSV(r io.Reader, put func(multihash.Multihash, types.BlockIndexRecord) error) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make([]int, len(csvColumns))
+	for i, name := range csvColumns {
+		columns[i] = -1
+		for j, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				columns[i] = j
+			}
+		}
+		if columns[i] < 0 {
+			return fmt.Errorf("CSV has no %s column", name)
+		}
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		digest, err := digestutil.Parse(row[columns[0]])
+		if err != nil {
+			return fmt.Errorf("line %d: parsing block multihash: %w", line, err)
+		}
+		offset, err := strconv.ParseUint(row[columns[2]], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: parsing offset: %w", line, err)
+		}
+		length, err := strconv.ParseUint(row[columns[3]], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: parsing length: %w", line, err)
+		}
+		if err := put(digest, types.BlockIndexRecord{CarPath: row[columns[1]], Offset: offset, Length: length}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Store) Query(ctx context.Context, digest multihash.Multihash) ([]types.BlockIndexRecord, error) {
+	return s.blocks.Query(ctx, digest)
+}
+
+func (s *Store) QueryShard(ctx context.Context, carPath string) ([]types.ShardBlockRecord, error) {
+	return s.blocks.QueryShard(ctx, carPath)
+}
+
+// Has returns true if the directory holds a CAR named after a shard with the
+// given multihash.
+func (s *Store) Has(ctx context.Context, digest multihash.Multihash) (bool, error) {
+	var found int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM shards WHERE multihash = $1`, digestutil.Format(digest)).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("querying shards: %w", err)
+	}
+	return true, nil
+}
+
+// ShardMigrated returns true if the directory holds the shard, since it can
+// then be served from the base URL.
+func (s *Store) ShardMigrated(ctx context.Context, shard ipld.Link) (bool, error) {
+	cl, ok := shard.(cidlink.Link)
+	if !ok {
+		return false, fmt.Errorf("shard is not a CID link: %T", shard)
+	}
+	return s.Has(ctx, cl.Cid.Hash())
+}
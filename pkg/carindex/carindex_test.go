@@ -0,0 +1,244 @@
+package carindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/internal/carv2"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type block struct {
+	cid  cid.Cid
+	data []byte
+}
+
+func randomBlocks(t *testing.T, n int) []block {
+	blocks := make([]block, 0, n)
+	for range n {
+		data := testutil.RandomBytes(t, 64+n)
+		digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		blocks = append(blocks, block{cid: cid.NewCidV1(cid.Raw, digest), data: data})
+	}
+	return blocks
+}
+
+// writeCAR writes the blocks to a CARv2 file with a multihash sorted index
+// and returns the CID of its CARv1 payload.
+func writeCAR(t *testing.T, dir string, name string, blocks []block, withIndex bool) cid.Cid {
+	header, err := qp.BuildMap(basicnode.Prototype.Map, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "roots", qp.List(1, func(la datamodel.ListAssembler) {
+			qp.ListEntry(la, qp.Link(cidlink.Link{Cid: blocks[0].cid}))
+		}))
+		qp.MapEntry(ma, "version", qp.Int(1))
+	})
+	require.NoError(t, err)
+	var headerBytes bytes.Buffer
+	require.NoError(t, dagcbor.Encode(header, &headerBytes))
+
+	var data bytes.Buffer
+	data.Write(varint.ToUvarint(uint64(headerBytes.Len())))
+	data.Write(headerBytes.Bytes())
+	type record struct {
+		digest []byte
+		offset uint64
+	}
+	var records []record
+	for _, b := range blocks {
+		records = append(records, record{digest: testutil.Must(multihash.Decode(b.cid.Hash()))(t).Digest, offset: uint64(data.Len())})
+		data.Write(varint.ToUvarint(uint64(len(b.cid.Bytes()) + len(b.data))))
+		data.Write(b.cid.Bytes())
+		data.Write(b.data)
+	}
+	slices.SortFunc(records, func(a, b record) int { return bytes.Compare(a.digest, b.digest) })
+
+	dataOffset := uint64(len(carv2.Pragma) + carv2.HeaderSize)
+	indexOffset := uint64(0)
+	if withIndex {
+		indexOffset = dataOffset + uint64(data.Len())
+	}
+
+	var car bytes.Buffer
+	car.Write(carv2.Pragma)
+	car.Write(make([]byte, 16))
+	binary.Write(&car, binary.LittleEndian, dataOffset)
+	binary.Write(&car, binary.LittleEndian, uint64(data.Len()))
+	binary.Write(&car, binary.LittleEndian, indexOffset)
+	car.Write(data.Bytes())
+	if withIndex {
+		width := uint32(len(records[0].digest) + 8)
+		car.Write(varint.ToUvarint(uint64(multicodec.CarMultihashIndexSorted)))
+		binary.Write(&car, binary.LittleEndian, int32(1))
+		binary.Write(&car, binary.LittleEndian, uint64(multihash.SHA2_256))
+		binary.Write(&car, binary.LittleEndian, int32(1))
+		binary.Write(&car, binary.LittleEndian, width)
+		binary.Write(&car, binary.LittleEndian, uint64(len(records))*uint64(width))
+		for _, r := range records {
+			car.Write(r.digest)
+			binary.Write(&car, binary.LittleEndian, r.offset)
+		}
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), car.Bytes(), 0o644))
+	return cid.NewCidV1(uint64(multicodec.Car), testutil.Must(multihash.Sum(data.Bytes(), multihash.SHA2_256, -1))(t))
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	blocks := randomBlocks(t, 5)
+	shard := writeCAR(t, dir, "placeholder.car", blocks[:3], true)
+	require.NoError(t, os.Rename(filepath.Join(dir, "placeholder.car"), filepath.Join(dir, shard.String()+".car")))
+	// the last block is in both CARs
+	writeCAR(t, dir, "other/unnamed.car", blocks[2:], true)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a CAR"), 0o644))
+
+	store, err := Open(t.Context(), dir, "https://cars.example.com/shards/", filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	t.Run("returns the position of blocks in the CARs", func(t *testing.T) {
+		for _, b := range blocks {
+			records, err := store.Query(t.Context(), b.cid.Hash())
+			require.NoError(t, err)
+			for _, r := range records {
+				name := r.CarPath[len("https://cars.example.com/shards/"):]
+				car, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				require.NoError(t, err)
+				require.Equal(t, b.data, car[r.Offset:r.Offset+r.Length])
+			}
+		}
+
+		records, err := store.Query(t.Context(), blocks[2].cid.Hash())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{
+			"https://cars.example.com/shards/" + shard.String() + ".car",
+			"https://cars.example.com/shards/other/unnamed.car",
+		}, []string{records[0].CarPath, records[1].CarPath})
+	})
+
+	t.Run("returns not found for unknown blocks", func(t *testing.T) {
+		_, err := store.Query(t.Context(), testutil.RandomMultihash(t))
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+
+	t.Run("returns the blocks of a CAR", func(t *testing.T) {
+		records, err := store.QueryShard(t.Context(), "https://cars.example.com/shards/other/unnamed.car")
+		require.NoError(t, err)
+		var digests []multihash.Multihash
+		for _, r := range records {
+			digests = append(digests, r.Digest)
+		}
+		require.ElementsMatch(t, []multihash.Multihash{blocks[2].cid.Hash(), blocks[3].cid.Hash(), blocks[4].cid.Hash()}, digests)
+
+		_, err = store.QueryShard(t.Context(), "https://cars.example.com/shards/missing.car")
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+
+	t.Run("CARs named after a shard CID are allocated and migrated", func(t *testing.T) {
+		has, err := store.Has(t.Context(), shard.Hash())
+		require.NoError(t, err)
+		require.True(t, has)
+
+		migrated, err := store.ShardMigrated(t.Context(), cidlink.Link{Cid: shard})
+		require.NoError(t, err)
+		require.True(t, migrated)
+
+		other := cid.NewCidV1(uint64(multicodec.Car), testutil.RandomMultihash(t))
+		migrated, err = store.ShardMigrated(t.Context(), cidlink.Link{Cid: other})
+		require.NoError(t, err)
+		require.False(t, migrated)
+	})
+}
+
+func TestOpenInvalidCARs(t *testing.T) {
+	t.Run("CAR without index", func(t *testing.T) {
+		dir := t.TempDir()
+		writeCAR(t, dir, "noindex.car", randomBlocks(t, 2), false)
+		_, err := Open(t.Context(), dir, "https://cars.example.com/", filepath.Join(t.TempDir(), "index.db"))
+		require.ErrorContains(t, err, "no index")
+	})
+
+	t.Run("not a CARv2 file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "v1.car"), testutil.RandomBytes(t, 128), 0o644))
+		_, err := Open(t.Context(), dir, "https://cars.example.com/", filepath.Join(t.TempDir(), "index.db"))
+		require.ErrorContains(t, err, "not a CARv2 file")
+	})
+}
+
+func TestOpenCSV(t *testing.T) {
+	dir := t.TempDir()
+	digest := testutil.RandomMultihash(t)
+	csv := "blockmultihash,carpath,offset,length\n" +
+		digestutil.Format(digest) + ",us-east-1/dotstorage-prod-1/raw/bafy/shard.car,100,42\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blocks.csv"), []byte(csv), 0o644))
+
+	// CSV records need no base URL
+	store, err := Open(t.Context(), dir, "", filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	records, err := store.Query(t.Context(), digest)
+	require.NoError(t, err)
+	require.Equal(t, []types.BlockIndexRecord{
+		{CarPath: "us-east-1/dotstorage-prod-1/raw/bafy/shard.car", Offset: 100, Length: 42},
+	}, records)
+
+	t.Run("invalid CSV", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "blocks.csv"), []byte("blockmultihash,carpath,offset\n"), 0o644))
+		_, err := Open(t.Context(), dir, "", filepath.Join(t.TempDir(), "index.db"))
+		require.ErrorContains(t, err, "no length column")
+	})
+}
+
+func TestOpenPersistsIndex(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	blocks := randomBlocks(t, 3)
+	writeCAR(t, dir, "a.car", blocks[:2], true)
+	writeCAR(t, dir, "b.car", blocks[2:], true)
+
+	store, err := Open(t.Context(), dir, "https://cars.example.com/", dbPath)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// removed files are dropped from the index, unchanged files are kept
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.car")))
+	store, err = Open(t.Context(), dir, "https://cars.example.com/", dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	records, err := store.Query(t.Context(), blocks[0].cid.Hash())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "https://cars.example.com/a.car", records[0].CarPath)
+
+	_, err = store.Query(t.Context(), blocks[2].cid.Hash())
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+}
+
+func TestOpenRequiresBaseURLForCARs(t *testing.T) {
+	dir := t.TempDir()
+	writeCAR(t, dir, "a.car", randomBlocks(t, 1), true)
+	_, err := Open(t.Context(), dir, "", filepath.Join(t.TempDir(), "index.db"))
+	require.ErrorContains(t, err, "base URL is required")
+}
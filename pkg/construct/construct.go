@@ -44,6 +44,7 @@ var log = logging.Logger("service")
 var providerIndexNamespace = datastore.NewKey("providerindex/")
 var providerIndexPublisherNamespace = providerIndexNamespace.Child(datastore.NewKey("publisher/"))
 var contentClaimsNamespace = datastore.NewKey("claims/")
+var providerCachingQueueNamespace = datastore.NewKey("providercachingqueue/")
 
 // ServiceConfig sets specific config values for the service
 type ServiceConfig struct {
@@ -70,6 +71,32 @@ type ServiceConfig struct {
 	// IPNIFindFallbackURLs are the URL(s) of IPNI nodes to use for find queries
 	// if the main URL is unavailable or returning no results.
 	IPNIFindFallbackURLs []string
+	// IPNIReaderPrivacy enables double hashed lookups when querying IPNI, so
+	// that queried multihashes are not revealed to IPNI nodes. Nodes that do not
+	// support double hashed lookups are queried with plain lookups.
+	IPNIReaderPrivacy bool
+	// IPNIFindMergeResults configures find queries to be sent to the main and
+	// all fallback IPNI nodes in parallel, merging their results, instead of
+	// only trying the fallback nodes when the main node is unavailable or
+	// returns no results.
+	IPNIFindMergeResults bool
+	// IPNIStreamingFind enables streaming NDJSON find responses from IPNI.
+	// Provider results are filtered as they are received and the query stops
+	// early once IPNIStreamResultLimit matching results are found for each
+	// target claim.
+	IPNIStreamingFind bool
+	// IPNIStreamResultLimit is the number of matching results per target claim
+	// after which a streaming find query is stopped. Defaults to
+	// providerindex.DefaultStreamResultLimit if not set.
+	IPNIStreamResultLimit int
+	// StrictSpaceFiltering excludes index and equals claims published without a
+	// space from query results filtered by space. Enable once previously
+	// published claims have been republished with space aware context IDs.
+	StrictSpaceFiltering bool
+	// DurableCachingQueue stores provider caching jobs in the datastore,
+	// so that queued jobs survive restarts, instead of processing them from an
+	// in-memory queue. Has no effect if a caching queue is passed explicitly.
+	DurableCachingQueue bool
 
 	// IPNIDirectAnnounceURLs are the URL(s) of IPNI nodes that
 	// advertisement announcements should be sent to. Defaults to IndexerURL if
@@ -104,6 +131,7 @@ type config struct {
 	legacyClaimsMappers []legacy.ContentToClaimsMapper
 	legacyClaimsBucket  types.ContentClaimsStore
 	legacyClaimsUrl     string
+	legacyClaimsOpts    []legacy.Option
 	httpClient          *http.Client
 	provIndexLog        logging.EventLogger
 }
@@ -124,10 +152,11 @@ func DefaultHTTPClient() *http.Client {
 // Option configures how the node is construct
 type Option func(*config) error
 
-// WithServiceOptions passes option to the core service
+// WithServiceOptions passes option to the core service. It may be used more
+// than once, options are accumulated.
 func WithServiceOptions(opts ...service.Option) Option {
 	return func(cfg *config) error {
-		cfg.opts = opts
+		cfg.opts = append(cfg.opts, opts...)
 		return nil
 	}
 }
@@ -246,6 +275,14 @@ func WithLegacyClaims(legacyClaimsMappers []legacy.ContentToClaimsMapper, legacy
 	}
 }
 
+// WithLegacyClaimsOptions passes configuration to the legacy claims store
+func WithLegacyClaimsOptions(opts ...legacy.Option) Option {
+	return func(cfg *config) error {
+		cfg.legacyClaimsOpts = append(cfg.legacyClaimsOpts, opts...)
+		return nil
+	}
+}
+
 // WithHTTPClient configures the HTTP client used when consuming HTTP APIs
 func WithHTTPClient(httpClient *http.Client) Option {
 	return func(cfg *config) error {
@@ -362,10 +399,36 @@ func Construct(sc ServiceConfig, opts ...Option) (Service, error) {
 	claimsCache := redis.NewContentClaimsStore(claimsClient, cfg.claimsCacheOpts...)
 	shardDagIndexesCache := redis.NewShardedDagIndexStore(indexesClient, cfg.indexesCacheOpts...)
 
+	// markers for indexes that have been fully cached and caching checkpoints
+	// expire with the records
+	cachedIndexes := redis.NewCachedIndexStore(providersClient, cfg.providersCacheOpts...)
+	checkpoints := redis.NewCachingCheckpointStore(providersClient, cfg.providersCacheOpts...)
+	providerCacher, err := providercacher.NewIncrementalProviderCacher(providersCache, cachedIndexes, providercacher.WithCheckpoints(checkpoints))
+	if err != nil {
+		return nil, fmt.Errorf("creating provider cacher: %w", err)
+	}
+
 	cachingQueue := cfg.cachingQueue
+	if cachingQueue == nil && sc.DurableCachingQueue {
+		// setup and start a durable provider caching queue in the datastore
+		dsQueue := providercacher.NewDatastoreCachingQueue(namespace.Wrap(initializeDatastore(&cfg), providerCachingQueueNamespace))
+		poller, err := providercacher.NewBatchCachingQueuePoller(dsQueue, providerCacher)
+		if err != nil {
+			return nil, fmt.Errorf("creating provider caching queue poller: %w", err)
+		}
+
+		depth, err := providercacher.RegisterQueueDepth(dsQueue)
+		if err != nil {
+			return nil, fmt.Errorf("registering provider caching queue depth: %w", err)
+		}
+
+		s.startupFuncs = append(s.startupFuncs, func(context.Context) error { poller.Start(); return nil })
+		s.shutdownFuncs = append(s.shutdownFuncs, func(context.Context) error { poller.Stop(); return depth.Unregister() })
+		cachingQueue = dsQueue
+	}
 	if cachingQueue == nil {
 		// setup and start the provider caching queue for indexes
-		cachingJobHandler := providercacher.NewJobHandler(providercacher.NewSimpleProviderCacher(providersCache))
+		cachingJobHandler := providercacher.NewJobHandler(providerCacher)
 
 		jq := jobqueue.NewJobQueue[providercacher.ProviderCachingJob](
 			jobqueue.JobHandler(cachingJobHandler.Handle),
@@ -386,24 +449,41 @@ func Construct(sc ServiceConfig, opts ...Option) (Service, error) {
 	}
 
 	// setup IPNI
-	// TODO: switch to double hashed client for reader privacy?
-	var findClient ipnifind.Finder
-	findClient, err := ipnifind.New(sc.IPNIFindURL, ipnifind.WithClient(httpClient))
+	findClient, err := newIPNIFinder(sc.IPNIFindURL, httpClient, sc, cfg.provIndexLog)
 	if err != nil {
 		return nil, err
 	}
 	if len(sc.IPNIFindFallbackURLs) > 0 {
-		tiers := []ipnifind.Finder{findClient}
+		nodes := []providerindex.IPNINode{{Endpoint: sc.IPNIFindURL, Finder: findClient}}
 		for _, url := range sc.IPNIFindFallbackURLs {
-			finder, err := ipnifind.New(url, ipnifind.WithClient(httpClient))
+			finder, err := newIPNIFinder(url, httpClient, sc, cfg.provIndexLog)
 			if err != nil {
 				return nil, fmt.Errorf("creating IPNI fallback find client for %s: %w", url, err)
 			}
-			tiers = append(tiers, finder)
+			nodes = append(nodes, providerindex.IPNINode{Endpoint: url, Finder: finder})
 		}
-		findClient, err = ipniclient.NewTieredFinder(tiers, ipniclient.WithTierFindTimeout(IPNIFindTimeout))
-		if err != nil {
-			return nil, fmt.Errorf("creating tiered IPNI find client: %w", err)
+		if sc.IPNIFindMergeResults {
+			findClient, err = providerindex.NewMergingFinder(
+				nodes,
+				providerindex.WithNodeFindTimeout(IPNIFindTimeout),
+				providerindex.WithMergingFinderLogger(cfg.provIndexLog),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("creating merging IPNI find client: %w", err)
+			}
+		} else {
+			tiers := make([]ipnifind.Finder, 0, len(nodes))
+			for _, node := range nodes {
+				tiers = append(tiers, node.Finder)
+			}
+			if sc.IPNIStreamingFind {
+				findClient = providerindex.NewTieredStreamFinder(tiers, IPNIFindTimeout)
+			} else {
+				findClient, err = ipniclient.NewTieredFinder(tiers, ipniclient.WithTierFindTimeout(IPNIFindTimeout))
+				if err != nil {
+					return nil, fmt.Errorf("creating tiered IPNI find client: %w", err)
+				}
+			}
 		}
 	}
 
@@ -476,7 +556,7 @@ func Construct(sc ServiceConfig, opts ...Option) (Service, error) {
 			return nil, fmt.Errorf("legacy claims url %s must contain the claim placeholder %s", cfg.legacyClaimsUrl, service.ClaimUrlPlaceholder)
 		}
 		legacyFinder := contentclaims.WithIdentityCids(contentclaims.WithCache(contentclaims.WithStore(contentclaims.NewNotFoundFinder(), cfg.legacyClaimsBucket), claimsCache))
-		legacyClaims, err = legacy.NewClaimsStore(cfg.legacyClaimsMappers, legacyFinder, cfg.legacyClaimsUrl)
+		legacyClaims, err = legacy.NewClaimsStore(cfg.legacyClaimsMappers, legacyFinder, cfg.legacyClaimsUrl, cfg.legacyClaimsOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("creating legacy claims store: %w", err)
 		}
@@ -484,7 +564,14 @@ func Construct(sc ServiceConfig, opts ...Option) (Service, error) {
 		legacyClaims = legacy.NewNoResultsClaimsFinder()
 	}
 
-	providerIndex := providerindex.New(providersCache, noProvidersCache, findClient, asyncPublisher, legacyClaims, providerindex.WithLogger(cfg.provIndexLog))
+	provIndexOpts := []providerindex.Option{providerindex.WithLogger(cfg.provIndexLog)}
+	if sc.IPNIStreamingFind {
+		provIndexOpts = append(provIndexOpts, providerindex.WithStreamingFind(sc.IPNIStreamResultLimit))
+	}
+	if sc.StrictSpaceFiltering {
+		provIndexOpts = append(provIndexOpts, providerindex.WithStrictSpaceFiltering())
+	}
+	providerIndex := providerindex.New(providersCache, noProvidersCache, findClient, asyncPublisher, legacyClaims, provIndexOpts...)
 
 	claimsStore := cfg.claimsStore
 	if claimsStore == nil {
@@ -502,33 +589,62 @@ func Construct(sc ServiceConfig, opts ...Option) (Service, error) {
 	blobIndexLookup := blobindexlookup.WithCache(
 		blobindexlookup.NewBlobIndexLookup(httpClient),
 		shardDagIndexesCache,
-		cachingQueue,
+		providercacher.NewShardSplittingQueue(cachingQueue, providercacher.DefaultMaxJobSlices),
 	)
 
+	publicAddrInfo, err := PublicAddrInfo(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	// with concurrency will still get overridden if a different walker setting is used
+	serviceOpts := append([]service.Option{service.WithConcurrency(15)}, cfg.opts...)
+
+	s.IndexingService = service.NewIndexingService(sc.ID, blobIndexLookup, claims, publicAddrInfo, providerIndex, serviceOpts...)
+
+	return s, nil
+}
+
+// PublicAddrInfo returns the peer ID and addresses the indexing service
+// publishes claims to IPNI with, derived from its private key and public URLs.
+func PublicAddrInfo(sc ServiceConfig) (peer.AddrInfo, error) {
 	peerID, err := peer.IDFromPrivateKey(sc.PrivateKey)
 	if err != nil {
-		return nil, fmt.Errorf("creating peer ID: %w", err)
+		return peer.AddrInfo{}, fmt.Errorf("creating peer ID: %w", err)
 	}
 
-	publicAddrInfo := peer.AddrInfo{ID: peerID}
+	addrInfo := peer.AddrInfo{ID: peerID}
 	for _, str := range sc.PublicURL {
 		u, err := url.Parse(str)
 		if err != nil {
-			return nil, fmt.Errorf("parsing public URL: %w", err)
+			return peer.AddrInfo{}, fmt.Errorf("parsing public URL: %w", err)
 		}
 		addr, err := maurl.FromURL(u)
 		if err != nil {
-			return nil, fmt.Errorf("converting URL to multiaddr: %w", err)
+			return peer.AddrInfo{}, fmt.Errorf("converting URL to multiaddr: %w", err)
 		}
-		publicAddrInfo.Addrs = append(publicAddrInfo.Addrs, addr)
+		addrInfo.Addrs = append(addrInfo.Addrs, addr)
 	}
+	return addrInfo, nil
+}
 
-	// with concurrency will still get overridden if a different walker setting is used
-	serviceOpts := append([]service.Option{service.WithConcurrency(15)}, cfg.opts...)
-
-	s.IndexingService = service.NewIndexingService(sc.ID, blobIndexLookup, claims, publicAddrInfo, providerIndex, serviceOpts...)
-
-	return s, nil
+// newIPNIFinder creates an instrumented find client for the IPNI node at the
+// given URL, optionally using double hashed lookups for reader privacy or
+// streaming find responses.
+func newIPNIFinder(url string, httpClient *http.Client, sc ServiceConfig, log logging.EventLogger) (ipnifind.Finder, error) {
+	var finder ipnifind.Finder
+	var err error
+	if sc.IPNIReaderPrivacy {
+		finder, err = providerindex.NewReaderPrivacyFinderFromURL(url, httpClient, providerindex.WithLogger(log))
+	} else if sc.IPNIStreamingFind {
+		finder, err = providerindex.NewNDJSONFinder(url, httpClient)
+	} else {
+		finder, err = ipnifind.New(url, ipnifind.WithClient(httpClient))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return providerindex.NewInstrumentedFinder(url, finder)
 }
 
 func initializeDatastore(cfg *config) datastore.Batching {
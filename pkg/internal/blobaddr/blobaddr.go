@@ -0,0 +1,24 @@
+// Package blobaddr converts the URLs blobs are located at into multiaddrs
+// that can be advertised for any blob stored at the same location.
+package blobaddr
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/ipni/go-libipni/maurl"
+	ma "github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+)
+
+// Placeholder stands in for the digest of a blob in advertised location URLs.
+const Placeholder = "{blob}"
+
+// FromLocation converts the URL the blob with the passed digest is located at
+// into a multiaddr, replacing the digest in its path with [Placeholder] so
+// that the URL of the blob can be reconstructed for fetching.
+func FromLocation(location url.URL, digest mh.Multihash) (ma.Multiaddr, error) {
+	location.Path = strings.ReplaceAll(location.Path, digestutil.Format(digest), Placeholder)
+	return maurl.FromURL(&location)
+}
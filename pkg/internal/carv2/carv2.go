@@ -0,0 +1,63 @@
+// Package carv2 reads the fixed size header at the start of CARv2 files.
+package carv2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Pragma is the fixed prefix of CARv2 files, a CARv1 header declaring
+// version 2.
+var Pragma = []byte{0x0a, 0xa1, 0x67, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x02}
+
+// HeaderSize is the size of the header following the pragma: characteristics
+// (16 bytes), data offset, data size and index offset (8 bytes each).
+const HeaderSize = 40
+
+// ErrNotCARv2 is returned when reading a file that does not start with the
+// CARv2 pragma.
+var ErrNotCARv2 = errors.New("not a CARv2 file")
+
+// Header is the CARv2 header.
+type Header struct {
+	// DataOffset is the offset of the CARv1 data payload from the start of the
+	// file.
+	DataOffset uint64
+	// DataSize is the size of the CARv1 data payload.
+	DataSize uint64
+	// IndexOffset is the offset of the index from the start of the file, or 0
+	// if the file has no index.
+	IndexOffset uint64
+}
+
+// HasPragma reports whether b starts with the CARv2 pragma.
+func HasPragma(b []byte) bool {
+	return bytes.HasPrefix(b, Pragma)
+}
+
+// ReadHeader reads the pragma and header at the start of a CARv2 file,
+// returning ErrNotCARv2 if the file does not start with the pragma.
+func ReadHeader(r io.Reader) (Header, error) {
+	buf := make([]byte, len(Pragma)+HeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Header{}, ErrNotCARv2
+		}
+		return Header{}, fmt.Errorf("reading CARv2 header: %w", err)
+	}
+	if !HasPragma(buf) {
+		return Header{}, ErrNotCARv2
+	}
+	h := Header{
+		DataOffset:  binary.LittleEndian.Uint64(buf[len(Pragma)+16:]),
+		DataSize:    binary.LittleEndian.Uint64(buf[len(Pragma)+24:]),
+		IndexOffset: binary.LittleEndian.Uint64(buf[len(Pragma)+32:]),
+	}
+	if h.DataOffset < uint64(len(buf)) {
+		return Header{}, fmt.Errorf("malformed CARv2 data offset %d", h.DataOffset)
+	}
+	return h, nil
+}
@@ -0,0 +1,110 @@
+package principalparser
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/storacha/go-ucanto/principal"
+	edverifier "github.com/storacha/go-ucanto/principal/ed25519/verifier"
+	rsaverifier "github.com/storacha/go-ucanto/principal/rsa/verifier"
+)
+
+// Ed25519 is the Ed25519 key type.
+var Ed25519 = KeyType{
+	Code:        edverifier.Code,
+	Decode:      edverifier.Decode,
+	PeerKeyType: pb.KeyType_Ed25519,
+	ToPubKey: func(v principal.Verifier) (crypto.PubKey, error) {
+		return crypto.UnmarshalEd25519PublicKey(v.Raw())
+	},
+	FromPubKey: func(pub crypto.PubKey) (principal.Verifier, error) {
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, fmt.Errorf("extracting raw bytes of public key: %w", err)
+		}
+		return edverifier.FromRaw(raw)
+	},
+}
+
+// RSA is the RSA key type. Peer IDs of RSA keys are a hash of the key, so
+// they cannot be converted back into a principal.
+var RSA = KeyType{
+	Code:        rsaverifier.Code,
+	Decode:      rsaverifier.Decode,
+	PeerKeyType: pb.KeyType_RSA,
+	ToPubKey: func(v principal.Verifier) (crypto.PubKey, error) {
+		key, err := x509.ParsePKCS1PublicKey(v.Raw())
+		if err != nil {
+			return nil, fmt.Errorf("parsing RSA public key: %w", err)
+		}
+		pkix, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding RSA public key: %w", err)
+		}
+		return crypto.UnmarshalRsaPublicKey(pkix)
+	},
+	FromPubKey: func(pub crypto.PubKey) (principal.Verifier, error) {
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, fmt.Errorf("extracting raw bytes of public key: %w", err)
+		}
+		key, err := x509.ParsePKIXPublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing RSA public key: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: not an RSA public key", ErrUnsupportedKeyType)
+		}
+		return rsaverifier.FromRaw(x509.MarshalPKCS1PublicKey(rsaKey))
+	},
+}
+
+// Secp256k1 is the secp256k1 key type, verifying ES256K signatures.
+var Secp256k1 = KeyType{
+	Code:        Secp256k1Code,
+	Decode:      DecodeSecp256k1,
+	PeerKeyType: pb.KeyType_Secp256k1,
+	ToPubKey: func(v principal.Verifier) (crypto.PubKey, error) {
+		return crypto.UnmarshalSecp256k1PublicKey(v.Raw())
+	},
+	FromPubKey: func(pub crypto.PubKey) (principal.Verifier, error) {
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, fmt.Errorf("extracting raw bytes of public key: %w", err)
+		}
+		return Secp256k1FromRaw(raw)
+	},
+}
+
+// P256 is the NIST P-256 key type, verifying ES256 signatures.
+var P256 = KeyType{
+	Code:        P256Code,
+	Decode:      DecodeP256,
+	PeerKeyType: pb.KeyType_ECDSA,
+	ToPubKey: func(v principal.Verifier) (crypto.PubKey, error) {
+		pv, err := P256FromRaw(v.Raw())
+		if err != nil {
+			return nil, err
+		}
+		key, err := pv.(P256Verifier).publicKey()
+		if err != nil {
+			return nil, err
+		}
+		pkix, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding P-256 public key: %w", err)
+		}
+		return crypto.UnmarshalECDSAPublicKey(pkix)
+	},
+	FromPubKey: func(pub crypto.PubKey) (principal.Verifier, error) {
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, fmt.Errorf("extracting raw bytes of public key: %w", err)
+		}
+		return P256FromPKIX(raw)
+	},
+}
@@ -0,0 +1,102 @@
+package principalparser
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/multiformat"
+	"github.com/storacha/go-ucanto/ucan/crypto/signature"
+)
+
+// P256Code is the multicodec code of P-256 public keys.
+const P256Code = 0x1200
+
+var p256TagSize = varint.UvarintSize(P256Code)
+
+// DecodeP256 decodes a multicodec tagged, compressed P-256 public key.
+func DecodeP256(b []byte) (principal.Verifier, error) {
+	raw, err := multiformat.UntagWith(P256Code, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	return P256FromRaw(raw)
+}
+
+// P256FromRaw takes a compressed P-256 public key and returns a P-256
+// verifier.
+func P256FromRaw(b []byte) (principal.Verifier, error) {
+	if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), b); x == nil {
+		return nil, fmt.Errorf("invalid compressed P-256 public key")
+	}
+	return P256Verifier(multiformat.TagWith(P256Code, b)), nil
+}
+
+// P256FromPKIX takes a DER encoded PKIX P-256 public key, as used by libp2p,
+// and returns a P-256 verifier.
+func P256FromPKIX(b []byte) (principal.Verifier, error) {
+	key, err := x509.ParsePKIXPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ECDSA public key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: not a P-256 public key", ErrUnsupportedKeyType)
+	}
+	return P256FromRaw(elliptic.MarshalCompressed(ecKey.Curve, ecKey.X, ecKey.Y))
+}
+
+// P256Verifier is a multicodec tagged, compressed P-256 public key verifying
+// ES256 signatures.
+type P256Verifier []byte
+
+func (v P256Verifier) Code() uint64 {
+	return P256Code
+}
+
+// Verify verifies a signature of the SHA-256 hash of msg, encoded as the 64
+// byte concatenation of r and s.
+func (v P256Verifier) Verify(msg []byte, sig signature.Signature) bool {
+	if sig.Code() != signature.ES256 || len(sig.Raw()) != 64 {
+		return false
+	}
+	pub, err := v.publicKey()
+	if err != nil {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig.Raw()[:32])
+	s := new(big.Int).SetBytes(sig.Raw()[32:])
+	hash := sha256.Sum256(msg)
+	return ecdsa.Verify(pub, hash[:], r, s)
+}
+
+// DID returns the did:key of the verifier, which is undefined until go-ucanto
+// supports P-256 keys.
+func (v P256Verifier) DID() did.DID {
+	id, _ := did.Decode(v)
+	return id
+}
+
+func (v P256Verifier) Encode() []byte {
+	return v
+}
+
+func (v P256Verifier) Raw() []byte {
+	k := make([]byte, len(v)-p256TagSize)
+	copy(k, v[p256TagSize:])
+	return k
+}
+
+func (v P256Verifier) publicKey() (*ecdsa.PublicKey, error) {
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), v[p256TagSize:])
+	if x == nil {
+		return nil, fmt.Errorf("invalid compressed P-256 public key")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
@@ -0,0 +1,160 @@
+// Package principalparser parses principals of the key types supported by the
+// indexing service into UCAN verifiers, and converts between principals and
+// libp2p peer IDs.
+//
+// Ed25519 and RSA keys are supported as UCAN issuers, e.g. of claim/cache
+// invocations and location commitments. Secp256k1 and P-256 keys are only
+// supported for converting peer IDs and verifying signatures: go-ucanto cannot
+// decode their did:keys, so UCANs they issue have an undefined issuer and fail
+// validation. Further key types can be added with [Registry.Register].
+package principalparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// ErrUnsupportedKeyType is returned for principals and peer IDs with keys of a
+// type that is not supported.
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+// DecodeFunc decodes a multicodec tagged public key into a verifier.
+type DecodeFunc func(b []byte) (principal.Verifier, error)
+
+// KeyType describes how keys of a type are decoded and converted to and from
+// libp2p keys.
+type KeyType struct {
+	// Code is the multicodec code of public keys of the type.
+	Code uint64
+	// Decode decodes a multicodec tagged public key into a verifier.
+	Decode DecodeFunc
+	// PeerKeyType is the libp2p type of keys of the type.
+	PeerKeyType pb.KeyType
+	// ToPubKey converts a verifier into a libp2p public key.
+	ToPubKey func(v principal.Verifier) (crypto.PubKey, error)
+	// FromPubKey converts a libp2p public key into a verifier.
+	FromPubKey func(pub crypto.PubKey) (principal.Verifier, error)
+}
+
+// Registry maps multicodec key type codes to the key types supported by the
+// parser.
+type Registry struct {
+	keyTypes map[uint64]KeyType
+}
+
+// NewRegistry creates a registry with Ed25519, RSA, secp256k1 and P-256 keys.
+func NewRegistry() *Registry {
+	r := &Registry{keyTypes: map[uint64]KeyType{}}
+	for _, kt := range []KeyType{Ed25519, RSA, Secp256k1, P256} {
+		r.Register(kt)
+	}
+	return r
+}
+
+// Register adds or replaces a key type.
+func (r *Registry) Register(kt KeyType) {
+	r.keyTypes[kt.Code] = kt
+}
+
+// Decode decodes a multicodec tagged public key into a verifier.
+func (r *Registry) Decode(b []byte) (principal.Verifier, error) {
+	code, _, err := varint.FromUvarint(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading key type: %w", err)
+	}
+	kt, ok := r.keyTypes[code]
+	if !ok {
+		return nil, fmt.Errorf("%w: 0x%x", ErrUnsupportedKeyType, code)
+	}
+	return kt.Decode(b)
+}
+
+// Parse parses a did:key into a verifier. It can be used as a
+// go-ucanto validator.PrincipalParserFunc.
+//
+// The key is decoded by the registry rather than by go-ucanto, so that key
+// types go-ucanto does not know about reach their registered decoder.
+func (r *Registry) Parse(str string) (principal.Verifier, error) {
+	key, ok := strings.CutPrefix(str, did.KeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("not a did:key: %s", str)
+	}
+	encoding, b, err := mbase.Decode(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding did:key: %w", err)
+	}
+	if encoding != mbase.Base58BTC {
+		return nil, fmt.Errorf("did:key is not base58btc encoded: %s", str)
+	}
+	return r.Decode(b)
+}
+
+// ToPeerID converts a did:key principal into a peer ID.
+func (r *Registry) ToPeerID(p ucan.Principal) (peer.ID, error) {
+	vfr, ok := p.(principal.Verifier)
+	if !ok {
+		var err error
+		vfr, err = r.Decode(p.DID().Bytes())
+		if err != nil {
+			return "", err
+		}
+	}
+	kt, ok := r.keyTypes[vfr.Code()]
+	if !ok || kt.ToPubKey == nil {
+		return "", fmt.Errorf("%w: 0x%x", ErrUnsupportedKeyType, vfr.Code())
+	}
+	pub, err := kt.ToPubKey(vfr)
+	if err != nil {
+		return "", fmt.Errorf("converting to libp2p public key: %w", err)
+	}
+	return peer.IDFromPublicKey(pub)
+}
+
+// FromPeerID converts a peer ID into a UCAN principal, with the public key
+// embedded in the peer ID. Peer IDs of large keys, like RSA and P-256 keys,
+// are a hash of the key and do not embed it, so they are not supported.
+func (r *Registry) FromPeerID(id peer.ID) (principal.Verifier, error) {
+	pub, err := id.ExtractPublicKey()
+	if err != nil {
+		if errors.Is(err, peer.ErrNoPublicKey) {
+			return nil, fmt.Errorf("%w: public key is not embedded in peer ID %s", ErrUnsupportedKeyType, id)
+		}
+		return nil, fmt.Errorf("extracting public key from peer ID: %w", err)
+	}
+	for _, kt := range r.keyTypes {
+		if kt.PeerKeyType == pub.Type() && kt.FromPubKey != nil {
+			return kt.FromPubKey(pub)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, pub.Type())
+}
+
+// Default is the registry used by the package level functions.
+var Default = NewRegistry()
+
+// Parse parses a did:key into a verifier using the [Default] registry.
+func Parse(str string) (principal.Verifier, error) {
+	return Default.Parse(str)
+}
+
+// FromPeerID converts a peer ID into a UCAN principal using the [Default]
+// registry.
+func FromPeerID(id peer.ID) (principal.Verifier, error) {
+	return Default.FromPeerID(id)
+}
+
+// ToPeerID converts a did:key principal into a peer ID using the [Default]
+// registry.
+func ToPeerID(p ucan.Principal) (peer.ID, error) {
+	return Default.ToPeerID(p)
+}
@@ -0,0 +1,164 @@
+package principalparser
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/multiformat"
+	rsasigner "github.com/storacha/go-ucanto/principal/rsa/signer"
+	"github.com/storacha/go-ucanto/ucan/crypto/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	rsa := testutil.Must(rsasigner.Generate())(t)
+
+	for _, signer := range []principal.Signer{testutil.Alice, rsa} {
+		vfr, err := Parse(signer.DID().String())
+		require.NoError(t, err)
+		require.Equal(t, signer.DID(), vfr.DID())
+		require.Equal(t, signer.Verifier().Code(), vfr.Code())
+
+		msg := []byte("hello")
+		require.True(t, vfr.Verify(msg, signer.Sign(msg)))
+	}
+
+	_, err := Parse("did:web:example.com")
+	require.Error(t, err)
+}
+
+func TestPeerIDConversion(t *testing.T) {
+	id, err := ToPeerID(testutil.Alice)
+	require.NoError(t, err)
+
+	vfr, err := FromPeerID(id)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Alice.DID(), vfr.DID())
+
+	t.Run("RSA principals", func(t *testing.T) {
+		rsa := testutil.Must(rsasigner.Generate())(t)
+		id, err := ToPeerID(rsa)
+		require.NoError(t, err)
+
+		// the peer ID is a hash of the key, so it can't be converted back
+		_, err = FromPeerID(id)
+		require.ErrorIs(t, err, ErrUnsupportedKeyType)
+	})
+
+	t.Run("secp256k1 principals", func(t *testing.T) {
+		_, pub, err := crypto.GenerateSecp256k1Key(rand.Reader)
+		require.NoError(t, err)
+		id, err := peer.IDFromPublicKey(pub)
+		require.NoError(t, err)
+
+		vfr, err := FromPeerID(id)
+		require.NoError(t, err)
+		require.Equal(t, uint64(Secp256k1Code), vfr.Code())
+
+		rid, err := ToPeerID(vfr)
+		require.NoError(t, err)
+		require.Equal(t, id, rid)
+	})
+
+	t.Run("P-256 principals", func(t *testing.T) {
+		_, pub, err := crypto.GenerateECDSAKeyPairWithCurve(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		id, err := peer.IDFromPublicKey(pub)
+		require.NoError(t, err)
+
+		// the peer ID is a hash of the key, so it can't be converted back
+		_, err = FromPeerID(id)
+		require.ErrorIs(t, err, ErrUnsupportedKeyType)
+	})
+}
+
+func TestParseSecp256k1(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	str := didKey(t, Secp256k1Code, key.PubKey().SerializeCompressed())
+
+	vfr, err := Parse(str)
+	require.NoError(t, err)
+	require.Equal(t, uint64(Secp256k1Code), vfr.Code())
+
+	msg := []byte("hello")
+	hash := sha256.Sum256(msg)
+	sig := secpecdsa.Sign(key, hash[:])
+	r, s := sig.R(), sig.S()
+	rb, sb := r.Bytes(), s.Bytes()
+	raw := append(rb[:], sb[:]...)
+	require.True(t, vfr.Verify(msg, signature.NewSignature(signature.ES256K, raw)))
+	require.False(t, vfr.Verify([]byte("other"), signature.NewSignature(signature.ES256K, raw)))
+	require.False(t, vfr.Verify(msg, signature.NewSignature(signature.ES256, raw)))
+}
+
+func TestParseP256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	str := didKey(t, P256Code, elliptic.MarshalCompressed(key.Curve, key.X, key.Y))
+
+	vfr, err := Parse(str)
+	require.NoError(t, err)
+	require.Equal(t, uint64(P256Code), vfr.Code())
+
+	msg := []byte("hello")
+	hash := sha256.Sum256(msg)
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	s.FillBytes(raw[32:])
+	require.True(t, vfr.Verify(msg, signature.NewSignature(signature.ES256, raw)))
+	require.False(t, vfr.Verify([]byte("other"), signature.NewSignature(signature.ES256, raw)))
+
+	_, err = ToPeerID(vfr)
+	require.NoError(t, err)
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("dispatches to registered decoders", func(t *testing.T) {
+		const code = 0x300000
+		var decoded []byte
+		r := NewRegistry()
+		r.Register(KeyType{
+			Code: code,
+			Decode: func(b []byte) (principal.Verifier, error) {
+				decoded = b
+				return testutil.Alice.Verifier(), nil
+			},
+		})
+
+		str := didKey(t, code, []byte("key"))
+		vfr, err := r.Parse(str)
+		require.NoError(t, err)
+		require.Equal(t, testutil.Alice.DID(), vfr.DID())
+		require.Equal(t, multiformat.TagWith(code, []byte("key")), decoded)
+
+		_, err = Parse(str)
+		require.ErrorIs(t, err, ErrUnsupportedKeyType)
+	})
+
+	t.Run("rejects non base58btc keys", func(t *testing.T) {
+		b := multiformat.TagWith(Secp256k1Code, make([]byte, 33))
+		str, err := mbase.Encode(mbase.Base32, b)
+		require.NoError(t, err)
+		_, err = Parse("did:key:" + str)
+		require.Error(t, err)
+	})
+}
+
+func didKey(t *testing.T, code uint64, raw []byte) string {
+	str, err := mbase.Encode(mbase.Base58BTC, multiformat.TagWith(code, raw))
+	require.NoError(t, err)
+	return "did:key:" + str
+}
@@ -0,0 +1,81 @@
+package principalparser
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/multiformat"
+	"github.com/storacha/go-ucanto/ucan/crypto/signature"
+)
+
+// Secp256k1Code is the multicodec code of secp256k1 public keys.
+const Secp256k1Code = 0xe7
+
+var secp256k1TagSize = varint.UvarintSize(Secp256k1Code)
+
+// DecodeSecp256k1 decodes a multicodec tagged, compressed secp256k1 public key.
+func DecodeSecp256k1(b []byte) (principal.Verifier, error) {
+	raw, err := multiformat.UntagWith(Secp256k1Code, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Secp256k1FromRaw(raw)
+}
+
+// Secp256k1FromRaw takes a compressed or uncompressed secp256k1 public key
+// and returns a secp256k1 verifier.
+func Secp256k1FromRaw(b []byte) (principal.Verifier, error) {
+	pub, err := secp256k1.ParsePubKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing secp256k1 public key: %w", err)
+	}
+	return Secp256k1Verifier(multiformat.TagWith(Secp256k1Code, pub.SerializeCompressed())), nil
+}
+
+// Secp256k1Verifier is a multicodec tagged, compressed secp256k1 public key
+// verifying ES256K signatures.
+type Secp256k1Verifier []byte
+
+func (v Secp256k1Verifier) Code() uint64 {
+	return Secp256k1Code
+}
+
+// Verify verifies a signature of the SHA-256 hash of msg, encoded as the
+// 64 byte concatenation of r and s.
+func (v Secp256k1Verifier) Verify(msg []byte, sig signature.Signature) bool {
+	if sig.Code() != signature.ES256K || len(sig.Raw()) != 64 {
+		return false
+	}
+	pub, err := secp256k1.ParsePubKey(v[secp256k1TagSize:])
+	if err != nil {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(sig.Raw()[:32]) || s.SetByteSlice(sig.Raw()[32:]) {
+		return false
+	}
+	hash := sha256.Sum256(msg)
+	return ecdsa.NewSignature(&r, &s).Verify(hash[:], pub)
+}
+
+// DID returns the did:key of the verifier, which is undefined until go-ucanto
+// supports secp256k1 keys.
+func (v Secp256k1Verifier) DID() did.DID {
+	id, _ := did.Decode(v)
+	return id
+}
+
+func (v Secp256k1Verifier) Encode() []byte {
+	return v
+}
+
+func (v Secp256k1Verifier) Raw() []byte {
+	k := make([]byte, len(v)-secp256k1TagSize)
+	copy(k, v[secp256k1TagSize:])
+	return k
+}
@@ -0,0 +1,213 @@
+package principalresolver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/validator"
+	"golang.org/x/sync/singleflight"
+)
+
+var log = logging.Logger("principalresolver")
+
+// DefaultRefreshInterval is the default age after which a [RefreshingResolver]
+// resolves a DID again.
+const DefaultRefreshInterval = time.Hour
+
+// DefaultFailureTTL is the default time for which a [RefreshingResolver]
+// remembers a failed resolution before trying to resolve the DID again.
+const DefaultFailureTTL = 30 * time.Second
+
+// DefaultMaxStaleAge is the default maximum age of a resolution that a
+// [RefreshingResolver] continues to use when refreshing it fails.
+const DefaultMaxStaleAge = 24 * time.Hour
+
+// DefaultResolveTimeout is the default time a [RefreshingResolver] waits for
+// a DID to be resolved.
+const DefaultResolveTimeout = 30 * time.Second
+
+var _ validator.PrincipalResolver = (*RefreshingResolver)(nil)
+
+type resolution struct {
+	key        did.DID
+	resolvedAt time.Time
+	// err is the error of the last failed resolution, if it failed after the
+	// last successful one.
+	err      validator.UnresolvedDID
+	failedAt time.Time
+}
+
+type outcome struct {
+	key did.DID
+	err validator.UnresolvedDID
+}
+
+// RefreshingResolver caches the resolutions of another resolver, resolving
+// DIDs again once their resolution is older than the refresh interval. If a
+// refresh fails, the last successful resolution continues to be used until it
+// is older than the maximum stale age, so that a DID document host being
+// briefly unavailable does not break validation. Failures are remembered for
+// the failure TTL, and concurrent resolutions of the same DID are shared, so
+// that an unavailable host is not hit by every request. Shared resolutions
+// are not canceled with the request that started them.
+type RefreshingResolver struct {
+	resolver    validator.PrincipalResolver
+	interval    time.Duration
+	failureTTL  time.Duration
+	maxStaleAge time.Duration
+	timeout     time.Duration
+	clock       clock.Clock
+	group       singleflight.Group
+	mutex       sync.RWMutex
+	resolutions map[did.DID]resolution
+}
+
+// RefreshingOption configures a [RefreshingResolver].
+type RefreshingOption func(*RefreshingResolver)
+
+// WithRefreshInterval sets the age after which DIDs are resolved again.
+func WithRefreshInterval(interval time.Duration) RefreshingOption {
+	return func(r *RefreshingResolver) {
+		r.interval = interval
+	}
+}
+
+// WithFailureTTL sets the time for which a failed resolution is remembered
+// before the DID is resolved again.
+func WithFailureTTL(ttl time.Duration) RefreshingOption {
+	return func(r *RefreshingResolver) {
+		r.failureTTL = ttl
+	}
+}
+
+// WithMaxStaleAge sets the maximum age of a resolution that continues to be
+// used when refreshing it fails.
+func WithMaxStaleAge(age time.Duration) RefreshingOption {
+	return func(r *RefreshingResolver) {
+		r.maxStaleAge = age
+	}
+}
+
+// WithResolveTimeout sets the time to wait for a DID to be resolved.
+func WithResolveTimeout(timeout time.Duration) RefreshingOption {
+	return func(r *RefreshingResolver) {
+		r.timeout = timeout
+	}
+}
+
+// WithClock sets the clock used to determine the age of resolutions.
+func WithClock(clock clock.Clock) RefreshingOption {
+	return func(r *RefreshingResolver) {
+		r.clock = clock
+	}
+}
+
+// NewRefreshingResolver wraps the passed resolver with a cache that is
+// refreshed periodically.
+func NewRefreshingResolver(resolver validator.PrincipalResolver, opts ...RefreshingOption) *RefreshingResolver {
+	r := &RefreshingResolver{
+		resolver:    resolver,
+		interval:    DefaultRefreshInterval,
+		failureTTL:  DefaultFailureTTL,
+		maxStaleAge: DefaultMaxStaleAge,
+		timeout:     DefaultResolveTimeout,
+		clock:       clock.New(),
+		resolutions: map[did.DID]resolution{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *RefreshingResolver) ResolveDIDKey(ctx context.Context, input did.DID) (did.DID, validator.UnresolvedDID) {
+	if res, ok := r.cached(input); ok {
+		return res.key, res.err
+	}
+
+	ch := r.group.DoChan(input.String(), func() (any, error) {
+		// another caller may have resolved the DID while this one was waiting
+		if res, ok := r.cached(input); ok {
+			return res, nil
+		}
+		// the resolution is shared with other callers, so it must not fail
+		// because the caller that started it went away
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+		defer cancel()
+		key, err := r.resolver.ResolveDIDKey(rctx, input)
+		return r.record(input, key, err), nil
+	})
+	select {
+	case v := <-ch:
+		res := v.Val.(outcome)
+		return res.key, res.err
+	case <-ctx.Done():
+		return did.Undef, validator.NewDIDKeyResolutionError(input, ctx.Err())
+	}
+}
+
+// cached returns the cached outcome of resolving the DID, if the resolution
+// is fresh or a failure to refresh it is still remembered.
+func (r *RefreshingResolver) cached(input did.DID) (outcome, bool) {
+	r.mutex.RLock()
+	cached, ok := r.resolutions[input]
+	r.mutex.RUnlock()
+	if !ok {
+		return outcome{}, false
+	}
+	now := r.clock.Now()
+	if cached.err == nil && now.Sub(cached.resolvedAt) < r.interval {
+		return outcome{key: cached.key}, true
+	}
+	if cached.err != nil && now.Sub(cached.failedAt) < r.failureTTL {
+		return r.fallback(cached, now), true
+	}
+	return outcome{}, false
+}
+
+// record stores the result of resolving the DID and returns the outcome to
+// report to callers.
+func (r *RefreshingResolver) record(input did.DID, key did.DID, err validator.UnresolvedDID) outcome {
+	now := r.clock.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err == nil {
+		r.resolutions[input] = resolution{key: key, resolvedAt: now}
+		return outcome{key: key}
+	}
+
+	r.prune(now)
+	cached := r.resolutions[input]
+	cached.err = err
+	cached.failedAt = now
+	r.resolutions[input] = cached
+	res := r.fallback(cached, now)
+	if res.err == nil {
+		log.Warnw("failed to refresh DID resolution, using previous resolution", "did", input, "err", err)
+	}
+	return res
+}
+
+// fallback returns the previous resolution of a DID whose last resolution
+// failed, if it is not too old, and the failure otherwise.
+func (r *RefreshingResolver) fallback(cached resolution, now time.Time) outcome {
+	if !cached.resolvedAt.IsZero() && now.Sub(cached.resolvedAt) < r.maxStaleAge {
+		return outcome{key: cached.key}
+	}
+	return outcome{key: did.Undef, err: cached.err}
+}
+
+// prune removes remembered failures that have expired and have no usable
+// previous resolution, so that DIDs that never resolve do not accumulate.
+// It must be called with the mutex held.
+func (r *RefreshingResolver) prune(now time.Time) {
+	for input, cached := range r.resolutions {
+		if cached.err != nil && now.Sub(cached.failedAt) >= r.failureTTL && now.Sub(cached.resolvedAt) >= r.maxStaleAge {
+			delete(r.resolutions, input)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package principalresolver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/validator"
+	"github.com/stretchr/testify/require"
+)
+
+type countingResolver struct {
+	key   did.DID
+	err   error
+	calls int
+	// block, if set, is waited on before resolving
+	block chan struct{}
+	mutex sync.Mutex
+}
+
+func (r *countingResolver) ResolveDIDKey(ctx context.Context, input did.DID) (did.DID, validator.UnresolvedDID) {
+	if r.block != nil {
+		select {
+		case <-r.block:
+		case <-ctx.Done():
+			return did.Undef, validator.NewDIDKeyResolutionError(input, ctx.Err())
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls++
+	if r.err != nil {
+		return did.Undef, validator.NewDIDKeyResolutionError(input, r.err)
+	}
+	return r.key, nil
+}
+
+func TestRefreshingResolver(t *testing.T) {
+	webDID, err := did.Parse("did:web:example.com")
+	require.NoError(t, err)
+	k0, err := did.Parse("did:key:z6MkghfetkhrBZwUupJrv8MmYDH1JhKCQCGj1trbaZPA3dAd")
+	require.NoError(t, err)
+	k1, err := did.Parse("did:key:z6MkrZ1r5XBFZjBU34qyD8fueMbMRkKw17BZaq2ivKFjnz2z")
+	require.NoError(t, err)
+
+	t.Run("caches resolutions", func(t *testing.T) {
+		inner := &countingResolver{key: k0}
+		r := NewRefreshingResolver(inner, WithClock(clock.NewMock()))
+
+		for range 3 {
+			resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+			require.NoError(t, err)
+			require.Equal(t, k0, resolved)
+		}
+		require.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("refreshes after interval", func(t *testing.T) {
+		clk := clock.NewMock()
+		inner := &countingResolver{key: k0}
+		r := NewRefreshingResolver(inner, WithClock(clk), WithRefreshInterval(time.Minute))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+
+		inner.key = k1
+		clk.Add(time.Minute)
+
+		resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+		require.Equal(t, k1, resolved)
+		require.Equal(t, 2, inner.calls)
+	})
+
+	t.Run("keeps previous resolution when refresh fails", func(t *testing.T) {
+		clk := clock.NewMock()
+		inner := &countingResolver{key: k0}
+		r := NewRefreshingResolver(inner, WithClock(clk), WithRefreshInterval(time.Minute))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+
+		inner.err = errors.New("unavailable")
+		clk.Add(time.Minute)
+
+		resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+		require.Equal(t, k0, resolved)
+	})
+
+	t.Run("returns error when never resolved", func(t *testing.T) {
+		inner := &countingResolver{err: errors.New("unavailable")}
+		r := NewRefreshingResolver(inner, WithClock(clock.NewMock()))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.Error(t, err)
+	})
+
+	t.Run("stops using previous resolution after max stale age", func(t *testing.T) {
+		clk := clock.NewMock()
+		inner := &countingResolver{key: k0}
+		r := NewRefreshingResolver(inner, WithClock(clk), WithRefreshInterval(time.Minute), WithMaxStaleAge(time.Hour))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+
+		inner.err = errors.New("unavailable")
+		clk.Add(time.Hour)
+
+		_, err = r.ResolveDIDKey(t.Context(), webDID)
+		require.Error(t, err)
+	})
+
+	t.Run("remembers failures for the failure TTL", func(t *testing.T) {
+		clk := clock.NewMock()
+		inner := &countingResolver{err: errors.New("unavailable")}
+		r := NewRefreshingResolver(inner, WithClock(clk), WithFailureTTL(time.Minute))
+
+		for range 3 {
+			_, err := r.ResolveDIDKey(t.Context(), webDID)
+			require.Error(t, err)
+		}
+		require.Equal(t, 1, inner.calls)
+
+		inner.err = nil
+		inner.key = k0
+		clk.Add(time.Minute)
+
+		resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+		require.Equal(t, k0, resolved)
+		require.Equal(t, 2, inner.calls)
+	})
+
+	t.Run("does not retry failed refreshes within the failure TTL", func(t *testing.T) {
+		clk := clock.NewMock()
+		inner := &countingResolver{key: k0}
+		r := NewRefreshingResolver(inner, WithClock(clk), WithRefreshInterval(time.Minute), WithFailureTTL(time.Minute))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+
+		inner.err = errors.New("unavailable")
+		clk.Add(time.Minute)
+
+		for range 3 {
+			resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+			require.NoError(t, err)
+			require.Equal(t, k0, resolved)
+		}
+		require.Equal(t, 2, inner.calls)
+	})
+
+	t.Run("shares concurrent resolutions", func(t *testing.T) {
+		inner := &countingResolver{key: k0, block: make(chan struct{})}
+		r := NewRefreshingResolver(inner, WithClock(clock.NewMock()))
+
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+				require.NoError(t, err)
+				require.Equal(t, k0, resolved)
+			}()
+		}
+		// give the callers time to join the in flight resolution
+		time.Sleep(50 * time.Millisecond)
+		close(inner.block)
+		wg.Wait()
+		require.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("does not cancel shared resolutions with their caller", func(t *testing.T) {
+		inner := &countingResolver{key: k0, block: make(chan struct{})}
+		r := NewRefreshingResolver(inner, WithClock(clock.NewMock()))
+
+		ctx, cancel := context.WithCancel(t.Context())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := r.ResolveDIDKey(ctx, webDID)
+			errs <- err
+		}()
+		// give the caller time to start the resolution, then go away
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+
+		close(inner.block)
+		resolved, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.NoError(t, err)
+		require.Equal(t, k0, resolved)
+		require.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("times out resolutions", func(t *testing.T) {
+		inner := &countingResolver{key: k0, block: make(chan struct{})}
+		r := NewRefreshingResolver(inner, WithClock(clock.NewMock()), WithResolveTimeout(10*time.Millisecond))
+
+		_, err := r.ResolveDIDKey(t.Context(), webDID)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
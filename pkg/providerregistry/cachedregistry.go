@@ -0,0 +1,117 @@
+package providerregistry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// DefaultCacheTTL is the default duration a [CachedRegistry] serves providers
+// from memory before listing them again.
+const DefaultCacheTTL = time.Minute
+
+var _ types.ProviderRegistry = (*CachedRegistry)(nil)
+
+// CachedRegistry keeps an in memory copy of the providers in another registry,
+// so that lookups on the query path do not hit the underlying store. Changes
+// made through the cached registry are visible immediately, changes made
+// elsewhere are visible after the TTL.
+type CachedRegistry struct {
+	registry  types.ProviderRegistry
+	ttl       time.Duration
+	clock     clock.Clock
+	mutex     sync.Mutex
+	providers []types.StorageProvider
+	expires   time.Time
+}
+
+// CachedRegistryOption configures a [CachedRegistry].
+type CachedRegistryOption func(*CachedRegistry)
+
+// WithCacheTTL sets how long providers are served from memory.
+func WithCacheTTL(ttl time.Duration) CachedRegistryOption {
+	return func(r *CachedRegistry) {
+		r.ttl = ttl
+	}
+}
+
+// WithClock sets the clock used to expire the cache.
+func WithClock(clock clock.Clock) CachedRegistryOption {
+	return func(r *CachedRegistry) {
+		r.clock = clock
+	}
+}
+
+// NewCachedRegistry wraps the passed registry with an in memory cache.
+func NewCachedRegistry(registry types.ProviderRegistry, opts ...CachedRegistryOption) *CachedRegistry {
+	r := &CachedRegistry{registry: registry, ttl: DefaultCacheTTL, clock: clock.New()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Get implements types.ProviderRegistry.
+func (r *CachedRegistry) Get(ctx context.Context, provider did.DID) (types.StorageProvider, error) {
+	providers, err := r.List(ctx)
+	if err != nil {
+		return types.StorageProvider{}, err
+	}
+	for _, sp := range providers {
+		if sp.DID == provider {
+			return sp, nil
+		}
+	}
+	return types.StorageProvider{}, types.ErrKeyNotFound
+}
+
+// Find implements types.ProviderRegistry.
+func (r *CachedRegistry) Find(ctx context.Context, peerID peer.ID) (types.StorageProvider, error) {
+	providers, err := r.List(ctx)
+	if err != nil {
+		return types.StorageProvider{}, err
+	}
+	return findIn(providers, peerID)
+}
+
+// List implements types.ProviderRegistry.
+func (r *CachedRegistry) List(ctx context.Context) ([]types.StorageProvider, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.providers != nil && r.clock.Now().Before(r.expires) {
+		return r.providers, nil
+	}
+	providers, err := r.registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if providers == nil {
+		providers = []types.StorageProvider{}
+	}
+	r.providers = providers
+	r.expires = r.clock.Now().Add(r.ttl)
+	return providers, nil
+}
+
+// Put implements types.ProviderRegistry.
+func (r *CachedRegistry) Put(ctx context.Context, provider types.StorageProvider) error {
+	defer r.invalidate()
+	return r.registry.Put(ctx, provider)
+}
+
+// Delete implements types.ProviderRegistry.
+func (r *CachedRegistry) Delete(ctx context.Context, provider did.DID) error {
+	defer r.invalidate()
+	return r.registry.Delete(ctx, provider)
+}
+
+func (r *CachedRegistry) invalidate() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.providers = nil
+}
@@ -0,0 +1,102 @@
+package providerregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+var _ types.ProviderRegistry = (*DatastoreRegistry)(nil)
+
+// DatastoreRegistry is a [types.ProviderRegistry] that stores providers as
+// JSON in a datastore, keyed by DID.
+type DatastoreRegistry struct {
+	ds datastore.Datastore
+}
+
+// NewDatastoreRegistry creates a registry backed by the passed datastore. The
+// datastore should be namespaced for the registry.
+func NewDatastoreRegistry(ds datastore.Datastore) *DatastoreRegistry {
+	return &DatastoreRegistry{ds: ds}
+}
+
+// Get implements types.ProviderRegistry.
+func (r *DatastoreRegistry) Get(ctx context.Context, provider did.DID) (types.StorageProvider, error) {
+	data, err := r.ds.Get(ctx, providerKey(provider))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return types.StorageProvider{}, types.ErrKeyNotFound
+		}
+		return types.StorageProvider{}, fmt.Errorf("getting provider: %w", err)
+	}
+	var sp types.StorageProvider
+	if err := json.Unmarshal(data, &sp); err != nil {
+		return types.StorageProvider{}, fmt.Errorf("deserializing provider: %w", err)
+	}
+	return sp, nil
+}
+
+// Find implements types.ProviderRegistry.
+func (r *DatastoreRegistry) Find(ctx context.Context, peerID peer.ID) (types.StorageProvider, error) {
+	providers, err := r.List(ctx)
+	if err != nil {
+		return types.StorageProvider{}, err
+	}
+	return findIn(providers, peerID)
+}
+
+// List implements types.ProviderRegistry.
+func (r *DatastoreRegistry) List(ctx context.Context) ([]types.StorageProvider, error) {
+	results, err := r.ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, fmt.Errorf("querying providers: %w", err)
+	}
+	defer results.Close()
+
+	var providers []types.StorageProvider
+	for res := range results.Next() {
+		if res.Error != nil {
+			return nil, fmt.Errorf("reading providers: %w", res.Error)
+		}
+		var sp types.StorageProvider
+		if err := json.Unmarshal(res.Value, &sp); err != nil {
+			return nil, fmt.Errorf("deserializing provider %s: %w", res.Key, err)
+		}
+		providers = append(providers, sp)
+	}
+	return providers, nil
+}
+
+// Put implements types.ProviderRegistry.
+func (r *DatastoreRegistry) Put(ctx context.Context, provider types.StorageProvider) error {
+	if err := Validate(provider); err != nil {
+		return err
+	}
+	data, err := json.Marshal(provider)
+	if err != nil {
+		return fmt.Errorf("serializing provider: %w", err)
+	}
+	if err := r.ds.Put(ctx, providerKey(provider.DID), data); err != nil {
+		return fmt.Errorf("storing provider: %w", err)
+	}
+	return nil
+}
+
+// Delete implements types.ProviderRegistry.
+func (r *DatastoreRegistry) Delete(ctx context.Context, provider did.DID) error {
+	if err := r.ds.Delete(ctx, providerKey(provider)); err != nil {
+		return fmt.Errorf("deleting provider: %w", err)
+	}
+	return nil
+}
+
+func providerKey(provider did.DID) datastore.Key {
+	return datastore.NewKey(provider.String())
+}
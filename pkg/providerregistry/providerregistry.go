@@ -0,0 +1,95 @@
+// Package providerregistry stores the storage providers allowed to cache
+// claims with the indexing service, along with the peer IDs and endpoints they
+// may use and whether they are active or suspended.
+package providerregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// Validate ensures a provider has a DID, at least one peer ID, a known status
+// and well formed endpoints.
+func Validate(provider types.StorageProvider) error {
+	if !provider.DID.Defined() {
+		return errors.New("missing provider DID")
+	}
+	if len(provider.PeerIDs) == 0 {
+		return errors.New("missing provider peer IDs")
+	}
+	switch provider.Status {
+	case types.ProviderStatusActive, types.ProviderStatusSuspended:
+	default:
+		return fmt.Errorf("unknown provider status: %q", provider.Status)
+	}
+	for _, endpoint := range provider.Endpoints {
+		if _, err := multiaddr.NewMultiaddr(endpoint); err != nil {
+			return fmt.Errorf("parsing provider endpoint %q: %w", endpoint, err)
+		}
+	}
+	return nil
+}
+
+// Authorize ensures the provider may cache claims for the passed peer, which
+// must be one of its peer IDs, using only its registered endpoints. It returns
+// an error wrapping [types.ErrUnauthorizedProvider] if not.
+func Authorize(provider types.StorageProvider, addrInfo peer.AddrInfo) error {
+	if provider.Status != types.ProviderStatusActive {
+		return fmt.Errorf("%w: provider %s is %s", types.ErrUnauthorizedProvider, provider.DID, provider.Status)
+	}
+	if !slices.Contains(provider.PeerIDs, addrInfo.ID) {
+		return fmt.Errorf("%w: peer %s is not registered for provider %s", types.ErrUnauthorizedProvider, addrInfo.ID, provider.DID)
+	}
+	if len(provider.Endpoints) == 0 {
+		return nil
+	}
+	for _, addr := range addrInfo.Addrs {
+		if !slices.Contains(provider.Endpoints, addr.String()) {
+			return fmt.Errorf("%w: endpoint %s is not registered for provider %s", types.ErrUnauthorizedProvider, addr, provider.DID)
+		}
+	}
+	return nil
+}
+
+// LoadFile adds the providers in the JSON file at path, a list of
+// [types.StorageProvider], to the registry. Providers are validated before any
+// are added, so an invalid file leaves the registry unchanged.
+func LoadFile(ctx context.Context, path string, registry types.ProviderRegistry) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading provider registry file: %w", err)
+	}
+	var providers []types.StorageProvider
+	if err := json.Unmarshal(data, &providers); err != nil {
+		return fmt.Errorf("parsing provider registry file: %w", err)
+	}
+	for _, provider := range providers {
+		if err := Validate(provider); err != nil {
+			return fmt.Errorf("invalid provider %s: %w", provider.DID, err)
+		}
+	}
+	for _, provider := range providers {
+		if err := registry.Put(ctx, provider); err != nil {
+			return fmt.Errorf("adding provider %s: %w", provider.DID, err)
+		}
+	}
+	return nil
+}
+
+// findIn returns the provider with the passed peer ID from the list.
+func findIn(providers []types.StorageProvider, peerID peer.ID) (types.StorageProvider, error) {
+	for _, provider := range providers {
+		if slices.Contains(provider.PeerIDs, peerID) {
+			return provider, nil
+		}
+	}
+	return types.StorageProvider{}, types.ErrKeyNotFound
+}
@@ -0,0 +1,148 @@
+package providerregistry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func randomProvider(t *testing.T) types.StorageProvider {
+	return types.StorageProvider{
+		DID:       testutil.RandomDID(t),
+		PeerIDs:   []peer.ID{testutil.RandomPeer(t)},
+		Endpoints: []string{testutil.RandomMultiaddr(t).String()},
+		Status:    types.ProviderStatusActive,
+	}
+}
+
+func TestDatastoreRegistry(t *testing.T) {
+	registry := NewDatastoreRegistry(datastore.NewMapDatastore())
+	provider := randomProvider(t)
+
+	_, err := registry.Get(t.Context(), provider.DID)
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	require.NoError(t, registry.Put(t.Context(), provider))
+
+	got, err := registry.Get(t.Context(), provider.DID)
+	require.NoError(t, err)
+	require.Equal(t, provider, got)
+
+	got, err = registry.Find(t.Context(), provider.PeerIDs[0])
+	require.NoError(t, err)
+	require.Equal(t, provider, got)
+
+	providers, err := registry.List(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []types.StorageProvider{provider}, providers)
+
+	require.NoError(t, registry.Delete(t.Context(), provider.DID))
+	_, err = registry.Find(t.Context(), provider.PeerIDs[0])
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	t.Run("rejects invalid providers", func(t *testing.T) {
+		invalid := randomProvider(t)
+		invalid.Status = "unknown"
+		require.Error(t, registry.Put(t.Context(), invalid))
+
+		invalid = randomProvider(t)
+		invalid.PeerIDs = nil
+		require.Error(t, registry.Put(t.Context(), invalid))
+
+		invalid = randomProvider(t)
+		invalid.Endpoints = []string{"not a multiaddr"}
+		require.Error(t, registry.Put(t.Context(), invalid))
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	provider := randomProvider(t)
+	addr := ma.StringCast(provider.Endpoints[0])
+
+	require.NoError(t, Authorize(provider, peer.AddrInfo{ID: provider.PeerIDs[0], Addrs: []ma.Multiaddr{addr}}))
+
+	err := Authorize(provider, peer.AddrInfo{ID: testutil.RandomPeer(t), Addrs: []ma.Multiaddr{addr}})
+	require.ErrorIs(t, err, types.ErrUnauthorizedProvider)
+
+	err = Authorize(provider, peer.AddrInfo{ID: provider.PeerIDs[0], Addrs: []ma.Multiaddr{testutil.RandomMultiaddr(t)}})
+	require.ErrorIs(t, err, types.ErrUnauthorizedProvider)
+
+	provider.Status = types.ProviderStatusSuspended
+	err = Authorize(provider, peer.AddrInfo{ID: provider.PeerIDs[0], Addrs: []ma.Multiaddr{addr}})
+	require.ErrorIs(t, err, types.ErrUnauthorizedProvider)
+
+	provider.Status = types.ProviderStatusActive
+	provider.Endpoints = nil
+	require.NoError(t, Authorize(provider, peer.AddrInfo{ID: provider.PeerIDs[0], Addrs: []ma.Multiaddr{testutil.RandomMultiaddr(t)}}))
+}
+
+func TestLoadFile(t *testing.T) {
+	providers := []types.StorageProvider{randomProvider(t), randomProvider(t)}
+	data, err := json.Marshal(providers)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "providers.json")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	registry := NewDatastoreRegistry(datastore.NewMapDatastore())
+	require.NoError(t, LoadFile(t.Context(), path, registry))
+
+	for _, provider := range providers {
+		got, err := registry.Get(t.Context(), provider.DID)
+		require.NoError(t, err)
+		require.Equal(t, provider, got)
+	}
+
+	t.Run("rejects files with invalid providers", func(t *testing.T) {
+		valid := randomProvider(t)
+		invalid := randomProvider(t)
+		invalid.Status = "unknown"
+		data, err := json.Marshal([]types.StorageProvider{valid, invalid})
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "providers.json")
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+
+		registry := NewDatastoreRegistry(datastore.NewMapDatastore())
+		require.ErrorContains(t, LoadFile(t.Context(), path, registry), "unknown provider status")
+		_, err = registry.Get(t.Context(), valid.DID)
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+}
+
+func TestCachedRegistry(t *testing.T) {
+	underlying := NewDatastoreRegistry(datastore.NewMapDatastore())
+	clk := clock.NewMock()
+	registry := NewCachedRegistry(underlying, WithCacheTTL(time.Minute), WithClock(clk))
+
+	provider := randomProvider(t)
+	require.NoError(t, registry.Put(t.Context(), provider))
+
+	got, err := registry.Find(t.Context(), provider.PeerIDs[0])
+	require.NoError(t, err)
+	require.Equal(t, provider, got)
+
+	// changes made to the underlying registry are seen after the TTL
+	other := randomProvider(t)
+	require.NoError(t, underlying.Put(t.Context(), other))
+	_, err = registry.Get(t.Context(), other.DID)
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	clk.Add(time.Minute)
+	got, err = registry.Get(t.Context(), other.DID)
+	require.NoError(t, err)
+	require.Equal(t, other, got)
+
+	// changes made through the cached registry are seen immediately
+	require.NoError(t, registry.Delete(t.Context(), provider.DID))
+	_, err = registry.Get(t.Context(), provider.DID)
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+}
@@ -0,0 +1,114 @@
+// Package ratelimit limits the rate of requests made by clients of the
+// indexing service, using token buckets keyed by client identity.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/storacha/indexing-service/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var log = logging.Logger("ratelimit")
+
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/ratelimit")
+
+// Limiter applies a rate limit to each client, identified by a key such as
+// the DID of the issuer of an invocation or the IP address of a request.
+type Limiter struct {
+	store     types.RateLimitStore
+	kind      string
+	limit     types.RateLimit
+	overrides map[string]types.RateLimit
+	requests  metric.Int64Counter
+}
+
+// LimiterOption configures a [Limiter].
+type LimiterOption func(l *Limiter)
+
+// WithOverrides sets limits for specific clients, replacing the default limit.
+func WithOverrides(overrides map[string]types.RateLimit) LimiterOption {
+	return func(l *Limiter) {
+		l.overrides = overrides
+	}
+}
+
+// NewLimiter creates a new [Limiter] applying the passed limit to each client.
+// The kind identifies the clients being limited (e.g. "publish" or "query"),
+// and namespaces their buckets in the store. Limits with a positive rate must
+// have a positive burst, since no request could be allowed otherwise.
+func NewLimiter(kind string, store types.RateLimitStore, limit types.RateLimit, opts ...LimiterOption) (*Limiter, error) {
+	l := &Limiter{
+		store: store,
+		kind:  kind,
+		limit: limit,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := validateLimit(limit); err != nil {
+		return nil, fmt.Errorf("invalid %s rate limit: %w", kind, err)
+	}
+	for key, override := range l.overrides {
+		if err := validateLimit(override); err != nil {
+			return nil, fmt.Errorf("invalid %s rate limit override for %s: %w", kind, key, err)
+		}
+	}
+
+	var err error
+	l.requests, err = meter.Int64Counter(
+		"ratelimit.requests",
+		metric.WithDescription("Number of requests checked against rate limits, by kind and outcome."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating rate limit requests counter: %w", err)
+	}
+	return l, nil
+}
+
+// Allow takes a token for the client identified by key, returning a
+// [types.RateLimitedError] if the client has exceeded its limit.
+//
+// Requests are allowed if the store fails, so that an unavailable store does
+// not take the service down with it.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	limit := l.limit
+	if override, ok := l.overrides[key]; ok {
+		limit = override
+	}
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	res, err := l.store.Take(ctx, l.kind+"/"+key, limit)
+	if err != nil {
+		log.Warnw("checking rate limit, allowing request", "kind", l.kind, "key", key, "error", err)
+		l.record(ctx, "error")
+		return nil
+	}
+	if !res.Allowed {
+		l.record(ctx, "limited")
+		return types.RateLimitedError{Key: key, RetryAfter: res.RetryAfter}
+	}
+	l.record(ctx, "allowed")
+	return nil
+}
+
+func validateLimit(limit types.RateLimit) error {
+	if limit.Rate > 0 && limit.Burst <= 0 {
+		return fmt.Errorf("burst must be positive, got %d", limit.Burst)
+	}
+	return nil
+}
+
+func (l *Limiter) record(ctx context.Context, outcome string) {
+	l.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("kind", l.kind),
+		attribute.String("outcome", outcome),
+	))
+}
@@ -0,0 +1,85 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("limits bursts and refills over time", func(t *testing.T) {
+		ctx := context.Background()
+		clk := clock.NewMock()
+		limiter, err := ratelimit.NewLimiter("test", ratelimit.NewMemoryStore(ratelimit.WithClock(clk)), types.RateLimit{Rate: 1, Burst: 2})
+		require.NoError(t, err)
+
+		require.NoError(t, limiter.Allow(ctx, "did:key:alice"))
+		require.NoError(t, limiter.Allow(ctx, "did:key:alice"))
+
+		err = limiter.Allow(ctx, "did:key:alice")
+		require.ErrorIs(t, err, types.ErrRateLimited)
+		var rle types.RateLimitedError
+		require.True(t, errors.As(err, &rle))
+		require.Equal(t, time.Second, rle.RetryAfter)
+
+		// other clients have their own buckets
+		require.NoError(t, limiter.Allow(ctx, "did:key:bob"))
+
+		clk.Add(time.Second)
+		require.NoError(t, limiter.Allow(ctx, "did:key:alice"))
+		require.ErrorIs(t, limiter.Allow(ctx, "did:key:alice"), types.ErrRateLimited)
+	})
+
+	t.Run("applies overrides", func(t *testing.T) {
+		ctx := context.Background()
+		limiter, err := ratelimit.NewLimiter("test", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 0.001, Burst: 1},
+			ratelimit.WithOverrides(map[string]types.RateLimit{
+				"did:key:trusted": {},
+				"did:key:bulk":    {Rate: 0.001, Burst: 3},
+			}),
+		)
+		require.NoError(t, err)
+
+		for range 10 {
+			require.NoError(t, limiter.Allow(ctx, "did:key:trusted"))
+		}
+		for range 3 {
+			require.NoError(t, limiter.Allow(ctx, "did:key:bulk"))
+		}
+		require.ErrorIs(t, limiter.Allow(ctx, "did:key:bulk"), types.ErrRateLimited)
+	})
+
+	t.Run("rejects limits without a burst", func(t *testing.T) {
+		_, err := ratelimit.NewLimiter("test", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 1})
+		require.Error(t, err)
+
+		_, err = ratelimit.NewLimiter("test", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 1, Burst: 1}, ratelimit.WithOverrides(map[string]types.RateLimit{
+			"did:key:alice": {Rate: 10},
+		}))
+		require.Error(t, err)
+
+		// overrides disabling limits need no burst
+		_, err = ratelimit.NewLimiter("test", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 1, Burst: 1}, ratelimit.WithOverrides(map[string]types.RateLimit{
+			"did:key:alice": {Rate: 0},
+		}))
+		require.NoError(t, err)
+	})
+
+	t.Run("allows requests when the store fails", func(t *testing.T) {
+		limiter, err := ratelimit.NewLimiter("test", failingStore{}, types.RateLimit{Rate: 1, Burst: 1})
+		require.NoError(t, err)
+		require.NoError(t, limiter.Allow(context.Background(), "did:key:alice"))
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit types.RateLimit) (types.RateLimitResult, error) {
+	return types.RateLimitResult{}, errors.New("store unavailable")
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// MemoryStore is a [types.RateLimitStore] that keeps token buckets in memory.
+// Limits only hold within a single process, so it is meant for tests and
+// single instance deployments.
+type MemoryStore struct {
+	clock   clock.Clock
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+var _ types.RateLimitStore = (*MemoryStore)(nil)
+
+// MemoryStoreOption configures a [MemoryStore].
+type MemoryStoreOption func(s *MemoryStore)
+
+// WithClock configures the store with a mockable clock for testing.
+func WithClock(clock clock.Clock) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.clock = clock
+	}
+}
+
+// NewMemoryStore creates a new, empty [MemoryStore].
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		clock:   clock.New(),
+		buckets: map[string]*bucket{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Take implements types.RateLimitStore.
+func (s *MemoryStore) Take(ctx context.Context, key string, limit types.RateLimit) (types.RateLimitResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	elapsed := max(0, now.Sub(b.updated).Seconds())
+	b.tokens = min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.Rate
+		return types.RateLimitResult{
+			Remaining:  0,
+			RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second))),
+		}, nil
+	}
+	b.tokens--
+	return types.RateLimitResult{Allowed: true, Remaining: int(b.tokens)}, nil
+}
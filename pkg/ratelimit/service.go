@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// Service wraps a [types.Service], limiting the rate at which claims are
+// published and cached by each issuer.
+type Service struct {
+	types.Service
+	limiter *Limiter
+}
+
+var _ types.Service = (*Service)(nil)
+
+type issuerKey struct{}
+
+// ContextWithIssuer returns a context carrying the DID of the issuer of the
+// invocation being handled, which the [Service] limits instead of the issuer of
+// the claim.
+func ContextWithIssuer(ctx context.Context, issuer did.DID) context.Context {
+	return context.WithValue(ctx, issuerKey{}, issuer)
+}
+
+// issuerOf returns the invocation issuer carried by the context, falling back
+// to the issuer of the claim.
+func issuerOf(ctx context.Context, claim delegation.Delegation) string {
+	if issuer, ok := ctx.Value(issuerKey{}).(did.DID); ok && issuer != did.Undef {
+		return issuer.String()
+	}
+	return claim.Issuer().DID().String()
+}
+
+// NewService wraps the passed service, limiting publishes and caches by the
+// DID of the issuer of the invocation, as set by [ContextWithIssuer]. If the
+// context does not carry an issuer, the issuer of the claim is limited, which
+// for `assert/*` invocations is the issuer of the invocation.
+func NewService(service types.Service, limiter *Limiter) *Service {
+	return &Service{Service: service, limiter: limiter}
+}
+
+// Cache caches the claim, unless the invocation issuer has exceeded their rate
+// limit.
+func (s *Service) Cache(ctx context.Context, provider peer.AddrInfo, claim delegation.Delegation) error {
+	if err := s.limiter.Allow(ctx, issuerOf(ctx, claim)); err != nil {
+		return err
+	}
+	return s.Service.Cache(ctx, provider, claim)
+}
+
+// Publish publishes the claim, unless the invocation issuer has exceeded their
+// rate limit.
+func (s *Service) Publish(ctx context.Context, claim delegation.Delegation) error {
+	if err := s.limiter.Allow(ctx, issuerOf(ctx, claim)); err != nil {
+		return err
+	}
+	return s.Service.Publish(ctx, claim)
+}
@@ -0,0 +1,44 @@
+package ratelimit_test
+
+import (
+	"testing"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	t.Run("limits the invocation issuer carried by the context", func(t *testing.T) {
+		limiter, err := ratelimit.NewLimiter("publish", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 0.001, Burst: 1})
+		require.NoError(t, err)
+
+		mockService := types.NewMockService(t)
+		mockService.EXPECT().Publish(mock.Anything, mock.Anything).Return(nil)
+		service := ratelimit.NewService(mockService, limiter)
+
+		// claims issued by the same principal, invoked by different issuers
+		claim := testutil.RandomLocationDelegation(t)
+		aliceCtx := ratelimit.ContextWithIssuer(t.Context(), testutil.Alice.DID())
+		bobCtx := ratelimit.ContextWithIssuer(t.Context(), testutil.Bob.DID())
+
+		require.NoError(t, service.Publish(aliceCtx, claim))
+		require.ErrorIs(t, service.Publish(aliceCtx, claim), types.ErrRateLimited)
+		require.NoError(t, service.Publish(bobCtx, claim))
+	})
+
+	t.Run("limits the claim issuer without an invocation issuer", func(t *testing.T) {
+		limiter, err := ratelimit.NewLimiter("publish", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 0.001, Burst: 1})
+		require.NoError(t, err)
+
+		mockService := types.NewMockService(t)
+		mockService.EXPECT().Publish(mock.Anything, mock.Anything).Return(nil)
+		service := ratelimit.NewService(mockService, limiter)
+
+		claim := testutil.RandomLocationDelegation(t)
+		require.NoError(t, service.Publish(t.Context(), claim))
+		require.ErrorIs(t, service.Publish(ratelimit.ContextWithIssuer(t.Context(), claim.Issuer().DID()), claim), types.ErrRateLimited)
+	})
+}
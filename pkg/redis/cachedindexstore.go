@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+var (
+	_ types.CachedIndexStore = (*CachedIndexStore)(nil)
+)
+
+// CachedIndexStore is a RedisStore for recording fully cached indexes that
+// implements types.CachedIndexStore
+type CachedIndexStore = Store[string, bool]
+
+// NewCachedIndexStore returns a new instance of a cached index store using the
+// given redis client. Records should expire no later than the provider records
+// they refer to, so the same expiration options should be used.
+func NewCachedIndexStore(client Client, opts ...Option) *CachedIndexStore {
+	return NewStore(cachedIndexFromRedis, cachedIndexToRedis, cachedIndexKeyString, client, named("cached_indexes", opts)...)
+}
+
+func cachedIndexFromRedis(data string) (bool, error) {
+	return data == "1", nil
+}
+
+func cachedIndexToRedis(cached bool) (string, error) {
+	if cached {
+		return "1", nil
+	}
+	return "0", nil
+}
+
+// cachedIndexKeyString prefixes the key with "cached/" to distinguish it from
+// the ProviderStore keys, in case the same Redis instance is being used for
+// both.
+func cachedIndexKeyString(k string) string {
+	return "cached/" + k
+}
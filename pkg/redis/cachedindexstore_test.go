@@ -0,0 +1,24 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedIndexStore(t *testing.T) {
+	mockRedis := NewMockRedis()
+	cachedIndexStore := redis.NewCachedIndexStore(mockRedis)
+
+	ctx := context.Background()
+	_, err := cachedIndexStore.Get(ctx, "some-index")
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	require.NoError(t, cachedIndexStore.Set(ctx, "some-index", true, true))
+	cached, err := cachedIndexStore.Get(ctx, "some-index")
+	require.NoError(t, err)
+	require.True(t, cached)
+}
@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+var (
+	_ types.CachingCheckpointStore = (*CachingCheckpointStore)(nil)
+)
+
+// CachingCheckpointStore is a RedisStore for storing provider caching job
+// progress that implements types.CachingCheckpointStore
+type CachingCheckpointStore = Store[string, types.CachingCheckpoint]
+
+// NewCachingCheckpointStore returns a new instance of a caching checkpoint
+// store using the given redis client
+func NewCachingCheckpointStore(client Client, opts ...Option) *CachingCheckpointStore {
+	return NewStore(cachingCheckpointFromRedis, cachingCheckpointToRedis, cachingCheckpointKeyString, client, named("caching_checkpoints", opts)...)
+}
+
+func cachingCheckpointFromRedis(data string) (types.CachingCheckpoint, error) {
+	var checkpoint types.CachingCheckpoint
+	_, err := fmt.Sscanf(data, "%d:%d", &checkpoint.Shard, &checkpoint.Slice)
+	if err != nil {
+		return types.CachingCheckpoint{}, fmt.Errorf("parsing caching checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+func cachingCheckpointToRedis(checkpoint types.CachingCheckpoint) (string, error) {
+	return fmt.Sprintf("%d:%d", checkpoint.Shard, checkpoint.Slice), nil
+}
+
+// cachingCheckpointKeyString prefixes the key with "checkpoint/" to
+// distinguish it from the ProviderStore keys, in case the same Redis instance
+// is being used for both.
+func cachingCheckpointKeyString(k string) string {
+	return "checkpoint/" + k
+}
@@ -0,0 +1,25 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingCheckpointStore(t *testing.T) {
+	mockRedis := NewMockRedis()
+	checkpointStore := redis.NewCachingCheckpointStore(mockRedis)
+
+	ctx := context.Background()
+	_, err := checkpointStore.Get(ctx, "some-job")
+	require.ErrorIs(t, err, types.ErrKeyNotFound)
+
+	checkpoint := types.CachingCheckpoint{Shard: 2, Slice: 10_000}
+	require.NoError(t, checkpointStore.Set(ctx, "some-job", checkpoint, true))
+	returned, err := checkpointStore.Get(ctx, "some-job")
+	require.NoError(t, err)
+	require.Equal(t, checkpoint, returned)
+}
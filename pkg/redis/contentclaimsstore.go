@@ -15,7 +15,7 @@ type ContentClaimsStore = Store[cid.Cid, delegation.Delegation]
 
 // NewContentClaimsStore returns a new instance of a Content Claims Store using the given redis client
 func NewContentClaimsStore(client Client, opts ...Option) *ContentClaimsStore {
-	return NewStore(delegationFromRedis, delegationToRedis, cidKeyString, client, opts...)
+	return NewStore(delegationFromRedis, delegationToRedis, cidKeyString, client, named("claims", opts)...)
 }
 
 func delegationFromRedis(data string) (delegation.Delegation, error) {
@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/storacha/indexing-service/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/redis")
+
+var cacheLookups = func() metric.Int64Counter {
+	counter, err := meter.Int64Counter(
+		"cache.lookups",
+		metric.WithDescription("Number of cache lookups, by cache and whether the key was found."),
+	)
+	if err != nil {
+		return noop.Int64Counter{}
+	}
+	return counter
+}()
+
+func recordLookup(ctx context.Context, cache string, hit bool) {
+	types.QueryStatsFromContext(ctx).AddCacheLookup(hit)
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("cache", cache),
+		attribute.String("result", result),
+	))
+}
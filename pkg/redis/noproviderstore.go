@@ -22,7 +22,7 @@ type NoProviderStore = Store[multihash.Multihash, multicodec.Code]
 
 // NewNoProviderStore returns a new instance of an IPNI store using the given redis client
 func NewNoProviderStore(client Client, opts ...Option) *NoProviderStore {
-	return NewStore(noProviderResultFromRedis, noProviderResultToRedis, noProviderMultihashKeyString, client, opts...)
+	return NewStore(noProviderResultFromRedis, noProviderResultToRedis, noProviderMultihashKeyString, client, named("no_providers", opts)...)
 }
 
 func noProviderResultFromRedis(data string) (multicodec.Code, error) {
@@ -19,7 +19,7 @@ type ProviderStore = BatchingValueSetStore[multihash.Multihash, model.ProviderRe
 
 // NewProviderStore returns a new instance of an IPNI store using the given redis client
 func NewProviderStore(client PipelineClient, opts ...Option) *ProviderStore {
-	return NewBatchingValueSetStore(providerResultFromRedis, providerResultToRedis, multihashKeyString, client, opts...)
+	return NewBatchingValueSetStore(providerResultFromRedis, providerResultToRedis, multihashKeyString, client, named("providers", opts)...)
 }
 
 func providerResultFromRedis(data string) (model.ProviderResult, error) {
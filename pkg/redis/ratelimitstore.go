@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+var (
+	_ types.RateLimitStore = (*RateLimitStore)(nil)
+)
+
+// takeTokenScript refills the token bucket at KEYS[1] for the time elapsed
+// since it was last updated, and takes a token from it if one is available.
+// Time is read from the Redis server, so that limits are consistent across
+// clients. It returns whether a token was taken, the number of whole tokens
+// remaining, and the milliseconds until a token is available.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RateLimitStore stores token buckets in Redis, so that limits hold across
+// all the instances of the service sharing the Redis instance.
+type RateLimitStore struct {
+	client redis.Scripter
+}
+
+// NewRateLimitStore returns a new instance of a rate limit store using the
+// given redis client.
+func NewRateLimitStore(client redis.Scripter) *RateLimitStore {
+	return &RateLimitStore{client: client}
+}
+
+// Take implements types.RateLimitStore.
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit types.RateLimit) (types.RateLimitResult, error) {
+	res, err := takeTokenScript.Run(ctx, s.client, []string{rateLimitKeyString(key)}, limit.Rate, limit.Burst).Int64Slice()
+	if err != nil {
+		return types.RateLimitResult{}, fmt.Errorf("taking token: %w", err)
+	}
+	if len(res) != 3 {
+		return types.RateLimitResult{}, fmt.Errorf("unexpected token bucket response: %v", res)
+	}
+	return types.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// rateLimitKeyString prefixes the key with "ratelimit/" to distinguish it
+// from other keys, in case the same Redis instance is being used.
+func rateLimitKeyString(key string) string {
+	return "ratelimit/" + key
+}
@@ -0,0 +1,52 @@
+package redis_test
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/storacha/indexing-service/pkg/redis"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	valkey "github.com/testcontainers/testcontainers-go/modules/valkey"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	container, err := valkey.Run(ctx, "valkey/valkey:7.2.5")
+	testcontainers.CleanupContainer(t, container)
+	require.NoError(t, err)
+
+	uri, err := container.ConnectionString(ctx)
+	require.NoError(t, err)
+
+	client := goredis.NewClient(&goredis.Options{Addr: strings.TrimPrefix(uri, "redis://")})
+	store := redis.NewRateLimitStore(client)
+
+	// a slow refill, so that the bucket does not refill during the test
+	limit := types.RateLimit{Rate: 0.01, Burst: 3}
+	for i := range 3 {
+		res, err := store.Take(ctx, "did:key:test", limit)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := store.Take(ctx, "did:key:test", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Positive(t, res.RetryAfter)
+
+	// buckets are independent
+	res, err = store.Take(ctx, "did:key:other", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
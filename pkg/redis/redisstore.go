@@ -59,6 +59,7 @@ var (
 
 type config struct {
 	expirationTime time.Duration
+	name           string
 }
 
 func newConfig(opts []Option) config {
@@ -79,6 +80,19 @@ func ExpirationTime(expirationTime time.Duration) Option {
 	}
 }
 
+// WithName sets the name that identifies the store in metrics.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// named prepends a default name to the passed options, so that it may be
+// overridden by a name passed by the caller.
+func named(name string, opts []Option) []Option {
+	return append([]Option{WithName(name)}, opts...)
+}
+
 // NewStore returns a new instance of a redis store with the provided serialization/deserialization functions
 func NewStore[Key, Value any](
 	fromRedis func(string) (Value, error),
@@ -96,10 +110,12 @@ func (rs *Store[Key, Value]) Get(ctx context.Context, key Key) (Value, error) {
 	if err != nil {
 		var v Value
 		if err == redis.Nil {
+			recordLookup(ctx, rs.config.name, false)
 			return v, types.ErrKeyNotFound
 		}
 		return v, fmt.Errorf("error accessing redis: %w", err)
 	}
+	recordLookup(ctx, rs.config.name, true)
 	return rs.fromRedis(data)
 }
 
@@ -145,8 +161,10 @@ func (rs *Store[Key, Value]) Members(ctx context.Context, key Key) ([]Value, err
 	// as opposed to other commands, SMembers doesn't return redis.Nil when the key doesn't exist, but an empty set
 	// this implementation assumes there is no need to differentiate between a non-existing key and an empty set
 	if len(data) == 0 {
+		recordLookup(ctx, rs.config.name, false)
 		return nil, types.ErrKeyNotFound
 	}
+	recordLookup(ctx, rs.config.name, true)
 
 	var values []Value
 	for _, d := range data {
@@ -223,8 +241,12 @@ type PipelineBatcher[K, V any] struct {
 	keyString func(K) string
 	config    config
 	pipeline  Pipeliner
+	adds      []*redis.IntCmd
+	added     uint64
 }
 
+var _ types.AddCounter = (*PipelineBatcher[any, any])(nil)
+
 func NewPipelineBatcher[K, V any](
 	pipeline Pipeliner,
 	toRedis func(V) (string, error),
@@ -249,7 +271,7 @@ func (pb *PipelineBatcher[K, V]) Add(ctx context.Context, key K, values ...V) er
 		}
 		data = append(data, d)
 	}
-	pb.pipeline.SAdd(ctx, pb.keyString(key), data...)
+	pb.adds = append(pb.adds, pb.pipeline.SAdd(ctx, pb.keyString(key), data...))
 	return nil
 }
 
@@ -264,7 +286,21 @@ func (pb *PipelineBatcher[K, V]) SetExpirable(ctx context.Context, key K, expire
 
 func (pb *PipelineBatcher[K, V]) Commit(ctx context.Context) error {
 	_, err := pb.pipeline.Exec(ctx)
-	return err
+	if err != nil {
+		return err
+	}
+	pb.added = 0
+	for _, cmd := range pb.adds {
+		pb.added += uint64(cmd.Val())
+	}
+	pb.adds = nil
+	return nil
+}
+
+// Added returns the number of values that were newly added to sets by the
+// last commit.
+func (pb *PipelineBatcher[K, V]) Added() uint64 {
+	return pb.added
 }
 
 // NewClientAdapter converts a [redis.Client] into a [PipelineClient].
@@ -402,6 +402,7 @@ func TestBatchingValueSetStore(t *testing.T) {
 
 	err = batch.Commit(ctx)
 	require.NoError(t, err)
+	require.Equal(t, uint64(len(testdata)), batch.(types.AddCounter).Added())
 
 	// verify everything was set
 	for _, d := range testdata {
@@ -17,7 +17,7 @@ type ShardedDagIndexStore = Store[types.EncodedContextID, blobindex.ShardedDagIn
 
 // NewShardedDagIndexStore returns a new instance of a ShardedDagIndex store using the given redis client
 func NewShardedDagIndexStore(client Client, opts ...Option) *ShardedDagIndexStore {
-	return NewStore(shardedDagIndexFromRedis, shardedDagIndexToRedis, encodedContextIDKeyString, client, opts...)
+	return NewStore(shardedDagIndexFromRedis, shardedDagIndexToRedis, encodedContextIDKeyString, client, named("indexes", opts)...)
 }
 
 func shardedDagIndexFromRedis(data string) (blobindex.ShardedDagIndexView, error) {
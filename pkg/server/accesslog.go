@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// AccessLogger writes a structured JSON line for each request it handles. A
+// sample rate below 1 logs only that fraction of requests, chosen at random.
+type AccessLogger struct {
+	out        io.Writer
+	sampleRate float64
+	mutex      sync.Mutex
+}
+
+// NewAccessLogger creates an [AccessLogger] that writes to out, logging the
+// passed fraction of requests (0 to 1).
+func NewAccessLogger(out io.Writer, sampleRate float64) *AccessLogger {
+	return &AccessLogger{out: out, sampleRate: sampleRate}
+}
+
+// AccessLogEntry is a line of the access log.
+type AccessLogEntry struct {
+	Time       time.Time      `json:"time"`
+	Route      string         `json:"route"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	Status     int            `json:"status"`
+	Bytes      int64          `json:"bytes"`
+	DurationMS int64          `json:"duration_ms"`
+	RemoteAddr string         `json:"remote_addr,omitempty"`
+	UserAgent  string         `json:"user_agent,omitempty"`
+	Query      *QueryLogEntry `json:"query,omitempty"`
+	stats      *types.QueryStats
+}
+
+// QueryLogEntry records what a query did, for requests to GET /claims.
+type QueryLogEntry struct {
+	Multihashes int      `json:"multihashes"`
+	Type        string   `json:"type"`
+	Spaces      []string `json:"spaces,omitempty"`
+	Claims      int      `json:"claims"`
+	Indexes     int      `json:"indexes"`
+	Jobs        int64    `json:"jobs"`
+	CacheHits   int64    `json:"cache_hits"`
+	CacheMisses int64    `json:"cache_misses"`
+}
+
+type accessLogEntryKey struct{}
+
+// logQuery adds the query to the access log entry for the request, if the
+// request is being logged. Requests making several queries, like IPNI batch
+// finds, log the totals of their queries.
+func logQuery(ctx context.Context, q types.Query, qr types.QueryResult) {
+	entry, ok := ctx.Value(accessLogEntryKey{}).(*AccessLogEntry)
+	if !ok {
+		return
+	}
+	if entry.Query == nil {
+		spaces := make([]string, 0, len(q.Match.Subject))
+		for _, space := range q.Match.Subject {
+			spaces = append(spaces, space.String())
+		}
+		entry.Query = &QueryLogEntry{
+			Type:   q.Type.String(),
+			Spaces: spaces,
+		}
+	}
+	entry.Query.Multihashes += len(q.Hashes)
+	if qr != nil {
+		entry.Query.Claims += len(qr.Claims())
+		entry.Query.Indexes += len(qr.Indexes())
+	}
+}
+
+// statusRecorder captures the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying writer, if it supports flushing, so that
+// streamed responses are not held back by logging.
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for [http.ResponseController].
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Wrap returns a handler that logs requests to the passed handler, which is
+// registered for route. A nil logger returns the handler unchanged.
+func (l *AccessLogger) Wrap(route string, handler http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+			handler(w, r)
+			return
+		}
+
+		start := time.Now()
+		entry := &AccessLogEntry{
+			Time:       start.UTC(),
+			Route:      route,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+			stats:      &types.QueryStats{},
+		}
+		ctx := context.WithValue(r.Context(), accessLogEntryKey{}, entry)
+		ctx = types.ContextWithQueryStats(ctx, entry.stats)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		handler(rec, r.WithContext(ctx))
+
+		entry.Status = rec.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Bytes = rec.bytes
+		entry.DurationMS = time.Since(start).Milliseconds()
+		if entry.Query != nil {
+			entry.Query.Jobs = entry.stats.Jobs()
+			entry.Query.CacheHits = entry.stats.CacheHits()
+			entry.Query.CacheMisses = entry.stats.CacheMisses()
+		}
+		l.write(entry)
+	}
+}
+
+func (l *AccessLogger) write(entry *AccessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("serializing access log entry: %s", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err := l.out.Write(line); err != nil {
+		log.Errorf("writing access log entry: %s", err)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogger(t *testing.T) {
+	t.Run("logs query stats for GET /claims", func(t *testing.T) {
+		mockService := types.NewMockService(t)
+
+		randomHash := testutil.RandomMultihash(t)
+		query := types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: []multihash.Multihash{randomHash},
+			Match:  types.Match{Subject: []did.DID{}},
+		}
+		locationClaim := testutil.RandomLocationDelegation(t)
+		claims := map[cid.Cid]delegation.Delegation{link.ToCID(locationClaim.Link()): locationClaim}
+		indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](-1)
+		queryResult := testutil.Must(queryresult.Build(claims, indexes))(t)
+		mockService.EXPECT().Query(mock.Anything, query).RunAndReturn(func(ctx context.Context, q types.Query) (types.QueryResult, error) {
+			stats := types.QueryStatsFromContext(ctx)
+			stats.AddJob()
+			stats.AddCacheLookup(true)
+			stats.AddCacheLookup(false)
+			stats.AddCacheLookup(false)
+			return queryResult, nil
+		})
+
+		var out bytes.Buffer
+		logger := NewAccessLogger(&out, 1)
+		svr := httptest.NewServer(logger.Wrap("GET /claims", GetClaimsHandler(mockService)))
+		defer svr.Close()
+
+		res, err := http.Get(fmt.Sprintf("%s/claims?multihash=%s", svr.URL, digestutil.Format(randomHash)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var entry AccessLogEntry
+		require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+		require.Equal(t, "GET /claims", entry.Route)
+		require.Equal(t, http.StatusOK, entry.Status)
+		require.Positive(t, entry.Bytes)
+		require.NotNil(t, entry.Query)
+		require.Equal(t, QueryLogEntry{
+			Multihashes: 1,
+			Type:        types.QueryTypeStandard.String(),
+			Claims:      1,
+			Indexes:     0,
+			Jobs:        1,
+			CacheHits:   1,
+			CacheMisses: 2,
+		}, *entry.Query)
+	})
+
+	t.Run("logs the totals of batch finds", func(t *testing.T) {
+		mockService := types.NewMockService(t)
+
+		locationClaim := testutil.RandomLocationDelegation(t)
+		claims := map[cid.Cid]delegation.Delegation{link.ToCID(locationClaim.Link()): locationClaim}
+		indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](-1)
+		queryResult := testutil.Must(queryresult.Build(claims, indexes))(t)
+		mockService.EXPECT().Query(mock.Anything, mock.Anything).Return(queryResult, nil).Times(3)
+
+		var out bytes.Buffer
+		logger := NewAccessLogger(&out, 1)
+		provider := peer.AddrInfo{ID: testutil.RandomPeer(t)}
+		svr := httptest.NewServer(logger.Wrap("POST /multihash", PostIPNIMultihashHandler(mockService, provider)))
+		defer svr.Close()
+
+		digests := []multihash.Multihash{testutil.RandomMultihash(t), testutil.RandomMultihash(t), testutil.RandomMultihash(t)}
+		body := testutil.Must(json.Marshal(findRequest{Multihashes: digests}))(t)
+		res, err := http.Post(svr.URL+"/multihash", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var entry AccessLogEntry
+		require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+		require.NotNil(t, entry.Query)
+		require.Equal(t, 3, entry.Query.Multihashes)
+		require.Equal(t, 3, entry.Query.Claims)
+	})
+
+	t.Run("logs errors without query results", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := NewAccessLogger(&out, 1)
+		svr := httptest.NewServer(logger.Wrap("GET /claims", GetClaimsHandler(types.NewMockService(t))))
+		defer svr.Close()
+
+		res, err := http.Get(fmt.Sprintf("%s/claims", svr.URL))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var entry AccessLogEntry
+		require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+		require.Equal(t, http.StatusBadRequest, entry.Status)
+		require.Nil(t, entry.Query)
+	})
+
+	t.Run("samples requests", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := NewAccessLogger(&out, 0)
+		svr := httptest.NewServer(logger.Wrap("GET /", GetRootHandler(testutil.Service)))
+		defer svr.Close()
+
+		for range 10 {
+			res, err := http.Get(svr.URL)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}
+		require.Empty(t, strings.TrimSpace(out.String()))
+	})
+}
+
+func TestStatusRecorder(t *testing.T) {
+	t.Run("flushes the underlying writer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		rec := &statusRecorder{ResponseWriter: w}
+		require.NoError(t, http.NewResponseController(rec).Flush())
+		require.True(t, w.Flushed)
+		require.Equal(t, http.StatusOK, rec.status)
+	})
+
+	t.Run("unwraps the underlying writer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		rec := &statusRecorder{ResponseWriter: w}
+		require.Same(t, w, rec.Unwrap())
+	})
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/principal"
+	hcmsg "github.com/storacha/go-ucanto/transport/headercar/message"
+	"github.com/storacha/indexing-service/pkg/service/blobindexlookup"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+const (
+	rawContentType = "application/vnd.ipld.raw"
+	// maxBlockSize is the largest block that is proxied.
+	maxBlockSize = 4 << 20
+	// blockRangeHeader is the response header carrying the value of the Range
+	// header to send to the redirect location to fetch the block.
+	blockRangeHeader = "X-Block-Range"
+)
+
+// BlockProxy fetches blocks stored within larger resources, such as blobs
+// holding the shards of a DAG. To keep the service from being used to send
+// requests to arbitrary hosts, blocks are only fetched from allowed hosts and
+// from the registered endpoints of the active storage providers that issued
+// the location claims.
+type BlockProxy struct {
+	client   *http.Client
+	hosts    []string
+	registry types.ProviderRegistry
+}
+
+// NewBlockProxy creates a block proxy fetching blocks with the passed HTTP
+// client from the allowed hosts, as host or host:port, and from the endpoints
+// of the providers in the registry, which may be nil.
+func NewBlockProxy(httpClient *http.Client, registry types.ProviderRegistry, allowedHosts []string) *BlockProxy {
+	return &BlockProxy{client: httpClient, hosts: allowedHosts, registry: registry}
+}
+
+// allowed returns true if the block may be fetched from the location.
+func (p *BlockProxy) allowed(ctx context.Context, loc queryresult.BlockLocation) bool {
+	if slices.Contains(p.hosts, loc.URL.Host) || slices.Contains(p.hosts, loc.URL.Hostname()) {
+		return true
+	}
+	if p.registry == nil || !loc.Issuer.Defined() {
+		return false
+	}
+	provider, err := p.registry.Get(ctx, loc.Issuer)
+	if err != nil {
+		if !errors.Is(err, types.ErrKeyNotFound) {
+			log.Warnf("getting provider %s: %s", loc.Issuer, err)
+		}
+		return false
+	}
+	if provider.Status != types.ProviderStatusActive {
+		return false
+	}
+	for _, endpoint := range provider.Endpoints {
+		addr, err := multiaddr.NewMultiaddr(endpoint)
+		if err != nil {
+			continue
+		}
+		u, err := maurl.ToURL(addr)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == loc.URL.Scheme && u.Host == loc.URL.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBlockHandler serves a block when a GET request is sent to
+// "/block/{cid}". Results can be filtered by space with "spaces" query
+// parameters, like for "/claims".
+//
+// If the block is stored as a whole resource, the client is redirected to it.
+// Otherwise, when a proxy is configured, the block is fetched from within the
+// resources holding it on the proxy's allowed hosts and streamed back once its
+// hash has been verified, trying each location in turn. Blocks are fetched
+// with UCAN authorized retrievals when the request carries
+// "space/content/retrieve" delegations for their space. Without a proxy, or
+// when no location may be proxied, the client is redirected to the resource
+// holding the block, with the byte range to request in the X-Block-Range
+// header.
+func GetBlockHandler(id principal.Signer, service types.Querier, proxy *BlockProxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := telemetry.StartSpan(r.Context(), "GetBlockHandler")
+		defer s.End()
+
+		parts := strings.Split(r.URL.Path, "/")
+		c, err := cid.Parse(parts[len(parts)-1])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CID: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		spaces, err := parseSpaces(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		dlgs, err := parseDelegations(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: []multihash.Multihash{c.Hash()},
+			Match: types.Match{
+				Subject: spaces,
+			},
+			Delegations: dlgs,
+		}
+		qr, err := service.Query(ctx, q)
+		logQuery(ctx, q, qr)
+		if err != nil {
+			if errors.Is(err, types.ErrUnauthorizedQuery) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, fmt.Sprintf("processing query: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		locations, err := queryresult.Locate(qr, q.Hashes)
+		if err != nil {
+			telemetry.Error(s, err, "locating block")
+			http.Error(w, fmt.Sprintf("locating block: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		if len(locations[0]) == 0 {
+			http.Error(w, fmt.Sprintf("no locations found for block: %s", c), http.StatusNotFound)
+			return
+		}
+
+		// locations may change, so redirects are not cached
+		for _, loc := range locations[0] {
+			if loc.Offset == 0 && loc.Length == nil {
+				w.Header().Set("Cache-Control", "no-store")
+				http.Redirect(w, r, loc.URL.String(), http.StatusTemporaryRedirect)
+				return
+			}
+		}
+
+		var proxied []queryresult.BlockLocation
+		if proxy != nil {
+			for _, loc := range locations[0] {
+				if proxy.allowed(ctx, loc) {
+					proxied = append(proxied, loc)
+				}
+			}
+		}
+		if len(proxied) == 0 {
+			loc := locations[0][0]
+			w.Header().Set("Cache-Control", "no-store")
+			if rng := loc.Range(); rng != "" {
+				w.Header().Set(blockRangeHeader, rng)
+			}
+			http.Redirect(w, r, loc.URL.String(), http.StatusTemporaryRedirect)
+			return
+		}
+
+		// blocks are immutable, so they can be cached indefinitely
+		etag := `"` + c.String() + `.raw"`
+		cacheControl := fmt.Sprintf("public, max-age=%d, immutable", maxCacheAge)
+		if r.Header.Get(hcmsg.HeaderName) != "" {
+			cacheControl = fmt.Sprintf("private, max-age=%d, immutable", maxCacheAge)
+		}
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", cacheControl)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var errs []error
+		for _, loc := range proxied {
+			data, err := fetchBlock(r, proxy.client, id, c.Hash(), loc, dlgs)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("fetching block from %s: %w", loc.URL.String(), err))
+				continue
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", cacheControl)
+			w.Header().Set("Content-Type", rawContentType)
+			_, err = w.Write(data)
+			if err != nil {
+				log.Errorf("sending block response: %s", err)
+			}
+			return
+		}
+		err = errors.Join(errs...)
+		telemetry.Error(s, err, "fetching block")
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, fmt.Sprintf("failed to fetch block from all locations: %s", err.Error()), http.StatusBadGateway)
+	}
+}
+
+// fetchBlock retrieves a block from a location and verifies it hashes to the
+// passed digest.
+func fetchBlock(r *http.Request, httpClient *http.Client, id principal.Signer, digest multihash.Multihash, loc queryresult.BlockLocation, dlgs []delegation.Delegation) ([]byte, error) {
+	limit := int64(maxBlockSize)
+	if loc.Length != nil {
+		if *loc.Length > maxBlockSize {
+			return nil, fmt.Errorf("block exceeds %d bytes", maxBlockSize)
+		}
+		limit = int64(*loc.Length)
+	}
+
+	req := types.NewRetrievalRequest(&loc.URL, &metadata.Range{Offset: loc.Offset, Length: loc.Length}, retrievalAuth(id, digest, loc, dlgs))
+	body, err := blobindexlookup.Retrieve(r.Context(), httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading block: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("block exceeds %d bytes", limit)
+	}
+
+	decoded, err := multihash.Decode(digest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding digest: %w", err)
+	}
+	sum, err := multihash.Sum(data, decoded.Code, decoded.Length)
+	if err != nil {
+		return nil, fmt.Errorf("hashing block: %w", err)
+	}
+	if !bytes.Equal(sum, digest) {
+		return nil, errors.New("block does not match digest")
+	}
+	return data, nil
+}
+
+// retrievalAuth returns the authorization for retrieving a block from a
+// location, or nil if the location must be retrieved publicly. Like for
+// indexes, authorized retrieval requires a space in the location claim, a
+// non-empty absolute byte range, and delegations allowing content to be retrieved from
+// the space.
+func retrievalAuth(id principal.Signer, digest multihash.Multihash, loc queryresult.BlockLocation, dlgs []delegation.Delegation) *types.RetrievalAuth {
+	if !loc.Space.Defined() || loc.Length == nil || *loc.Length == 0 || len(dlgs) == 0 {
+		return nil
+	}
+	var proofs []delegation.Proof
+	for _, d := range dlgs {
+		for _, c := range d.Capabilities() {
+			if c.Can() == content.Retrieve.Can() && c.With() == loc.Space.String() {
+				proofs = append(proofs, delegation.FromDelegation(d))
+			}
+		}
+	}
+	if len(proofs) == 0 {
+		return nil
+	}
+
+	// the block is retrieved from the blob holding it, which is the block
+	// itself if it was not located through a shard
+	blob := digest
+	if loc.Shard != nil {
+		blob = loc.Shard
+	}
+	cap := content.Retrieve.New(loc.Space.String(), content.RetrieveCaveats{
+		Blob: content.BlobDigest{Digest: blob},
+		Range: content.Range{
+			Start: loc.Offset,
+			End:   loc.Offset + *loc.Length - 1,
+		},
+	})
+	auth := types.NewRetrievalAuth(id, loc.Issuer, cap, proofs)
+	return &auth
+}
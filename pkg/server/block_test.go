@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBlockHandler(t *testing.T) {
+	data := testutil.RandomBytes(t, 100)
+	block := cid.NewCidV1(cid.Raw, testutil.Must(multihash.Sum(data, multihash.SHA2_256, -1))(t))
+	shardData := append(append(testutil.RandomBytes(t, 10), data...), testutil.RandomBytes(t, 20)...)
+
+	// queryResult builds a query result locating the block in a shard served
+	// at the passed URL.
+	queryResult := func(t *testing.T, shardURL string) types.QueryResult {
+		shard := testutil.RandomMultihash(t)
+		index := blobindex.NewShardedDagIndexView(cidlink.Link{Cid: block}, 1)
+		index.SetSlice(shard, block.Hash(), blobindex.Position{Offset: 10, Length: uint64(len(data))})
+		claim := testutil.Must(assert.Location.Delegate(testutil.Service, testutil.Service, testutil.Service.DID().String(), assert.LocationCaveats{
+			Content:  ctypes.FromHash(shard),
+			Location: []url.URL{*testutil.Must(url.Parse(shardURL))(t)},
+		}, delegation.WithNoExpiration()))(t)
+		indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](1)
+		indexes.Set(types.EncodedContextID("index"), index)
+		return testutil.Must(queryresult.Build(map[cid.Cid]delegation.Delegation{link.ToCID(claim.Link()): claim}, indexes))(t)
+	}
+
+	newServer := func(t *testing.T, qr types.QueryResult, proxy *BlockProxy) *httptest.Server {
+		mockService := types.NewMockService(t)
+		mockService.EXPECT().Query(mock.Anything, mock.Anything).Return(qr, nil)
+		svr := httptest.NewServer(GetBlockHandler(testutil.Service, mockService, proxy))
+		t.Cleanup(svr.Close)
+		return svr
+	}
+
+	// newStorage serves the blob at any path, counting requests.
+	newStorage := func(t *testing.T, blob []byte) (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.ServeContent(w, r, "blob", time.Now(), bytes.NewReader(blob))
+		}))
+		t.Cleanup(storage.Close)
+		return storage, &requests
+	}
+
+	noRedirects := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	t.Run("redirects to blocks stored as whole resources", func(t *testing.T) {
+		claim := testutil.Must(assert.Location.Delegate(testutil.Service, testutil.Service, testutil.Service.DID().String(), assert.LocationCaveats{
+			Content:  ctypes.FromHash(block.Hash()),
+			Location: []url.URL{*testutil.Must(url.Parse("https://storage.example.com/block"))(t)},
+		}, delegation.WithNoExpiration()))(t)
+		qr := testutil.Must(queryresult.Build(map[cid.Cid]delegation.Delegation{link.ToCID(claim.Link()): claim}, bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0)))(t)
+		svr := newServer(t, qr, nil)
+
+		res, err := noRedirects.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		require.Equal(t, "https://storage.example.com/block", res.Header.Get("Location"))
+		require.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+	})
+
+	t.Run("redirects to blocks within larger resources with their range", func(t *testing.T) {
+		svr := newServer(t, queryResult(t, "https://storage.example.com/blob"), nil)
+
+		res, err := noRedirects.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		require.Equal(t, "https://storage.example.com/blob", res.Header.Get("Location"))
+		require.Equal(t, fmt.Sprintf("bytes=10-%d", 10+len(data)-1), res.Header.Get(blockRangeHeader))
+		require.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+	})
+
+	t.Run("proxies the verified block from allowed hosts", func(t *testing.T) {
+		storage, _ := newStorage(t, shardData)
+		host := testutil.Must(url.Parse(storage.URL))(t).Host
+		svr := newServer(t, queryResult(t, storage.URL), NewBlockProxy(storage.Client(), nil, []string{host}))
+
+		res, err := http.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, rawContentType, res.Header.Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf(`"%s.raw"`, block), res.Header.Get("ETag"))
+		require.Equal(t, data, testutil.Must(io.ReadAll(res.Body))(t))
+	})
+
+	t.Run("proxies the verified block from registered provider endpoints", func(t *testing.T) {
+		storage, _ := newStorage(t, shardData)
+		registry := providerregistry.NewDatastoreRegistry(dssync.MutexWrap(datastore.NewMapDatastore()))
+		endpoint := testutil.Must(maurl.FromURL(testutil.Must(url.Parse(storage.URL))(t)))(t)
+		require.NoError(t, registry.Put(t.Context(), types.StorageProvider{
+			DID:       testutil.Service.DID(),
+			PeerIDs:   []peer.ID{testutil.RandomPeer(t)},
+			Endpoints: []string{endpoint.String()},
+			Status:    types.ProviderStatusActive,
+		}))
+		svr := newServer(t, queryResult(t, storage.URL), NewBlockProxy(storage.Client(), registry, nil))
+
+		res, err := http.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, data, testutil.Must(io.ReadAll(res.Body))(t))
+	})
+
+	t.Run("redirects to blocks on other hosts instead of fetching them", func(t *testing.T) {
+		storage, requests := newStorage(t, shardData)
+		svr := newServer(t, queryResult(t, storage.URL), NewBlockProxy(storage.Client(), nil, []string{"storage.example.com"}))
+
+		res, err := noRedirects.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		require.Equal(t, storage.URL, res.Header.Get("Location"))
+		require.Equal(t, fmt.Sprintf("bytes=10-%d", 10+len(data)-1), res.Header.Get(blockRangeHeader))
+		require.Zero(t, requests.Load())
+	})
+
+	t.Run("rejects blocks not matching their hash", func(t *testing.T) {
+		storage, _ := newStorage(t, testutil.RandomBytes(t, len(shardData)))
+		host := testutil.Must(url.Parse(storage.URL))(t).Host
+		svr := newServer(t, queryResult(t, storage.URL), NewBlockProxy(storage.Client(), nil, []string{host}))
+
+		res, err := http.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		require.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		require.Empty(t, res.Header.Get("ETag"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		qr := testutil.Must(queryresult.Build(map[cid.Cid]delegation.Delegation{}, bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0)))(t)
+		svr := newServer(t, qr, nil)
+
+		res, err := http.Get(fmt.Sprintf("%s/block/%s", svr.URL, block))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
+func TestFetchBlock(t *testing.T) {
+	t.Run("rejects blocks larger than the maximum size without fetching them", func(t *testing.T) {
+		var requests atomic.Int32
+		storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+		}))
+		defer storage.Close()
+
+		length := uint64(maxBlockSize + 1)
+		loc := queryresult.BlockLocation{URL: *testutil.Must(url.Parse(storage.URL))(t), Offset: 10, Length: &length}
+		req := httptest.NewRequest(http.MethodGet, "/block/x", nil)
+		_, err := fetchBlock(req, storage.Client(), testutil.Service, testutil.RandomMultihash(t), loc, nil)
+		require.ErrorContains(t, err, "block exceeds")
+		require.Zero(t, requests.Load())
+	})
+}
+
+func TestRetrievalAuth(t *testing.T) {
+	space := testutil.RandomDID(t)
+	digest := testutil.RandomMultihash(t)
+	shard := testutil.RandomMultihash(t)
+	length := uint64(50)
+	loc := queryresult.BlockLocation{
+		URL:    *testutil.Must(url.Parse("https://storage.example.com/blob"))(t),
+		Offset: 100,
+		Length: &length,
+		Shard:  shard,
+		Space:  space,
+		Issuer: testutil.Alice.DID(),
+	}
+	retrieve := testutil.Must(content.Retrieve.Delegate(
+		testutil.Bob,
+		testutil.Service,
+		space.String(),
+		content.RetrieveCaveats{Blob: content.BlobDigest{Digest: shard}},
+	))(t)
+	other := testutil.Must(content.Retrieve.Delegate(
+		testutil.Bob,
+		testutil.Service,
+		testutil.RandomDID(t).String(),
+		content.RetrieveCaveats{Blob: content.BlobDigest{Digest: shard}},
+	))(t)
+
+	t.Run("authorizes retrieval from the shard", func(t *testing.T) {
+		auth := retrievalAuth(testutil.Service, digest, loc, []delegation.Delegation{retrieve, other})
+		require.NotNil(t, auth)
+		require.Equal(t, testutil.Service.DID(), auth.Issuer.DID())
+		require.Equal(t, testutil.Alice.DID(), auth.Audience.DID())
+		require.Equal(t, content.RetrieveAbility, auth.Capability.Can())
+		require.Equal(t, space.String(), auth.Capability.With())
+		caveats, ok := auth.Capability.Nb().(content.RetrieveCaveats)
+		require.True(t, ok)
+		require.Equal(t, shard, caveats.Blob.Digest)
+		require.Equal(t, content.Range{Start: 100, End: 149}, caveats.Range)
+		require.Len(t, auth.Proofs, 1)
+	})
+
+	t.Run("retrieves publicly without delegations for the space", func(t *testing.T) {
+		require.Nil(t, retrievalAuth(testutil.Service, digest, loc, nil))
+		require.Nil(t, retrievalAuth(testutil.Service, digest, loc, []delegation.Delegation{other}))
+	})
+
+	t.Run("retrieves publicly without a byte range", func(t *testing.T) {
+		unbounded := loc
+		unbounded.Length = nil
+		require.Nil(t, retrievalAuth(testutil.Service, digest, unbounded, []delegation.Delegation{retrieve}))
+	})
+}
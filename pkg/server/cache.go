@@ -0,0 +1,37 @@
+package server
+
+import (
+	"strings"
+	"time"
+)
+
+// maxCacheAge is the max-age of responses that never expire, one year.
+const maxCacheAge = 365 * 24 * 60 * 60
+
+// maxAge returns the number of seconds a response can be cached for until
+// exp, in seconds since the Unix epoch, capped to maxCacheAge. Responses that
+// never expire have a nil exp. It returns false if the response has already
+// expired.
+func maxAge(exp *int, now time.Time) (int64, bool) {
+	if exp == nil {
+		return maxCacheAge, true
+	}
+	ttl := int64(*exp) - now.Unix()
+	if ttl <= 0 {
+		return 0, false
+	}
+	return min(ttl, maxCacheAge), true
+}
+
+// etagMatches returns true if an If-None-Match header matches the ETag, using
+// the weak comparison required for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/core/car"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+const (
+	dagJSONContentType = "application/vnd.ipld.dag-json"
+	jsonContentType    = "application/json"
+)
+
+// claimContentTypes are the representations a claim can be served as, the
+// first one being served when the client does not ask for a specific one.
+var claimContentTypes = []string{car.ContentType, dagJSONContentType, jsonContentType}
+
+// claimSummary is the JSON representation of a claim, summarizing its first
+// capability.
+type claimSummary struct {
+	Claim      string          `json:"claim"`
+	Issuer     string          `json:"issuer"`
+	Audience   string          `json:"audience"`
+	Capability string          `json:"capability"`
+	With       string          `json:"with"`
+	Caveats    json.RawMessage `json:"caveats"`
+	// Expiration is the expiry of the claim in seconds since the Unix epoch,
+	// or null if it never expires.
+	Expiration *int `json:"expiration"`
+}
+
+// GetClaimHandler retrieves a single content claim by it's root CID.
+//
+// The claim is served as a CAR archive unless the Accept header asks for its
+// DAG-JSON encoding (application/vnd.ipld.dag-json) or a JSON summary
+// (application/json). Responses can be cached until the claim expires.
+func GetClaimHandler(service types.Getter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		c, err := cid.Parse(parts[len(parts)-1])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CID: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Vary", "Accept")
+		contentType := negotiate(r.Header.Get("Accept"), claimContentTypes)
+		if contentType == "" {
+			http.Error(w, fmt.Sprintf("not acceptable, supported content types: %s", strings.Join(claimContentTypes, ", ")), http.StatusNotAcceptable)
+			return
+		}
+
+		dlg, err := service.Get(r.Context(), cidlink.Link{Cid: c})
+		if err != nil {
+			if errors.Is(err, types.ErrKeyNotFound) {
+				http.Error(w, fmt.Sprintf("not found: %s", c), http.StatusNotFound)
+				return
+			}
+			log.Errorf("getting claim: %s", err)
+			http.Error(w, "failed to get claim", http.StatusInternalServerError)
+			return
+		}
+
+		etag := claimETag(c, contentType)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", claimCacheControl(dlg, time.Now()))
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var body io.Reader
+		switch contentType {
+		case dagJSONContentType:
+			body, err = encodeClaimDAGJSON(dlg)
+		case jsonContentType:
+			body, err = encodeClaimSummary(dlg)
+		default:
+			body = dlg.Archive()
+		}
+		if err != nil {
+			log.Errorf("encoding claim %s: %s", c, err)
+			http.Error(w, "failed to encode claim", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_, err = io.Copy(w, body)
+		if err != nil {
+			log.Warnf("serving claim: %s: %s", c, err)
+		}
+	}
+}
+
+// encodeClaimDAGJSON encodes the root block of a claim as DAG-JSON. Proofs
+// are not included, only linked.
+func encodeClaimDAGJSON(dlg delegation.Delegation) (io.Reader, error) {
+	nd, err := ipld.Decode(dlg.Root().Bytes(), dagcbor.Decode)
+	if err != nil {
+		return nil, fmt.Errorf("decoding claim: %w", err)
+	}
+	data, err := ipld.Encode(nd, dagjson.Encode)
+	if err != nil {
+		return nil, fmt.Errorf("encoding DAG-JSON: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+func encodeClaimSummary(dlg delegation.Delegation) (io.Reader, error) {
+	summary := claimSummary{
+		Claim:      dlg.Link().String(),
+		Issuer:     dlg.Issuer().DID().String(),
+		Audience:   dlg.Audience().DID().String(),
+		Caveats:    json.RawMessage("null"),
+		Expiration: dlg.Expiration(),
+	}
+	if caps := dlg.Capabilities(); len(caps) > 0 {
+		summary.Capability = caps[0].Can()
+		summary.With = caps[0].With()
+		if nb, ok := caps[0].Nb().(datamodel.Node); ok {
+			caveats, err := ipld.Encode(nb, dagjson.Encode)
+			if err != nil {
+				return nil, fmt.Errorf("encoding caveats: %w", err)
+			}
+			summary.Caveats = caveats
+		}
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("encoding JSON: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// claimETag returns a strong ETag for a representation of a claim. The CAR
+// representation is tagged with the claim CID alone.
+func claimETag(c cid.Cid, contentType string) string {
+	switch contentType {
+	case dagJSONContentType:
+		return `"` + c.String() + `.dag-json"`
+	case jsonContentType:
+		return `"` + c.String() + `.json"`
+	default:
+		return `"` + c.String() + `"`
+	}
+}
+
+// claimCacheControl returns the Cache-Control header for a claim. Claims are
+// immutable, but must not be served from a cache once they have expired.
+func claimCacheControl(dlg delegation.Delegation, now time.Time) string {
+	age, ok := maxAge(dlg.Expiration(), now)
+	if !ok {
+		return "no-store"
+	}
+	return fmt.Sprintf("public, max-age=%d, immutable", age)
+}
+
+// negotiate returns the offered media type the Accept header gives the
+// highest quality, preferring earlier offers on ties. It returns the first
+// offer if the header is empty and "" if no offer is acceptable.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQuality {
+			best, bestQuality = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality an Accept header gives a media type,
+// taken from the most specific media range matching it.
+func acceptQuality(accept string, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+		s := -1
+		switch {
+		case mediaRange == mediaType:
+			s = 2
+		case mediaRange == "*/*":
+			s = 0
+		case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity, quality = s, 1
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				quality = q
+			}
+		}
+	}
+	return quality
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// maxFindBatchSize is the maximum number of multihashes that can be found in a
+// single batch request. Each multihash is queried separately while a request
+// only takes one token from the query rate limit, so it is kept small.
+const maxFindBatchSize = 10
+
+// findRequest is the body of an IPNI batch find request.
+type findRequest struct {
+	Multihashes []multihash.Multihash
+}
+
+// GetIPNIMultihashHandler emulates the IPNI find API for a single multihash,
+// sent base58 encoded in a GET request to "/multihash/{multihash}". Results
+// can be filtered by space with "spaces" query parameters, like for "/claims".
+//
+// The location, index and equals claims found by a standard query are
+// returned as provider results of the passed provider, which should be the
+// indexing service itself.
+func GetIPNIMultihashHandler(service types.Querier, provider peer.AddrInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := telemetry.StartSpan(r.Context(), "GetIPNIMultihashHandler")
+		defer s.End()
+
+		parts := strings.Split(r.URL.Path, "/")
+		digest, err := multihash.FromB58String(parts[len(parts)-1])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid multihash: %s", err), http.StatusBadRequest)
+			return
+		}
+		serveFind(ctx, w, r, service, provider, []multihash.Multihash{digest})
+	}
+}
+
+// PostIPNIMultihashHandler emulates the IPNI batch find API, finding the
+// multihashes sent in the JSON body of a POST request to "/multihash".
+func PostIPNIMultihashHandler(service types.Querier, provider peer.AddrInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := telemetry.StartSpan(r.Context(), "PostIPNIMultihashHandler")
+		defer s.End()
+
+		var req findRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("decoding find request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if len(req.Multihashes) == 0 {
+			http.Error(w, "no multihashes in find request", http.StatusBadRequest)
+			return
+		}
+		if len(req.Multihashes) > maxFindBatchSize {
+			http.Error(w, fmt.Sprintf("too many multihashes in find request, maximum is %d", maxFindBatchSize), http.StatusBadRequest)
+			return
+		}
+		for _, digest := range req.Multihashes {
+			if _, err := multihash.Decode(digest); err != nil {
+				http.Error(w, fmt.Sprintf("invalid multihash: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+		serveFind(ctx, w, r, service, provider, req.Multihashes)
+	}
+}
+
+// GetIPNIProvidersHandler lists the providers of the emulated IPNI find API
+// when a GET request is sent to "/providers". These are the passed provider,
+// which index and equals results are attributed to, and the active storage
+// providers in the registry, which location results are attributed to by the
+// peer ID of their DID. A nil registry lists the passed provider only.
+func GetIPNIProvidersHandler(provider peer.AddrInfo, registry types.ProviderRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providers := []model.ProviderInfo{{AddrInfo: provider}}
+		if registry != nil {
+			sps, err := registry.List(r.Context())
+			if err != nil {
+				http.Error(w, fmt.Sprintf("listing storage providers: %s", err), http.StatusInternalServerError)
+				return
+			}
+			for _, sp := range sps {
+				if sp.Status != types.ProviderStatusActive {
+					continue
+				}
+				info, err := storageProviderAddrInfo(sp)
+				if err != nil {
+					log.Debugf("skipping storage provider %s: %s", sp.DID, err)
+					continue
+				}
+				providers = append(providers, model.ProviderInfo{AddrInfo: info})
+			}
+		}
+
+		data, err := json.Marshal(providers)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("marshalling providers: %s", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(data)
+		if err != nil {
+			log.Errorf("sending providers response: %s", err)
+		}
+	}
+}
+
+// storageProviderAddrInfo returns the peer ID of the DID of a storage provider
+// with its endpoints as addresses. Endpoints that are not valid multiaddrs are
+// left out.
+func storageProviderAddrInfo(sp types.StorageProvider) (peer.AddrInfo, error) {
+	id, err := principalparser.ToPeerID(sp.DID)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	info := peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{}}
+	for _, endpoint := range sp.Endpoints {
+		addr, err := multiaddr.NewMultiaddr(endpoint)
+		if err != nil {
+			log.Debugf("skipping endpoint %s of storage provider %s: %s", endpoint, sp.DID, err)
+			continue
+		}
+		info.Addrs = append(info.Addrs, addr)
+	}
+	return info, nil
+}
+
+func serveFind(ctx context.Context, w http.ResponseWriter, r *http.Request, service types.Querier, provider peer.AddrInfo, digests []multihash.Multihash) {
+	spaces, err := parseSpaces(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dlgs, err := parseDelegations(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := find(ctx, service, provider, digests, spaces, dlgs)
+	if err != nil {
+		if errors.Is(err, types.ErrUnauthorizedQuery) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// like IPNI, respond not found if none of the multihashes have results
+	if len(res.MultihashResults) == 0 {
+		http.Error(w, "no results found", http.StatusNotFound)
+		return
+	}
+
+	data, err := model.MarshalFindResponse(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("marshalling find response: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		log.Errorf("sending find response: %s", err)
+	}
+}
+
+// find queries each multihash separately, so that provider results are only
+// returned for the multihash their claims were found for. Multihashes without
+// results are left out of the response.
+func find(ctx context.Context, service types.Querier, provider peer.AddrInfo, digests []multihash.Multihash, spaces []did.DID, dlgs []delegation.Delegation) (*model.FindResponse, error) {
+	res := &model.FindResponse{}
+	for _, digest := range digests {
+		q := types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: []multihash.Multihash{digest},
+			Match: types.Match{
+				Subject: spaces,
+			},
+			Delegations: dlgs,
+		}
+		qr, err := service.Query(ctx, q)
+		logQuery(ctx, q, qr)
+		if err != nil {
+			return nil, fmt.Errorf("processing query: %w", err)
+		}
+
+		results, err := queryresult.ProviderResults(qr, digest, provider)
+		if err != nil {
+			return nil, fmt.Errorf("converting claims to provider results: %w", err)
+		}
+		if len(results) == 0 {
+			continue
+		}
+		res.MultihashResults = append(res.MultihashResults, model.MultihashResult{
+			Multihash:       digest,
+			ProviderResults: results,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPNIFindHandlers(t *testing.T) {
+	provider := peer.AddrInfo{ID: testutil.RandomPeer(t), Addrs: []multiaddr.Multiaddr{testutil.RandomMultiaddr(t)}}
+
+	found := testutil.RandomMultihash(t)
+	claim := testutil.RandomLocationDelegation(t)
+	qr := testutil.Must(queryresult.Build(
+		map[cid.Cid]delegation.Delegation{link.ToCID(claim.Link()): claim},
+		bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0),
+	))(t)
+	missing := testutil.RandomMultihash(t)
+	empty := testutil.Must(queryresult.Build(
+		map[cid.Cid]delegation.Delegation{},
+		bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0),
+	))(t)
+
+	mockService := types.NewMockService(t)
+	query := func(digest multihash.Multihash) types.Query {
+		return types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: []multihash.Multihash{digest},
+			Match:  types.Match{Subject: []did.DID{}},
+		}
+	}
+	mockService.EXPECT().Query(mock.Anything, query(found)).Return(qr, nil).Maybe()
+	mockService.EXPECT().Query(mock.Anything, query(missing)).Return(empty, nil).Maybe()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /multihash/{multihash}", GetIPNIMultihashHandler(mockService, provider))
+	mux.HandleFunc("POST /multihash", PostIPNIMultihashHandler(mockService, provider))
+	registry := providerregistry.NewDatastoreRegistry(dssync.MutexWrap(datastore.NewMapDatastore()))
+	endpoint := testutil.RandomMultiaddr(t)
+	active := testutil.RandomSigner(t)
+	require.NoError(t, registry.Put(t.Context(), types.StorageProvider{
+		DID:       active.DID(),
+		PeerIDs:   []peer.ID{testutil.RandomPeer(t)},
+		Endpoints: []string{endpoint.String()},
+		Status:    types.ProviderStatusActive,
+	}))
+	require.NoError(t, registry.Put(t.Context(), types.StorageProvider{
+		DID:     testutil.RandomSigner(t).DID(),
+		PeerIDs: []peer.ID{testutil.RandomPeer(t)},
+		Status:  types.ProviderStatusSuspended,
+	}))
+	mux.HandleFunc("GET /providers", GetIPNIProvidersHandler(provider, registry))
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	t.Run("GET /multihash/{multihash}", func(t *testing.T) {
+		res, err := http.Get(fmt.Sprintf("%s/multihash/%s", svr.URL, found.B58String()))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		findRes, err := model.UnmarshalFindResponse(testutil.Must(io.ReadAll(res.Body))(t))
+		require.NoError(t, err)
+		require.Len(t, findRes.MultihashResults, 1)
+		require.Equal(t, found, findRes.MultihashResults[0].Multihash)
+		expected := testutil.Must(queryresult.ProviderResults(qr, found, provider))(t)
+		require.Len(t, findRes.MultihashResults[0].ProviderResults, 1)
+		require.True(t, expected[0].Equal(findRes.MultihashResults[0].ProviderResults[0]))
+		// location results are attributed to the claim issuer
+		issuer := testutil.Must(principalparser.ToPeerID(claim.Issuer()))(t)
+		require.Equal(t, issuer, findRes.MultihashResults[0].ProviderResults[0].Provider.ID)
+	})
+
+	t.Run("GET /multihash/{multihash} not found", func(t *testing.T) {
+		res, err := http.Get(fmt.Sprintf("%s/multihash/%s", svr.URL, missing.B58String()))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("GET /multihash/{multihash} invalid multihash", func(t *testing.T) {
+		res, err := http.Get(fmt.Sprintf("%s/multihash/invalid", svr.URL))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("POST /multihash", func(t *testing.T) {
+		body := testutil.Must(json.Marshal(findRequest{Multihashes: []multihash.Multihash{missing, found}}))(t)
+		res, err := http.Post(svr.URL+"/multihash", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		findRes, err := model.UnmarshalFindResponse(testutil.Must(io.ReadAll(res.Body))(t))
+		require.NoError(t, err)
+		require.Len(t, findRes.MultihashResults, 1)
+		require.Equal(t, found, findRes.MultihashResults[0].Multihash)
+	})
+
+	t.Run("POST /multihash without multihashes", func(t *testing.T) {
+		res, err := http.Post(svr.URL+"/multihash", "application/json", bytes.NewReader([]byte(`{"Multihashes":[]}`)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("POST /multihash with too many multihashes", func(t *testing.T) {
+		digests := make([]multihash.Multihash, 0, maxFindBatchSize+1)
+		for range maxFindBatchSize + 1 {
+			digests = append(digests, testutil.RandomMultihash(t))
+		}
+		body := testutil.Must(json.Marshal(findRequest{Multihashes: digests}))(t)
+		res, err := http.Post(svr.URL+"/multihash", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("GET /providers", func(t *testing.T) {
+		res, err := http.Get(svr.URL + "/providers")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var providers []model.ProviderInfo
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&providers))
+		require.Len(t, providers, 2)
+		require.Equal(t, provider.ID, providers[0].AddrInfo.ID)
+		require.Equal(t, provider.Addrs, providers[0].AddrInfo.Addrs)
+		// active storage providers are listed, by the peer ID of their DID
+		require.Equal(t, testutil.Must(principalparser.ToPeerID(active))(t), providers[1].AddrInfo.ID)
+		require.Equal(t, []multiaddr.Multiaddr{endpoint}, providers[1].AddrInfo.Addrs)
+	})
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	hcmsg "github.com/storacha/go-ucanto/transport/headercar/message"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	qdm "github.com/storacha/indexing-service/pkg/service/queryresult/datamodel"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+const dagCBORContentType = "application/vnd.ipld.dag-cbor"
+
+// locationsContentTypes are the encodings block locations can be served in,
+// JSON being served when the client does not ask for a specific one.
+var locationsContentTypes = []string{jsonContentType, dagCBORContentType}
+
+// GetLocateHandler resolves the URLs and byte ranges blocks can be retrieved
+// from when a GET request is sent to "/locate/{cid}". More blocks can be
+// located in the same request with "cid" query parameters, and results can be
+// filtered by space with "spaces" query parameters, like for "/claims".
+//
+// Locations are computed from the claims and indexes found by a standard
+// query, and returned as JSON or DAG-CBOR depending on the Accept header.
+func GetLocateHandler(service types.Querier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := telemetry.StartSpan(r.Context(), "GetLocateHandler")
+		defer s.End()
+
+		parts := strings.Split(r.URL.Path, "/")
+		cidStrings := append([]string{parts[len(parts)-1]}, r.URL.Query()["cid"]...)
+		cids := make([]cid.Cid, 0, len(cidStrings))
+		hashes := make([]multihash.Multihash, 0, len(cidStrings))
+		for _, cidString := range cidStrings {
+			c, err := cid.Parse(cidString)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid CID: %s", err), http.StatusBadRequest)
+				return
+			}
+			cids = append(cids, c)
+			hashes = append(hashes, c.Hash())
+		}
+
+		// responses are also cached per encoding, see withGzip
+		w.Header().Add("Vary", "Accept")
+		contentType := negotiate(r.Header.Get("Accept"), locationsContentTypes)
+		if contentType == "" {
+			http.Error(w, fmt.Sprintf("not acceptable, supported content types: %s", strings.Join(locationsContentTypes, ", ")), http.StatusNotAcceptable)
+			return
+		}
+
+		spaces, err := parseSpaces(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		dlgs, err := parseDelegations(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: hashes,
+			Match: types.Match{
+				Subject: spaces,
+			},
+			Delegations: dlgs,
+		}
+		qr, err := service.Query(ctx, q)
+		logQuery(ctx, q, qr)
+		if err != nil {
+			if errors.Is(err, types.ErrUnauthorizedQuery) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, fmt.Sprintf("processing query: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		locations, err := queryresult.Locate(qr, hashes)
+		if err != nil {
+			telemetry.Error(s, err, "locating blocks")
+			http.Error(w, fmt.Sprintf("locating blocks: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		found := false
+		model := qdm.LocationsModel{Blocks: make([]qdm.BlockLocationsModel, 0, len(cids))}
+		for i, c := range cids {
+			found = found || len(locations[i]) > 0
+			model.Blocks = append(model.Blocks, toBlockLocationsModel(c, locations[i]))
+		}
+		if !found {
+			http.Error(w, "no locations found", http.StatusNotFound)
+			return
+		}
+
+		// the locations are derived from the content addressed query result. The
+		// ETag is weak since the response may be gzipped.
+		etag := fmt.Sprintf(`W/"%s.locations.%s"`, qr.Root().Link(), strings.TrimPrefix(contentType, "application/"))
+		w.Header().Set("ETag", etag)
+		// delegations in the agent message may authorize private results
+		w.Header().Add("Vary", hcmsg.HeaderName)
+		w.Header().Set("Cache-Control", queryResultCacheControl(qr, r.Header.Get(hcmsg.HeaderName) != "", time.Now()))
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		encode := dagjson.Encode
+		if contentType == dagCBORContentType {
+			encode = dagcbor.Encode
+		}
+		data, err := ipld.Marshal(encode, &model, qdm.LocationsType())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("encoding locations: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_, err = w.Write(data)
+		if err != nil {
+			log.Errorf("sending locations response: %s", err)
+		}
+	}
+}
+
+func toBlockLocationsModel(c cid.Cid, locations []queryresult.BlockLocation) qdm.BlockLocationsModel {
+	model := qdm.BlockLocationsModel{
+		Cid:       c.String(),
+		Multihash: digestutil.Format(c.Hash()),
+		Locations: make([]qdm.BlockLocationModel, 0, len(locations)),
+	}
+	for _, loc := range locations {
+		lm := qdm.BlockLocationModel{
+			Url:    loc.URL.String(),
+			Offset: int64(loc.Offset),
+		}
+		if rng := loc.Range(); rng != "" {
+			lm.Range = &rng
+		}
+		if loc.Length != nil {
+			length := int64(*loc.Length)
+			lm.Length = &length
+		}
+		if loc.Shard != nil {
+			shard := digestutil.Format(loc.Shard)
+			lm.Shard = &shard
+		}
+		if loc.Expiration != nil {
+			exp := int64(*loc.Expiration)
+			lm.Expiration = &exp
+		}
+		model.Locations = append(model.Locations, lm)
+	}
+	return model
+}
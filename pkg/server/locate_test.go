@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	hcmsg "github.com/storacha/go-ucanto/transport/headercar/message"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	qdm "github.com/storacha/indexing-service/pkg/service/queryresult/datamodel"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLocateHandler(t *testing.T) {
+	block := cid.NewCidV1(cid.Raw, testutil.RandomMultihash(t))
+	shard := testutil.RandomMultihash(t)
+	index := blobindex.NewShardedDagIndexView(cidlink.Link{Cid: block}, 1)
+	index.SetSlice(shard, block.Hash(), blobindex.Position{Offset: 10, Length: 20})
+	shardURL := testutil.Must(url.Parse("https://storage.example.com/blob"))(t)
+	shardClaim := testutil.Must(assert.Location.Delegate(testutil.Service, testutil.Service, testutil.Service.DID().String(), assert.LocationCaveats{
+		Content:  ctypes.FromHash(shard),
+		Location: []url.URL{*shardURL},
+	}, delegation.WithNoExpiration()))(t)
+	indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](1)
+	indexes.Set(types.EncodedContextID("index"), index)
+	qr := testutil.Must(queryresult.Build(map[cid.Cid]delegation.Delegation{link.ToCID(shardClaim.Link()): shardClaim}, indexes))(t)
+
+	other := cid.NewCidV1(cid.Raw, testutil.RandomMultihash(t))
+	mockService := types.NewMockService(t)
+	mockService.EXPECT().Query(mock.Anything, types.Query{
+		Type:   types.QueryTypeStandard,
+		Hashes: []multihash.Multihash{block.Hash(), other.Hash()},
+		Match:  types.Match{Subject: []did.DID{}},
+	}).Return(qr, nil)
+	mockService.EXPECT().Query(mock.Anything, types.Query{
+		Type:   types.QueryTypeStandard,
+		Hashes: []multihash.Multihash{other.Hash()},
+		Match:  types.Match{Subject: []did.DID{}},
+	}).Return(qr, nil)
+
+	svr := httptest.NewServer(GetLocateHandler(mockService))
+	defer svr.Close()
+
+	get := func(t *testing.T, path string, accept string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, svr.URL+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	path := fmt.Sprintf("/locate/%s?cid=%s", block, other)
+
+	t.Run("JSON", func(t *testing.T) {
+		res := get(t, path, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf(`W/"%s.locations.json"`, qr.Root().Link()), res.Header.Get("ETag"))
+		require.Equal(t, []string{"Accept", hcmsg.HeaderName}, res.Header.Values("Vary"))
+
+		var locations struct {
+			Blocks []struct {
+				Cid       string `json:"cid"`
+				Multihash string `json:"multihash"`
+				Locations []struct {
+					URL    string  `json:"url"`
+					Offset uint64  `json:"offset"`
+					Length *uint64 `json:"length"`
+					Range  string  `json:"range"`
+					Shard  *string `json:"shard"`
+				} `json:"locations"`
+			} `json:"blocks"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&locations))
+		require.Len(t, locations.Blocks, 2)
+
+		b := locations.Blocks[0]
+		require.Equal(t, block.String(), b.Cid)
+		require.Equal(t, digestutil.Format(block.Hash()), b.Multihash)
+		require.Len(t, b.Locations, 1)
+		require.Equal(t, shardURL.String(), b.Locations[0].URL)
+		require.Equal(t, uint64(10), b.Locations[0].Offset)
+		require.Equal(t, uint64(20), *b.Locations[0].Length)
+		require.Equal(t, "bytes=10-29", b.Locations[0].Range)
+		require.Equal(t, digestutil.Format(shard), *b.Locations[0].Shard)
+
+		require.Equal(t, other.String(), locations.Blocks[1].Cid)
+		require.Empty(t, locations.Blocks[1].Locations)
+	})
+
+	t.Run("DAG-CBOR", func(t *testing.T) {
+		res := get(t, path, "application/vnd.ipld.dag-cbor")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/vnd.ipld.dag-cbor", res.Header.Get("Content-Type"))
+
+		var locations qdm.LocationsModel
+		_, err := ipld.Unmarshal(testutil.Must(io.ReadAll(res.Body))(t), dagcbor.Decode, &locations, qdm.LocationsType())
+		require.NoError(t, err)
+		require.Len(t, locations.Blocks, 2)
+		require.Equal(t, "bytes=10-29", *locations.Blocks[0].Locations[0].Range)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		res := get(t, fmt.Sprintf("/locate/%s", other), "")
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("invalid CID", func(t *testing.T) {
+		res := get(t, "/locate/invalid", "")
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		res := get(t, path, "application/vnd.ipld.car")
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	})
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// WithProviderRegistry sets the storage provider registry, whose active
+// providers are listed on GET /providers with [WithIPNIFind].
+func WithProviderRegistry(registry types.ProviderRegistry) Option {
+	return func(c *config) error {
+		c.providerRegistry = registry
+		return nil
+	}
+}
+
+// WithProviderAdmin sets the storage provider registry and exposes an admin
+// API for it at /admin/providers. Requests must carry the passed token as a
+// bearer token.
+func WithProviderAdmin(registry types.ProviderRegistry, token string) Option {
+	return func(c *config) error {
+		if token == "" {
+			return errors.New("missing admin token for provider registry")
+		}
+		c.adminToken = token
+		return WithProviderRegistry(registry)(c)
+	}
+}
+
+// withAdminAuth responds with 401 Unauthorized to requests that do not carry
+// the admin token as a bearer token.
+func withAdminAuth(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// ListProvidersHandler lists the storage providers in the registry.
+func ListProvidersHandler(registry types.ProviderRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providers, err := registry.List(r.Context())
+		if err != nil {
+			log.Errorf("listing storage providers: %s", err)
+			http.Error(w, "failed to list storage providers", http.StatusInternalServerError)
+			return
+		}
+		if providers == nil {
+			providers = []types.StorageProvider{}
+		}
+		writeJSON(w, providers)
+	}
+}
+
+// GetProviderHandler gets a storage provider from the registry by DID.
+func GetProviderHandler(registry types.ProviderRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := did.Parse(r.PathValue("did"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid DID: %s", err), http.StatusBadRequest)
+			return
+		}
+		provider, err := registry.Get(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, types.ErrKeyNotFound) {
+				http.Error(w, fmt.Sprintf("not found: %s", id), http.StatusNotFound)
+				return
+			}
+			log.Errorf("getting storage provider: %s", err)
+			http.Error(w, "failed to get storage provider", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, provider)
+	}
+}
+
+// PutProviderHandler adds or replaces a storage provider in the registry. The
+// DID in the body, if set, must match the DID in the path.
+func PutProviderHandler(registry types.ProviderRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := did.Parse(r.PathValue("did"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid DID: %s", err), http.StatusBadRequest)
+			return
+		}
+		var provider types.StorageProvider
+		if err := json.NewDecoder(r.Body).Decode(&provider); err != nil {
+			http.Error(w, fmt.Sprintf("invalid storage provider: %s", err), http.StatusBadRequest)
+			return
+		}
+		if provider.DID.Defined() && provider.DID != id {
+			http.Error(w, "storage provider DID does not match path", http.StatusBadRequest)
+			return
+		}
+		provider.DID = id
+		if err := providerregistry.Validate(provider); err != nil {
+			http.Error(w, fmt.Sprintf("invalid storage provider: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := registry.Put(r.Context(), provider); err != nil {
+			log.Errorf("storing storage provider: %s", err)
+			http.Error(w, "failed to store storage provider", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, provider)
+	}
+}
+
+// DeleteProviderHandler removes a storage provider from the registry.
+func DeleteProviderHandler(registry types.ProviderRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := did.Parse(r.PathValue("did"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid DID: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := registry.Delete(r.Context(), id); err != nil {
+			log.Errorf("deleting storage provider: %s", err)
+			http.Error(w, "failed to delete storage provider", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("writing response: %s", err)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderAdmin(t *testing.T) {
+	registry := providerregistry.NewDatastoreRegistry(datastore.NewMapDatastore())
+	mux, err := NewServer(types.NewMockService(t), WithIdentity(testutil.Service), WithProviderAdmin(registry, "secret"))
+	require.NoError(t, err)
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	provider := types.StorageProvider{
+		DID:     testutil.Alice.DID(),
+		PeerIDs: []peer.ID{testutil.RandomPeer(t)},
+		Status:  types.ProviderStatusActive,
+	}
+
+	request := func(t *testing.T, method, path, token string, body any) *http.Response {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, err := http.NewRequest(method, svr.URL+path, &buf)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	t.Run("rejects requests without the admin token", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/admin/providers", "", nil)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = request(t, http.MethodPut, "/admin/providers/"+provider.DID.String(), "wrong", provider)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("adds, gets, lists and deletes providers", func(t *testing.T) {
+		res := request(t, http.MethodPut, "/admin/providers/"+provider.DID.String(), "secret", provider)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = request(t, http.MethodGet, "/admin/providers/"+provider.DID.String(), "secret", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var got types.StorageProvider
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		require.Equal(t, provider, got)
+
+		res = request(t, http.MethodGet, "/admin/providers", "secret", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var list []types.StorageProvider
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&list))
+		require.Equal(t, []types.StorageProvider{provider}, list)
+
+		res = request(t, http.MethodDelete, "/admin/providers/"+provider.DID.String(), "secret", nil)
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = request(t, http.MethodGet, "/admin/providers/"+provider.DID.String(), "secret", nil)
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("rejects invalid providers", func(t *testing.T) {
+		invalid := provider
+		invalid.Status = "unknown"
+		res := request(t, http.MethodPut, "/admin/providers/"+provider.DID.String(), "secret", invalid)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res = request(t, http.MethodPut, "/admin/providers/"+testutil.Bob.DID().String(), "secret", provider)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestProviderRegistryWithoutAdmin(t *testing.T) {
+	registry := providerregistry.NewDatastoreRegistry(datastore.NewMapDatastore())
+	provider := types.StorageProvider{
+		DID:     testutil.Alice.DID(),
+		PeerIDs: []peer.ID{testutil.RandomPeer(t)},
+		Status:  types.ProviderStatusActive,
+	}
+	require.NoError(t, registry.Put(t.Context(), provider))
+	mux, err := NewServer(types.NewMockService(t), WithIdentity(testutil.Service), WithIPNIFind(peer.AddrInfo{ID: testutil.RandomPeer(t)}), WithProviderRegistry(registry))
+	require.NoError(t, err)
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	t.Run("lists registered providers", func(t *testing.T) {
+		res, err := http.Get(svr.URL + "/providers")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var infos []json.RawMessage
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&infos))
+		require.Len(t, infos, 2)
+	})
+
+	t.Run("does not expose the admin API", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, svr.URL+"/admin/providers/"+provider.DID.String(), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer ")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/storacha/indexing-service/pkg/ratelimit"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// WithRateLimit limits the rate of requests to the handler by client IP
+// address, responding with 429 Too Many Requests to clients that exceed their
+// limit. A nil limiter returns the handler unchanged.
+//
+// trustedProxies is the number of reverse proxies (e.g. load balancers) in
+// front of the server that append the address they received a request from to
+// the X-Forwarded-For header. The client address is read from the header at
+// that depth, so that addresses added by clients are ignored.
+func WithRateLimit(limiter *ratelimit.Limiter, trustedProxies int, handler http.HandlerFunc) http.HandlerFunc {
+	if limiter == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := limiter.Allow(r.Context(), clientIP(r, trustedProxies))
+		var rle types.RateLimitedError
+		if errors.As(err, &rle) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rle.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// clientIP returns the IP address of the client making the request. With no
+// trusted proxies, it is the remote address of the connection. Otherwise it is
+// the address the outermost trusted proxy received the request from, which is
+// the entry of the X-Forwarded-For header at the depth of the trusted proxies,
+// counting from the right. If there are fewer entries than trusted proxies,
+// the leftmost entry is used.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var forwarded []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, addr := range strings.Split(header, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					forwarded = append(forwarded, addr)
+				}
+			}
+		}
+		if len(forwarded) > 0 {
+			return forwarded[max(len(forwarded)-trustedProxies, 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	limiter, err := ratelimit.NewLimiter("query", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 0.5, Burst: 1})
+	require.NoError(t, err)
+
+	svr := httptest.NewServer(WithRateLimit(limiter, 0, GetRootHandler(testutil.Service)))
+	defer svr.Close()
+
+	res, err := http.Get(svr.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = http.Get(svr.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "2", res.Header.Get("Retry-After"))
+}
+
+func TestClientIP(t *testing.T) {
+	request := func(forwarded ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		for _, f := range forwarded {
+			r.Header.Add("X-Forwarded-For", f)
+		}
+		return r
+	}
+
+	t.Run("uses the remote address without trusted proxies", func(t *testing.T) {
+		require.Equal(t, "10.0.0.1", clientIP(request("1.1.1.1"), 0))
+	})
+
+	t.Run("reads X-Forwarded-For at the depth of trusted proxies", func(t *testing.T) {
+		// the client spoofed 6.6.6.6, the outer proxy saw 1.1.1.1 and the
+		// inner proxy saw the outer proxy at 2.2.2.2
+		r := request("6.6.6.6, 1.1.1.1", "2.2.2.2")
+		require.Equal(t, "2.2.2.2", clientIP(r, 1))
+		require.Equal(t, "1.1.1.1", clientIP(r, 2))
+	})
+
+	t.Run("uses the leftmost address if there are fewer than trusted proxies", func(t *testing.T) {
+		require.Equal(t, "1.1.1.1", clientIP(request("1.1.1.1"), 2))
+	})
+
+	t.Run("uses the remote address without X-Forwarded-For", func(t *testing.T) {
+		require.Equal(t, "10.0.0.1", clientIP(request(), 1))
+	})
+}
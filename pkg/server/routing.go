@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	rtypes "github.com/ipfs/boxo/routing/http/types"
+	jsontypes "github.com/ipfs/boxo/routing/http/types/json"
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/validator"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// gatewayHTTPProtocol is the transfer protocol of trustless gateways.
+	gatewayHTTPProtocol = "transport-ipfs-gateway-http"
+)
+
+// routingContentTypes are the encodings provider records can be served in,
+// JSON being served when the client does not ask for a specific one.
+var routingContentTypes = []string{jsonContentType, ndjsonContentType}
+
+// GetRoutingProvidersHandler answers delegated routing (Routing V1 HTTP API)
+// provider lookups sent in GET requests to "/routing/v1/providers/{cid}".
+//
+// The location commitments found by a standard query are mapped to peer
+// records of their issuers, with the origins of the claimed locations as HTTP
+// transfer addresses. Records are served as JSON, or streamed as NDJSON if the
+// Accept header asks for it.
+func GetRoutingProvidersHandler(service types.Querier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := telemetry.StartSpan(r.Context(), "GetRoutingProvidersHandler")
+		defer s.End()
+
+		parts := strings.Split(r.URL.Path, "/")
+		c, err := cid.Parse(parts[len(parts)-1])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CID: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Vary", "Accept")
+		contentType := negotiate(r.Header.Get("Accept"), routingContentTypes)
+		if contentType == "" {
+			http.Error(w, fmt.Sprintf("not acceptable, supported content types: %s", strings.Join(routingContentTypes, ", ")), http.StatusNotAcceptable)
+			return
+		}
+
+		q := types.Query{
+			Type:   types.QueryTypeStandard,
+			Hashes: []multihash.Multihash{c.Hash()},
+			Match: types.Match{
+				Subject: []did.DID{},
+			},
+		}
+		qr, err := service.Query(ctx, q)
+		logQuery(ctx, q, qr)
+		if err != nil {
+			if errors.Is(err, types.ErrUnauthorizedQuery) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, fmt.Sprintf("processing query: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", queryResultCacheControl(qr, false, time.Now()))
+		if contentType == ndjsonContentType {
+			streamPeerRecords(w, c, peerRecords(qr))
+			return
+		}
+
+		// records of the same peer are merged, since a JSON response is only
+		// sent once all of them are known
+		providers := jsontypes.RecordsArray{}
+		byPeer := map[peer.ID]*rtypes.PeerRecord{}
+		for record, err := range peerRecords(qr) {
+			if err != nil {
+				telemetry.Error(s, err, "building peer records")
+				http.Error(w, fmt.Sprintf("building peer records: %s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			if merged, ok := byPeer[*record.ID]; ok {
+				merged.Addrs = append(merged.Addrs, record.Addrs...)
+				continue
+			}
+			byPeer[*record.ID] = record
+			providers = append(providers, record)
+		}
+		if len(providers) == 0 {
+			http.Error(w, fmt.Sprintf("no providers found for CID: %s", c), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		err = json.NewEncoder(w).Encode(jsontypes.ProvidersResponse{Providers: providers})
+		if err != nil {
+			log.Errorf("sending providers response: %s", err)
+		}
+	}
+}
+
+// streamPeerRecords writes each peer record as NDJSON as soon as it is built,
+// flushing it to the client. The response status is only sent with the first
+// record, so that errors and the absence of records can still be reported.
+func streamPeerRecords(w http.ResponseWriter, c cid.Cid, records iter.Seq2[*rtypes.PeerRecord, error]) {
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	sent := false
+	for record, err := range records {
+		if err != nil {
+			if !sent {
+				http.Error(w, fmt.Sprintf("building peer records: %s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			log.Errorf("building peer records: %s", err)
+			return
+		}
+		if !sent {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			sent = true
+		}
+		if err := enc.Encode(record); err != nil {
+			log.Errorf("sending provider record: %s", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if !sent {
+		http.Error(w, fmt.Sprintf("no providers found for CID: %s", c), http.StatusNotFound)
+	}
+}
+
+// peerRecords maps the location commitments in a query result to peer records
+// of their issuers, built as the claims are decoded. A record is produced for
+// each location commitment with the origins of its locations that were not
+// already produced for its issuer, so an issuer may have several records; its
+// first record is produced even if it has no new addresses. Issuers that
+// cannot be converted to a peer ID, like did:web storage nodes, are skipped.
+func peerRecords(qr types.QueryResult) iter.Seq2[*rtypes.PeerRecord, error] {
+	return func(yield func(*rtypes.PeerRecord, error) bool) {
+		blocks, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(qr.Blocks()))
+		if err != nil {
+			yield(nil, fmt.Errorf("reading blocks from query result: %w", err))
+			return
+		}
+
+		seen := map[peer.ID][]rtypes.Multiaddr{}
+		for _, root := range qr.Claims() {
+			claim, err := delegation.NewDelegationView(root, blocks)
+			if err != nil {
+				yield(nil, fmt.Errorf("decoding claim %s: %w", root, err))
+				return
+			}
+			if len(claim.Capabilities()) == 0 {
+				continue
+			}
+			match, err := assert.Location.Match(validator.NewSource(claim.Capabilities()[0], claim))
+			if err != nil {
+				continue
+			}
+
+			id, err := principalparser.ToPeerID(claim.Issuer())
+			if err != nil {
+				log.Debugf("skipping location commitment %s of issuer %s: %s", root, claim.Issuer().DID(), err)
+				continue
+			}
+			known, ok := seen[id]
+			record := &rtypes.PeerRecord{
+				Schema:    rtypes.SchemaPeer,
+				ID:        &id,
+				Addrs:     []rtypes.Multiaddr{},
+				Protocols: []string{gatewayHTTPProtocol},
+			}
+			for _, location := range match.Value().Nb().Location {
+				// trustless gateways are addressed by the origin they serve from
+				addr, err := maurl.FromURL(&url.URL{Scheme: location.Scheme, Host: location.Host})
+				if err != nil {
+					log.Debugf("skipping location %s: %s", location.String(), err)
+					continue
+				}
+				if slices.ContainsFunc(known, func(a rtypes.Multiaddr) bool { return a.Equal(addr) }) {
+					continue
+				}
+				known = append(known, rtypes.Multiaddr{Multiaddr: addr})
+				record.Addrs = append(record.Addrs, rtypes.Multiaddr{Multiaddr: addr})
+			}
+			seen[id] = known
+			if ok && len(record.Addrs) == 0 {
+				continue
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
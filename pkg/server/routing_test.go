@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/service/queryresult"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRoutingProvidersHandler(t *testing.T) {
+	content := cid.NewCidV1(cid.Raw, testutil.RandomMultihash(t))
+	locationClaim := func(t *testing.T, issuer principal.Signer, locations ...string) delegation.Delegation {
+		var urls []url.URL
+		for _, l := range locations {
+			urls = append(urls, *testutil.Must(url.Parse(l))(t))
+		}
+		return testutil.Must(assert.Location.Delegate(issuer, issuer, issuer.DID().String(), assert.LocationCaveats{
+			Content:  ctypes.FromHash(testutil.RandomMultihash(t)),
+			Location: urls,
+		}, delegation.WithNoExpiration()))(t)
+	}
+
+	claims := map[cid.Cid]delegation.Delegation{}
+	for _, claim := range []delegation.Delegation{
+		locationClaim(t, testutil.Alice, "https://alice.example.com/blob/1", "https://alice.example.com/blob/2"),
+		locationClaim(t, testutil.Bob, "https://bob.example.com:8443/blob/1"),
+		// origins already found for an issuer add no records
+		locationClaim(t, testutil.Alice, "https://alice.example.com/blob/3"),
+		testutil.RandomIndexDelegation(t),
+		testutil.Must(delegation.Delegate(testutil.Alice, testutil.Alice, []ucan.Capability[ucan.NoCaveats]{}))(t),
+	} {
+		claims[link.ToCID(claim.Link())] = claim
+	}
+	qr := testutil.Must(queryresult.Build(claims, bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0)))(t)
+
+	// a result without location commitments has no providers
+	empty := cid.NewCidV1(cid.Raw, testutil.RandomMultihash(t))
+	indexClaim := testutil.RandomIndexDelegation(t)
+	emptyQr := testutil.Must(queryresult.Build(
+		map[cid.Cid]delegation.Delegation{link.ToCID(indexClaim.Link()): indexClaim},
+		bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0),
+	))(t)
+
+	mockService := types.NewMockService(t)
+	mockService.EXPECT().Query(mock.Anything, mock.MatchedBy(func(q types.Query) bool {
+		return q.Hashes[0].String() == content.Hash().String()
+	})).Return(qr, nil).Maybe()
+	mockService.EXPECT().Query(mock.Anything, mock.MatchedBy(func(q types.Query) bool {
+		return q.Hashes[0].String() == empty.Hash().String()
+	})).Return(emptyQr, nil).Maybe()
+
+	// records are streamed through the access logger
+	logger := NewAccessLogger(io.Discard, 1)
+	svr := httptest.NewServer(logger.Wrap("GET /routing/v1/providers/{cid}", GetRoutingProvidersHandler(mockService)))
+	defer svr.Close()
+
+	get := func(t *testing.T, c cid.Cid, accept string) *http.Response {
+		req := testutil.Must(http.NewRequest(http.MethodGet, fmt.Sprintf("%s/routing/v1/providers/%s", svr.URL, c), nil))(t)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := testutil.Must(http.DefaultClient.Do(req))(t)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	type record struct {
+		Schema    string
+		ID        string
+		Addrs     []string
+		Protocols []string
+	}
+	addr := func(t *testing.T, origin string) string {
+		return testutil.Must(maurl.FromURL(testutil.Must(url.Parse(origin))(t)))(t).String()
+	}
+	expected := []record{
+		{
+			Schema:    "peer",
+			ID:        testutil.Must(principalparser.ToPeerID(testutil.Alice))(t).String(),
+			Addrs:     []string{addr(t, "https://alice.example.com")},
+			Protocols: []string{"transport-ipfs-gateway-http"},
+		},
+		{
+			Schema:    "peer",
+			ID:        testutil.Must(principalparser.ToPeerID(testutil.Bob))(t).String(),
+			Addrs:     []string{addr(t, "https://bob.example.com:8443")},
+			Protocols: []string{"transport-ipfs-gateway-http"},
+		},
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		res := get(t, content, "application/json")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		var body struct{ Providers []record }
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		require.ElementsMatch(t, expected, body.Providers)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		res := get(t, content, "application/x-ndjson")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+		var records []record
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			var r record
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+			records = append(records, r)
+		}
+		require.NoError(t, scanner.Err())
+		require.ElementsMatch(t, expected, records)
+	})
+
+	t.Run("no location commitments", func(t *testing.T) {
+		res := get(t, empty, "")
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		res := get(t, content, "application/vnd.ipld.car")
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	})
+}
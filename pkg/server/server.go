@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -37,6 +38,7 @@ import (
 	hcmsg "github.com/storacha/go-ucanto/transport/headercar/message"
 	ucanhttp "github.com/storacha/go-ucanto/transport/http"
 	"github.com/storacha/indexing-service/pkg/build"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 	"github.com/storacha/indexing-service/pkg/telemetry"
 	"github.com/storacha/indexing-service/pkg/types"
@@ -56,6 +58,15 @@ type config struct {
 	enableTelemetry      bool
 	ipniConfig           *ipniConfig
 	publisherStore       store.PublisherStore
+	metricsHandler       http.Handler
+	accessLogger         *AccessLogger
+	publishLimiter       *ratelimit.Limiter
+	queryLimiter         *ratelimit.Limiter
+	trustedProxies       int
+	providerRegistry     types.ProviderRegistry
+	adminToken           string
+	blockProxy           *BlockProxy
+	ipniFindProvider     *peer.AddrInfo
 }
 
 type Option func(*config) error
@@ -82,6 +93,78 @@ func WithTelemetry() Option {
 	}
 }
 
+// WithMetrics exposes metrics served by the passed handler on GET /metrics,
+// e.g. the handler returned by [telemetry.SetupMetrics].
+func WithMetrics(handler http.Handler) Option {
+	return func(c *config) error {
+		c.metricsHandler = handler
+		return nil
+	}
+}
+
+// WithAccessLog logs requests to the passed access logger.
+func WithAccessLog(logger *AccessLogger) Option {
+	return func(c *config) error {
+		c.accessLogger = logger
+		return nil
+	}
+}
+
+// WithPublishRateLimit limits the rate at which each issuer may publish and
+// cache claims.
+func WithPublishRateLimit(limiter *ratelimit.Limiter) Option {
+	return func(c *config) error {
+		c.publishLimiter = limiter
+		return nil
+	}
+}
+
+// WithQueryRateLimit limits the rate at which each client IP address may query
+// claims.
+func WithQueryRateLimit(limiter *ratelimit.Limiter) Option {
+	return func(c *config) error {
+		c.queryLimiter = limiter
+		return nil
+	}
+}
+
+// WithTrustedProxies sets the number of reverse proxies in front of the server
+// that append to the X-Forwarded-For header, so that queries are rate limited
+// by the address of the client rather than of the nearest proxy.
+func WithTrustedProxies(hops int) Option {
+	return func(c *config) error {
+		if hops < 0 {
+			return fmt.Errorf("trusted proxies must not be negative, got %d", hops)
+		}
+		c.trustedProxies = hops
+		return nil
+	}
+}
+
+// WithBlockProxy serves blocks requested on GET /block/{cid} that are stored
+// within larger resources by fetching them with the passed proxy. Without it,
+// clients are redirected to the resources holding the blocks, with the byte
+// range of blocks stored within larger resources in the X-Block-Range header.
+func WithBlockProxy(proxy *BlockProxy) Option {
+	return func(c *config) error {
+		c.blockProxy = proxy
+		return nil
+	}
+}
+
+// WithIPNIFind serves an IPNI compatible find API on GET /multihash/{mh},
+// POST /multihash and GET /providers, returning claims as provider results of
+// the passed provider, or of their issuer for location commitments. The
+// provider should be the peer ID and public addresses the indexing service
+// publishes claims to IPNI with. Active storage providers in the registry set
+// with [WithProviderRegistry] are also listed on GET /providers.
+func WithIPNIFind(provider peer.AddrInfo) Option {
+	return func(c *config) error {
+		c.ipniFindProvider = &provider
+		return nil
+	}
+}
+
 func WithIPNI(provider peer.AddrInfo, metadata metadata.Metadata) Option {
 	return func(c *config) error {
 		mb, err := metadata.MarshalBinary()
@@ -145,16 +228,44 @@ func NewServer(indexer types.Service, opts ...Option) (*http.ServeMux, error) {
 		log.Infof("Server ID: %s", c.id.DID())
 	}
 
+	if c.publishLimiter != nil {
+		indexer = ratelimit.NewService(indexer, c.publishLimiter)
+	}
+
 	mux := http.NewServeMux()
-	maybeInstrumentAndAdd(mux, "GET /", GetRootHandler(c.id), c.enableTelemetry)
-	maybeInstrumentAndAdd(mux, "GET /claim/{claim}", GetClaimHandler(indexer), c.enableTelemetry)
+	add := func(route string, handler http.HandlerFunc) {
+		maybeInstrumentAndAdd(mux, route, c.accessLogger.Wrap(route, handler), c.enableTelemetry)
+	}
+	limitQueries := func(handler http.HandlerFunc) http.HandlerFunc {
+		return WithRateLimit(c.queryLimiter, c.trustedProxies, handler)
+	}
+	add("GET /", GetRootHandler(c.id))
+	add("GET /claim/{claim}", GetClaimHandler(indexer))
 	// temporary fix: post claims handler accessible at POST / too
-	maybeInstrumentAndAdd(mux, "POST /", PostClaimsHandler(c.id, indexer, c.contentClaimsOptions...), c.enableTelemetry)
-	maybeInstrumentAndAdd(mux, "POST /claims", PostClaimsHandler(c.id, indexer, c.contentClaimsOptions...), c.enableTelemetry)
-	maybeInstrumentAndAdd(mux, "GET /claims", withGzip(GetClaimsHandler(indexer)), c.enableTelemetry)
-	maybeInstrumentAndAdd(mux, "GET /.well-known/did.json", GetDIDDocument(c.id), c.enableTelemetry)
+	add("POST /", PostClaimsHandler(c.id, indexer, c.contentClaimsOptions...))
+	add("POST /claims", PostClaimsHandler(c.id, indexer, c.contentClaimsOptions...))
+	add("GET /claims", limitQueries(withGzip(GetClaimsHandler(indexer))))
+	add("GET /locate/{cid}", limitQueries(withGzip(GetLocateHandler(indexer))))
+	add("GET /block/{cid}", limitQueries(GetBlockHandler(c.id, indexer, c.blockProxy)))
+	// not gzipped, so NDJSON responses are streamed
+	add("GET /routing/v1/providers/{cid}", limitQueries(GetRoutingProvidersHandler(indexer)))
+	add("GET /.well-known/did.json", GetDIDDocument(c.id))
 	if c.ipniConfig != nil {
-		maybeInstrumentAndAdd(mux, "GET /cid/{cid}", GetIPNICIDHandler(indexer, c.ipniConfig), c.enableTelemetry)
+		add("GET /cid/{cid}", GetIPNICIDHandler(indexer, c.ipniConfig))
+	}
+	if c.ipniFindProvider != nil {
+		add("GET /multihash/{multihash}", limitQueries(withGzip(GetIPNIMultihashHandler(indexer, *c.ipniFindProvider))))
+		add("POST /multihash", limitQueries(withGzip(PostIPNIMultihashHandler(indexer, *c.ipniFindProvider))))
+		add("GET /providers", GetIPNIProvidersHandler(*c.ipniFindProvider, c.providerRegistry))
+	}
+	if c.providerRegistry != nil && c.adminToken != "" {
+		add("GET /admin/providers", withAdminAuth(c.adminToken, ListProvidersHandler(c.providerRegistry)))
+		add("GET /admin/providers/{did}", withAdminAuth(c.adminToken, GetProviderHandler(c.providerRegistry)))
+		add("PUT /admin/providers/{did}", withAdminAuth(c.adminToken, PutProviderHandler(c.providerRegistry)))
+		add("DELETE /admin/providers/{did}", withAdminAuth(c.adminToken, DeleteProviderHandler(c.providerRegistry)))
+	}
+	if c.metricsHandler != nil {
+		mux.Handle("GET /metrics", c.metricsHandler)
 	}
 	// Temporary endpoint to publish an orphan advert to the indexer's IPNI chain
 	if c.publisherStore != nil {
@@ -188,6 +299,9 @@ func (w gzipResponseWriter) Write(b []byte) (int, error) {
 // withGzip wraps a handler to support gzip compression if the client accepts it
 func withGzip(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// responses are cached per encoding
+		w.Header().Add("Vary", "Accept-Encoding")
+
 		// Check if client accepts gzip encoding
 		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			handler(w, r)
@@ -219,34 +333,6 @@ func GetRootHandler(id principal.Signer) http.HandlerFunc {
 	}
 }
 
-// GetClaimHandler retrieves a single content claim by it's root CID.
-func GetClaimHandler(service types.Getter) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		parts := strings.Split(r.URL.Path, "/")
-		c, err := cid.Parse(parts[len(parts)-1])
-		if err != nil {
-			http.Error(w, fmt.Sprintf("invalid CID: %s", err), http.StatusBadRequest)
-			return
-		}
-
-		dlg, err := service.Get(r.Context(), cidlink.Link{Cid: c})
-		if err != nil {
-			if errors.Is(err, types.ErrKeyNotFound) {
-				http.Error(w, fmt.Sprintf("not found: %s", c), http.StatusNotFound)
-				return
-			}
-			log.Errorf("getting claim: %s", err)
-			http.Error(w, "failed to get claim", http.StatusInternalServerError)
-			return
-		}
-
-		_, err = io.Copy(w, dlg.Archive())
-		if err != nil {
-			log.Warnf("serving claim: %s: %s", c, err)
-		}
-	}
-}
-
 // PostClaimsHandler invokes the ucanto service when a POST request is sent to
 // "/claims".
 func PostClaimsHandler(id principal.Signer, service types.Publisher, options ...server.Option) http.HandlerFunc {
@@ -314,53 +400,49 @@ func GetClaimsHandler(service types.Querier) http.HandlerFunc {
 			return
 		}
 
-		spaceStrings := r.URL.Query()["spaces"]
-		spaces := make([]did.DID, 0, len(spaceStrings))
-		for _, spaceString := range spaceStrings {
-			space, err := did.Parse(spaceString)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("invalid did: %s", err.Error()), http.StatusBadRequest)
-				return
-			}
-			spaces = append(spaces, space)
+		spaces, err := parseSpaces(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		var dlgs []delegation.Delegation
-		agentMsgHeader := r.Header.Get(hcmsg.HeaderName)
-		if agentMsgHeader != "" {
-			msg, err := hcmsg.DecodeHeader(agentMsgHeader)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("decoding agent message: %s", err.Error()), http.StatusBadRequest)
-				return
-			}
-
-			for _, root := range msg.Invocations() {
-				dlg, ok, err := msg.Invocation(root)
-				if err != nil {
-					log.Warnf("failed to extract delegation from agent message: %w", err)
-					continue
-				}
-				if !ok {
-					log.Warnf("delegation not found in agent message: %s", root.String())
-					continue
-				}
-				dlgs = append(dlgs, dlg)
-			}
+		dlgs, err := parseDelegations(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		qr, err := service.Query(ctx, types.Query{
+		q := types.Query{
 			Type:   queryType,
 			Hashes: hashes,
 			Match: types.Match{
 				Subject: spaces,
 			},
 			Delegations: dlgs,
-		})
+		}
+		qr, err := service.Query(ctx, q)
+		logQuery(ctx, q, qr)
 		if err != nil {
+			if errors.Is(err, types.ErrUnauthorizedQuery) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
 			http.Error(w, fmt.Sprintf("processing query: %s", err.Error()), http.StatusInternalServerError)
 			return
 		}
 
+		// query results are content addressed, so identical results have the
+		// same root. The ETag is weak since the result may be gzipped.
+		etag := `W/"` + qr.Root().Link().String() + `"`
+		w.Header().Set("ETag", etag)
+		// delegations in the agent message may authorize private results
+		w.Header().Add("Vary", hcmsg.HeaderName)
+		w.Header().Set("Cache-Control", queryResultCacheControl(qr, r.Header.Get(hcmsg.HeaderName) != "", time.Now()))
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		body := car.Encode([]datamodel.Link{qr.Root().Link()}, qr.Blocks())
 		w.WriteHeader(http.StatusOK)
 		_, err = io.Copy(w, body)
@@ -370,6 +452,94 @@ func GetClaimsHandler(service types.Querier) http.HandlerFunc {
 	}
 }
 
+// parseSpaces parses the DIDs of the spaces to filter query results by from
+// the "spaces" query parameters.
+func parseSpaces(r *http.Request) ([]did.DID, error) {
+	spaceStrings := r.URL.Query()["spaces"]
+	spaces := make([]did.DID, 0, len(spaceStrings))
+	for _, spaceString := range spaceStrings {
+		space, err := did.Parse(spaceString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid did: %s", err.Error())
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, nil
+}
+
+// parseDelegations extracts the delegations authorizing a query from the agent
+// message header, if any.
+func parseDelegations(r *http.Request) ([]delegation.Delegation, error) {
+	var dlgs []delegation.Delegation
+	agentMsgHeader := r.Header.Get(hcmsg.HeaderName)
+	if agentMsgHeader == "" {
+		return dlgs, nil
+	}
+
+	msg, err := hcmsg.DecodeHeader(agentMsgHeader)
+	if err != nil {
+		return nil, fmt.Errorf("decoding agent message: %s", err.Error())
+	}
+
+	for _, root := range msg.Invocations() {
+		dlg, ok, err := msg.Invocation(root)
+		if err != nil {
+			log.Warnf("failed to extract delegation from agent message: %w", err)
+			continue
+		}
+		if !ok {
+			log.Warnf("delegation not found in agent message: %s", root.String())
+			continue
+		}
+		dlgs = append(dlgs, dlg)
+	}
+	return dlgs, nil
+}
+
+// maxQueryResultCacheAge is the max-age of query results, five minutes. New
+// claims for the queried content may be published at any time, so results are
+// not cached for as long as the claims they hold.
+const maxQueryResultCacheAge = 5 * 60
+
+// queryResultCacheControl returns the Cache-Control header for a query result,
+// which can be cached until the first of its claims expires, for at most
+// maxQueryResultCacheAge. Results of queries authorized with delegations may
+// include private data, so only the client may cache them. Empty results must
+// be revalidated, since claims for the queried content may be published at
+// any time.
+func queryResultCacheControl(qr types.QueryResult, private bool, now time.Time) string {
+	if len(qr.Claims()) == 0 {
+		return "no-cache"
+	}
+	blocks, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(qr.Blocks()))
+	if err != nil {
+		log.Warnf("reading blocks from query result: %s", err)
+		return "no-store"
+	}
+
+	var exp *int
+	for _, root := range qr.Claims() {
+		claim, err := delegation.NewDelegationView(root, blocks)
+		if err != nil {
+			log.Warnf("decoding claim %s: %s", root, err)
+			return "no-store"
+		}
+		if e := claim.Expiration(); e != nil && (exp == nil || *e < *exp) {
+			exp = e
+		}
+	}
+
+	age, ok := maxAge(exp, now)
+	if !ok {
+		return "no-store"
+	}
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, min(age, maxQueryResultCacheAge))
+}
+
 func GetIPNICIDHandler(service types.Querier, config *ipniConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, s := telemetry.StartSpan(r.Context(), "GetClaimsHandler")
@@ -11,9 +11,13 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
 	"github.com/ipni/go-libipni/find/model"
 	"github.com/ipni/go-libipni/maurl"
 	"github.com/ipni/go-libipni/metadata"
@@ -22,6 +26,7 @@ import (
 	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-libstoracha/blobindex"
 	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
 	"github.com/storacha/go-libstoracha/capabilities/space/content"
 	"github.com/storacha/go-libstoracha/digestutil"
 	"github.com/storacha/go-libstoracha/testutil"
@@ -111,6 +116,173 @@ func TestGetClaimHandler(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, http.StatusBadRequest, res.StatusCode)
 	})
+
+	getClaim := func(t *testing.T, claim delegation.Delegation, header http.Header) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/claim/%s", svr.URL, claim.Link()), nil)
+		require.NoError(t, err)
+		req.Header = header
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	t.Run("CAR by default", func(t *testing.T) {
+		res := getClaim(t, claim, http.Header{"Accept": {"*/*"}})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/vnd.ipld.car", res.Header.Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf(`"%s"`, claim.Link()), res.Header.Get("ETag"))
+		require.Equal(t, "Accept", res.Header.Get("Vary"))
+
+		d, err := delegation.Extract(testutil.Must(io.ReadAll(res.Body))(t))
+		require.NoError(t, err)
+		require.Equal(t, claim.Link(), d.Link())
+	})
+
+	t.Run("DAG-JSON", func(t *testing.T) {
+		res := getClaim(t, claim, http.Header{"Accept": {"application/vnd.ipld.dag-json"}})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/vnd.ipld.dag-json", res.Header.Get("Content-Type"))
+		require.Equal(t, fmt.Sprintf(`"%s.dag-json"`, claim.Link()), res.Header.Get("ETag"))
+
+		nd, err := ipld.Decode(testutil.Must(io.ReadAll(res.Body))(t), dagjson.Decode)
+		require.NoError(t, err)
+		data, err := ipld.Encode(nd, dagcbor.Encode)
+		require.NoError(t, err)
+		require.Equal(t, claim.Root().Bytes(), data)
+	})
+
+	t.Run("JSON summary", func(t *testing.T) {
+		expiring, err := assert.Index.Delegate(
+			testutil.Service,
+			testutil.Alice,
+			testutil.Service.DID().String(),
+			assert.IndexCaveats{Content: testutil.RandomCID(t), Index: testutil.RandomCID(t)},
+			delegation.WithExpiration(int(time.Now().Add(time.Hour).Unix())),
+		)
+		require.NoError(t, err)
+		require.NoError(t, store.Put(context.Background(), expiring.Link(), expiring))
+
+		res := getClaim(t, expiring, http.Header{"Accept": {"text/html, application/json;q=0.9, */*;q=0.1"}})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		require.Regexp(t, `^public, max-age=(3600|359\d), immutable$`, res.Header.Get("Cache-Control"))
+
+		var summary map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&summary))
+		require.Equal(t, expiring.Link().String(), summary["claim"])
+		require.Equal(t, testutil.Service.DID().String(), summary["issuer"])
+		require.Equal(t, testutil.Alice.DID().String(), summary["audience"])
+		require.Equal(t, assert.IndexAbility, summary["capability"])
+		require.Equal(t, testutil.Service.DID().String(), summary["with"])
+		require.Equal(t, float64(*expiring.Expiration()), summary["expiration"])
+		caveats := summary["caveats"].(map[string]any)
+		require.Contains(t, caveats, "content")
+		require.Contains(t, caveats, "index")
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		res := getClaim(t, claim, http.Header{"If-None-Match": {fmt.Sprintf(`"%s"`, claim.Link())}})
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+
+		res = getClaim(t, claim, http.Header{
+			"Accept":        {"application/json"},
+			"If-None-Match": {fmt.Sprintf(`"%s"`, claim.Link())},
+		})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		res := getClaim(t, claim, http.Header{"Accept": {"text/html"}})
+		require.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	})
+}
+
+func TestClaimCacheControl(t *testing.T) {
+	now := time.Now()
+	newClaim := func(t *testing.T, opts ...delegation.Option) delegation.Delegation {
+		claim, err := assert.Index.Delegate(
+			testutil.Service,
+			testutil.Service,
+			testutil.Service.DID().String(),
+			assert.IndexCaveats{Content: testutil.RandomCID(t), Index: testutil.RandomCID(t)},
+			opts...,
+		)
+		require.NoError(t, err)
+		return claim
+	}
+
+	require.Equal(t, "public, max-age=31536000, immutable", claimCacheControl(newClaim(t, delegation.WithNoExpiration()), now))
+	require.Equal(t, "public, max-age=60, immutable", claimCacheControl(newClaim(t, delegation.WithExpiration(int(now.Unix())+60)), now))
+	require.Equal(t, "no-store", claimCacheControl(newClaim(t, delegation.WithExpiration(int(now.Unix())-60)), now))
+}
+
+func TestQueryResultCacheControl(t *testing.T) {
+	now := time.Now()
+	newClaim := func(t *testing.T, opts ...delegation.Option) delegation.Delegation {
+		claim, err := assert.Index.Delegate(
+			testutil.Service,
+			testutil.Service,
+			testutil.Service.DID().String(),
+			assert.IndexCaveats{Content: testutil.RandomCID(t), Index: testutil.RandomCID(t)},
+			opts...,
+		)
+		require.NoError(t, err)
+		return claim
+	}
+	newResult := func(t *testing.T, claims ...delegation.Delegation) types.QueryResult {
+		claimsMap := map[cid.Cid]delegation.Delegation{}
+		for _, c := range claims {
+			claimsMap[link.ToCID(c.Link())] = c
+		}
+		indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](-1)
+		return testutil.Must(queryresult.Build(claimsMap, indexes))(t)
+	}
+
+	t.Run("cached until the first claim expires", func(t *testing.T) {
+		qr := newResult(t,
+			newClaim(t, delegation.WithExpiration(int(now.Unix())+120)),
+			newClaim(t, delegation.WithNoExpiration()),
+			newClaim(t, delegation.WithExpiration(int(now.Unix())+60)),
+		)
+		require.Equal(t, "public, max-age=60", queryResultCacheControl(qr, false, now))
+		require.Equal(t, "private, max-age=60", queryResultCacheControl(qr, true, now))
+	})
+
+	t.Run("capped for claims that expire later or never", func(t *testing.T) {
+		qr := newResult(t, newClaim(t, delegation.WithNoExpiration()))
+		require.Equal(t, "public, max-age=300", queryResultCacheControl(qr, false, now))
+		qr = newResult(t, newClaim(t, delegation.WithExpiration(int(now.Unix())+3600)))
+		require.Equal(t, "private, max-age=300", queryResultCacheControl(qr, true, now))
+	})
+
+	t.Run("expired claims", func(t *testing.T) {
+		qr := newResult(t, newClaim(t, delegation.WithExpiration(int(now.Unix())-1)))
+		require.Equal(t, "no-store", queryResultCacheControl(qr, false, now))
+	})
+
+	t.Run("empty results", func(t *testing.T) {
+		require.Equal(t, "no-cache", queryResultCacheControl(newResult(t), false, now))
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/vnd.ipld.car", "application/vnd.ipld.dag-json", "application/json"}
+	for accept, expected := range map[string]string{
+		"":                                  "application/vnd.ipld.car",
+		"*/*":                               "application/vnd.ipld.car",
+		"application/*":                     "application/vnd.ipld.car",
+		"application/json":                  "application/json",
+		"APPLICATION/JSON":                  "application/json",
+		"application/json, */*;q=0.5":       "application/json",
+		"application/*;q=0.5, */*;q=0.8":    "application/vnd.ipld.car",
+		"application/json;q=0.5, */*;q=0.8": "application/vnd.ipld.car",
+		"*/*, application/vnd.ipld.car;q=0": "application/vnd.ipld.dag-json",
+		"text/html":                         "",
+		"application/json;q=0":              "",
+	} {
+		require.Equal(t, expected, negotiate(accept, offers), "Accept: %s", accept)
+	}
 }
 
 func TestGetClaimsHandler(t *testing.T) {
@@ -155,6 +327,19 @@ func TestGetClaimsHandler(t *testing.T) {
 
 		require.ElementsMatch(t, queryResult.Claims(), result.Claims())
 		require.ElementsMatch(t, queryResult.Indexes(), result.Indexes())
+
+		etag := fmt.Sprintf(`W/"%s"`, queryResult.Root().Link())
+		require.Equal(t, etag, res.Header.Get("ETag"))
+		require.Equal(t, queryResultCacheControl(queryResult, false, time.Now()), res.Header.Get("Cache-Control"))
+		require.Equal(t, []string{hcmsg.HeaderName}, res.Header.Values("Vary"))
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/claims?multihash=%s", svr.URL, digestutil.Format(randomHash)), nil)
+		require.NoError(t, err)
+		req.Header.Set("If-None-Match", etag)
+		res, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+		require.Empty(t, testutil.Must(io.ReadAll(res.Body))(t))
 	})
 
 	t.Run("empty results are ok", func(t *testing.T) {
@@ -186,6 +371,7 @@ func TestGetClaimsHandler(t *testing.T) {
 
 		require.Empty(t, result.Claims())
 		require.Empty(t, result.Indexes())
+		require.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
 	})
 
 	t.Run("invalid hash", func(t *testing.T) {
@@ -265,6 +451,21 @@ func TestGetClaimsHandler(t *testing.T) {
 		require.Equal(t, http.StatusOK, res.StatusCode)
 	})
 
+	t.Run("unauthorized query for private space", func(t *testing.T) {
+		mockService := types.NewMockService(t)
+
+		randomHash := testutil.RandomMultihash(t)
+		randomSubject := testutil.RandomPrincipal(t).DID()
+		mockService.EXPECT().Query(mock.Anything, mock.AnythingOfType("Query")).Return(nil, fmt.Errorf("%w: missing delegation", types.ErrUnauthorizedQuery))
+
+		svr := httptest.NewServer(GetClaimsHandler(mockService))
+		defer svr.Close()
+
+		res, err := http.Get(fmt.Sprintf("%s/claims?multihash=%s&spaces=%s", svr.URL, digestutil.Format(randomHash), randomSubject.String()))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
 	t.Run("invalid "+hcmsg.HeaderName, func(t *testing.T) {
 		mockService := types.NewMockService(t)
 
@@ -399,6 +600,7 @@ func TestGetClaimsHandler(t *testing.T) {
 			require.NoError(t, err)
 			require.Equal(t, http.StatusOK, res.StatusCode)
 			require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+			require.Equal(t, []string{"Accept-Encoding", hcmsg.HeaderName}, res.Header.Values("Vary"))
 
 			// Decompress the response
 			gzReader, err := gzip.NewReader(res.Body)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ipni/go-libipni/find/model"
 	"github.com/storacha/go-libstoracha/blobindex"
@@ -55,6 +56,7 @@ func (b *cachingLookup) Find(ctx context.Context, contextID types.EncodedContext
 	err = b.cachingQueue.Queue(ctx, providercacher.ProviderCachingJob{
 		Provider: provider,
 		Index:    index,
+		QueuedAt: time.Now(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("queueing provider caching for index failed: %w", err)
@@ -32,25 +32,31 @@ func NewBlobIndexLookup(httpClient *http.Client) BlobIndexLookup {
 
 // Find fetches the blob index from the given fetchURL
 func (s *simpleLookup) Find(ctx context.Context, _ types.EncodedContextID, result model.ProviderResult, request types.RetrievalRequest) (blobindex.ShardedDagIndexView, error) {
-	var body io.ReadCloser
+	body, err := Retrieve(ctx, s.httpClient, request)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return blobindex.Extract(body)
+}
+
+// Retrieve fetches the blob, or the byte range of it, described by the
+// request. If retrieval authorization details were provided, it makes a UCAN
+// authorized retrieval request, otherwise it attempts a legacy public
+// retrieval with no authorization.
+func Retrieve(ctx context.Context, httpClient *http.Client, request types.RetrievalRequest) (io.ReadCloser, error) {
 	if request.Auth != nil {
-		// If retrieval authorization details were provided, make a UCAN authorized
-		// retrieval request.
-		b, err := doAuthorizedRetrieval(ctx, s.httpClient, request)
+		body, err := doAuthorizedRetrieval(ctx, httpClient, request)
 		if err != nil {
 			return nil, fmt.Errorf("executing authorized retrieval: %w", err)
 		}
-		body = b
-	} else {
-		// Otherwise, attempt a legacy public retrieval with no authorization.
-		b, err := doPublicRetrieval(ctx, s.httpClient, request)
-		if err != nil {
-			return nil, fmt.Errorf("executing public retrieval: %w", err)
-		}
-		body = b
+		return body, nil
 	}
-	defer body.Close()
-	return blobindex.Extract(body)
+	body, err := doPublicRetrieval(ctx, httpClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("executing public retrieval: %w", err)
+	}
+	return body, nil
 }
 
 func doAuthorizedRetrieval(ctx context.Context, httpClient *http.Client, request types.RetrievalRequest) (io.ReadCloser, error) {
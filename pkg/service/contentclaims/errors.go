@@ -1,8 +1,11 @@
 package contentclaims
 
 import (
+	"fmt"
+
 	"github.com/ipld/go-ipld-prime/datamodel"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/indexing-service/pkg/types"
 )
 
 type Failure struct {
@@ -39,3 +42,24 @@ func NewMissingClaimError() Failure {
 		message: "Claim data was not found in the invocation payload.",
 	}
 }
+
+func NewRateLimitExceededError(err types.RateLimitedError) Failure {
+	return Failure{
+		name:    "RateLimitExceeded",
+		message: fmt.Sprintf("Rate limit exceeded, retry after %s.", err.RetryAfter),
+	}
+}
+
+func NewUnauthorizedProviderError(err error) Failure {
+	return Failure{
+		name:    "UnauthorizedProvider",
+		message: err.Error(),
+	}
+}
+
+func NewUnresolvedDIDError(err error) Failure {
+	return Failure{
+		name:    "UnresolvedDID",
+		message: err.Error(),
+	}
+}
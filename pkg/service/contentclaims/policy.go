@@ -0,0 +1,85 @@
+package contentclaims
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+)
+
+// AuthorityPolicy restricts who may invoke abilities, such as `assert/index`
+// and `assert/equals`, to configured authorities. An invocation of a
+// restricted ability must be issued by an authority, or carry a chain of
+// delegations from one. Abilities without configured authorities may be
+// self-issued by any principal, as usual.
+type AuthorityPolicy struct {
+	authorities map[ucan.Ability][]did.DID
+}
+
+// NewAuthorityPolicy creates a policy restricting each ability in the map to
+// the listed authorities.
+func NewAuthorityPolicy(authorities map[ucan.Ability][]did.DID) *AuthorityPolicy {
+	return &AuthorityPolicy{authorities: authorities}
+}
+
+// ParseAuthorities parses a JSON object mapping abilities to the DIDs of their
+// authorities, e.g. {"assert/index": ["did:web:up.storacha.network"]}.
+// Abilities may be wildcards like "assert/*", restricting every ability they
+// cover. The
+// passed service DID is added as an authority for every listed ability, so
+// that the service may always delegate the abilities it is restricting.
+func ParseAuthorities(data []byte, service did.DID) (map[ucan.Ability][]did.DID, error) {
+	var raw map[ucan.Ability][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing authorities JSON: %w", err)
+	}
+	authorities := make(map[ucan.Ability][]did.DID, len(raw))
+	for ability, dids := range raw {
+		authorities[ability] = []did.DID{service}
+		for _, s := range dids {
+			authority, err := did.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("parsing authority DID for %s: %w", ability, err)
+			}
+			authorities[ability] = append(authorities[ability], authority)
+		}
+	}
+	return authorities, nil
+}
+
+// CanIssue reports whether the issuer may issue the capability without a
+// proof. It should be passed to the UCAN server with the go-ucanto
+// server.WithCanIssue option, so that the validator enforces the policy when
+// checking proof chains.
+func (p *AuthorityPolicy) CanIssue(capability ucan.Capability[any], issuer did.DID) bool {
+	restricted := false
+	for ability, authorities := range p.authorities {
+		// a wildcard capability is restricted by the abilities it covers, and a
+		// wildcard ability restricts the capabilities it covers
+		if !covers(capability.Can(), ability) && !covers(ability, capability.Can()) {
+			continue
+		}
+		restricted = true
+		if !slices.Contains(authorities, issuer) {
+			return false
+		}
+	}
+	if restricted {
+		return true
+	}
+	return validator.IsSelfIssued(capability, issuer)
+}
+
+// covers reports whether the (possibly wildcard) ability can covers the
+// passed ability.
+func covers(can ucan.Ability, ability ucan.Ability) bool {
+	if can == ability || can == "*" {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(can, "/*")
+	return ok && strings.HasPrefix(ability, prefix+"/")
+}
@@ -0,0 +1,52 @@
+package contentclaims
+
+import (
+	"testing"
+
+	cassert "github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthorities(t *testing.T) {
+	authorities, err := ParseAuthorities([]byte(`{"assert/index": ["did:web:up.storacha.network"], "assert/equals": []}`), testutil.Service.DID())
+	require.NoError(t, err)
+	require.Equal(t, map[ucan.Ability][]did.DID{
+		cassert.IndexAbility:  {testutil.Service.DID(), testutil.Must(did.Parse("did:web:up.storacha.network"))(t)},
+		cassert.EqualsAbility: {testutil.Service.DID()},
+	}, authorities)
+
+	_, err = ParseAuthorities([]byte(`{"assert/index": ["not a did"]}`), testutil.Service.DID())
+	require.Error(t, err)
+}
+
+func TestAuthorityPolicyWildcards(t *testing.T) {
+	policy := NewAuthorityPolicy(map[ucan.Ability][]did.DID{
+		cassert.IndexAbility: {testutil.Bob.DID()},
+	})
+	for _, can := range []ucan.Ability{cassert.IndexAbility, "assert/*", "*"} {
+		capability := ucan.NewCapability[any](can, testutil.Alice.DID().String(), nil)
+		require.False(t, policy.CanIssue(capability, testutil.Alice.DID()), can)
+		require.True(t, policy.CanIssue(capability, testutil.Bob.DID()), can)
+	}
+
+	capability := ucan.NewCapability[any]("space/info", testutil.Alice.DID().String(), nil)
+	require.True(t, policy.CanIssue(capability, testutil.Alice.DID()))
+	require.False(t, policy.CanIssue(capability, testutil.Bob.DID()))
+}
+
+func TestAuthorityPolicyWildcardAuthorities(t *testing.T) {
+	policy := NewAuthorityPolicy(map[ucan.Ability][]did.DID{
+		"assert/*": {testutil.Bob.DID()},
+	})
+	for _, can := range []ucan.Ability{cassert.IndexAbility, cassert.EqualsAbility, "assert/*", "*"} {
+		capability := ucan.NewCapability[any](can, testutil.Alice.DID().String(), nil)
+		require.False(t, policy.CanIssue(capability, testutil.Alice.DID()), can)
+		require.True(t, policy.CanIssue(capability, testutil.Bob.DID()), can)
+	}
+
+	capability := ucan.NewCapability[any]("space/info", testutil.Alice.DID().String(), nil)
+	require.True(t, policy.CanIssue(capability, testutil.Alice.DID()))
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 
 	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-ucanto/core/car"
 	"github.com/storacha/go-ucanto/core/delegation"
 )
 
@@ -32,6 +33,7 @@ func (sf *simpleFinder) Find(ctx context.Context, id ipld.Link, fetchURL *url.UR
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Accept", car.ContentType)
 
 	resp, err := sf.httpClient.Do(req)
 	if err != nil {
@@ -31,6 +31,7 @@ func TestSimpleFinder__Find(t *testing.T) {
 		{
 			name: "success fetch",
 			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "application/vnd.ipld.car", r.Header.Get("Accept"))
 				claimBytes := testutil.Must(io.ReadAll(claim.Archive()))(t)
 				testutil.Must(w.Write(claimBytes))(t)
 			},
@@ -3,10 +3,15 @@ package contentclaims
 import (
 	"github.com/storacha/go-ucanto/principal"
 	"github.com/storacha/go-ucanto/server"
+	"github.com/storacha/indexing-service/pkg/principalparser"
 	"github.com/storacha/indexing-service/pkg/types"
 )
 
+// NewUCANServer creates a UCAN server for the content claims service. Ed25519
+// and RSA issuers are accepted through [principalparser], unless the principal
+// parser is overridden by the passed options.
 func NewUCANServer(id principal.Signer, service types.Publisher, options ...server.Option) (server.ServerView[server.Service], error) {
+	options = append([]server.Option{server.WithPrincipalParser(principalparser.Parse)}, options...)
 	ucanService := NewUCANService(service)
 	for ability, method := range ucanService {
 		options = append(options, server.WithServiceMethod(ability, method))
@@ -23,10 +23,12 @@ import (
 	unit "github.com/storacha/go-ucanto/core/result/ok"
 	"github.com/storacha/go-ucanto/did"
 	ed25519 "github.com/storacha/go-ucanto/principal/ed25519/signer"
+	rsasigner "github.com/storacha/go-ucanto/principal/rsa/signer"
 	"github.com/storacha/go-ucanto/principal/signer"
 	"github.com/storacha/go-ucanto/server"
 	"github.com/storacha/go-ucanto/ucan"
 	"github.com/storacha/indexing-service/pkg/principalresolver"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/types"
 	"github.com/stretchr/testify/require"
 )
@@ -179,6 +181,140 @@ func TestPrincipalResolver(t *testing.T) {
 	})
 }
 
+func TestRateLimit(t *testing.T) {
+	limiter, err := ratelimit.NewLimiter("publish", ratelimit.NewMemoryStore(), types.RateLimit{Rate: 0.001, Burst: 1})
+	require.NoError(t, err)
+
+	server, err := NewUCANServer(testutil.Service, ratelimit.NewService(&mockIndexer{}, limiter))
+	require.NoError(t, err)
+
+	conn, err := client.NewConnection(testutil.Service, server)
+	require.NoError(t, err)
+
+	publish := func() result.Result[unit.Unit, datamodel.Node] {
+		inv := testutil.Must(cassert.Equals.Invoke(
+			testutil.Service,
+			testutil.Service,
+			testutil.Service.DID().String(),
+			cassert.EqualsCaveats{
+				Content: ctypes.FromHash(testutil.RandomMultihash(t)),
+				Equals:  testutil.RandomCID(t),
+			},
+		))(t)
+
+		resp, err := client.Execute(t.Context(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptlnk, ok := resp.Get(inv.Link())
+		require.True(t, ok, "missing receipt for invocation: %s", inv.Link())
+
+		reader, err := receipt.NewReceiptReader[unit.Unit, datamodel.Node](rcptsch)
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptlnk, resp.Blocks())
+		require.NoError(t, err)
+		return rcpt.Out()
+	}
+
+	result.MatchResultR0(publish(), func(ok unit.Unit) {}, func(x datamodel.Node) {
+		require.Fail(t, "unexpected failure")
+	})
+
+	result.MatchResultR0(publish(), func(ok unit.Unit) {
+		require.Fail(t, "expected rate limit failure")
+	}, func(x datamodel.Node) {
+		name, err := testutil.Must(x.LookupByString("name"))(t).AsString()
+		require.NoError(t, err)
+		require.Equal(t, "RateLimitExceeded", name)
+	})
+}
+
+func TestAuthorityPolicy(t *testing.T) {
+	policy := NewAuthorityPolicy(map[ucan.Ability][]did.DID{
+		cassert.EqualsAbility: {testutil.Bob.DID()},
+	})
+	server, err := NewUCANServer(testutil.Service, &mockIndexer{}, server.WithCanIssue(policy.CanIssue))
+	require.NoError(t, err)
+
+	conn, err := client.NewConnection(testutil.Service, server)
+	require.NoError(t, err)
+
+	invoke := func(t *testing.T, issuer ucan.Signer, with did.DID, proofs ...delegation.Proof) bool {
+		inv := testutil.Must(cassert.Equals.Invoke(
+			issuer,
+			testutil.Service,
+			with.String(),
+			cassert.EqualsCaveats{
+				Content: ctypes.FromHash(testutil.RandomMultihash(t)),
+				Equals:  testutil.RandomCID(t),
+			},
+			delegation.WithProof(proofs...),
+		))(t)
+
+		resp, err := client.Execute(t.Context(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+
+		rcptlnk, ok := resp.Get(inv.Link())
+		require.True(t, ok, "missing receipt for invocation: %s", inv.Link())
+
+		reader, err := receipt.NewReceiptReader[unit.Unit, datamodel.Node](rcptsch)
+		require.NoError(t, err)
+
+		rcpt, err := reader.Read(rcptlnk, resp.Blocks())
+		require.NoError(t, err)
+		return result.MatchResultR1(rcpt.Out(), func(unit.Unit) bool { return true }, func(datamodel.Node) bool { return false })
+	}
+
+	delegate := func(t *testing.T, issuer ucan.Signer, audience ucan.Principal) delegation.Proof {
+		return delegation.FromDelegation(testutil.Must(delegation.Delegate(
+			issuer,
+			audience,
+			[]ucan.Capability[ucan.NoCaveats]{
+				ucan.NewCapability(cassert.EqualsAbility, issuer.DID().String(), ucan.NoCaveats{}),
+			},
+		))(t))
+	}
+
+	t.Run("rejects self-issued invocation by other principals", func(t *testing.T) {
+		require.False(t, invoke(t, testutil.Alice, testutil.Alice.DID()))
+	})
+
+	t.Run("accepts invocation issued by authority", func(t *testing.T) {
+		require.True(t, invoke(t, testutil.Bob, testutil.Bob.DID()))
+	})
+
+	t.Run("accepts invocation delegated from authority", func(t *testing.T) {
+		require.True(t, invoke(t, testutil.Alice, testutil.Bob.DID(), delegate(t, testutil.Bob, testutil.Alice)))
+	})
+
+	t.Run("rejects invocation delegated from other principals", func(t *testing.T) {
+		require.False(t, invoke(t, testutil.Alice, testutil.Mallory.DID(), delegate(t, testutil.Mallory, testutil.Alice)))
+	})
+
+	t.Run("does not restrict other abilities", func(t *testing.T) {
+		inv := testutil.Must(cassert.Index.Invoke(
+			testutil.Alice,
+			testutil.Service,
+			testutil.Alice.DID().String(),
+			cassert.IndexCaveats{
+				Content: testutil.RandomCID(t),
+				Index:   testutil.RandomCID(t),
+			},
+		))(t)
+		resp, err := client.Execute(t.Context(), []invocation.Invocation{inv}, conn)
+		require.NoError(t, err)
+		rcptlnk, ok := resp.Get(inv.Link())
+		require.True(t, ok)
+		reader, err := receipt.NewReceiptReader[unit.Unit, datamodel.Node](rcptsch)
+		require.NoError(t, err)
+		rcpt, err := reader.Read(rcptlnk, resp.Blocks())
+		require.NoError(t, err)
+		result.MatchResultR0(rcpt.Out(), func(unit.Unit) {}, func(x datamodel.Node) {
+			require.Fail(t, "unexpected failure", printer.Sprint(x))
+		})
+	})
+}
+
 type mockIndexer struct {
 }
 
@@ -202,3 +338,38 @@ func (m *mockIndexer) Query(ctx context.Context, q types.Query) (types.QueryResu
 }
 
 var _ types.Service = (*mockIndexer)(nil)
+
+func TestRSAIssuer(t *testing.T) {
+	server, err := NewUCANServer(testutil.Service, &mockIndexer{})
+	require.NoError(t, err)
+
+	conn, err := client.NewConnection(testutil.Service, server)
+	require.NoError(t, err)
+
+	issuer := testutil.Must(rsasigner.Generate())(t)
+	inv := testutil.Must(cassert.Equals.Invoke(
+		issuer,
+		testutil.Service,
+		issuer.DID().String(),
+		cassert.EqualsCaveats{
+			Content: ctypes.FromHash(testutil.RandomMultihash(t)),
+			Equals:  testutil.RandomCID(t),
+		},
+	))(t)
+
+	resp, err := client.Execute(t.Context(), []invocation.Invocation{inv}, conn)
+	require.NoError(t, err)
+
+	rcptlnk, ok := resp.Get(inv.Link())
+	require.True(t, ok, "missing receipt for invocation: %s", inv.Link())
+
+	reader, err := receipt.NewReceiptReader[unit.Unit, datamodel.Node](rcptsch)
+	require.NoError(t, err)
+
+	rcpt, err := reader.Read(rcptlnk, resp.Blocks())
+	require.NoError(t, err)
+
+	result.MatchResultR0(rcpt.Out(), func(ok unit.Unit) {}, func(x datamodel.Node) {
+		require.Fail(t, "unexpected failure", printer.Sprint(x))
+	})
+}
@@ -2,9 +2,9 @@ package contentclaims
 
 import (
 	"context"
+	"errors"
 
 	logging "github.com/ipfs/go-log/v2"
-	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/storacha/go-libstoracha/capabilities/assert"
 	"github.com/storacha/go-libstoracha/capabilities/claim"
@@ -15,9 +15,10 @@ import (
 	"github.com/storacha/go-ucanto/core/result"
 	"github.com/storacha/go-ucanto/core/result/failure"
 	"github.com/storacha/go-ucanto/core/result/ok"
-	"github.com/storacha/go-ucanto/principal/ed25519/verifier"
 	"github.com/storacha/go-ucanto/server"
 	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/ratelimit"
 	"github.com/storacha/indexing-service/pkg/types"
 )
 
@@ -29,6 +30,10 @@ func NewUCANService(service types.Publisher) map[ucan.Ability]server.ServiceMeth
 			assert.Equals,
 			func(ctx context.Context, cap ucan.Capability[assert.EqualsCaveats], inv invocation.Invocation, ictx server.InvocationContext) (result.Result[ok.Unit, failure.IPLDBuilderFailure], fx.Effects, error) {
 				err := service.Publish(ctx, inv)
+				var rle types.RateLimitedError
+				if errors.As(err, &rle) {
+					return result.Error[ok.Unit, failure.IPLDBuilderFailure](NewRateLimitExceededError(rle)), nil, nil
+				}
 				if err != nil {
 					log.Errorf("publishing equals claim: %s", err)
 					return nil, nil, err
@@ -40,6 +45,13 @@ func NewUCANService(service types.Publisher) map[ucan.Ability]server.ServiceMeth
 			assert.Index,
 			func(ctx context.Context, cap ucan.Capability[assert.IndexCaveats], inv invocation.Invocation, ictx server.InvocationContext) (result.Result[ok.Unit, failure.IPLDBuilderFailure], fx.Effects, error) {
 				err := service.Publish(ctx, inv)
+				var rle types.RateLimitedError
+				if errors.As(err, &rle) {
+					return result.Error[ok.Unit, failure.IPLDBuilderFailure](NewRateLimitExceededError(rle)), nil, nil
+				}
+				if errors.Is(err, types.ErrUnresolvedDID) {
+					return result.Error[ok.Unit, failure.IPLDBuilderFailure](NewUnresolvedDIDError(err)), nil, nil
+				}
 				if err != nil {
 					log.Errorf("publishing index claim: %s", err)
 					return nil, nil, err
@@ -50,7 +62,7 @@ func NewUCANService(service types.Publisher) map[ucan.Ability]server.ServiceMeth
 		claim.CacheAbility: server.Provide(
 			claim.Cache,
 			func(ctx context.Context, cap ucan.Capability[claim.CacheCaveats], inv invocation.Invocation, ictx server.InvocationContext) (result.Result[ok.Unit, failure.IPLDBuilderFailure], fx.Effects, error) {
-				peerid, err := toPeerID(inv.Issuer())
+				peerid, err := principalparser.ToPeerID(inv.Issuer())
 				if err != nil {
 					return nil, nil, err
 				}
@@ -75,7 +87,16 @@ func NewUCANService(service types.Publisher) map[ucan.Ability]server.ServiceMeth
 					return nil, nil, err
 				}
 
-				err = service.Cache(ctx, provider, claim)
+				// limit the storage node invoking the cache, rather than the
+				// issuer of the claim
+				err = service.Cache(ratelimit.ContextWithIssuer(ctx, inv.Issuer().DID()), provider, claim)
+				var rle types.RateLimitedError
+				if errors.As(err, &rle) {
+					return result.Error[ok.Unit, failure.IPLDBuilderFailure](NewRateLimitExceededError(rle)), nil, nil
+				}
+				if errors.Is(err, types.ErrUnauthorizedProvider) {
+					return result.Error[ok.Unit, failure.IPLDBuilderFailure](NewUnauthorizedProviderError(err)), nil, nil
+				}
 				if err != nil {
 					log.Errorf("caching claim: %s", err)
 					return nil, nil, err
@@ -85,15 +106,3 @@ func NewUCANService(service types.Publisher) map[ucan.Ability]server.ServiceMeth
 		),
 	}
 }
-
-func toPeerID(principal ucan.Principal) (peer.ID, error) {
-	vfr, err := verifier.Decode(principal.DID().Bytes())
-	if err != nil {
-		return "", err
-	}
-	pub, err := crypto.UnmarshalEd25519PublicKey(vfr.Raw())
-	if err != nil {
-		return "", err
-	}
-	return peer.IDFromPublicKey(pub)
-}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/service")
+
+var (
+	queryDuration = func() metric.Float64Histogram {
+		histogram, err := meter.Float64Histogram(
+			"query.duration",
+			metric.WithDescription("Duration of queries, by query type and outcome."),
+			metric.WithUnit("ms"),
+		)
+		if err != nil {
+			return noop.Float64Histogram{}
+		}
+		return histogram
+	}()
+
+	claimFetches = func() metric.Int64Counter {
+		counter, err := meter.Int64Counter(
+			"claim.fetches",
+			metric.WithDescription("Number of claim fetches during queries, by provider and outcome."),
+		)
+		if err != nil {
+			return noop.Int64Counter{}
+		}
+		return counter
+	}()
+
+	indexFetches = func() metric.Int64Counter {
+		counter, err := meter.Int64Counter(
+			"index.fetches",
+			metric.WithDescription("Number of index fetches during queries, by provider and outcome."),
+		)
+		if err != nil {
+			return noop.Int64Counter{}
+		}
+		return counter
+	}()
+
+	claimsPublished = func() metric.Int64Counter {
+		counter, err := meter.Int64Counter(
+			"claims.published",
+			metric.WithDescription("Number of claims published, by ability and outcome."),
+		)
+		if err != nil {
+			return noop.Int64Counter{}
+		}
+		return counter
+	}()
+
+	claimsCached = func() metric.Int64Counter {
+		counter, err := meter.Int64Counter(
+			"claims.cached",
+			metric.WithDescription("Number of claims cached, by ability and outcome."),
+		)
+		if err != nil {
+			return noop.Int64Counter{}
+		}
+		return counter
+	}()
+)
+
+func outcome(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("outcome", "error")
+	}
+	return attribute.String("outcome", "success")
+}
+
+func recordQuery(ctx context.Context, queryType string, start time.Time, err error) {
+	queryDuration.Record(ctx, float64(time.Since(start).Milliseconds()), metric.WithAttributes(
+		attribute.String("query_type", queryType),
+		outcome(err),
+	))
+}
+
+func recordFetch(ctx context.Context, counter metric.Int64Counter, provider string, err error) {
+	counter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("provider", provider),
+		outcome(err),
+	))
+}
+
+func recordClaim(ctx context.Context, counter metric.Int64Counter, ability string, err error) {
+	counter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("ability", ability),
+		outcome(err),
+	))
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// WithPrivateSpaces marks the passed spaces as private. Queries that name a
+// private space must carry a valid `space/content/retrieve` delegation for the
+// space, issued (directly or via a proof chain) by the space, to the indexing
+// service. Claims for private spaces are removed from the results of queries
+// that are not authorized for the space. The resolver is used to resolve the
+// keys of non `did:key` principals in the proof chain, and may be nil, in which
+// case any resolver set by [WithPrincipalResolver] is used.
+func WithPrivateSpaces(spaces []did.DID, resolver validator.PrincipalResolver) Option {
+	return func(is *IndexingService) {
+		is.privateSpaces = make(map[did.DID]struct{}, len(spaces))
+		for _, space := range spaces {
+			is.privateSpaces[space] = struct{}{}
+		}
+		if resolver != nil {
+			is.principalResolver = resolver
+		}
+	}
+}
+
+// authorizeQuery ensures the query carries a valid delegation for each private
+// space it names. It returns the set of private spaces the query is
+// authorized for.
+func (is *IndexingService) authorizeQuery(ctx context.Context, q types.Query) (map[did.DID]struct{}, error) {
+	authorized := map[did.DID]struct{}{}
+	for _, space := range q.Match.Subject {
+		if _, ok := is.privateSpaces[space]; !ok {
+			continue
+		}
+		if err := is.authorizeSpace(ctx, space, q.Delegations); err != nil {
+			return nil, fmt.Errorf("%w: space %s: %w", types.ErrUnauthorizedQuery, space, err)
+		}
+		authorized[space] = struct{}{}
+	}
+	return authorized, nil
+}
+
+// authorizeSpace finds a delegation that authorizes the indexing service to
+// access content in the passed space.
+func (is *IndexingService) authorizeSpace(ctx context.Context, space did.DID, dlgs []delegation.Delegation) error {
+	authority, err := is.authority()
+	if err != nil {
+		return err
+	}
+	resolveDIDKey := validator.FailDIDKeyResolution
+	if is.principalResolver != nil {
+		resolveDIDKey = is.principalResolver.ResolveDIDKey
+	}
+	vctx := validator.NewValidationContext(
+		authority,
+		content.Retrieve,
+		validator.IsSelfIssued,
+		func(ctx context.Context, auth validator.Authorization[any]) validator.Revoked { return nil },
+		validator.ProofUnavailable,
+		principalparser.Parse,
+		resolveDIDKey,
+		validator.NotExpiredNotTooEarly,
+	)
+
+	var authErr error
+	for _, dlg := range dlgs {
+		if dlg.Audience().DID() != is.id.DID() {
+			continue
+		}
+		if !slices.ContainsFunc(dlg.Capabilities(), func(c ucan.Capability[any]) bool { return c.With() == space.String() }) {
+			continue
+		}
+		_, err := validator.Access(ctx, dlg, vctx)
+		if err != nil {
+			authErr = errors.Join(authErr, err)
+			continue
+		}
+		return nil
+	}
+	if authErr != nil {
+		return authErr
+	}
+	return errors.New("missing delegation")
+}
+
+// authority returns the verifier for the indexing service identity.
+func (is *IndexingService) authority() (principal.Verifier, error) {
+	if s, ok := is.id.(principal.Signer); ok {
+		return s.Verifier(), nil
+	}
+	return principalparser.Parse(is.id.DID().String())
+}
+
+// isAccessible determines if claims for the passed space may be included in
+// the results of a query authorized for the passed private spaces.
+func (is *IndexingService) isAccessible(space did.DID, authorized map[did.DID]struct{}) bool {
+	if _, ok := is.privateSpaces[space]; !ok {
+		return true
+	}
+	_, ok := authorized[space]
+	return ok
+}
+
+// filterPrivateClaims removes claims for private spaces the query is not
+// authorized for from the passed claims.
+func (is *IndexingService) filterPrivateClaims(claims map[cid.Cid]delegation.Delegation, authorized map[did.DID]struct{}) {
+	locationSpaces := locationSpacesOf(claims)
+	maps.DeleteFunc(claims, func(_ cid.Cid, claim delegation.Delegation) bool {
+		return !is.isAccessible(claimSpaceOf(claim, locationSpaces), authorized)
+	})
+}
+
+// locationSpacesOf maps the content of the location commitments among the
+// passed claims to the spaces they were committed for.
+func locationSpacesOf(claims map[cid.Cid]delegation.Delegation) map[string]did.DID {
+	spaces := map[string]did.DID{}
+	for _, claim := range claims {
+		caps := claim.Capabilities()
+		if len(caps) == 0 || caps[0].Can() != assert.LocationAbility {
+			continue
+		}
+		nb, err := assert.LocationCaveatsReader.Read(caps[0].Nb())
+		if err != nil || nb.Space == did.Undef {
+			continue
+		}
+		spaces[string(nb.Content.Hash())] = nb.Space
+	}
+	return spaces
+}
+
+// claimSpaceOf returns the space a claim belongs to, or [did.Undef] if it is
+// not associated with a space. Index and equals claims published without a
+// retrieval authorization take the space of the location commitment for the
+// blob they refer to, if one is among the passed location spaces.
+func claimSpaceOf(claim delegation.Delegation, locationSpaces map[string]did.DID) did.DID {
+	caps := claim.Capabilities()
+	if len(caps) == 0 {
+		return did.Undef
+	}
+	if caps[0].Can() == assert.LocationAbility {
+		nb, err := assert.LocationCaveatsReader.Read(caps[0].Nb())
+		if err != nil {
+			return did.Undef
+		}
+		return nb.Space
+	}
+	if space := claimSpace(claim); space != did.Undef {
+		return space
+	}
+
+	var digests []mh.Multihash
+	switch caps[0].Can() {
+	case assert.IndexAbility:
+		nb, err := assert.IndexCaveatsReader.Read(caps[0].Nb())
+		if err != nil {
+			return did.Undef
+		}
+		digests = append(digests, link.ToCID(nb.Index).Hash())
+	case assert.EqualsAbility:
+		nb, err := assert.EqualsCaveatsReader.Read(caps[0].Nb())
+		if err != nil {
+			return did.Undef
+		}
+		digests = append(digests, nb.Content.Hash(), link.ToCID(nb.Equals).Hash())
+	}
+	for _, digest := range digests {
+		if space, ok := locationSpaces[string(digest)]; ok {
+			return space
+		}
+	}
+	return did.Undef
+}
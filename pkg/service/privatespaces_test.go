@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/service/blobindexlookup"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/service/providerindex"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateSpaces(t *testing.T) {
+	providerAddr := &peer.AddrInfo{
+		Addrs: []ma.Multiaddr{
+			testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fclaims%2F%7Bclaim%7D"))(t),
+			testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fblobs%2F%7Bblob%7D"))(t),
+		},
+	}
+	targetClaims := []multicodec.Code{metadata.LocationCommitmentID}
+
+	t.Run("rejects queries for a private space without a delegation", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		privateSpace := testutil.RandomDID(t)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithPrivateSpaces([]did.DID{privateSpace}, nil))
+
+		_, err := service.Query(t.Context(), types.Query{
+			Type:   types.QueryTypeLocation,
+			Hashes: []mh.Multihash{testutil.RandomMultihash(t)},
+			Match:  types.Match{Subject: []did.DID{privateSpace}},
+		})
+		require.ErrorIs(t, err, types.ErrUnauthorizedQuery)
+	})
+
+	t.Run("rejects queries with a delegation issued by someone else", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		privateSpace := testutil.RandomDID(t)
+
+		dlg, err := content.Retrieve.Delegate(
+			testutil.Alice,
+			testutil.Service,
+			privateSpace.String(),
+			content.RetrieveCaveats{Blob: content.BlobDigest{Digest: testutil.RandomMultihash(t)}},
+		)
+		require.NoError(t, err)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithPrivateSpaces([]did.DID{privateSpace}, nil))
+
+		_, err = service.Query(t.Context(), types.Query{
+			Type:        types.QueryTypeLocation,
+			Hashes:      []mh.Multihash{testutil.RandomMultihash(t)},
+			Match:       types.Match{Subject: []did.DID{privateSpace}},
+			Delegations: []delegation.Delegation{dlg},
+		})
+		require.ErrorIs(t, err, types.ErrUnauthorizedQuery)
+	})
+
+	t.Run("allows authorized queries for a private space", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		privateSpace := testutil.Must(signer.Generate())(t)
+
+		contentLink := testutil.RandomCID(t).(cidlink.Link)
+		locationDelegationCid, locationDelegation, locationResult := buildTestLocationClaim(t, contentLink, providerAddr, privateSpace.DID(), rand.Uint64N(5000))
+
+		dlg, err := content.Retrieve.Delegate(
+			privateSpace,
+			testutil.Service,
+			privateSpace.DID().String(),
+			content.RetrieveCaveats{Blob: content.BlobDigest{Digest: contentLink.Hash()}},
+		)
+		require.NoError(t, err)
+
+		mockProviderIndex.EXPECT().Find(extmocks.AnyContext, providerindex.QueryKey{
+			Spaces:       []did.DID{privateSpace.DID()},
+			Hash:         contentLink.Hash(),
+			TargetClaims: targetClaims,
+		}).Return([]model.ProviderResult{locationResult}, nil)
+		locationClaimURL := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", locationDelegationCid)))(t)
+		mockClaimsService.EXPECT().Find(extmocks.AnyContext, locationDelegationCid, locationClaimURL).Return(locationDelegation, nil)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithPrivateSpaces([]did.DID{privateSpace.DID()}, nil))
+
+		result, err := service.Query(t.Context(), types.Query{
+			Type:        types.QueryTypeLocation,
+			Hashes:      []mh.Multihash{contentLink.Hash()},
+			Match:       types.Match{Subject: []did.DID{privateSpace.DID()}},
+			Delegations: []delegation.Delegation{dlg},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []ipld.Link{locationDelegation.Link()}, result.Claims())
+	})
+
+	t.Run("allows delegations for the space in any capability", func(t *testing.T) {
+		privateSpace := testutil.Must(signer.Generate())(t)
+		otherSpace := testutil.Must(signer.Generate())(t)
+		digest := testutil.RandomMultihash(t)
+
+		dlg, err := delegation.Delegate(
+			privateSpace,
+			testutil.Service,
+			[]ucan.Capability[content.RetrieveCaveats]{
+				content.Retrieve.New(otherSpace.DID().String(), content.RetrieveCaveats{Blob: content.BlobDigest{Digest: digest}}),
+				content.Retrieve.New(privateSpace.DID().String(), content.RetrieveCaveats{Blob: content.BlobDigest{Digest: digest}}),
+			},
+		)
+		require.NoError(t, err)
+
+		service := NewIndexingService(testutil.Service, blobindexlookup.NewMockBlobIndexLookup(t), contentclaims.NewMockContentClaimsService(t), peer.AddrInfo{ID: testutil.RandomPeer(t)}, providerindex.NewMockProviderIndex(t), WithPrivateSpaces([]did.DID{privateSpace.DID()}, nil))
+		require.NoError(t, service.authorizeSpace(t.Context(), privateSpace.DID(), []delegation.Delegation{dlg}))
+	})
+
+	t.Run("removes private space claims from unauthorized results", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		privateSpace := testutil.RandomDID(t)
+		publicSpace := testutil.RandomDID(t)
+
+		contentLink := testutil.RandomCID(t).(cidlink.Link)
+		privateDelegationCid, privateDelegation, privateResult := buildTestLocationClaim(t, contentLink, providerAddr, privateSpace, rand.Uint64N(5000))
+		publicDelegationCid, publicDelegation, publicResult := buildTestLocationClaim(t, contentLink, providerAddr, publicSpace, rand.Uint64N(5000))
+
+		mockProviderIndex.EXPECT().Find(extmocks.AnyContext, providerindex.QueryKey{
+			Hash:         contentLink.Hash(),
+			TargetClaims: targetClaims,
+		}).Return([]model.ProviderResult{privateResult, publicResult}, nil)
+		privateClaimURL := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", privateDelegationCid)))(t)
+		mockClaimsService.EXPECT().Find(extmocks.AnyContext, privateDelegationCid, privateClaimURL).Return(privateDelegation, nil)
+		publicClaimURL := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", publicDelegationCid)))(t)
+		mockClaimsService.EXPECT().Find(extmocks.AnyContext, publicDelegationCid, publicClaimURL).Return(publicDelegation, nil)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithPrivateSpaces([]did.DID{privateSpace}, nil))
+
+		result, err := service.Query(t.Context(), types.Query{
+			Type:   types.QueryTypeLocation,
+			Hashes: []mh.Multihash{contentLink.Hash()},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []ipld.Link{publicDelegation.Link()}, result.Claims())
+	})
+}
+
+func TestClaimSpaceOf(t *testing.T) {
+	space := testutil.RandomDID(t)
+	index := testutil.RandomCID(t)
+	indexClaim := testutil.Must(assert.Index.Delegate(testutil.Service, testutil.Service, testutil.Service.DID().String(), assert.IndexCaveats{
+		Content: testutil.RandomCID(t),
+		Index:   index,
+	}))(t)
+	locationClaim := testutil.Must(assert.Location.Delegate(testutil.Alice, testutil.Alice, testutil.Alice.DID().String(), assert.LocationCaveats{
+		Space:    space,
+		Content:  ctypes.FromHash(link.ToCID(index).Hash()),
+		Location: []url.URL{*testutil.Must(url.Parse("https://storage.example.com/index"))(t)},
+	}))(t)
+
+	t.Run("location commitment", func(t *testing.T) {
+		require.Equal(t, space, claimSpaceOf(locationClaim, nil))
+	})
+
+	t.Run("index claim takes the space of the index location commitment", func(t *testing.T) {
+		claims := map[cid.Cid]delegation.Delegation{
+			link.ToCID(indexClaim.Link()):    indexClaim,
+			link.ToCID(locationClaim.Link()): locationClaim,
+		}
+		require.Equal(t, space, claimSpaceOf(indexClaim, locationSpacesOf(claims)))
+	})
+
+	t.Run("index claim without a location commitment", func(t *testing.T) {
+		require.Equal(t, did.Undef, claimSpaceOf(indexClaim, map[string]did.DID{}))
+	})
+}
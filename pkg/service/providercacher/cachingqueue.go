@@ -2,12 +2,16 @@ package providercacher
 
 import (
 	"context"
+	"time"
 
+	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipni/go-libipni/find/model"
 	"github.com/storacha/go-libstoracha/blobindex"
 	"github.com/storacha/go-libstoracha/queuepoller"
 )
 
+var log = logging.Logger("providercacher")
+
 type (
 	CachingQueueQueuer = queuepoller.QueueQueuer[ProviderCachingJob]
 	CachingQueue       = queuepoller.Queue[ProviderCachingJob]
@@ -15,11 +19,18 @@ type (
 	ProviderCachingJob struct {
 		Provider model.ProviderResult
 		Index    blobindex.ShardedDagIndexView
+		// QueuedAt is the time the job was first queued, used to measure the
+		// lag of the queue. It is zero if unknown.
+		QueuedAt time.Time
 	}
 
 	JobHandler struct {
 		providerCacher ProviderCacher
 	}
+
+	BatchJobHandler struct {
+		providerCacher BatchProviderCacher
+	}
 )
 
 func NewJobHandler(providerCacher ProviderCacher) *JobHandler {
@@ -29,5 +40,32 @@ func NewJobHandler(providerCacher ProviderCacher) *JobHandler {
 }
 
 func (j *JobHandler) Handle(ctx context.Context, job ProviderCachingJob) error {
+	recordLag(ctx, job)
 	return j.providerCacher.CacheProviderForIndexRecords(ctx, job.Provider, job.Index)
 }
+
+func NewBatchJobHandler(providerCacher BatchProviderCacher) *BatchJobHandler {
+	return &BatchJobHandler{
+		providerCacher: providerCacher,
+	}
+}
+
+// Handle caches the records of all the jobs together. Since caching is
+// idempotent, an error fails every job in the batch so they are retried.
+func (j *BatchJobHandler) Handle(ctx context.Context, jobs []queuepoller.WithID[ProviderCachingJob]) map[string]error {
+	cachingJobs := make([]ProviderCachingJob, 0, len(jobs))
+	for _, job := range jobs {
+		recordLag(ctx, job.Job)
+		cachingJobs = append(cachingJobs, job.Job)
+	}
+	errs := make(map[string]error, len(jobs))
+	added, err := j.providerCacher.CacheProvidersForIndexRecords(ctx, cachingJobs)
+	if err != nil {
+		for _, job := range jobs {
+			errs[job.ID] = err
+		}
+		return errs
+	}
+	log.Debugw("cached provider records", "jobs", len(jobs), "new", added)
+	return errs
+}
@@ -12,3 +12,9 @@ type CachingQueuePoller = queuepoller.QueuePoller[ProviderCachingJob]
 func NewCachingQueuePoller(queue CachingQueue, cacher ProviderCacher, opts ...queuepoller.Option) (*CachingQueuePoller, error) {
 	return queuepoller.NewQueuePoller(queue, queuepoller.JobHandler(NewJobHandler(cacher).Handle), opts...)
 }
+
+// NewBatchCachingQueuePoller creates a new CachingQueuePoller instance that
+// caches the jobs of each batch read from the queue together.
+func NewBatchCachingQueuePoller(queue CachingQueue, cacher BatchProviderCacher, opts ...queuepoller.Option) (*CachingQueuePoller, error) {
+	return queuepoller.NewQueuePoller(queue, queuepoller.BatchJobHandler(NewBatchJobHandler(cacher).Handle), opts...)
+}
@@ -0,0 +1,452 @@
+package providercacher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/queuepoller"
+)
+
+const (
+	// DefaultVisibilityTimeout is the default duration a job read from a
+	// [DatastoreCachingQueue] is hidden from other readers before it becomes
+	// available again, if not deleted or released.
+	DefaultVisibilityTimeout = 10 * time.Minute
+	// DefaultMaxAttempts is the default number of times a job is read from a
+	// [DatastoreCachingQueue] before it is moved to the dead-letter area.
+	DefaultMaxAttempts = 5
+	// DefaultPollInterval is the default maximum duration a read from an empty
+	// [DatastoreCachingQueue] waits for jobs to become available.
+	DefaultPollInterval = time.Second
+)
+
+var (
+	jobsPrefix       = datastore.NewKey("jobs")
+	indexesPrefix    = datastore.NewKey("indexes")
+	deadLetterPrefix = datastore.NewKey("deadletter")
+)
+
+// ErrJobNotFound is returned when a job does not exist in the queue.
+var ErrJobNotFound = errors.New("job not found")
+
+var _ CachingQueue = (*DatastoreCachingQueue)(nil)
+
+// dsCachingQueueRecord is the JSON serialized state of a job in the queue. The
+// index is stored separately.
+type dsCachingQueueRecord struct {
+	Provider  model.ProviderResult `json:"provider"`
+	Attempts  int                  `json:"attempts"`
+	VisibleAt int64                `json:"visibleAt"`
+	QueuedAt  int64                `json:"queuedAt,omitempty"`
+}
+
+// DatastoreCachingQueue is a durable [CachingQueue] backed by a datastore,
+// providing the same at-least-once semantics as the SQS backed queue for
+// deployments without SQS.
+//
+// Jobs read from the queue are hidden from subsequent reads for the
+// visibility timeout. Jobs that are not deleted within the timeout (e.g.
+// because the process was restarted) become available again. Jobs read more
+// than the maximum number of attempts, or that cannot be decoded, are moved to
+// a dead-letter area, from where they may be inspected and redriven.
+type DatastoreCachingQueue struct {
+	ds                datastore.Batching
+	clock             clock.Clock
+	visibilityTimeout time.Duration
+	maxAttempts       int
+	pollInterval      time.Duration
+	mutex             sync.Mutex
+	notify            chan struct{}
+}
+
+// DatastoreCachingQueueOption configures a [DatastoreCachingQueue].
+type DatastoreCachingQueueOption func(q *DatastoreCachingQueue)
+
+// WithVisibilityTimeout sets the duration a job read from the queue is hidden
+// from other reads.
+func WithVisibilityTimeout(timeout time.Duration) DatastoreCachingQueueOption {
+	return func(q *DatastoreCachingQueue) {
+		q.visibilityTimeout = timeout
+	}
+}
+
+// WithMaxAttempts sets the number of times a job is read from the queue before
+// it is moved to the dead-letter area.
+func WithMaxAttempts(attempts int) DatastoreCachingQueueOption {
+	return func(q *DatastoreCachingQueue) {
+		q.maxAttempts = attempts
+	}
+}
+
+// WithPollInterval sets the maximum duration a read from an empty queue waits
+// for jobs to become available.
+func WithPollInterval(interval time.Duration) DatastoreCachingQueueOption {
+	return func(q *DatastoreCachingQueue) {
+		q.pollInterval = interval
+	}
+}
+
+// WithQueueClock configures the queue with a mockable clock for testing.
+func WithQueueClock(clock clock.Clock) DatastoreCachingQueueOption {
+	return func(q *DatastoreCachingQueue) {
+		q.clock = clock
+	}
+}
+
+// NewDatastoreCachingQueue creates a new [DatastoreCachingQueue] that stores
+// jobs in the passed datastore. The datastore should be namespaced for the
+// exclusive use of the queue.
+func NewDatastoreCachingQueue(ds datastore.Batching, opts ...DatastoreCachingQueueOption) *DatastoreCachingQueue {
+	q := &DatastoreCachingQueue{
+		ds:                ds,
+		clock:             clock.New(),
+		visibilityTimeout: DefaultVisibilityTimeout,
+		maxAttempts:       DefaultMaxAttempts,
+		pollInterval:      DefaultPollInterval,
+		notify:            make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Queue adds a job to the queue.
+func (q *DatastoreCachingQueue) Queue(ctx context.Context, job ProviderCachingJob) error {
+	r, err := job.Index.Archive()
+	if err != nil {
+		return fmt.Errorf("serializing index to CAR: %w", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading index from CAR: %w", err)
+	}
+
+	id := uuid.New().String()
+	now := q.clock.Now()
+	queuedAt := job.QueuedAt
+	if queuedAt.IsZero() {
+		queuedAt = now
+	}
+	rec, err := json.Marshal(dsCachingQueueRecord{
+		Provider:  job.Provider,
+		VisibleAt: now.UnixMilli(),
+		QueuedAt:  queuedAt.UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("serializing job: %w", err)
+	}
+
+	batch, err := q.ds.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("creating batch: %w", err)
+	}
+	if err := batch.Put(ctx, indexesPrefix.ChildString(id), data); err != nil {
+		return fmt.Errorf("storing index: %w", err)
+	}
+	if err := batch.Put(ctx, jobsPrefix.ChildString(id), rec); err != nil {
+		return fmt.Errorf("storing job: %w", err)
+	}
+	if err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("committing job: %w", err)
+	}
+
+	q.signal()
+	return nil
+}
+
+// Read reads up to maxJobs visible jobs from the queue, hiding them from other
+// reads for the visibility timeout. If no jobs are visible, it waits up to the
+// poll interval for jobs to be queued or released before returning an empty
+// slice.
+func (q *DatastoreCachingQueue) Read(ctx context.Context, maxJobs int) ([]queuepoller.WithID[ProviderCachingJob], error) {
+	jobs, err := q.receive(ctx, maxJobs)
+	if err != nil || len(jobs) > 0 {
+		return jobs, err
+	}
+
+	timer := q.clock.Timer(q.pollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return []queuepoller.WithID[ProviderCachingJob]{}, nil
+	case <-q.notify:
+	case <-timer.C:
+	}
+	return q.receive(ctx, maxJobs)
+}
+
+func (q *DatastoreCachingQueue) receive(ctx context.Context, maxJobs int) ([]queuepoller.WithID[ProviderCachingJob], error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := q.clock.Now()
+	results, err := q.ds.Query(ctx, query.Query{
+		Prefix:  jobsPrefix.String(),
+		Filters: []query.Filter{visibleFilter{now: now}},
+		Limit:   maxJobs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying jobs: %w", err)
+	}
+	// read the entries up front so the jobs can be updated while iterating
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, fmt.Errorf("iterating jobs: %w", err)
+	}
+
+	jobs := []queuepoller.WithID[ProviderCachingJob]{}
+	for _, entry := range entries {
+		id := datastore.NewKey(entry.Key).BaseNamespace()
+		var rec dsCachingQueueRecord
+		if err := json.Unmarshal(entry.Value, &rec); err != nil {
+			// a record that cannot be decoded would otherwise block the queue
+			log.Errorf("moving undecodable provider caching job %s to dead-letter area: %s", id, err)
+			if err := q.moveToDeadLetter(ctx, id, entry.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if rec.Attempts >= q.maxAttempts {
+			if err := q.moveToDeadLetter(ctx, id, entry.Value); err != nil {
+				return nil, err
+			}
+			log.Warnf("moved provider caching job %s to dead-letter area after %d attempts", id, rec.Attempts)
+			continue
+		}
+
+		data, err := q.ds.Get(ctx, indexesPrefix.ChildString(id))
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return nil, fmt.Errorf("reading stored index for job %s: %w", id, err)
+		}
+		var index blobindex.ShardedDagIndexView
+		if err == nil {
+			index, err = blobindex.Extract(bytes.NewReader(data))
+		}
+		if err != nil {
+			log.Errorf("moving provider caching job %s with unreadable index to dead-letter area: %s", id, err)
+			if err := q.moveToDeadLetter(ctx, id, entry.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rec.Attempts++
+		rec.VisibleAt = now.Add(q.visibilityTimeout).UnixMilli()
+		if err := q.putRecord(ctx, id, rec); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, queuepoller.WithID[ProviderCachingJob]{
+			ID:  receiptHandle(id, rec.Attempts),
+			Job: ProviderCachingJob{Provider: rec.Provider, Index: index, QueuedAt: unixMilli(rec.QueuedAt)},
+		})
+	}
+	return jobs, nil
+}
+
+// visibleFilter matches the jobs that are visible at the passed time. Records
+// that cannot be decoded are matched too, so that they are moved out of the
+// way of the jobs behind them.
+type visibleFilter struct {
+	now time.Time
+}
+
+func (f visibleFilter) Filter(e query.Entry) bool {
+	var rec dsCachingQueueRecord
+	if err := json.Unmarshal(e.Value, &rec); err != nil {
+		return true
+	}
+	return rec.VisibleAt <= f.now.UnixMilli()
+}
+
+// Release makes a job available for processing again. Releasing a job that
+// was read again after its visibility timeout expired has no effect.
+func (q *DatastoreCachingQueue) Release(ctx context.Context, jobID string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	id, rec, err := q.getReceived(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	rec.VisibleAt = q.clock.Now().UnixMilli()
+	if err := q.putRecord(ctx, id, rec); err != nil {
+		return err
+	}
+	q.signal()
+	return nil
+}
+
+// Delete removes a job from the queue. Deleting a job that was read again
+// after its visibility timeout expired has no effect.
+func (q *DatastoreCachingQueue) Delete(ctx context.Context, jobID string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	id, _, err := q.getReceived(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	batch, err := q.ds.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("creating batch: %w", err)
+	}
+	if err := batch.Delete(ctx, jobsPrefix.ChildString(id)); err != nil {
+		return fmt.Errorf("deleting job: %w", err)
+	}
+	if err := batch.Delete(ctx, indexesPrefix.ChildString(id)); err != nil {
+		return fmt.Errorf("deleting index: %w", err)
+	}
+	return batch.Commit(ctx)
+}
+
+// Len returns the number of jobs in the queue, including jobs that are being
+// processed but excluding jobs in the dead-letter area.
+func (q *DatastoreCachingQueue) Len(ctx context.Context) (int, error) {
+	results, err := q.ds.Query(ctx, query.Query{Prefix: jobsPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("querying jobs: %w", err)
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return 0, fmt.Errorf("iterating jobs: %w", err)
+	}
+	return len(entries), nil
+}
+
+// DeadLetters returns the IDs of the jobs in the dead-letter area.
+func (q *DatastoreCachingQueue) DeadLetters(ctx context.Context) ([]string, error) {
+	results, err := q.ds.Query(ctx, query.Query{Prefix: deadLetterPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("querying dead-letter jobs: %w", err)
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, fmt.Errorf("iterating dead-letter jobs: %w", err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, datastore.NewKey(entry.Key).BaseNamespace())
+	}
+	return ids, nil
+}
+
+// Redrive moves a job from the dead-letter area back onto the queue, resetting
+// its attempts.
+func (q *DatastoreCachingQueue) Redrive(ctx context.Context, id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	value, err := q.ds.Get(ctx, deadLetterPrefix.ChildString(id))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return ErrJobNotFound
+		}
+		return fmt.Errorf("reading dead-letter job: %w", err)
+	}
+	var rec dsCachingQueueRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return fmt.Errorf("deserializing job %s: %w", id, err)
+	}
+	rec.Attempts = 0
+	rec.VisibleAt = q.clock.Now().UnixMilli()
+	if err := q.putRecord(ctx, id, rec); err != nil {
+		return err
+	}
+	if err := q.ds.Delete(ctx, deadLetterPrefix.ChildString(id)); err != nil {
+		return fmt.Errorf("deleting dead-letter job: %w", err)
+	}
+	q.signal()
+	return nil
+}
+
+func (q *DatastoreCachingQueue) moveToDeadLetter(ctx context.Context, id string, value []byte) error {
+	batch, err := q.ds.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("creating batch: %w", err)
+	}
+	if err := batch.Put(ctx, deadLetterPrefix.ChildString(id), value); err != nil {
+		return fmt.Errorf("storing dead-letter job: %w", err)
+	}
+	if err := batch.Delete(ctx, jobsPrefix.ChildString(id)); err != nil {
+		return fmt.Errorf("deleting job: %w", err)
+	}
+	return batch.Commit(ctx)
+}
+
+// getReceived returns the job for a receipt handle, or [ErrJobNotFound] if the
+// job no longer exists or has been read again since the receipt was issued.
+func (q *DatastoreCachingQueue) getReceived(ctx context.Context, jobID string) (string, dsCachingQueueRecord, error) {
+	id, attempts, err := parseReceiptHandle(jobID)
+	if err != nil {
+		return "", dsCachingQueueRecord{}, err
+	}
+	value, err := q.ds.Get(ctx, jobsPrefix.ChildString(id))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return "", dsCachingQueueRecord{}, ErrJobNotFound
+		}
+		return "", dsCachingQueueRecord{}, fmt.Errorf("reading job: %w", err)
+	}
+	var rec dsCachingQueueRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return "", dsCachingQueueRecord{}, fmt.Errorf("deserializing job %s: %w", id, err)
+	}
+	if rec.Attempts != attempts {
+		return "", dsCachingQueueRecord{}, ErrJobNotFound
+	}
+	return id, rec, nil
+}
+
+func (q *DatastoreCachingQueue) putRecord(ctx context.Context, id string, rec dsCachingQueueRecord) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("serializing job: %w", err)
+	}
+	if err := q.ds.Put(ctx, jobsPrefix.ChildString(id), value); err != nil {
+		return fmt.Errorf("storing job: %w", err)
+	}
+	return nil
+}
+
+// signal wakes up a pending read, if any.
+func (q *DatastoreCachingQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// receiptHandle identifies a single read of a job, so that a stale handle
+// cannot delete or release a job that has since been read again.
+func receiptHandle(id string, attempts int) string {
+	return id + ":" + strconv.Itoa(attempts)
+}
+
+func parseReceiptHandle(handle string) (string, int, error) {
+	id, attempts, ok := strings.Cut(handle, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid receipt handle: %s", handle)
+	}
+	n, err := strconv.Atoi(attempts)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid receipt handle: %s", handle)
+	}
+	return id, n, nil
+}
@@ -0,0 +1,213 @@
+package providercacher_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/service/providercacher"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatastoreCachingQueue(t *testing.T) {
+	newJob := func(t *testing.T) providercacher.ProviderCachingJob {
+		_, index := testutil.RandomShardedDagIndexView(t, 32)
+		return providercacher.ProviderCachingJob{
+			Provider: testutil.RandomLocationCommitmentProviderResult(t),
+			Index:    index,
+		}
+	}
+
+	t.Run("reads and deletes queued jobs", func(t *testing.T) {
+		ctx := context.Background()
+		queue := providercacher.NewDatastoreCachingQueue(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+		job := newJob(t)
+		require.NoError(t, queue.Queue(ctx, job))
+
+		jobs, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.Equal(t, job.Provider, jobs[0].Job.Provider)
+		expected, err := job.Index.Archive()
+		require.NoError(t, err)
+		actual, err := jobs[0].Job.Index.Archive()
+		require.NoError(t, err)
+		expectedBytes, err := io.ReadAll(expected)
+		require.NoError(t, err)
+		actualBytes, err := io.ReadAll(actual)
+		require.NoError(t, err)
+		require.Equal(t, expectedBytes, actualBytes)
+
+		require.NoError(t, queue.Delete(ctx, jobs[0].ID))
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		jobs, err = queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, jobs)
+	})
+
+	t.Run("hides read jobs until visibility timeout expires", func(t *testing.T) {
+		ctx := context.Background()
+		clk := clock.NewMock()
+		queue := providercacher.NewDatastoreCachingQueue(
+			dssync.MutexWrap(datastore.NewMapDatastore()),
+			providercacher.WithQueueClock(clk),
+			providercacher.WithVisibilityTimeout(time.Minute),
+			providercacher.WithPollInterval(0),
+		)
+
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+
+		first, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+
+		jobs, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, jobs)
+
+		clk.Add(2 * time.Minute)
+		second, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+
+		// the receipt from the first read is stale
+		require.ErrorIs(t, queue.Delete(ctx, first[0].ID), providercacher.ErrJobNotFound)
+		require.NoError(t, queue.Delete(ctx, second[0].ID))
+	})
+
+	t.Run("released jobs are available immediately", func(t *testing.T) {
+		ctx := context.Background()
+		queue := providercacher.NewDatastoreCachingQueue(
+			dssync.MutexWrap(datastore.NewMapDatastore()),
+			providercacher.WithPollInterval(0),
+		)
+
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+
+		jobs, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.NoError(t, queue.Release(ctx, jobs[0].ID))
+
+		jobs, err = queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+	})
+
+	t.Run("moves jobs to dead-letter area after max attempts", func(t *testing.T) {
+		ctx := context.Background()
+		queue := providercacher.NewDatastoreCachingQueue(
+			dssync.MutexWrap(datastore.NewMapDatastore()),
+			providercacher.WithMaxAttempts(2),
+			providercacher.WithPollInterval(0),
+		)
+
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+
+		for range 2 {
+			jobs, err := queue.Read(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			require.NoError(t, queue.Release(ctx, jobs[0].ID))
+		}
+
+		jobs, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, jobs)
+
+		dead, err := queue.DeadLetters(ctx)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+
+		require.NoError(t, queue.Redrive(ctx, dead[0]))
+		jobs, err = queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		dead, err = queue.DeadLetters(ctx)
+		require.NoError(t, err)
+		require.Empty(t, dead)
+	})
+
+	t.Run("moves undecodable jobs to dead-letter area", func(t *testing.T) {
+		ctx := context.Background()
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		queue := providercacher.NewDatastoreCachingQueue(ds, providercacher.WithPollInterval(0))
+
+		require.NoError(t, ds.Put(ctx, datastore.NewKey("jobs/bad-record"), []byte("not json")))
+		require.NoError(t, ds.Put(ctx, datastore.NewKey("jobs/bad-index"), []byte(`{"visibleAt":0}`)))
+		require.NoError(t, ds.Put(ctx, datastore.NewKey("indexes/bad-index"), []byte("not an index")))
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+
+		jobs, err := queue.Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		dead, err := queue.DeadLetters(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"bad-record", "bad-index"}, dead)
+	})
+
+	t.Run("reads at most the requested number of jobs", func(t *testing.T) {
+		ctx := context.Background()
+		queue := providercacher.NewDatastoreCachingQueue(dssync.MutexWrap(datastore.NewMapDatastore()), providercacher.WithPollInterval(0))
+
+		for range 3 {
+			require.NoError(t, queue.Queue(ctx, newJob(t)))
+		}
+
+		jobs, err := queue.Read(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+
+		jobs, err = queue.Read(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+	})
+
+	t.Run("reports length and preserves queued time", func(t *testing.T) {
+		ctx := context.Background()
+		clk := clock.NewMock()
+		clk.Set(time.UnixMilli(1_000_000))
+		queue := providercacher.NewDatastoreCachingQueue(
+			dssync.MutexWrap(datastore.NewMapDatastore()),
+			providercacher.WithQueueClock(clk),
+		)
+
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+		require.NoError(t, queue.Queue(ctx, newJob(t)))
+
+		n, err := queue.Len(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		jobs, err := queue.Read(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.True(t, jobs[0].Job.QueuedAt.Equal(clk.Now()))
+
+		require.NoError(t, queue.Delete(ctx, jobs[0].ID))
+		n, err = queue.Len(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("jobs survive a new queue instance", func(t *testing.T) {
+		ctx := context.Background()
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+		require.NoError(t, providercacher.NewDatastoreCachingQueue(ds).Queue(ctx, newJob(t)))
+
+		jobs, err := providercacher.NewDatastoreCachingQueue(ds).Read(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+	})
+}
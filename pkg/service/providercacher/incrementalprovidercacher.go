@@ -0,0 +1,305 @@
+package providercacher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/providerresults"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"github.com/storacha/indexing-service/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/service/providercacher")
+
+// ErrCachingInterrupted is returned when a caching job ran out of time after
+// checkpointing its progress, so that it should be retried rather than dropped.
+var ErrCachingInterrupted = errors.New("provider caching interrupted")
+
+// IncrementalProviderCacher is a [BatchProviderCacher] that avoids rewriting
+// provider records that are already cached.
+//
+// Once all the records for an index have been written, a marker keyed by the
+// context ID of the provider result is stored, and subsequent jobs for the same
+// provider result and index are skipped until the marker expires. Records for
+// multiple jobs are written in shared batches.
+//
+// If a checkpoint store is configured, the progress of each job is stored
+// every time a batch is committed, and a retried job resumes from where it was
+// interrupted.
+type IncrementalProviderCacher struct {
+	providerStore types.ProviderStore
+	cachedIndexes types.CachedIndexStore
+	checkpoints   types.CachingCheckpointStore
+	newEntries    metric.Int64Counter
+	skipped       metric.Int64Counter
+}
+
+var _ BatchProviderCacher = (*IncrementalProviderCacher)(nil)
+
+// IncrementalProviderCacherOption configures an [IncrementalProviderCacher].
+type IncrementalProviderCacherOption func(c *IncrementalProviderCacher)
+
+// WithCheckpoints stores the progress of caching jobs in the passed store, so
+// that interrupted jobs are resumed instead of restarted.
+func WithCheckpoints(checkpoints types.CachingCheckpointStore) IncrementalProviderCacherOption {
+	return func(c *IncrementalProviderCacher) {
+		c.checkpoints = checkpoints
+	}
+}
+
+// NewIncrementalProviderCacher creates a new [IncrementalProviderCacher]. The
+// cached index store should expire markers no later than the provider store
+// expires the records they refer to.
+func NewIncrementalProviderCacher(providerStore types.ProviderStore, cachedIndexes types.CachedIndexStore, opts ...IncrementalProviderCacherOption) (*IncrementalProviderCacher, error) {
+	c := &IncrementalProviderCacher{
+		providerStore: providerStore,
+		cachedIndexes: cachedIndexes,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var err error
+	c.newEntries, err = meter.Int64Counter(
+		"providercacher.new_entries",
+		metric.WithDescription("Number of provider records newly added to the cache."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating new entries counter: %w", err)
+	}
+	c.skipped, err = meter.Int64Counter(
+		"providercacher.skipped_indexes",
+		metric.WithDescription("Number of indexes skipped because they were already fully cached."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating skipped indexes counter: %w", err)
+	}
+	return c, nil
+}
+
+// CacheProviderForIndexRecords caches the provider for all the records in the
+// index, unless they have already been cached.
+func (c *IncrementalProviderCacher) CacheProviderForIndexRecords(ctx context.Context, provider model.ProviderResult, index blobindex.ShardedDagIndexView) error {
+	_, err := c.CacheProvidersForIndexRecords(ctx, []ProviderCachingJob{{Provider: provider, Index: index}})
+	return err
+}
+
+// CacheProvidersForIndexRecords caches the provider for all the records in the
+// index of each job, sharing write batches between jobs. It returns the number
+// of records that were newly added to the cache.
+//
+// If checkpoints are enabled and the context deadline is exceeded after this
+// call stored a newer checkpoint, the returned error wraps
+// [ErrCachingInterrupted] instead of [context.DeadlineExceeded], so that the
+// jobs are retried. A job that times out without making progress returns the
+// deadline error, so that it is not retried indefinitely.
+func (c *IncrementalProviderCacher) CacheProvidersForIndexRecords(ctx context.Context, jobs []ProviderCachingJob) (uint64, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ProviderCacher.CacheProvidersForIndexRecords")
+	defer span.End()
+
+	w := batchWriter{cacher: c, batch: c.providerStore.Batch()}
+	skipped := 0
+	err := func() error {
+		for _, job := range jobs {
+			key, err := cachedIndexKey(job.Provider, job.Index)
+			if err != nil {
+				return err
+			}
+			cached, err := c.cachedIndexes.Get(ctx, key)
+			if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+				return fmt.Errorf("checking if index is cached: %w", err)
+			}
+			if cached {
+				c.skipped.Add(ctx, 1)
+				skipped++
+				continue
+			}
+			if err := c.cacheJob(ctx, &w, key, job); err != nil {
+				return err
+			}
+		}
+		return w.commit(ctx)
+	}()
+	if err != nil {
+		if w.checkpointed && errors.Is(err, context.DeadlineExceeded) {
+			return w.added, fmt.Errorf("%w: %s", ErrCachingInterrupted, err)
+		}
+		return w.added, err
+	}
+
+	c.newEntries.Add(ctx, int64(w.added))
+	span.SetAttributes(
+		attribute.Int("total", w.total),
+		attribute.Int64("new", int64(w.added)),
+		attribute.Int("skipped", skipped),
+	)
+	return w.added, nil
+}
+
+func (c *IncrementalProviderCacher) cacheJob(ctx context.Context, w *batchWriter, key string, job ProviderCachingJob) error {
+	var cursor types.CachingCheckpoint
+	if c.checkpoints != nil {
+		var err error
+		cursor, err = c.checkpoints.Get(ctx, key)
+		if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+			return fmt.Errorf("reading caching checkpoint: %w", err)
+		}
+	}
+
+	w.begin(key, cursor)
+
+	// Prioritize the root
+	rootDigest := link.ToCID(job.Index.Content()).Hash()
+	if err := w.add(ctx, rootDigest, job.Provider); err != nil {
+		return err
+	}
+
+	// iterate in a stable order, so that a checkpoint identifies the same
+	// position when the job is retried
+	for i, shard := range sortedShards(job.Index) {
+		if i < cursor.Shard {
+			continue
+		}
+		for j, hash := range sortedSlices(job.Index.Shards().Get(shard)) {
+			if i == cursor.Shard && j < cursor.Slice {
+				continue
+			}
+			w.cursor = types.CachingCheckpoint{Shard: i, Slice: j + 1}
+			if string(hash) == string(rootDigest) {
+				continue // already added
+			}
+			if err := w.add(ctx, hash, job.Provider); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.end()
+	return nil
+}
+
+// batchWriter writes provider records to the store, committing when the batch
+// reaches [MaxBatchSize]. When a batch is committed, jobs whose records have
+// all been written are marked as cached and the progress of the job being
+// written is checkpointed.
+type batchWriter struct {
+	cacher  *IncrementalProviderCacher
+	batch   types.ValueSetCacheBatcher[mh.Multihash, model.ProviderResult]
+	size    int
+	total   int
+	added   uint64
+	done    []string
+	current string
+	cursor  types.CachingCheckpoint
+	// checkpointed is set once a checkpoint is stored for progress made by
+	// this writer.
+	checkpointed bool
+}
+
+func (w *batchWriter) begin(key string, cursor types.CachingCheckpoint) {
+	w.current = key
+	w.cursor = cursor
+}
+
+func (w *batchWriter) end() {
+	w.done = append(w.done, w.current)
+	w.current = ""
+}
+
+func (w *batchWriter) add(ctx context.Context, digest mh.Multihash, provider model.ProviderResult) error {
+	if err := w.batch.Add(ctx, digest, provider); err != nil {
+		return fmt.Errorf("batch adding provider: %w", err)
+	}
+	if err := w.batch.SetExpirable(ctx, digest, true); err != nil {
+		return fmt.Errorf("batch setting provider expirable: %w", err)
+	}
+	w.total++
+	w.size++
+	if w.size >= MaxBatchSize {
+		if err := w.commit(ctx); err != nil {
+			return err
+		}
+		w.batch = w.cacher.providerStore.Batch()
+	}
+	return nil
+}
+
+func (w *batchWriter) commit(ctx context.Context) error {
+	committed := w.size > 0
+	if committed {
+		if err := w.batch.Commit(ctx); err != nil {
+			return fmt.Errorf("batch commiting: %w", err)
+		}
+		if counter, ok := w.batch.(types.AddCounter); ok {
+			w.added += counter.Added()
+		} else {
+			// assume all records were new if the store cannot tell
+			w.added += uint64(w.size)
+		}
+		w.size = 0
+	}
+
+	// only mark indexes as cached once all their records have been written
+	for _, key := range w.done {
+		if err := w.cacher.cachedIndexes.Set(ctx, key, true, true); err != nil {
+			return fmt.Errorf("marking index as cached: %w", err)
+		}
+	}
+	w.done = nil
+
+	if w.cacher.checkpoints != nil && w.current != "" {
+		if err := w.cacher.checkpoints.Set(ctx, w.current, w.cursor, true); err != nil {
+			return fmt.Errorf("storing caching checkpoint: %w", err)
+		}
+		w.checkpointed = w.checkpointed || committed
+	}
+	return nil
+}
+
+func sortedShards(index blobindex.ShardedDagIndexView) []mh.Multihash {
+	var shards []mh.Multihash
+	for shard := range index.Shards().Iterator() {
+		shards = append(shards, shard)
+	}
+	slices.SortFunc(shards, func(a, b mh.Multihash) int { return bytes.Compare(a, b) })
+	return shards
+}
+
+func sortedSlices(shard blobindex.MultihashMap[blobindex.Position]) []mh.Multihash {
+	hashes := make([]mh.Multihash, 0, shard.Size())
+	for hash := range shard.Iterator() {
+		hashes = append(hashes, hash)
+	}
+	slices.SortFunc(hashes, func(a, b mh.Multihash) int { return bytes.Compare(a, b) })
+	return hashes
+}
+
+// cachedIndexKey returns a key identifying the provider result and index. The
+// context ID is kept readable, while the rest of the provider result (which
+// includes the metadata, e.g. a location commitment expiry), the index content
+// root and its shards are hashed.
+func cachedIndexKey(provider model.ProviderResult, index blobindex.ShardedDagIndexView) (string, error) {
+	data, err := providerresults.MarshalCBOR(provider)
+	if err != nil {
+		return "", fmt.Errorf("serializing provider result: %w", err)
+	}
+	h := sha256.New()
+	h.Write(data)
+	h.Write(link.ToCID(index.Content()).Hash())
+	for _, shard := range sortedShards(index) {
+		h.Write(shard)
+	}
+	return hex.EncodeToString(provider.ContextID) + "/" + hex.EncodeToString(h.Sum(nil)), nil
+}
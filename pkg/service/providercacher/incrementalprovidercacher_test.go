@@ -0,0 +1,201 @@
+package providercacher_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/service/providercacher"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalProviderCacher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches records and skips indexes already cached", func(t *testing.T) {
+		providerStore := &MockProviderStore{store: map[string][]model.ProviderResult{}}
+		cachedIndexes := &MockCachedIndexStore{store: map[string]bool{}}
+		cacher, err := providercacher.NewIncrementalProviderCacher(providerStore, cachedIndexes)
+		require.NoError(t, err)
+
+		provider := testutil.RandomProviderResult(t)
+		_, index := testutil.RandomShardedDagIndexView(t, 32)
+
+		added, err := cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: provider, Index: index}})
+		require.NoError(t, err)
+		require.NotZero(t, added)
+		require.Len(t, cachedIndexes.store, 1)
+		entries := len(providerStore.store)
+
+		// a second job for the same provider result and index writes nothing
+		providerStore.store = map[string][]model.ProviderResult{}
+		added, err = cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: provider, Index: index}})
+		require.NoError(t, err)
+		require.Zero(t, added)
+		require.Empty(t, providerStore.store)
+
+		// a different provider result for the same index is cached
+		other := testutil.RandomProviderResult(t)
+		added, err = cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: other, Index: index}})
+		require.NoError(t, err)
+		require.Equal(t, uint64(entries), added)
+		require.Len(t, cachedIndexes.store, 2)
+	})
+
+	t.Run("caches multiple jobs together", func(t *testing.T) {
+		providerStore := &MockProviderStore{store: map[string][]model.ProviderResult{}}
+		cachedIndexes := &MockCachedIndexStore{store: map[string]bool{}}
+		cacher, err := providercacher.NewIncrementalProviderCacher(providerStore, cachedIndexes)
+		require.NoError(t, err)
+
+		var jobs []providercacher.ProviderCachingJob
+		for range 3 {
+			_, index := testutil.RandomShardedDagIndexView(t, 32)
+			jobs = append(jobs, providercacher.ProviderCachingJob{Provider: testutil.RandomProviderResult(t), Index: index})
+		}
+
+		added, err := cacher.CacheProvidersForIndexRecords(ctx, jobs)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(providerStore.store)), added)
+		require.Len(t, cachedIndexes.store, 3)
+	})
+
+	t.Run("resumes interrupted jobs from checkpoint", func(t *testing.T) {
+		root := testutil.RandomCID(t)
+		index := blobindex.NewShardedDagIndexView(root, 2)
+		for _, shard := range testutil.RandomMultihashes(t, 2) {
+			for _, slice := range testutil.RandomMultihashes(t, 6_000) {
+				index.SetSlice(shard, slice, blobindex.Position{})
+			}
+		}
+		provider := testutil.RandomProviderResult(t)
+
+		providerStore := &MockProviderStore{store: map[string][]model.ProviderResult{}}
+		cachedIndexes := &MockCachedIndexStore{store: map[string]bool{}}
+		checkpoints := &MockCachingCheckpointStore{store: map[string]types.CachingCheckpoint{}}
+
+		// the second commit runs out of time
+		interrupted := &interruptingProviderStore{MockProviderStore: providerStore, commits: 1}
+		cacher, err := providercacher.NewIncrementalProviderCacher(interrupted, cachedIndexes, providercacher.WithCheckpoints(checkpoints))
+		require.NoError(t, err)
+
+		added, err := cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: provider, Index: index}})
+		require.ErrorIs(t, err, providercacher.ErrCachingInterrupted)
+		require.NotErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, uint64(providercacher.MaxBatchSize), added)
+		require.Len(t, checkpoints.store, 1)
+		require.Empty(t, cachedIndexes.store)
+
+		cacher, err = providercacher.NewIncrementalProviderCacher(providerStore, cachedIndexes, providercacher.WithCheckpoints(checkpoints))
+		require.NoError(t, err)
+
+		added, err = cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: provider, Index: index}})
+		require.NoError(t, err)
+		// only the records after the checkpoint are written
+		require.Equal(t, uint64(12_001-providercacher.MaxBatchSize), added)
+		require.Len(t, providerStore.store, 12_001)
+		require.Len(t, cachedIndexes.store, 1)
+	})
+
+	t.Run("returns deadline error for jobs interrupted without progress", func(t *testing.T) {
+		_, index := testutil.RandomShardedDagIndexView(t, 32)
+		provider := testutil.RandomProviderResult(t)
+
+		providerStore := &MockProviderStore{store: map[string][]model.ProviderResult{}}
+		cachedIndexes := &MockCachedIndexStore{store: map[string]bool{}}
+		checkpoints := &MockCachingCheckpointStore{store: map[string]types.CachingCheckpoint{}}
+
+		// the first commit runs out of time
+		interrupted := &interruptingProviderStore{MockProviderStore: providerStore}
+		cacher, err := providercacher.NewIncrementalProviderCacher(interrupted, cachedIndexes, providercacher.WithCheckpoints(checkpoints))
+		require.NoError(t, err)
+
+		_, err = cacher.CacheProvidersForIndexRecords(ctx, []providercacher.ProviderCachingJob{{Provider: provider, Index: index}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotErrorIs(t, err, providercacher.ErrCachingInterrupted)
+	})
+}
+
+// interruptingProviderStore fails commits with a deadline exceeded error once
+// the configured number of commits have succeeded.
+type interruptingProviderStore struct {
+	*MockProviderStore
+	commits int
+}
+
+func (s *interruptingProviderStore) Batch() types.ValueSetCacheBatcher[multihash.Multihash, model.ProviderResult] {
+	return &interruptingBatcher{MockBatcher: &MockBatcher{store: s.MockProviderStore}, store: s}
+}
+
+type interruptingBatcher struct {
+	*MockBatcher
+	store *interruptingProviderStore
+}
+
+func (b *interruptingBatcher) Commit(ctx context.Context) error {
+	if b.store.commits == 0 {
+		return context.DeadlineExceeded
+	}
+	b.store.commits--
+	return b.MockBatcher.Commit(ctx)
+}
+
+// MockCachingCheckpointStore is a mock implementation of the
+// CachingCheckpointStore interface
+type MockCachingCheckpointStore struct {
+	store map[string]types.CachingCheckpoint
+}
+
+var _ types.CachingCheckpointStore = &MockCachingCheckpointStore{}
+
+func (m *MockCachingCheckpointStore) Get(ctx context.Context, key string) (types.CachingCheckpoint, error) {
+	checkpoint, ok := m.store[key]
+	if !ok {
+		return types.CachingCheckpoint{}, types.ErrKeyNotFound
+	}
+	return checkpoint, nil
+}
+
+func (m *MockCachingCheckpointStore) Set(ctx context.Context, key string, value types.CachingCheckpoint, expires bool) error {
+	m.store[key] = value
+	return nil
+}
+
+func (m *MockCachingCheckpointStore) SetExpirable(ctx context.Context, key string, expires bool) error {
+	return nil
+}
+
+// MockCachedIndexStore is a mock implementation of the CachedIndexStore
+// interface
+type MockCachedIndexStore struct {
+	store map[string]bool
+	mutex sync.RWMutex
+}
+
+var _ types.CachedIndexStore = &MockCachedIndexStore{}
+
+func (m *MockCachedIndexStore) Get(ctx context.Context, key string) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	cached, ok := m.store[key]
+	if !ok {
+		return false, types.ErrKeyNotFound
+	}
+	return cached, nil
+}
+
+func (m *MockCachedIndexStore) Set(ctx context.Context, key string, value bool, expires bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.store[key] = value
+	return nil
+}
+
+func (m *MockCachedIndexStore) SetExpirable(ctx context.Context, key string, expires bool) error {
+	return nil
+}
@@ -10,3 +10,12 @@ import (
 type ProviderCacher interface {
 	CacheProviderForIndexRecords(ctx context.Context, provider model.ProviderResult, index blobindex.ShardedDagIndexView) error
 }
+
+// BatchProviderCacher is a ProviderCacher that is able to cache the records of
+// multiple indexes at once.
+type BatchProviderCacher interface {
+	ProviderCacher
+	// CacheProvidersForIndexRecords caches the provider for the records in the
+	// index of each job, returning the number of newly cached records.
+	CacheProvidersForIndexRecords(ctx context.Context, jobs []ProviderCachingJob) (uint64, error)
+}
@@ -0,0 +1,62 @@
+package providercacher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var queueLag = func() metric.Float64Histogram {
+	histogram, err := meter.Float64Histogram(
+		"caching_queue.lag",
+		metric.WithDescription("Time between a provider caching job being queued and being processed."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return noop.Float64Histogram{}
+	}
+	return histogram
+}()
+
+func recordLag(ctx context.Context, job ProviderCachingJob) {
+	if job.QueuedAt.IsZero() {
+		return
+	}
+	queueLag.Record(ctx, float64(time.Since(job.QueuedAt).Milliseconds()))
+}
+
+func unixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// QueueLengther is a caching queue that can report the number of jobs it holds.
+type QueueLengther interface {
+	Len(ctx context.Context) (int, error)
+}
+
+// RegisterQueueDepth reports the number of jobs in the queue as the
+// caching_queue.depth gauge whenever metrics are collected. The returned
+// registration should be unregistered when the queue is no longer in use.
+func RegisterQueueDepth(queue QueueLengther) (metric.Registration, error) {
+	depth, err := meter.Int64ObservableGauge(
+		"caching_queue.depth",
+		metric.WithDescription("Number of provider caching jobs in the queue."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating queue depth gauge: %w", err)
+	}
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		n, err := queue.Len(ctx)
+		if err != nil {
+			return err
+		}
+		o.ObserveInt64(depth, int64(n))
+		return nil
+	}, depth)
+}
@@ -235,8 +235,11 @@ type MockCommand struct {
 type MockBatcher struct {
 	store    *MockProviderStore
 	commands []MockCommand
+	added    uint64
 }
 
+var _ types.AddCounter = &MockBatcher{}
+
 func (mb *MockBatcher) Add(ctx context.Context, key multihash.Multihash, newProviders ...model.ProviderResult) error {
 	mb.commands = append(mb.commands, MockCommand{
 		op:     "add",
@@ -258,10 +261,11 @@ func (mb *MockBatcher) SetExpirable(ctx context.Context, key multihash.Multihash
 func (mb *MockBatcher) Commit(ctx context.Context) error {
 	for _, c := range mb.commands {
 		if c.op == "add" {
-			_, err := mb.store.Add(ctx, c.key, c.values...)
+			n, err := mb.store.Add(ctx, c.key, c.values...)
 			if err != nil {
 				return err
 			}
+			mb.added += n
 		} else if c.op == "setExpirable" {
 			err := mb.store.SetExpirable(ctx, c.key, c.expire)
 			if err != nil {
@@ -271,3 +275,7 @@ func (mb *MockBatcher) Commit(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (mb *MockBatcher) Added() uint64 {
+	return mb.added
+}
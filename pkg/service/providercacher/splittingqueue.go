@@ -0,0 +1,65 @@
+package providercacher
+
+import (
+	"context"
+
+	"github.com/storacha/go-libstoracha/blobindex"
+)
+
+// DefaultMaxJobSlices is the default number of slices above which an index is
+// split into a caching job per shard when queued.
+const DefaultMaxJobSlices = 100_000
+
+// ShardSplittingQueue is a [CachingQueueQueuer] that splits jobs for large
+// indexes into a job per shard, so that each job can be completed within the
+// processing time available to it.
+type ShardSplittingQueue struct {
+	queue     CachingQueueQueuer
+	maxSlices int
+}
+
+var _ CachingQueueQueuer = (*ShardSplittingQueue)(nil)
+
+// NewShardSplittingQueue wraps the passed queue, splitting jobs for indexes
+// with more than maxSlices slices into a job per shard.
+func NewShardSplittingQueue(queue CachingQueueQueuer, maxSlices int) *ShardSplittingQueue {
+	return &ShardSplittingQueue{queue: queue, maxSlices: maxSlices}
+}
+
+// Queue queues the job, or a job per shard if the index is large.
+func (q *ShardSplittingQueue) Queue(ctx context.Context, job ProviderCachingJob) error {
+	for _, j := range SplitJob(job, q.maxSlices) {
+		if err := q.queue.Queue(ctx, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitJob splits a job for an index with more than maxSlices slices into a
+// job per shard. Each job has an index for the same content, containing a
+// single shard. Jobs for smaller indexes, or indexes with a single shard, are
+// returned unchanged.
+func SplitJob(job ProviderCachingJob, maxSlices int) []ProviderCachingJob {
+	shards := job.Index.Shards()
+	if shards.Size() <= 1 {
+		return []ProviderCachingJob{job}
+	}
+	total := 0
+	for _, slices := range shards.Iterator() {
+		total += slices.Size()
+	}
+	if total <= maxSlices {
+		return []ProviderCachingJob{job}
+	}
+
+	jobs := make([]ProviderCachingJob, 0, shards.Size())
+	for _, shard := range sortedShards(job.Index) {
+		index := blobindex.NewShardedDagIndexView(job.Index.Content(), 1)
+		for slice, position := range shards.Get(shard).Iterator() {
+			index.SetSlice(shard, slice, position)
+		}
+		jobs = append(jobs, ProviderCachingJob{Provider: job.Provider, Index: index, QueuedAt: job.QueuedAt})
+	}
+	return jobs
+}
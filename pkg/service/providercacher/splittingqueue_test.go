@@ -0,0 +1,52 @@
+package providercacher_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/service/providercacher"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardSplittingQueue(t *testing.T) {
+	root := testutil.RandomCID(t)
+	index := blobindex.NewShardedDagIndexView(root, 3)
+	for _, shard := range testutil.RandomMultihashes(t, 3) {
+		for _, slice := range testutil.RandomMultihashes(t, 4) {
+			index.SetSlice(shard, slice, blobindex.Position{Offset: 1, Length: 2})
+		}
+	}
+	job := providercacher.ProviderCachingJob{Provider: testutil.RandomProviderResult(t), Index: index}
+
+	t.Run("queues small indexes unchanged", func(t *testing.T) {
+		mockQueue := providercacher.NewMockCachingQueue(t)
+		mockQueue.EXPECT().Queue(mock.Anything, job).Return(nil)
+
+		queue := providercacher.NewShardSplittingQueue(mockQueue, 12)
+		require.NoError(t, queue.Queue(context.Background(), job))
+	})
+
+	t.Run("splits large indexes into a job per shard", func(t *testing.T) {
+		var queued []providercacher.ProviderCachingJob
+		mockQueue := providercacher.NewMockCachingQueue(t)
+		mockQueue.EXPECT().Queue(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, j providercacher.ProviderCachingJob) error {
+			queued = append(queued, j)
+			return nil
+		}).Times(3)
+
+		queue := providercacher.NewShardSplittingQueue(mockQueue, 11)
+		require.NoError(t, queue.Queue(context.Background(), job))
+
+		for _, j := range queued {
+			require.Equal(t, job.Provider, j.Provider)
+			require.Equal(t, root, j.Index.Content())
+			require.Equal(t, 1, j.Index.Shards().Size())
+			for shard, slices := range j.Index.Shards().Iterator() {
+				require.Equal(t, index.Shards().Get(shard), slices)
+			}
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package providerindex
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// InstrumentedFinder is an IPNI finder that records the latency, errors and
+// number of results of find queries to a single IPNI node, labelled with the
+// node's endpoint.
+type InstrumentedFinder struct {
+	finder       ipnifind.Finder
+	attrs        metric.MeasurementOption
+	findDuration metric.Float64Histogram
+	findErrors   metric.Int64Counter
+	findResults  metric.Int64Counter
+}
+
+var _ StreamFinder = (*InstrumentedFinder)(nil)
+
+// NewInstrumentedFinder wraps the finder for the IPNI node at the passed
+// endpoint.
+func NewInstrumentedFinder(endpoint string, finder ipnifind.Finder) (*InstrumentedFinder, error) {
+	f := InstrumentedFinder{
+		finder: finder,
+		attrs:  metric.WithAttributes(attribute.String("endpoint", endpoint)),
+	}
+
+	var err error
+	f.findDuration, err = meter.Float64Histogram(
+		"ipni.find.duration",
+		metric.WithDescription("Duration of find queries to individual IPNI nodes."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating find duration histogram: %w", err)
+	}
+	f.findErrors, err = meter.Int64Counter(
+		"ipni.find.errors",
+		metric.WithDescription("Number of failed find queries to individual IPNI nodes."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating find errors counter: %w", err)
+	}
+	f.findResults, err = meter.Int64Counter(
+		"ipni.find.results",
+		metric.WithDescription("Number of provider results returned by individual IPNI nodes."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating find results counter: %w", err)
+	}
+	return &f, nil
+}
+
+// Find queries the IPNI node, recording the outcome.
+func (f *InstrumentedFinder) Find(ctx context.Context, digest mh.Multihash) (*model.FindResponse, error) {
+	start := time.Now()
+	res, err := f.finder.Find(ctx, digest)
+	f.findDuration.Record(ctx, float64(time.Since(start).Milliseconds()), f.attrs)
+	if err != nil {
+		f.findErrors.Add(ctx, 1, f.attrs)
+		return nil, err
+	}
+	var count int64
+	for _, mhres := range res.MultihashResults {
+		count += int64(len(mhres.ProviderResults))
+	}
+	f.findResults.Add(ctx, count, f.attrs)
+	return res, nil
+}
+
+// FindStream streams results from the IPNI node, recording the outcome once
+// the stream ends.
+func (f *InstrumentedFinder) FindStream(ctx context.Context, digest mh.Multihash) iter.Seq2[model.ProviderResult, error] {
+	return func(yield func(model.ProviderResult, error) bool) {
+		start := time.Now()
+		var count int64
+		defer func() {
+			f.findDuration.Record(ctx, float64(time.Since(start).Milliseconds()), f.attrs)
+			f.findResults.Add(ctx, count, f.attrs)
+		}()
+		for result, err := range findStream(ctx, f.finder, digest) {
+			if err != nil {
+				f.findErrors.Add(ctx, 1, f.attrs)
+			} else {
+				count++
+			}
+			if !yield(result, err) {
+				return
+			}
+		}
+	}
+}
@@ -18,7 +18,7 @@ type ProviderIndex interface {
 	//     the resulting records in the cache
 	//     b. the are no records in the cache or IPNI, it can attempt to read from legacy systems -- Dynamo tables & content claims storage, synthetically constructing provider results
 	//  2. With returned provider results, filter additionally for claim type. If space dids are set, calculate an encodedcontextid's by hashing space DID and Hash, and filter for a matching context id
-	//     Future TODO: kick off a conversion task to update the recrds
+	//     Legacy claims are moved to IPNI by a legacy.Migrator, after which the legacy mappers can be retired
 	Find(context.Context, QueryKey) ([]model.ProviderResult, error)
 	// Cache writes entries to the cache but does not publish/announce an
 	// advertisement for them. Entries expire after a pre-determined time.
@@ -26,5 +26,9 @@ type ProviderIndex interface {
 	// Publish should do the following:
 	// 1. Write the entries to the cache with no expiration until publishing is complete
 	// 2. Generate an advertisement for the advertised hashes and publish/announce it
+	//
+	// An error wrapping publisher.ErrAlreadyAdvertised is returned if an
+	// advertisement was already published for the context ID with the same
+	// metadata, in which case the hashes are cached but not advertised.
 	Publish(ctx context.Context, provider peer.AddrInfo, contextID string, digests iter.Seq[multihash.Multihash], meta meta.Metadata) error
 }
@@ -0,0 +1,53 @@
+package legacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+var _ ProgressStore = (*DatastoreProgressStore)(nil)
+
+// DatastoreProgressStore is a [ProgressStore] that stores migration progress
+// as JSON in a datastore, keyed by mapper name.
+type DatastoreProgressStore struct {
+	ds datastore.Datastore
+}
+
+// NewDatastoreProgressStore creates a progress store backed by the passed
+// datastore. The datastore should be namespaced for the store.
+func NewDatastoreProgressStore(ds datastore.Datastore) *DatastoreProgressStore {
+	return &DatastoreProgressStore{ds: ds}
+}
+
+// Get implements ProgressStore.
+func (s *DatastoreProgressStore) Get(ctx context.Context, mapper string) (MigrationProgress, error) {
+	data, err := s.ds.Get(ctx, datastore.NewKey(mapper))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return MigrationProgress{}, types.ErrKeyNotFound
+		}
+		return MigrationProgress{}, fmt.Errorf("getting migration progress: %w", err)
+	}
+	var progress MigrationProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return MigrationProgress{}, fmt.Errorf("deserializing migration progress: %w", err)
+	}
+	return progress, nil
+}
+
+// Put implements ProgressStore.
+func (s *DatastoreProgressStore) Put(ctx context.Context, progress MigrationProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("serializing migration progress: %w", err)
+	}
+	if err := s.ds.Put(ctx, datastore.NewKey(progress.Mapper), data); err != nil {
+		return fmt.Errorf("storing migration progress: %w", err)
+	}
+	return nil
+}
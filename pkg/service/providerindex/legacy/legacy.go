@@ -5,14 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/storacha/go-libstoracha/capabilities/assert"
 	"github.com/storacha/go-libstoracha/digestutil"
 	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/indexing-service/pkg/internal/blobaddr"
 	"github.com/storacha/indexing-service/pkg/internal/link"
 	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/telemetry"
 	"github.com/storacha/indexing-service/pkg/types"
 	"golang.org/x/exp/slices"
 
@@ -26,8 +29,14 @@ import (
 	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/did"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/service/providerindex/legacy")
+
 // ProviderID is the peer ID used in synthetized provider results.
 var ProviderID, _ = peer.Decode("12D3KooWLrikEsjt5wz326bRhCyEThRhJ936o13c5Ej7ttLbkxgp")
 
@@ -46,6 +55,10 @@ type ClaimsStore struct {
 	claimsStore contentclaims.Finder
 	claimsAddr  ma.Multiaddr
 	log         logging.EventLogger
+	merge       MergeStrategy
+	timeout     time.Duration
+	lookups     metric.Int64Counter
+	results     metric.Int64Counter
 }
 
 // ContentToClaimsMapper maps content hashes to claim cids
@@ -53,8 +66,68 @@ type ContentToClaimsMapper interface {
 	GetClaims(ctx context.Context, contentHash multihash.Multihash) (claimsCids []cid.Cid, err error)
 }
 
+// NamedMapper is a mapper with an explicit name, used to identify it in
+// traces, metrics and migration progress.
+type NamedMapper interface {
+	ContentToClaimsMapper
+	Name() string
+}
+
+type namedMapper struct {
+	ContentToClaimsMapper
+	name string
+}
+
+func (m namedMapper) Name() string {
+	return m.name
+}
+
+// Named gives a mapper an explicit name. The name must stay the same across
+// deployments, as it identifies the progress of the mapper's migration.
+func Named(name string, mapper ContentToClaimsMapper) NamedMapper {
+	return namedMapper{ContentToClaimsMapper: mapper, name: name}
+}
+
+// MapperName returns the name used to identify a mapper in traces and
+// metrics, which is the name of a [NamedMapper] or the type of other mappers.
+func MapperName(mapper ContentToClaimsMapper) string {
+	if named, ok := mapper.(NamedMapper); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", mapper)
+}
+
+// MergeStrategy determines how the results of the content to claims mappers
+// are combined by ClaimsStore.Find.
+type MergeStrategy string
+
+const (
+	// MergeFirstHit consults mappers in order and returns the results of the
+	// first mapper that returns relevant claims. This is the default.
+	MergeFirstHit MergeStrategy = "first-hit"
+	// MergeUnion consults all mappers in parallel and returns the results of
+	// all of them.
+	MergeUnion MergeStrategy = "union"
+	// MergeUnionPerClaimType consults all mappers in parallel and, for each
+	// claim type, returns the results of the first mapper in priority order
+	// that returned claims of that type.
+	MergeUnionPerClaimType MergeStrategy = "union-per-claim-type"
+)
+
+// ParseMergeStrategy parses a merge strategy from its name.
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch m := MergeStrategy(s); m {
+	case MergeFirstHit, MergeUnion, MergeUnionPerClaimType:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy: %q", s)
+	}
+}
+
 type config struct {
-	log logging.EventLogger
+	log     logging.EventLogger
+	merge   MergeStrategy
+	timeout time.Duration
 }
 
 // Option configures the ClaimsStore.
@@ -68,11 +141,28 @@ func WithLogger(log logging.EventLogger) Option {
 	}
 }
 
+// WithMergeStrategy configures how the results of the mappers are combined.
+// The default is MergeFirstHit.
+func WithMergeStrategy(merge MergeStrategy) Option {
+	return func(conf *config) {
+		conf.merge = merge
+	}
+}
+
+// WithMapperTimeout sets the time each mapper is given to return its claims.
+// A mapper that times out is treated as having failed. No timeout is applied
+// by default.
+func WithMapperTimeout(timeout time.Duration) Option {
+	return func(conf *config) {
+		conf.timeout = timeout
+	}
+}
+
 // NewClaimsStore builds a new store able to find claims in legacy services.
 //
-// It uses a series of mappers to fetch claims from. Mappers will be consulted in order, so their positions in the list
-// define their priority, with the first position being the top priority. This is important because the claims returned
-// by Find will be the ones coming from the first mapper that returns relevant claims.
+// It uses a series of mappers to fetch claims from. The position of mappers in the list defines their priority, with
+// the first position being the top priority. How the priority is used depends on the merge strategy: by default, the
+// claims returned by Find will be the ones coming from the first mapper that returns relevant claims.
 func NewClaimsStore(contentToClaimsMappers []ContentToClaimsMapper, claimStore contentclaims.Finder, claimsUrl string, options ...Option) (ClaimsStore, error) {
 	conf := config{}
 	for _, option := range options {
@@ -81,6 +171,12 @@ func NewClaimsStore(contentToClaimsMappers []ContentToClaimsMapper, claimStore c
 	if conf.log == nil {
 		conf.log = logging.Logger("legacy")
 	}
+	if conf.merge == "" {
+		conf.merge = MergeFirstHit
+	}
+	if _, err := ParseMergeStrategy(string(conf.merge)); err != nil {
+		return ClaimsStore{}, err
+	}
 	legacyClaimsUrl, err := url.Parse(claimsUrl)
 	if err != nil {
 		return ClaimsStore{}, err
@@ -90,22 +186,91 @@ func NewClaimsStore(contentToClaimsMappers []ContentToClaimsMapper, claimStore c
 		return ClaimsStore{}, err
 	}
 
+	lookups, err := meter.Int64Counter(
+		"legacy.mapper.lookups",
+		metric.WithDescription("Number of lookups in legacy content to claims mappers, by mapper and outcome."),
+	)
+	if err != nil {
+		return ClaimsStore{}, fmt.Errorf("creating mapper lookups counter: %w", err)
+	}
+	results, err := meter.Int64Counter(
+		"legacy.mapper.results",
+		metric.WithDescription("Number of provider results returned from legacy content to claims mappers, by mapper and claim type."),
+	)
+	if err != nil {
+		return ClaimsStore{}, fmt.Errorf("creating mapper results counter: %w", err)
+	}
+
 	return ClaimsStore{
 		mappers:     contentToClaimsMappers,
 		claimsStore: claimStore,
 		claimsAddr:  claimsAddr,
 		log:         conf.log,
+		merge:       conf.merge,
+		timeout:     conf.timeout,
+		lookups:     lookups,
+		results:     results,
 	}, nil
 }
 
-// Find looks for the corresponding claims for a given content hash in the mapper and then fetches the claims from the
-// claims store.
-// Find will look for relevant claims (as indicated by targetClaims) in content-to-claims mappers in the order they
-// were specified when this LegacyClaimsStore was created (see NewLegacyClaimsStore). As soon as a mapper returns
-// relevant claims, these will be returned and no more mappers will be checked.
+// mapperResult is a provider result tagged with the mapper that produced it
+// and the type of claim it was synthetized from.
+type mapperResult struct {
+	// mapper is the position of the mapper in the list of mappers.
+	mapper int
+	claim  multicodec.Code
+	result model.ProviderResult
+}
+
+// Find looks for the corresponding claims for a given content hash in the mappers and then fetches the claims from the
+// claims store. Only claims of the types indicated by targetClaims are returned.
+//
+// With the default MergeFirstHit strategy, mappers are checked in the order they were specified when this ClaimsStore
+// was created (see NewClaimsStore). As soon as a mapper returns relevant claims, these will be returned and no more
+// mappers will be checked.
+//
+// With the MergeUnion and MergeUnionPerClaimType strategies, all mappers are checked in parallel. Errors from
+// individual mappers are logged and only returned if no mapper returned relevant claims.
 func (cs ClaimsStore) Find(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code) ([]model.ProviderResult, error) {
-	for _, mapper := range cs.mappers {
-		results, err := cs.findInMapper(ctx, contentHash, targetClaims, mapper)
+	ctx, s := telemetry.StartSpan(ctx, "legacy.ClaimsStore.Find")
+	defer s.End()
+	s.SetAttributes(attribute.String("merge", string(cs.merge)))
+
+	var results []mapperResult
+	var err error
+	switch cs.merge {
+	case MergeUnion, MergeUnionPerClaimType:
+		results, err = cs.findInAllMappers(ctx, contentHash, targetClaims)
+	default:
+		results, err = cs.findInFirstMapper(ctx, contentHash, targetClaims)
+	}
+	if err != nil {
+		telemetry.Error(s, err, "finding legacy claims")
+		return nil, err
+	}
+	if cs.merge == MergeUnionPerClaimType {
+		results = firstMapperPerClaimType(results)
+	}
+
+	providerResults := make([]model.ProviderResult, 0, len(results))
+	for _, r := range results {
+		name := MapperName(cs.mappers[r.mapper])
+		s.AddEvent("legacy result", trace.WithAttributes(
+			attribute.String("mapper", name),
+			attribute.String("claim", claimName(r.claim)),
+		))
+		cs.results.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("mapper", name),
+			attribute.String("claim", claimName(r.claim)),
+		))
+		providerResults = append(providerResults, r.result)
+	}
+	return providerResults, nil
+}
+
+func (cs ClaimsStore) findInFirstMapper(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code) ([]mapperResult, error) {
+	for i := range cs.mappers {
+		results, err := cs.lookup(ctx, contentHash, targetClaims, i)
 		if err != nil {
 			return nil, err
 		}
@@ -115,20 +280,128 @@ func (cs ClaimsStore) Find(ctx context.Context, contentHash multihash.Multihash,
 		}
 	}
 
-	return []model.ProviderResult{}, nil
+	return nil, nil
+}
+
+func (cs ClaimsStore) findInAllMappers(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code) ([]mapperResult, error) {
+	mapperResults := make([][]mapperResult, len(cs.mappers))
+	errs := make([]error, len(cs.mappers))
+	var wg sync.WaitGroup
+	for i := range cs.mappers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mapperResults[i], errs[i] = cs.lookup(ctx, contentHash, targetClaims, i)
+		}()
+	}
+	wg.Wait()
+
+	// results are kept in mapper priority order
+	var results []mapperResult
+	for _, r := range mapperResults {
+		results = append(results, r...)
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		if len(results) == 0 {
+			return nil, err
+		}
+		cs.log.Warnf("some legacy mappers failed finding claims for %s: %s", digestutil.Format(contentHash), err)
+	}
+	return results, nil
+}
+
+// firstMapperPerClaimType filters results, which must be in mapper priority
+// order, keeping for each claim type only the results of the first mapper that
+// returned claims of that type.
+func firstMapperPerClaimType(results []mapperResult) []mapperResult {
+	owners := map[multicodec.Code]int{}
+	filtered := make([]mapperResult, 0, len(results))
+	for _, r := range results {
+		owner, ok := owners[r.claim]
+		if !ok {
+			owners[r.claim] = r.mapper
+			owner = r.mapper
+		}
+		if owner == r.mapper {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// lookup finds claims in the mapper at position i, applying the configured
+// timeout and recording the outcome.
+func (cs ClaimsStore) lookup(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code, i int) ([]mapperResult, error) {
+	mapper := cs.mappers[i]
+	if cs.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cs.timeout)
+		defer cancel()
+	}
+	results, err := cs.findInMapper(ctx, contentHash, targetClaims, mapper)
+	cs.recordLookup(ctx, mapper, len(results), err)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("mapper %s timed out: %w", MapperName(mapper), err)
+		}
+		return nil, fmt.Errorf("mapper %s: %w", MapperName(mapper), err)
+	}
+	for j := range results {
+		results[j].mapper = i
+	}
+	return results, nil
+}
+
+func (cs ClaimsStore) recordLookup(ctx context.Context, mapper ContentToClaimsMapper, results int, err error) {
+	if cs.lookups == nil {
+		return
+	}
+	outcome := "miss"
+	if errors.Is(err, context.DeadlineExceeded) {
+		outcome = "timeout"
+	} else if err != nil {
+		outcome = "error"
+	} else if results > 0 {
+		outcome = "hit"
+	}
+	cs.lookups.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+		attribute.String("mapper", MapperName(mapper)),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// claimTypes maps claim abilities to the claim types they are found by.
+var claimTypes = map[string]multicodec.Code{
+	assert.LocationAbility: metadata.LocationCommitmentID,
+	assert.IndexAbility:    metadata.IndexClaimID,
+	assert.EqualsAbility:   metadata.EqualsClaimID,
+}
+
+func claimName(claim multicodec.Code) string {
+	switch claim {
+	case metadata.LocationCommitmentID:
+		return "location"
+	case metadata.IndexClaimID:
+		return "index"
+	case metadata.EqualsClaimID:
+		return "equals"
+	default:
+		return claim.String()
+	}
 }
 
-func (cs ClaimsStore) findInMapper(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code, mapper ContentToClaimsMapper) ([]model.ProviderResult, error) {
+func (cs ClaimsStore) findInMapper(ctx context.Context, contentHash multihash.Multihash, targetClaims []multicodec.Code, mapper ContentToClaimsMapper) ([]mapperResult, error) {
 	claimsCids, err := mapper.GetClaims(ctx, contentHash)
 	if err != nil {
 		if errors.Is(err, types.ErrKeyNotFound) {
-			return []model.ProviderResult{}, nil
+			return []mapperResult{}, nil
 		}
 
 		return nil, err
 	}
 
-	results := []model.ProviderResult{}
+	results := []mapperResult{}
 
 	for _, claimCid := range claimsCids {
 		claim, err := cs.claimsStore.Find(ctx, cidlink.Link{Cid: claimCid}, &url.URL{})
@@ -148,7 +421,10 @@ func (cs ClaimsStore) findInMapper(ctx context.Context, contentHash multihash.Mu
 			continue
 		}
 
-		results = append(results, pr)
+		results = append(results, mapperResult{
+			claim:  claimTypes[claim.Capabilities()[0].Can()],
+			result: pr,
+		})
 	}
 
 	return results, nil
@@ -247,8 +523,7 @@ func (cs ClaimsStore) synthetizeLocationProviderResult(caveats assert.LocationCa
 	for _, l := range caveats.Location {
 		// generalize the location URL by replacing actual hashes with the placeholder.
 		// That will allow the correct URL to be reconstructed for fetching
-		l.Path = strings.ReplaceAll(l.Path, digestutil.Format(caveats.Content.Hash()), "{blob}")
-		ma, err := maurl.FromURL(&l)
+		ma, err := blobaddr.FromLocation(l, caveats.Content.Hash())
 		if err != nil {
 			return model.ProviderResult{}, err
 		}
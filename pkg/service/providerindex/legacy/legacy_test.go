@@ -2,9 +2,11 @@ package legacy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/multiformats/go-multicodec"
 	"github.com/storacha/go-libstoracha/digestutil"
@@ -17,7 +19,9 @@ import (
 
 	"github.com/ipfs/go-cid"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/find/model"
 	"github.com/ipni/go-libipni/maurl"
+	"github.com/multiformats/go-multihash"
 	cassert "github.com/storacha/go-libstoracha/capabilities/assert"
 	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
 	"github.com/storacha/go-ucanto/core/delegation"
@@ -144,6 +148,145 @@ func TestFind(t *testing.T) {
 	})
 }
 
+func TestFindMergeStrategies(t *testing.T) {
+	allTargetClaims := []multicodec.Code{metadata.LocationCommitmentID, metadata.IndexClaimID, metadata.EqualsClaimID}
+
+	t.Run("union returns the results of all mappers", func(t *testing.T) {
+		mockMapper1 := NewMockContentToClaimsMapper(t)
+		mockMapper2 := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		legacyClaims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper1, mockMapper2}, mockStore, "https://storacha.network/claims/{claim}", WithMergeStrategy(MergeUnion)))(t)
+
+		contentHash := testutil.RandomMultihash(t)
+
+		location1Delegation := testutil.RandomLocationDelegation(t)
+		location1DelegationCid := link.ToCID(testutil.RandomCID(t))
+		location2Delegation := testutil.RandomLocationDelegation(t)
+		location2DelegationCid := link.ToCID(testutil.RandomCID(t))
+		indexDelegation := testutil.RandomIndexDelegation(t)
+		indexDelegationCid := link.ToCID(testutil.RandomCID(t))
+
+		mockMapper1.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{location1DelegationCid}, nil)
+		mockMapper2.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{location2DelegationCid, indexDelegationCid}, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: location1DelegationCid}, &url.URL{}).Return(location1Delegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: location2DelegationCid}, &url.URL{}).Return(location2Delegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: indexDelegationCid}, &url.URL{}).Return(indexDelegation, nil)
+
+		results, err := legacyClaims.Find(context.Background(), contentHash, allTargetClaims)
+
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		// results are in mapper priority order
+		require.Equal(t, location1DelegationCid, claimCid(t, results[0]))
+		require.Equal(t, location2DelegationCid, claimCid(t, results[1]))
+		require.Equal(t, indexDelegationCid, claimCid(t, results[2]))
+	})
+
+	t.Run("union per claim type returns each claim type from the first mapper that has it", func(t *testing.T) {
+		mockMapper1 := NewMockContentToClaimsMapper(t)
+		mockMapper2 := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		legacyClaims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper1, mockMapper2}, mockStore, "https://storacha.network/claims/{claim}", WithMergeStrategy(MergeUnionPerClaimType)))(t)
+
+		contentHash := testutil.RandomMultihash(t)
+
+		location1Delegation := testutil.RandomLocationDelegation(t)
+		location1DelegationCid := link.ToCID(testutil.RandomCID(t))
+		location2Delegation := testutil.RandomLocationDelegation(t)
+		location2DelegationCid := link.ToCID(testutil.RandomCID(t))
+		indexDelegation := testutil.RandomIndexDelegation(t)
+		indexDelegationCid := link.ToCID(testutil.RandomCID(t))
+
+		mockMapper1.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{location1DelegationCid}, nil)
+		mockMapper2.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{location2DelegationCid, indexDelegationCid}, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: location1DelegationCid}, &url.URL{}).Return(location1Delegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: location2DelegationCid}, &url.URL{}).Return(location2Delegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: indexDelegationCid}, &url.URL{}).Return(indexDelegation, nil)
+
+		results, err := legacyClaims.Find(context.Background(), contentHash, allTargetClaims)
+
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, location1DelegationCid, claimCid(t, results[0]))
+		require.Equal(t, indexDelegationCid, claimCid(t, results[1]))
+	})
+
+	t.Run("union ignores failing mappers when others return results", func(t *testing.T) {
+		mockMapper1 := NewMockContentToClaimsMapper(t)
+		mockMapper2 := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		legacyClaims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper1, mockMapper2}, mockStore, "https://storacha.network/claims/{claim}", WithMergeStrategy(MergeUnion)))(t)
+
+		contentHash := testutil.RandomMultihash(t)
+		locationDelegation := testutil.RandomLocationDelegation(t)
+		locationDelegationCid := link.ToCID(testutil.RandomCID(t))
+
+		mapperErr := errors.New("mapper unavailable")
+		mockMapper1.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return(nil, mapperErr)
+		mockMapper2.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{locationDelegationCid}, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: locationDelegationCid}, &url.URL{}).Return(locationDelegation, nil)
+
+		results, err := legacyClaims.Find(context.Background(), contentHash, allTargetClaims)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		// with no results from any mapper, the error is returned
+		otherHash := testutil.RandomMultihash(t)
+		mockMapper1.EXPECT().GetClaims(extmocks.AnyContext, otherHash).Return(nil, mapperErr)
+		mockMapper2.EXPECT().GetClaims(extmocks.AnyContext, otherHash).Return(nil, types.ErrKeyNotFound)
+
+		_, err = legacyClaims.Find(context.Background(), otherHash, allTargetClaims)
+		require.ErrorIs(t, err, mapperErr)
+	})
+
+	t.Run("mappers that exceed the timeout are treated as failed", func(t *testing.T) {
+		mockMapper1 := NewMockContentToClaimsMapper(t)
+		mockMapper2 := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		legacyClaims := testutil.Must(NewClaimsStore(
+			[]ContentToClaimsMapper{mockMapper1, mockMapper2},
+			mockStore,
+			"https://storacha.network/claims/{claim}",
+			WithMergeStrategy(MergeUnion),
+			WithMapperTimeout(10*time.Millisecond),
+		))(t)
+
+		contentHash := testutil.RandomMultihash(t)
+		locationDelegation := testutil.RandomLocationDelegation(t)
+		locationDelegationCid := link.ToCID(testutil.RandomCID(t))
+
+		mockMapper1.EXPECT().GetClaims(extmocks.AnyContext, contentHash).RunAndReturn(func(ctx context.Context, _ multihash.Multihash) ([]cid.Cid, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		mockMapper2.EXPECT().GetClaims(extmocks.AnyContext, contentHash).Return([]cid.Cid{locationDelegationCid}, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: locationDelegationCid}, &url.URL{}).Return(locationDelegation, nil)
+
+		results, err := legacyClaims.Find(context.Background(), contentHash, allTargetClaims)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+	})
+
+	t.Run("unknown merge strategy", func(t *testing.T) {
+		_, err := NewClaimsStore(nil, contentclaims.NewMockContentClaimsFinder(t), "https://storacha.network/claims/{claim}", WithMergeStrategy("everything"))
+		require.Error(t, err)
+	})
+}
+
+func claimCid(t *testing.T, result model.ProviderResult) cid.Cid {
+	md := metadata.MetadataContext.New()
+	require.NoError(t, md.UnmarshalBinary(result.Metadata))
+	for _, code := range []multicodec.Code{metadata.LocationCommitmentID, metadata.IndexClaimID, metadata.EqualsClaimID} {
+		if p := md.Get(code); p != nil {
+			if hc, ok := p.(metadata.HasClaim); ok {
+				return hc.GetClaim()
+			}
+		}
+	}
+	t.Fatal("no claim in metadata")
+	return cid.Undef
+}
+
 func TestSynthetizeProviderResult(t *testing.T) {
 	allTargetClaims := []multicodec.Code{metadata.LocationCommitmentID, metadata.IndexClaimID, metadata.EqualsClaimID}
 
@@ -0,0 +1,347 @@
+package legacy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	meta "github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/ipnipublisher/publisher"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// allClaims are the claim types republished by a migration.
+var allClaims = []multicodec.Code{metadata.LocationCommitmentID, metadata.IndexClaimID, metadata.EqualsClaimID}
+
+// Publisher publishes provider results to IPNI. It is implemented by
+// providerindex.ProviderIndex.
+type Publisher interface {
+	Publish(ctx context.Context, provider peer.AddrInfo, contextID string, digests iter.Seq[multihash.Multihash], meta meta.Metadata) error
+}
+
+// DigestWalker walks the content hashes in a legacy table.
+type DigestWalker interface {
+	// Walk calls fn for each content hash in the table, starting at the
+	// position identified by the passed cursor, or at the beginning of the table
+	// if the cursor is empty. The cursor passed to fn is the position to resume
+	// from in order to visit the content hash again. Content hashes may be
+	// visited more than once.
+	Walk(ctx context.Context, cursor string, fn func(digest multihash.Multihash, cursor string) error) error
+}
+
+// MigrationProgress is the progress of migrating the claims of a legacy
+// mapper to IPNI.
+type MigrationProgress struct {
+	// Mapper is the name of the migrated mapper, see [NamedMapper].
+	Mapper string `json:"mapper"`
+	// Cursor is the position in the mapper's table to resume the migration
+	// from.
+	Cursor string `json:"cursor,omitempty"`
+	// Digests is the number of content hashes visited. Content hashes visited
+	// again after a migration is resumed are counted again.
+	Digests uint64 `json:"digests"`
+	// Published is the number of context IDs published to IPNI, each with all
+	// the content hashes of a batch that were found in it.
+	Published uint64 `json:"published"`
+	// Skipped is the number of context IDs the publisher skipped because they
+	// were already advertised. Content hashes of a skipped context ID that were
+	// not in its first advertisement are not advertised.
+	Skipped uint64 `json:"skipped"`
+	// Failed is the number of content hashes that failed to migrate in the
+	// current walk of the table.
+	Failed uint64 `json:"failed"`
+	// FailedCursor is the position to walk the table again from in order to
+	// retry the content hashes that failed to migrate.
+	FailedCursor string `json:"failedCursor,omitempty"`
+	// Retry is true when the whole table has been walked with failures, and
+	// the next walk retries them from Cursor.
+	Retry bool `json:"retry,omitempty"`
+	// Complete is true when the whole table has been walked without failures,
+	// at which point the mapper no longer needs to be consulted on the query
+	// path, see [ActiveMappers].
+	Complete bool `json:"complete"`
+	// UpdatedAt is the time the progress was last recorded.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ProgressStore records the progress of legacy migrations.
+type ProgressStore interface {
+	// Get returns the progress of the migration of the named mapper, or
+	// types.ErrKeyNotFound if it has not been started.
+	Get(ctx context.Context, mapper string) (MigrationProgress, error)
+	// Put records the progress of a migration.
+	Put(ctx context.Context, progress MigrationProgress) error
+}
+
+// DefaultMigrationBatchSize is the default number of content hashes whose
+// provider results are grouped before they are published.
+const DefaultMigrationBatchSize = 10_000
+
+// Migrator republishes the claims found by legacy content to claims mappers to
+// IPNI, so that the mappers can eventually be retired.
+//
+// The claims are synthesized into provider results in the same way as on the
+// query path, and published with the addresses of the synthesized results.
+// Mappers used for migration should therefore produce claims that do not
+// expire.
+//
+// The provider results of a batch of content hashes are grouped by context ID
+// and each context ID is published once, with all the content hashes found in
+// it. A context ID that is published again is skipped by the publisher, so
+// content hashes of a context ID found in a later batch are not advertised.
+// Batches should therefore be large enough to hold the content hashes of a
+// context ID that are close together in the walked table. Skips are counted
+// separately from publications when the publisher reports them with
+// publisher.ErrAlreadyAdvertised.
+//
+// Content hashes that fail to migrate are retried by walking the table again
+// from the batch of the first failure, so a migration is only complete once a
+// walk has no failures.
+type Migrator struct {
+	claims    ClaimsStore
+	publisher Publisher
+	provider  peer.ID
+	progress  ProgressStore
+	batchSize int
+}
+
+// MigratorOption configures a [Migrator].
+type MigratorOption func(*Migrator)
+
+// WithBatchSize sets the number of content hashes whose provider results are
+// grouped before they are published.
+func WithBatchSize(size int) MigratorOption {
+	return func(m *Migrator) {
+		m.batchSize = size
+	}
+}
+
+// NewMigrator creates a migrator that synthesizes provider results with the
+// passed claims store and publishes them with the given publisher under the
+// passed provider ID. Progress is recorded in the passed progress store.
+func NewMigrator(claims ClaimsStore, publisher Publisher, provider peer.ID, progress ProgressStore, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		claims:    claims,
+		publisher: publisher,
+		provider:  provider,
+		progress:  progress,
+		batchSize: DefaultMigrationBatchSize,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// group is the content hashes found in a context ID.
+type group struct {
+	provider peer.AddrInfo
+	meta     meta.Metadata
+	digests  []multihash.Multihash
+}
+
+// batch groups the provider results of content hashes by context ID.
+type batch struct {
+	groups map[string]*group
+	// order is the order context IDs were first found in
+	order []string
+	// digests is the number of content hashes with provider results
+	digests int
+}
+
+func newBatch() *batch {
+	return &batch{groups: map[string]*group{}}
+}
+
+// add adds the provider results found for a content hash to the batch.
+func (b *batch) add(provider peer.ID, digest multihash.Multihash, results []mapperResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	for _, r := range results {
+		contextID := string(r.result.ContextID)
+		if g, ok := b.groups[contextID]; ok {
+			g.digests = append(g.digests, digest)
+			continue
+		}
+		md := metadata.MetadataContext.New()
+		if err := md.UnmarshalBinary(r.result.Metadata); err != nil {
+			return fmt.Errorf("decoding metadata: %w", err)
+		}
+		b.groups[contextID] = &group{
+			provider: peer.AddrInfo{ID: provider, Addrs: r.result.Provider.Addrs},
+			meta:     md,
+			digests:  []multihash.Multihash{digest},
+		}
+		b.order = append(b.order, contextID)
+	}
+	b.digests++
+	return nil
+}
+
+// flush publishes each context ID in the batch once and empties the batch.
+// Context IDs that fail to publish are logged and their content hashes counted
+// as failed.
+func (m *Migrator) flush(ctx context.Context, b *batch, progress *MigrationProgress) {
+	for _, contextID := range b.order {
+		g := b.groups[contextID]
+		err := m.publisher.Publish(ctx, g.provider, contextID, slices.Values(g.digests), g.meta)
+		if err != nil {
+			if errors.Is(err, publisher.ErrAlreadyAdvertised) {
+				progress.Skipped++
+				m.claims.log.Warnf("skipped %d legacy content hashes from %s in an already advertised context ID", len(g.digests), progress.Mapper)
+				continue
+			}
+			progress.fail(len(g.digests))
+			m.claims.log.Warnf("failed to publish %d legacy content hashes from %s: %s", len(g.digests), progress.Mapper, err)
+			continue
+		}
+		progress.Published++
+	}
+	*b = *newBatch()
+}
+
+// fail counts content hashes of the current batch that failed to migrate. The
+// cursor the batch started at is remembered for the first failures of a walk,
+// so that walking again from it retries all of them.
+func (p *MigrationProgress) fail(n int) {
+	if p.Failed == 0 {
+		p.FailedCursor = p.Cursor
+	}
+	p.Failed += uint64(n)
+}
+
+// Walk migrates every content hash visited by the walker, resuming from the
+// recorded progress of the mapper. The provider results of the content hashes
+// are published in batches, and progress is recorded after a batch is
+// published, when the walk moves past a cursor. Content hashes that fail to
+// migrate are logged and counted, but do not stop the walk. If any failed once
+// the whole table is walked, the migration is left incomplete, to be walked
+// again from the first failure. Walking a mapper whose migration is complete
+// does nothing.
+func (m *Migrator) Walk(ctx context.Context, mapper NamedMapper, walker DigestWalker) (MigrationProgress, error) {
+	progress, err := m.Progress(ctx, mapper)
+	if err != nil {
+		return MigrationProgress{}, err
+	}
+	if progress.Complete {
+		if progress.Failed == 0 {
+			return progress, nil
+		}
+		// migrations used to be completed despite failures
+		progress.Complete = false
+		progress.Cursor = progress.FailedCursor
+		progress.Retry = true
+	}
+	if progress.Retry {
+		progress.Failed = 0
+		progress.FailedCursor = ""
+		progress.Retry = false
+	}
+
+	b := newBatch()
+	var last multihash.Multihash
+	err = walker.Walk(ctx, progress.Cursor, func(digest multihash.Multihash, cursor string) error {
+		// the cursor can only be recorded once the content hashes before it are
+		// published
+		if cursor != progress.Cursor && (b.digests == 0 || b.digests >= m.batchSize) {
+			m.flush(ctx, b, &progress)
+			progress.Cursor = cursor
+			if err := m.save(ctx, &progress); err != nil {
+				return err
+			}
+		}
+		// the same content hash is often visited several times in a row
+		if bytes.Equal(digest, last) {
+			return nil
+		}
+		last = digest
+
+		progress.Digests++
+		results, err := m.claims.findInMapper(ctx, digest, allClaims, mapper)
+		if err == nil {
+			err = b.add(m.provider, digest, results)
+		}
+		if err != nil {
+			progress.fail(1)
+			m.claims.log.Warnf("failed to migrate legacy claims for %s from %s: %s", digestutil.Format(digest), progress.Mapper, err)
+		}
+		return nil
+	})
+	// content hashes visited before a failure are published, so that resuming
+	// does not need to visit them again
+	m.flush(ctx, b, &progress)
+	if err != nil {
+		if serr := m.save(ctx, &progress); serr != nil {
+			err = errors.Join(err, serr)
+		}
+		return progress, fmt.Errorf("walking %s: %w", progress.Mapper, err)
+	}
+
+	if progress.Failed > 0 {
+		m.claims.log.Warnf("%d legacy content hashes from %s failed to migrate, walk again to retry them", progress.Failed, progress.Mapper)
+		progress.Cursor = progress.FailedCursor
+		progress.Retry = true
+	} else {
+		progress.Cursor = ""
+		progress.Complete = true
+	}
+	if err := m.save(ctx, &progress); err != nil {
+		return progress, err
+	}
+	return progress, nil
+}
+
+// Progress returns the recorded progress of the migration of a mapper.
+func (m *Migrator) Progress(ctx context.Context, mapper NamedMapper) (MigrationProgress, error) {
+	name := mapper.Name()
+	progress, err := m.progress.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, types.ErrKeyNotFound) {
+			return MigrationProgress{Mapper: name}, nil
+		}
+		return MigrationProgress{}, fmt.Errorf("getting migration progress: %w", err)
+	}
+	return progress, nil
+}
+
+func (m *Migrator) save(ctx context.Context, progress *MigrationProgress) error {
+	progress.UpdatedAt = time.Now()
+	if err := m.progress.Put(ctx, *progress); err != nil {
+		return fmt.Errorf("recording migration progress: %w", err)
+	}
+	return nil
+}
+
+// ActiveMappers returns the mappers whose migration is not complete or has
+// failures, so that migrated mappers are no longer consulted on the query
+// path. Mappers that are not a [NamedMapper] cannot be migrated and are always
+// returned.
+func ActiveMappers(ctx context.Context, progress ProgressStore, mappers []ContentToClaimsMapper) ([]ContentToClaimsMapper, error) {
+	active := make([]ContentToClaimsMapper, 0, len(mappers))
+	for _, mapper := range mappers {
+		named, ok := mapper.(NamedMapper)
+		if !ok {
+			active = append(active, mapper)
+			continue
+		}
+		p, err := progress.Get(ctx, named.Name())
+		if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+			return nil, fmt.Errorf("getting migration progress of %s: %w", named.Name(), err)
+		}
+		if p.Complete && p.Failed == 0 {
+			continue
+		}
+		active = append(active, mapper)
+	}
+	return active, nil
+}
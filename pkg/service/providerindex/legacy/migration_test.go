@@ -0,0 +1,318 @@
+package legacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	meta "github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	ipnipublisher "github.com/storacha/go-libstoracha/ipnipublisher/publisher"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type publication struct {
+	provider  peer.AddrInfo
+	contextID string
+	digests   []multihash.Multihash
+}
+
+type recordingPublisher struct {
+	publications []publication
+	err          error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, provider peer.AddrInfo, contextID string, digests iter.Seq[multihash.Multihash], md meta.Metadata) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.publications = append(p.publications, publication{provider, contextID, slices.Collect(digests)})
+	return nil
+}
+
+type page struct {
+	cursor  string
+	digests []multihash.Multihash
+	err     error
+}
+
+type pagedWalker struct {
+	pages   []page
+	started string
+}
+
+func (w *pagedWalker) Walk(ctx context.Context, cursor string, fn func(digest multihash.Multihash, cursor string) error) error {
+	w.started = cursor
+	skipping := cursor != ""
+	for _, p := range w.pages {
+		if skipping && p.cursor != cursor {
+			continue
+		}
+		skipping = false
+		if p.err != nil {
+			return p.err
+		}
+		for _, d := range p.digests {
+			if err := fn(d, p.cursor); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestMigrator(t *testing.T) {
+	providerID := testutil.RandomPeer(t)
+
+	t.Run("walks and republishes claims", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress)
+		mapper := Named("claims", mockMapper)
+
+		d1, d2, d3 := testutil.RandomMultihash(t), testutil.RandomMultihash(t), testutil.RandomMultihash(t)
+		locationDelegation := testutil.RandomLocationDelegation(t)
+		locationDelegationCid := link.ToCID(testutil.RandomCID(t))
+		indexDelegation := testutil.RandomIndexDelegation(t)
+		indexDelegationCid := link.ToCID(testutil.RandomCID(t))
+
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d1).Return([]cid.Cid{locationDelegationCid}, nil).Once()
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d2).Return(nil, types.ErrKeyNotFound).Once()
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d3).Return([]cid.Cid{indexDelegationCid}, nil).Once()
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: locationDelegationCid}, &url.URL{}).Return(locationDelegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: indexDelegationCid}, &url.URL{}).Return(indexDelegation, nil)
+
+		walker := &pagedWalker{pages: []page{
+			{cursor: "", digests: []multihash.Multihash{d1, d1, d2}},
+			{cursor: "page-2", digests: []multihash.Multihash{d3}},
+		}}
+
+		p, err := migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.Empty(t, p.Cursor)
+		require.Equal(t, "claims", p.Mapper)
+		require.Equal(t, uint64(3), p.Digests)
+		require.Equal(t, uint64(2), p.Published)
+		require.Equal(t, uint64(0), p.Failed)
+
+		require.Len(t, publisher.publications, 2)
+		require.Equal(t, providerID, publisher.publications[0].provider.ID)
+		require.NotEmpty(t, publisher.publications[0].provider.Addrs)
+		require.Equal(t, []multihash.Multihash{d1}, publisher.publications[0].digests)
+		require.Equal(t, []multihash.Multihash{d3}, publisher.publications[1].digests)
+
+		// the recorded progress is returned
+		recorded, err := migrator.Progress(t.Context(), mapper)
+		require.NoError(t, err)
+		require.Equal(t, p.Digests, recorded.Digests)
+		require.True(t, recorded.Complete)
+
+		// walking a completed migration does nothing
+		p, err = migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.Len(t, publisher.publications, 2)
+	})
+
+	t.Run("resumes from the recorded cursor", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress)
+		mapper := Named("claims", mockMapper)
+
+		d1, d2, d3 := testutil.RandomMultihash(t), testutil.RandomMultihash(t), testutil.RandomMultihash(t)
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d1).Return(nil, types.ErrKeyNotFound).Once()
+		// the page the walk failed in is visited again
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d2).Return(nil, types.ErrKeyNotFound).Twice()
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d3).Return(nil, types.ErrKeyNotFound).Once()
+
+		walkErr := errors.New("throttled")
+		walker := &pagedWalker{pages: []page{
+			{cursor: "", digests: []multihash.Multihash{d1}},
+			{cursor: "page-2", digests: []multihash.Multihash{d2}},
+			{cursor: "page-3", err: walkErr},
+		}}
+		_, err := migrator.Walk(t.Context(), mapper, walker)
+		require.ErrorIs(t, err, walkErr)
+
+		p, err := migrator.Progress(t.Context(), mapper)
+		require.NoError(t, err)
+		require.False(t, p.Complete)
+		require.Equal(t, "page-2", p.Cursor)
+		require.Equal(t, uint64(2), p.Digests)
+
+		walker.pages[2] = page{cursor: "page-3", digests: []multihash.Multihash{d3}}
+		p, err = migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.Equal(t, "page-2", walker.started)
+		require.True(t, p.Complete)
+		require.Equal(t, uint64(4), p.Digests)
+	})
+
+	t.Run("counts failed content hashes, continues and retries them", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{err: errors.New("queue unavailable")}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress)
+		mapper := Named("claims", mockMapper)
+
+		d1, d2 := testutil.RandomMultihash(t), testutil.RandomMultihash(t)
+		locationDelegation := testutil.RandomLocationDelegation(t)
+		locationDelegationCid := link.ToCID(testutil.RandomCID(t))
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d1).Return([]cid.Cid{locationDelegationCid}, nil).Twice()
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d2).Return(nil, types.ErrKeyNotFound).Twice()
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: locationDelegationCid}, &url.URL{}).Return(locationDelegation, nil)
+
+		walker := &pagedWalker{pages: []page{{digests: []multihash.Multihash{d1, d2}}}}
+		p, err := migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.False(t, p.Complete)
+		require.True(t, p.Retry)
+		require.Equal(t, uint64(2), p.Digests)
+		require.Equal(t, uint64(1), p.Failed)
+		require.Equal(t, uint64(0), p.Published)
+
+		// the mapper is still consulted on the query path
+		active, err := ActiveMappers(t.Context(), progress, []ContentToClaimsMapper{mapper})
+		require.NoError(t, err)
+		require.Len(t, active, 1)
+
+		// walking again retries the failed content hashes
+		publisher.err = nil
+		p, err = migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.False(t, p.Retry)
+		require.Equal(t, uint64(0), p.Failed)
+		require.Equal(t, uint64(1), p.Published)
+		require.Len(t, publisher.publications, 1)
+		require.Equal(t, []multihash.Multihash{d1}, publisher.publications[0].digests)
+	})
+
+	t.Run("counts context IDs skipped by the publisher", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{err: fmt.Errorf("publishing advert: %w", ipnipublisher.ErrAlreadyAdvertised)}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress)
+		mapper := Named("claims", mockMapper)
+
+		d1 := testutil.RandomMultihash(t)
+		locationDelegation := testutil.RandomLocationDelegation(t)
+		locationDelegationCid := link.ToCID(testutil.RandomCID(t))
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d1).Return([]cid.Cid{locationDelegationCid}, nil).Once()
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: locationDelegationCid}, &url.URL{}).Return(locationDelegation, nil)
+
+		walker := &pagedWalker{pages: []page{{digests: []multihash.Multihash{d1}}}}
+		p, err := migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.Equal(t, uint64(1), p.Skipped)
+		require.Equal(t, uint64(0), p.Published)
+		require.Equal(t, uint64(0), p.Failed)
+	})
+
+	t.Run("retries migrations completed with failures", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress)
+		mapper := Named("claims", mockMapper)
+		require.NoError(t, progress.Put(t.Context(), MigrationProgress{Mapper: "claims", Failed: 1, Complete: true}))
+
+		d1 := testutil.RandomMultihash(t)
+		mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d1).Return(nil, types.ErrKeyNotFound).Once()
+
+		walker := &pagedWalker{pages: []page{{digests: []multihash.Multihash{d1}}}}
+		p, err := migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.Equal(t, uint64(0), p.Failed)
+	})
+
+	t.Run("publishes each context ID once", func(t *testing.T) {
+		mockMapper := NewMockContentToClaimsMapper(t)
+		mockStore := contentclaims.NewMockContentClaimsFinder(t)
+		claims := testutil.Must(NewClaimsStore([]ContentToClaimsMapper{mockMapper}, mockStore, "https://storacha.network/claims/{claim}"))(t)
+		publisher := &recordingPublisher{}
+		progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		migrator := NewMigrator(claims, publisher, providerID, progress, WithBatchSize(2))
+		mapper := Named("block-index", mockMapper)
+
+		// the blocks of two shards, split over three pages, with the first batch
+		// published once it is full, before the second shard is found
+		d1, d2, d3, d4 := testutil.RandomMultihash(t), testutil.RandomMultihash(t), testutil.RandomMultihash(t), testutil.RandomMultihash(t)
+		shard1Delegation := testutil.RandomLocationDelegation(t)
+		shard1DelegationCid := link.ToCID(testutil.RandomCID(t))
+		shard2Delegation := testutil.RandomLocationDelegation(t)
+		shard2DelegationCid := link.ToCID(testutil.RandomCID(t))
+		for _, d := range []multihash.Multihash{d1, d2} {
+			mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d).Return([]cid.Cid{shard1DelegationCid}, nil).Once()
+		}
+		for _, d := range []multihash.Multihash{d3, d4} {
+			mockMapper.EXPECT().GetClaims(extmocks.AnyContext, d).Return([]cid.Cid{shard2DelegationCid}, nil).Once()
+		}
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: shard1DelegationCid}, &url.URL{}).Return(shard1Delegation, nil)
+		mockStore.EXPECT().Find(extmocks.AnyContext, cidlink.Link{Cid: shard2DelegationCid}, &url.URL{}).Return(shard2Delegation, nil)
+
+		walker := &pagedWalker{pages: []page{
+			{cursor: "", digests: []multihash.Multihash{d1}},
+			{cursor: "page-2", digests: []multihash.Multihash{d2, d3}},
+			{cursor: "page-3", digests: []multihash.Multihash{d4}},
+		}}
+
+		p, err := migrator.Walk(t.Context(), mapper, walker)
+		require.NoError(t, err)
+		require.True(t, p.Complete)
+		require.Equal(t, uint64(4), p.Digests)
+		require.Equal(t, uint64(2), p.Published)
+
+		require.Len(t, publisher.publications, 2)
+		require.Equal(t, []multihash.Multihash{d1, d2}, publisher.publications[0].digests)
+		require.Equal(t, []multihash.Multihash{d3, d4}, publisher.publications[1].digests)
+	})
+}
+
+func TestActiveMappers(t *testing.T) {
+	progress := NewDatastoreProgressStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	require.NoError(t, progress.Put(t.Context(), MigrationProgress{Mapper: "claims", Complete: true}))
+	require.NoError(t, progress.Put(t.Context(), MigrationProgress{Mapper: "block-index", Cursor: "page-2"}))
+	// migrations used to be completed despite failures
+	require.NoError(t, progress.Put(t.Context(), MigrationProgress{Mapper: "legacy-index", Failed: 1, Complete: true}))
+
+	claims := Named("claims", NewMockContentToClaimsMapper(t))
+	blockIndex := Named("block-index", NewMockContentToClaimsMapper(t))
+	legacyIndex := Named("legacy-index", NewMockContentToClaimsMapper(t))
+	fallback := Named("bucket-fallback", NewMockContentToClaimsMapper(t))
+	unnamed := NewMockContentToClaimsMapper(t)
+
+	active, err := ActiveMappers(t.Context(), progress, []ContentToClaimsMapper{claims, blockIndex, legacyIndex, fallback, unnamed})
+	require.NoError(t, err)
+	require.Equal(t, []ContentToClaimsMapper{blockIndex, legacyIndex, fallback, unnamed}, active)
+}
@@ -0,0 +1,181 @@
+package providerindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/indexing-service/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DefaultNodeFindTimeout is the default duration after which a find query to
+// an individual IPNI node is cancelled when merging results.
+const DefaultNodeFindTimeout = 2500 * time.Millisecond
+
+var meter = otel.Meter("github.com/storacha/indexing-service/pkg/service/providerindex")
+
+// IPNINode is an IPNI node that is queried by a [MergingFinder].
+type IPNINode struct {
+	// Endpoint identifies the node in logs and metrics, typically its URL.
+	Endpoint string
+	Finder   ipnifind.Finder
+}
+
+// MergingFinder is an IPNI finder that queries all configured IPNI nodes in
+// parallel and returns the union of their provider results, deduplicated by
+// provider, context ID and metadata.
+//
+// Per node latency, errors and results are not recorded by the finder, but by
+// wrapping each node's finder in an [InstrumentedFinder].
+type MergingFinder struct {
+	nodes         []IPNINode
+	timeout       time.Duration
+	log           logging.EventLogger
+	mergedResults metric.Int64Histogram
+}
+
+var _ ipnifind.Finder = (*MergingFinder)(nil)
+
+// MergingFinderOption configures a [MergingFinder].
+type MergingFinderOption func(*MergingFinder)
+
+// WithNodeFindTimeout sets the timeout for each individual IPNI node find
+// operation.
+func WithNodeFindTimeout(timeout time.Duration) MergingFinderOption {
+	return func(f *MergingFinder) {
+		f.timeout = timeout
+	}
+}
+
+// WithMergingFinderLogger configures the finder to use the passed logger
+// instead of the default logger.
+func WithMergingFinderLogger(log logging.EventLogger) MergingFinderOption {
+	return func(f *MergingFinder) {
+		f.log = log
+	}
+}
+
+// NewMergingFinder creates a new [MergingFinder] that queries the passed IPNI
+// nodes.
+func NewMergingFinder(nodes []IPNINode, opts ...MergingFinderOption) (*MergingFinder, error) {
+	f := MergingFinder{nodes: nodes, timeout: DefaultNodeFindTimeout}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	if f.log == nil {
+		f.log = logging.Logger("providerindex")
+	}
+
+	var err error
+	f.mergedResults, err = meter.Int64Histogram(
+		"ipni.find.merged_results",
+		metric.WithDescription("Number of distinct provider results after merging results from all IPNI nodes."),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating merged results histogram: %w", err)
+	}
+	return &f, nil
+}
+
+// Find queries all IPNI nodes in parallel for the passed multihash and merges
+// their results. An error is returned only if all nodes fail.
+func (f *MergingFinder) Find(ctx context.Context, digest mh.Multihash) (*model.FindResponse, error) {
+	ctx, s := telemetry.StartSpan(ctx, "MergingFinder.Find")
+	defer s.End()
+
+	type nodeResult struct {
+		results []model.ProviderResult
+		err     error
+	}
+
+	nodeResults := make([]nodeResult, len(f.nodes))
+	var wg sync.WaitGroup
+	for i, node := range f.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := f.findNode(ctx, node, digest)
+			nodeResults[i] = nodeResult{results: results, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var findErr error
+	var failed int
+	var merged []model.ProviderResult
+	seen := map[string]struct{}{}
+	for i, nr := range nodeResults {
+		if nr.err != nil {
+			findErr = errors.Join(findErr, fmt.Errorf("finding in %s: %w", f.nodes[i].Endpoint, nr.err))
+			failed++
+			continue
+		}
+		for _, result := range nr.results {
+			key := resultKey(result)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			merged = append(merged, result)
+		}
+	}
+
+	// only fail if no node was able to answer
+	if failed > 0 && failed == len(f.nodes) {
+		telemetry.Error(s, findErr, "finding in all IPNI nodes")
+		return nil, findErr
+	}
+	if findErr != nil {
+		f.log.Warnf("finding %s in some IPNI nodes: %s", digestutil.Format(digest), findErr)
+	}
+
+	f.mergedResults.Record(ctx, int64(len(merged)))
+	s.SetAttributes(attribute.Int("results", len(merged)))
+	if len(merged) == 0 {
+		return &model.FindResponse{}, nil
+	}
+	return &model.FindResponse{
+		MultihashResults: []model.MultihashResult{
+			{
+				Multihash:       digest,
+				ProviderResults: merged,
+			},
+		},
+	}, nil
+}
+
+func (f *MergingFinder) findNode(ctx context.Context, node IPNINode, digest mh.Multihash) ([]model.ProviderResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	res, err := node.Finder.Find(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []model.ProviderResult
+	for _, mhres := range res.MultihashResults {
+		results = append(results, mhres.ProviderResults...)
+	}
+	return results, nil
+}
+
+// resultKey returns a key that identifies a provider result by its provider,
+// context ID and metadata.
+func resultKey(result model.ProviderResult) string {
+	var provider string
+	if result.Provider != nil {
+		provider = result.Provider.ID.String()
+	}
+	return provider + "\x00" + string(result.ContextID) + "\x00" + string(result.Metadata)
+}
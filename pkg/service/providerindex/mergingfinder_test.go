@@ -0,0 +1,138 @@
+package providerindex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergingFinder(t *testing.T) {
+	findResponse := func(digest multihash.Multihash, results ...model.ProviderResult) *model.FindResponse {
+		return &model.FindResponse{
+			MultihashResults: []model.MultihashResult{
+				{
+					Multihash:       digest,
+					ProviderResults: results,
+				},
+			},
+		}
+	}
+
+	t.Run("merges and dedupes results from all nodes", func(t *testing.T) {
+		mockFinder1 := extmocks.NewMockIpniFinder(t)
+		mockFinder2 := extmocks.NewMockIpniFinder(t)
+
+		finder, err := NewMergingFinder([]IPNINode{
+			{Endpoint: "https://one.example", Finder: mockFinder1},
+			{Endpoint: "https://two.example", Finder: mockFinder2},
+		})
+		require.NoError(t, err)
+
+		someHash := testutil.RandomMultihash(t)
+		sharedResult := testutil.RandomLocationCommitmentProviderResult(t)
+		result1 := testutil.RandomIndexClaimProviderResult(t)
+		result2 := testutil.RandomLocationCommitmentProviderResult(t)
+
+		mockFinder1.EXPECT().Find(extmocks.AnyContext, someHash).Return(findResponse(someHash, sharedResult, result1), nil)
+		mockFinder2.EXPECT().Find(extmocks.AnyContext, someHash).Return(findResponse(someHash, result2, sharedResult), nil)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Len(t, res.MultihashResults, 1)
+		require.Equal(t, []model.ProviderResult{sharedResult, result1, result2}, res.MultihashResults[0].ProviderResults)
+	})
+
+	t.Run("returns partial results when some nodes fail", func(t *testing.T) {
+		mockFinder1 := extmocks.NewMockIpniFinder(t)
+		mockFinder2 := extmocks.NewMockIpniFinder(t)
+
+		finder, err := NewMergingFinder([]IPNINode{
+			{Endpoint: "https://one.example", Finder: mockFinder1},
+			{Endpoint: "https://two.example", Finder: mockFinder2},
+		})
+		require.NoError(t, err)
+
+		someHash := testutil.RandomMultihash(t)
+		result := testutil.RandomLocationCommitmentProviderResult(t)
+
+		mockFinder1.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, errors.New("something went wrong"))
+		mockFinder2.EXPECT().Find(extmocks.AnyContext, someHash).Return(findResponse(someHash, result), nil)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Equal(t, findResponse(someHash, result), res)
+	})
+
+	t.Run("returns empty response when no node has results", func(t *testing.T) {
+		mockFinder1 := extmocks.NewMockIpniFinder(t)
+		mockFinder2 := extmocks.NewMockIpniFinder(t)
+
+		finder, err := NewMergingFinder([]IPNINode{
+			{Endpoint: "https://one.example", Finder: mockFinder1},
+			{Endpoint: "https://two.example", Finder: mockFinder2},
+		})
+		require.NoError(t, err)
+
+		someHash := testutil.RandomMultihash(t)
+
+		mockFinder1.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil)
+		mockFinder2.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, errors.New("something went wrong"))
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Empty(t, res.MultihashResults)
+	})
+
+	t.Run("returns error when all nodes fail", func(t *testing.T) {
+		mockFinder1 := extmocks.NewMockIpniFinder(t)
+		mockFinder2 := extmocks.NewMockIpniFinder(t)
+
+		finder, err := NewMergingFinder([]IPNINode{
+			{Endpoint: "https://one.example", Finder: mockFinder1},
+			{Endpoint: "https://two.example", Finder: mockFinder2},
+		})
+		require.NoError(t, err)
+
+		someHash := testutil.RandomMultihash(t)
+		err1 := errors.New("node one failed")
+		err2 := errors.New("node two failed")
+
+		mockFinder1.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, err1)
+		mockFinder2.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, err2)
+
+		_, err = finder.Find(context.Background(), someHash)
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, err2)
+	})
+
+	t.Run("applies per node timeout", func(t *testing.T) {
+		mockFinder1 := extmocks.NewMockIpniFinder(t)
+		mockFinder2 := extmocks.NewMockIpniFinder(t)
+
+		finder, err := NewMergingFinder([]IPNINode{
+			{Endpoint: "https://one.example", Finder: mockFinder1},
+			{Endpoint: "https://two.example", Finder: mockFinder2},
+		}, WithNodeFindTimeout(10*time.Millisecond))
+		require.NoError(t, err)
+
+		someHash := testutil.RandomMultihash(t)
+		result := testutil.RandomLocationCommitmentProviderResult(t)
+
+		mockFinder1.EXPECT().Find(extmocks.AnyContext, someHash).RunAndReturn(func(ctx context.Context, _ multihash.Multihash) (*model.FindResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		mockFinder2.EXPECT().Find(extmocks.AnyContext, someHash).Return(findResponse(someHash, result), nil)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Equal(t, findResponse(someHash, result), res)
+	})
+}
@@ -36,6 +36,9 @@ const (
 	// It is set relatively high, since the IPNI client is tiered, with individual
 	// timeouts set at 1.5s.
 	IPNITimeout = 5 * time.Second
+	// DefaultStreamResultLimit is the default number of matching provider
+	// results, per target claim, after which a streaming IPNI query is stopped.
+	DefaultStreamResultLimit = 100
 )
 
 type QueryKey struct {
@@ -54,13 +57,22 @@ type ProviderIndexService struct {
 	mutex           sync.Mutex
 	clock           clock.Clock
 	log             logging.EventLogger
+	streaming       bool
+	streamLimit     int
+	strictSpaces    bool
 }
 
 var _ ProviderIndex = (*ProviderIndexService)(nil)
 
 type config struct {
-	log   logging.EventLogger
-	clock clock.Clock
+	log          logging.EventLogger
+	clock        clock.Clock
+	streaming    bool
+	streamLimit  int
+	strictSpaces bool
+
+	readerPrivacyThreshold int
+	readerPrivacyBackoff   time.Duration
 }
 
 // Option configures an ProviderIndex.
@@ -81,6 +93,39 @@ func WithClock(clock clock.Clock) Option {
 	}
 }
 
+// WithStreamingFind configures the provider index to stream results from IPNI,
+// filtering them by codec as they are received and stopping the query early
+// once limit matching results have been found for each target claim. If limit
+// is not positive, [DefaultStreamResultLimit] is used. IPNI find clients that
+// implement [StreamFinder] avoid buffering the entire response.
+func WithStreamingFind(limit int) Option {
+	return func(conf *config) {
+		conf.streaming = true
+		conf.streamLimit = limit
+	}
+}
+
+// WithStrictSpaceFiltering configures the provider index to exclude index and
+// equals claims published without a space (i.e. with legacy context IDs) from
+// results filtered by space. By default they are included, so that records
+// published before context IDs were space aware continue to be returned until
+// they have been republished.
+func WithStrictSpaceFiltering() Option {
+	return func(conf *config) {
+		conf.strictSpaces = true
+	}
+}
+
+// WithReaderPrivacyFallback configures how a [ReaderPrivacyFinder] falls back
+// to plain lookups: after threshold consecutive double hashed lookups fail as
+// unsupported, plain lookups are used for the backoff duration.
+func WithReaderPrivacyFallback(threshold int, backoff time.Duration) Option {
+	return func(conf *config) {
+		conf.readerPrivacyThreshold = threshold
+		conf.readerPrivacyBackoff = backoff
+	}
+}
+
 func New(providerStore types.ProviderStore, noProviderStore types.NoProviderStore, findClient ipnifind.Finder, asyncPublisher publisher.AsyncPublisher, legacyClaims legacy.ClaimsFinder, options ...Option) *ProviderIndexService {
 	conf := config{}
 	for _, option := range options {
@@ -92,6 +137,9 @@ func New(providerStore types.ProviderStore, noProviderStore types.NoProviderStor
 	if conf.log == nil {
 		conf.log = logging.Logger("providerindex")
 	}
+	if conf.streamLimit <= 0 {
+		conf.streamLimit = DefaultStreamResultLimit
+	}
 	return &ProviderIndexService{
 		providerStore:   providerStore,
 		noProviderStore: noProviderStore,
@@ -100,6 +148,9 @@ func New(providerStore types.ProviderStore, noProviderStore types.NoProviderStor
 		legacyClaims:    legacyClaims,
 		clock:           conf.clock,
 		log:             conf.log,
+		streaming:       conf.streaming,
+		streamLimit:     conf.streamLimit,
+		strictSpaces:    conf.strictSpaces,
 	}
 }
 
@@ -110,8 +161,8 @@ func New(providerStore types.ProviderStore, noProviderStore types.NoProviderStor
 //     claims storage, synthetically constructing provider results
 //     c. finally, store the resulting records in the cache
 //  2. With returned provider results, filter additionally for claim type. If space dids are set, calculate an
-//     encodedcontextid's by hashing space DID and Hash, and filter for a matching context id
-//     Future TODO: kick off a conversion task to update the records
+//     encodedcontextid's by hashing space DID and Hash (or the index/equals CID for index and equals
+//     claims), and filter for a matching context id
 func (pi *ProviderIndexService) Find(ctx context.Context, qk QueryKey) ([]model.ProviderResult, error) {
 	ctx, s := telemetry.StartSpan(ctx, "ProviderIndexService.Find")
 	defer s.End()
@@ -124,7 +175,7 @@ func (pi *ProviderIndexService) Find(ctx context.Context, qk QueryKey) ([]model.
 	}
 
 	s.AddEvent("filtering results by space")
-	return filterBySpace(results, qk.Hash, qk.Spaces)
+	return filterBySpace(results, qk.Hash, qk.Spaces, !pi.strictSpaces)
 }
 
 func (pi *ProviderIndexService) getProviderResults(ctx context.Context, mh mh.Multihash, targetClaims []multicodec.Code) ([]model.ProviderResult, error) {
@@ -271,17 +322,24 @@ func (pi *ProviderIndexService) fetchFromIPNI(ctx context.Context, s trace.Span,
 	ctx, cancel := pi.clock.WithTimeout(ctx, IPNITimeout)
 	defer cancel()
 
-	findRes, err := pi.findClient.Find(ctx, mh)
-	if err != nil {
-		pi.log.Warnf("finding %s in IPNI: %s", digestutil.Format(mh), err)
-	} else {
-		for _, mhres := range findRes.MultihashResults {
-			results = append(results, mhres.ProviderResults...)
+	if pi.streaming {
+		results, err = pi.streamFromIPNI(ctx, s, mh, targetClaims)
+		if err != nil {
+			return nil, err
 		}
-
-		results, err = filterCodecs(results, targetClaims)
+	} else {
+		findRes, err := pi.findClient.Find(ctx, mh)
 		if err != nil {
-			return nil, fmt.Errorf("filtering codecs: %w", err)
+			pi.log.Warnf("finding %s in IPNI: %s", digestutil.Format(mh), err)
+		} else {
+			for _, mhres := range findRes.MultihashResults {
+				results = append(results, mhres.ProviderResults...)
+			}
+
+			results, err = filterCodecs(results, targetClaims)
+			if err != nil {
+				return nil, fmt.Errorf("filtering codecs: %w", err)
+			}
 		}
 	}
 	if len(results) == 0 {
@@ -290,6 +348,66 @@ func (pi *ProviderIndexService) fetchFromIPNI(ctx context.Context, s trace.Span,
 	return results, nil
 }
 
+// streamFromIPNI streams provider results from IPNI, filtering them by codec as
+// they are received. The query is stopped once the configured number of
+// matching results has been found for every target claim.
+func (pi *ProviderIndexService) streamFromIPNI(ctx context.Context, s trace.Span, mh mh.Multihash, targetClaims []multicodec.Code) ([]model.ProviderResult, error) {
+	var results []model.ProviderResult
+	counts := map[multicodec.Code]int{}
+	received := 0
+	for result, err := range findStream(ctx, pi.findClient, mh) {
+		if err != nil {
+			// keep any results that were received before the error
+			pi.log.Warnf("finding %s in IPNI: %s", digestutil.Format(mh), err)
+			break
+		}
+		received++
+
+		matches, err := filterCodecs([]model.ProviderResult{result}, targetClaims)
+		if err != nil {
+			return nil, fmt.Errorf("filtering codecs: %w", err)
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		results = append(results, result)
+
+		if streamLimitReached(result, targetClaims, counts, len(results), pi.streamLimit) {
+			s.AddEvent("stream result limit reached")
+			break
+		}
+	}
+	s.SetAttributes(
+		attribute.Int("ipni.stream.received", received),
+		attribute.Int("ipni.stream.matched", len(results)),
+	)
+	return results, nil
+}
+
+// streamLimitReached records the matching result against the target claims it
+// satisfies and determines whether enough results have been found for all of
+// them. When there are no target claims, the total number of results is used.
+func streamLimitReached(result model.ProviderResult, targetClaims []multicodec.Code, counts map[multicodec.Code]int, total int, limit int) bool {
+	if len(targetClaims) == 0 {
+		return total >= limit
+	}
+	md := metadata.MetadataContext.New()
+	if err := md.UnmarshalBinary(result.Metadata); err != nil {
+		return false
+	}
+	for _, code := range md.Protocols() {
+		if slices.Contains(targetClaims, code) {
+			counts[code]++
+		}
+	}
+	for _, code := range targetClaims {
+		if counts[code] < limit {
+			return false
+		}
+	}
+	return true
+}
+
 func filterCodecs(results []model.ProviderResult, codecs []multicodec.Code) ([]model.ProviderResult, error) {
 	if len(codecs) == 0 {
 		return results, nil
@@ -308,29 +426,36 @@ func filterCodecs(results []model.ProviderResult, codecs []multicodec.Code) ([]m
 	})
 }
 
-func filterBySpace(results []model.ProviderResult, mh mh.Multihash, spaces []did.DID) ([]model.ProviderResult, error) {
+// filterBySpace filters results to those published for one of the passed
+// spaces. Results that were published without a space are included only if
+// includeUnscoped is true.
+func filterBySpace(results []model.ProviderResult, mh mh.Multihash, spaces []did.DID, includeUnscoped bool) ([]model.ProviderResult, error) {
 	if len(spaces) == 0 {
 		return results, nil
 	}
-	encryptedIds := make([]types.EncodedContextID, 0, len(spaces))
-	for _, space := range spaces {
-		encryptedId, err := types.ContextID{
-			Space: &space,
-			Hash:  mh,
-		}.ToEncoded()
-		if err != nil {
-			return nil, err
-		}
-		encryptedIds = append(encryptedIds, encryptedId)
-	}
 
 	filtered, err := filter(results, func(result model.ProviderResult) (bool, error) {
-		if !filterableByContextID(result) {
+		scope, ok := resultScope(result, mh)
+		if !ok {
 			return true, nil
 		}
-		return slices.ContainsFunc(encryptedIds, func(encyptedID types.EncodedContextID) bool {
-			return bytes.Equal(result.ContextID, encyptedID)
-		}), nil
+		for _, space := range spaces {
+			encryptedID, err := types.ContextID{
+				Space: &space,
+				Hash:  scope.hash,
+			}.ToEncoded()
+			if err != nil {
+				return false, err
+			}
+			if bytes.Equal(result.ContextID, encryptedID) {
+				return true, nil
+			}
+		}
+		if scope.unscoped == nil {
+			// we cannot tell if the result was published without a space
+			return includeUnscoped && scope.maybeUnscoped, nil
+		}
+		return includeUnscoped && bytes.Equal(result.ContextID, scope.unscoped), nil
 	})
 	if err != nil {
 		return nil, err
@@ -419,12 +544,8 @@ func (pi *ProviderIndexService) Publish(ctx context.Context, provider peer.AddrI
 	s.AddEvent("start publish")
 	err = pi.asyncPublisher.Publish(ctx, provider, contextID, digests, meta)
 	if err != nil {
-		if errors.Is(err, publisher.ErrAlreadyAdvertised) {
-			// skipping is ok in this case
-			pi.log.Warnf("Skipping previously published advert")
-			return nil
-		}
-
+		// callers decide whether skipping a previously published advert is ok,
+		// since the digests are not advertised again
 		return fmt.Errorf("publishing advert: %w", err)
 	}
 	return nil
@@ -445,15 +566,44 @@ func filter[T any](results []T, filterFunc func(T) (bool, error)) ([]T, error) {
 	return filtered, nil
 }
 
-// filterableByContextID determines if the metadata can be filtered using a
-// [types.ContextID].
-func filterableByContextID(result model.ProviderResult) bool {
+// contextIDScope describes how the context ID of a provider result is derived.
+type contextIDScope struct {
+	// hash is the multihash that is combined with the space DID to derive a
+	// space aware context ID.
+	hash mh.Multihash
+	// unscoped is the context ID the result has when published without a space,
+	// if known.
+	unscoped []byte
+	// maybeUnscoped is true when the unscoped context ID is not known, but the
+	// result may have been published without a space.
+	maybeUnscoped bool
+}
+
+// resultScope determines how the context ID of the result was derived, for
+// a result found by querying the passed multihash. It returns false if the
+// result cannot be filtered by context ID.
+func resultScope(result model.ProviderResult, digest mh.Multihash) (contextIDScope, bool) {
 	md := metadata.MetadataContext.New()
 	err := md.UnmarshalBinary(result.Metadata)
 	if err != nil {
-		return false
+		return contextIDScope{}, false
+	}
+	if md.Get(metadata.LocationCommitmentID) != nil {
+		return contextIDScope{hash: digest}, true
+	}
+	for _, code := range md.Protocols() {
+		switch protocol := md.Get(code).(type) {
+		case *metadata.IndexClaimMetadata:
+			return contextIDScope{hash: protocol.Index.Hash(), unscoped: protocol.Index.Bytes()}, true
+		case *metadata.EqualsClaimMetadata:
+			// equals claims are published for both the content and the equals
+			// multihash, with an unscoped context ID of the content multihash, which
+			// is unknown when the equals multihash was queried.
+			if bytes.Equal(protocol.Equals.Hash(), digest) {
+				return contextIDScope{hash: protocol.Equals.Hash(), maybeUnscoped: true}, true
+			}
+			return contextIDScope{hash: protocol.Equals.Hash(), unscoped: digest}, true
+		}
 	}
-	// we're only able to filter results with location commitment metadata atm
-	lcommMeta := md.Get(metadata.LocationCommitmentID)
-	return lcommMeta != nil
+	return contextIDScope{}, false
 }
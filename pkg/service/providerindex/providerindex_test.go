@@ -10,13 +10,17 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/ipni/go-libipni/find/model"
+	meta "github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-libstoracha/ipnipublisher/publisher"
 	"github.com/storacha/go-libstoracha/metadata"
 	"github.com/storacha/go-libstoracha/testutil"
 	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/did"
 	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/internal/link"
 	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
 	"github.com/storacha/indexing-service/pkg/types"
 	"github.com/stretchr/testify/mock"
@@ -624,7 +628,7 @@ func TestGetProviderResults(t *testing.T) {
 }
 
 func TestPublish(t *testing.T) {
-	t.Run("allow skip publish existing advert", func(t *testing.T) {
+	t.Run("reports skipped publish of existing advert", func(t *testing.T) {
 		mockStore := types.NewMockProviderStore(t)
 		mockBatcher := types.NewMockValueSetCacheBatcher[multihash.Multihash, model.ProviderResult](t)
 		mockNoProviderStore := types.NewMockNoProviderStore(t)
@@ -652,6 +656,91 @@ func TestPublish(t *testing.T) {
 		mockIpniPublisher.EXPECT().Publish(extmocks.AnyContext, provider, contextID, anyDigestSeq, meta).Return(publisher.ErrAlreadyAdvertised)
 
 		err = providerIndex.Publish(context.Background(), provider, contextID, slices.Values([]multihash.Multihash{digest}), meta)
+		require.ErrorIs(t, err, publisher.ErrAlreadyAdvertised)
+	})
+}
+
+func TestFilterBySpace(t *testing.T) {
+	space := testutil.RandomDID(t)
+	otherSpace := testutil.RandomDID(t)
+	someHash := testutil.RandomMultihash(t)
+
+	providerResult := func(contextID []byte, protocol meta.Protocol) model.ProviderResult {
+		md := metadata.MetadataContext.New(protocol)
+		b, err := md.MarshalBinary()
+		require.NoError(t, err)
+		return model.ProviderResult{ContextID: contextID, Metadata: b, Provider: &peer.AddrInfo{ID: testutil.RandomPeer(t)}}
+	}
+	contextID := func(space did.DID, hash multihash.Multihash) []byte {
+		id, err := types.ContextID{Space: &space, Hash: hash}.ToEncoded()
+		require.NoError(t, err)
+		return id
+	}
+
+	t.Run("filters location commitments", func(t *testing.T) {
+		md := &metadata.LocationCommitmentMetadata{Claim: link.ToCID(testutil.RandomCID(t))}
+		matching := providerResult(contextID(space, someHash), md)
+		other := providerResult(contextID(otherSpace, someHash), md)
+
+		results, err := filterBySpace([]model.ProviderResult{matching, other}, someHash, []did.DID{space}, true)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching}, results)
+	})
+
+	t.Run("filters index claims", func(t *testing.T) {
+		index := link.ToCID(testutil.RandomCID(t))
+		md := &metadata.IndexClaimMetadata{Index: index, Claim: link.ToCID(testutil.RandomCID(t))}
+		matching := providerResult(contextID(space, index.Hash()), md)
+		other := providerResult(contextID(otherSpace, index.Hash()), md)
+		unscoped := providerResult(index.Bytes(), md)
+
+		results, err := filterBySpace([]model.ProviderResult{matching, other, unscoped}, someHash, []did.DID{space}, true)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching, unscoped}, results)
+
+		results, err = filterBySpace([]model.ProviderResult{matching, other, unscoped}, someHash, []did.DID{space}, false)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching}, results)
+	})
+
+	t.Run("filters equals claims queried by content hash", func(t *testing.T) {
+		equals := link.ToCID(testutil.RandomCID(t))
+		md := &metadata.EqualsClaimMetadata{Equals: equals, Claim: link.ToCID(testutil.RandomCID(t))}
+		matching := providerResult(contextID(space, equals.Hash()), md)
+		other := providerResult(contextID(otherSpace, equals.Hash()), md)
+		unscoped := providerResult(someHash, md)
+
+		results, err := filterBySpace([]model.ProviderResult{matching, other, unscoped}, someHash, []did.DID{space}, true)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching, unscoped}, results)
+
+		results, err = filterBySpace([]model.ProviderResult{matching, other, unscoped}, someHash, []did.DID{space}, false)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching}, results)
+	})
+
+	t.Run("filters equals claims queried by equals hash", func(t *testing.T) {
+		equals := link.ToCID(testutil.RandomCID(t))
+		md := &metadata.EqualsClaimMetadata{Equals: equals, Claim: link.ToCID(testutil.RandomCID(t))}
+		matching := providerResult(contextID(space, equals.Hash()), md)
+		unknown := providerResult(testutil.RandomMultihash(t), md)
+
+		results, err := filterBySpace([]model.ProviderResult{matching, unknown}, equals.Hash(), []did.DID{space}, true)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching, unknown}, results)
+
+		results, err = filterBySpace([]model.ProviderResult{matching, unknown}, equals.Hash(), []did.DID{space}, false)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{matching}, results)
+	})
+
+	t.Run("returns all results when no spaces are passed", func(t *testing.T) {
+		all := []model.ProviderResult{
+			testutil.RandomLocationCommitmentProviderResult(t),
+			testutil.RandomIndexClaimProviderResult(t),
+		}
+		results, err := filterBySpace(all, someHash, nil, false)
 		require.NoError(t, err)
+		require.Equal(t, all, results)
 	})
 }
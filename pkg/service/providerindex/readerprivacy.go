@@ -0,0 +1,166 @@
+package providerindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-libipni/apierror"
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+)
+
+// DefaultReaderPrivacyFailureThreshold is the default number of consecutive
+// double hashed lookups that must fail as unsupported before the
+// [ReaderPrivacyFinder] falls back to plain lookups.
+const DefaultReaderPrivacyFailureThreshold = 3
+
+// DefaultReaderPrivacyBackoff is the default time the [ReaderPrivacyFinder]
+// uses plain lookups for before trying double hashed lookups again.
+const DefaultReaderPrivacyBackoff = 10 * time.Minute
+
+// ReaderPrivacyFinder is an IPNI finder that performs double hashed lookups, so
+// that queried multihashes are never revealed to the IPNI node. Provider
+// results are decrypted locally.
+//
+// IPNI nodes that do not support double hashed lookups respond with an error
+// status. A query that fails this way is retried with a plain lookup, and once
+// enough consecutive queries have failed, the finder uses plain lookups only
+// until a backoff elapses, after which double hashed lookups are tried again.
+//
+// Note that the double hashed client reports a 404 response as an empty
+// result, so a node answering 404 to all double hashed lookups cannot be told
+// apart from a node that does not have the content. Reader privacy must only
+// be configured for IPNI nodes that are known to support it.
+type ReaderPrivacyFinder struct {
+	private   ipnifind.Finder
+	plain     ipnifind.Finder
+	threshold int
+	backoff   time.Duration
+	clock     clock.Clock
+	log       logging.EventLogger
+
+	mu         sync.Mutex
+	failures   int
+	plainUntil time.Time
+}
+
+var _ ipnifind.Finder = (*ReaderPrivacyFinder)(nil)
+
+// NewReaderPrivacyFinder creates a new [ReaderPrivacyFinder] that uses the
+// private finder for double hashed lookups and the plain finder when the IPNI
+// node does not support them.
+func NewReaderPrivacyFinder(private ipnifind.Finder, plain ipnifind.Finder, options ...Option) *ReaderPrivacyFinder {
+	conf := config{}
+	for _, option := range options {
+		option(&conf)
+	}
+	if conf.log == nil {
+		conf.log = logging.Logger("providerindex")
+	}
+	if conf.clock == nil {
+		conf.clock = clock.New()
+	}
+	if conf.readerPrivacyThreshold <= 0 {
+		conf.readerPrivacyThreshold = DefaultReaderPrivacyFailureThreshold
+	}
+	if conf.readerPrivacyBackoff <= 0 {
+		conf.readerPrivacyBackoff = DefaultReaderPrivacyBackoff
+	}
+	return &ReaderPrivacyFinder{
+		private:   private,
+		plain:     plain,
+		threshold: conf.readerPrivacyThreshold,
+		backoff:   conf.readerPrivacyBackoff,
+		clock:     conf.clock,
+		log:       conf.log,
+	}
+}
+
+// NewReaderPrivacyFinderFromURL creates a [ReaderPrivacyFinder] for the IPNI
+// node at the given URL.
+func NewReaderPrivacyFinderFromURL(url string, httpClient *http.Client, options ...Option) (*ReaderPrivacyFinder, error) {
+	private, err := ipnifind.NewDHashClient(
+		ipnifind.WithDHStoreURL(url),
+		ipnifind.WithProvidersURL(url),
+		ipnifind.WithClient(httpClient),
+		ipnifind.WithPcachePreload(false),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating double hashed find client: %w", err)
+	}
+	plain, err := ipnifind.New(url, ipnifind.WithClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("creating find client: %w", err)
+	}
+	return NewReaderPrivacyFinder(private, plain, options...), nil
+}
+
+// Find queries for provider results for the passed multihash using a double
+// hashed lookup, falling back to a plain lookup if the IPNI node does not
+// support them.
+func (f *ReaderPrivacyFinder) Find(ctx context.Context, digest mh.Multihash) (*model.FindResponse, error) {
+	if f.usePlain() {
+		return f.plain.Find(ctx, digest)
+	}
+
+	res, err := f.private.Find(ctx, digest)
+	if err == nil {
+		f.recordSuccess()
+		return res, nil
+	}
+	if !isDoubleHashingUnsupported(err) {
+		return nil, err
+	}
+
+	f.recordUnsupported(err)
+	f.log.Debugf("finding %s in IPNI without reader privacy", digestutil.Format(digest))
+	return f.plain.Find(ctx, digest)
+}
+
+func (f *ReaderPrivacyFinder) usePlain() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clock.Now().Before(f.plainUntil)
+}
+
+func (f *ReaderPrivacyFinder) recordSuccess() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = 0
+}
+
+// recordUnsupported counts a double hashed lookup that failed as unsupported,
+// switching to plain lookups for the backoff once the threshold is reached.
+func (f *ReaderPrivacyFinder) recordUnsupported(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures++
+	if f.failures < f.threshold {
+		return
+	}
+	f.failures = 0
+	f.plainUntil = f.clock.Now().Add(f.backoff)
+	f.log.Warnf("IPNI node does not support double hashed lookups, falling back to plain lookups for %s: %s", f.backoff, err)
+}
+
+// isDoubleHashingUnsupported determines if the error returned from a double
+// hashed lookup indicates that the IPNI node does not support them.
+func isDoubleHashingUnsupported(err error) bool {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Status() {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package providerindex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipni/go-libipni/apierror"
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderPrivacyFinder(t *testing.T) {
+	t.Run("uses double hashed lookup", func(t *testing.T) {
+		mockPrivateFinder := extmocks.NewMockIpniFinder(t)
+		mockPlainFinder := extmocks.NewMockIpniFinder(t)
+
+		finder := NewReaderPrivacyFinder(mockPrivateFinder, mockPlainFinder)
+
+		someHash := testutil.RandomMultihash(t)
+		expectedResponse := &model.FindResponse{
+			MultihashResults: []model.MultihashResult{
+				{
+					Multihash:       someHash,
+					ProviderResults: []model.ProviderResult{testutil.RandomLocationCommitmentProviderResult(t)},
+				},
+			},
+		}
+
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(expectedResponse, nil)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Equal(t, expectedResponse, res)
+	})
+
+	t.Run("falls back to plain lookups when double hashing is unsupported", func(t *testing.T) {
+		mockPrivateFinder := extmocks.NewMockIpniFinder(t)
+		mockPlainFinder := extmocks.NewMockIpniFinder(t)
+
+		finder := NewReaderPrivacyFinder(mockPrivateFinder, mockPlainFinder, WithReaderPrivacyFallback(1, time.Minute))
+
+		someHash := testutil.RandomMultihash(t)
+		anotherHash := testutil.RandomMultihash(t)
+		expectedResponse := &model.FindResponse{
+			MultihashResults: []model.MultihashResult{
+				{
+					Multihash:       someHash,
+					ProviderResults: []model.ProviderResult{testutil.RandomLocationCommitmentProviderResult(t)},
+				},
+			},
+		}
+
+		unsupportedErr := apierror.New(errors.New("not implemented"), http.StatusNotImplemented)
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, unsupportedErr).Once()
+		mockPlainFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(expectedResponse, nil).Once()
+		// subsequent lookups go straight to the plain finder
+		mockPlainFinder.EXPECT().Find(extmocks.AnyContext, anotherHash).Return(&model.FindResponse{}, nil).Once()
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Equal(t, expectedResponse, res)
+
+		res, err = finder.Find(context.Background(), anotherHash)
+		require.NoError(t, err)
+		require.Empty(t, res.MultihashResults)
+	})
+
+	t.Run("keeps double hashing after transient unsupported errors", func(t *testing.T) {
+		mockPrivateFinder := extmocks.NewMockIpniFinder(t)
+		mockPlainFinder := extmocks.NewMockIpniFinder(t)
+
+		finder := NewReaderPrivacyFinder(mockPrivateFinder, mockPlainFinder, WithReaderPrivacyFallback(2, time.Minute))
+
+		someHash := testutil.RandomMultihash(t)
+		anotherHash := testutil.RandomMultihash(t)
+		thirdHash := testutil.RandomMultihash(t)
+
+		unsupportedErr := apierror.New(errors.New("bad request"), http.StatusBadRequest)
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, unsupportedErr).Once()
+		mockPlainFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil).Once()
+		// a success resets the count of consecutive failures
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, anotherHash).Return(&model.FindResponse{}, nil).Once()
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, unsupportedErr).Once()
+		mockPlainFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil).Once()
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, thirdHash).Return(&model.FindResponse{}, nil).Once()
+
+		for _, digest := range []mh.Multihash{someHash, anotherHash, someHash, thirdHash} {
+			_, err := finder.Find(context.Background(), digest)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("resumes double hashing after the backoff", func(t *testing.T) {
+		mockPrivateFinder := extmocks.NewMockIpniFinder(t)
+		mockPlainFinder := extmocks.NewMockIpniFinder(t)
+
+		clk := clock.NewMock()
+		finder := NewReaderPrivacyFinder(mockPrivateFinder, mockPlainFinder, WithClock(clk), WithReaderPrivacyFallback(1, time.Minute))
+
+		someHash := testutil.RandomMultihash(t)
+		unsupportedErr := apierror.New(errors.New("method not allowed"), http.StatusMethodNotAllowed)
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, unsupportedErr).Once()
+		mockPlainFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil).Twice()
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil).Once()
+
+		_, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+
+		// still within the backoff
+		clk.Add(30 * time.Second)
+		_, err = finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+
+		clk.Add(time.Minute)
+		_, err = finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+	})
+
+	t.Run("returns other errors without falling back", func(t *testing.T) {
+		mockPrivateFinder := extmocks.NewMockIpniFinder(t)
+		mockPlainFinder := extmocks.NewMockIpniFinder(t)
+
+		finder := NewReaderPrivacyFinder(mockPrivateFinder, mockPlainFinder)
+
+		someHash := testutil.RandomMultihash(t)
+		expectedErr := errors.New("connection refused")
+
+		mockPrivateFinder.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, expectedErr)
+
+		_, err := finder.Find(context.Background(), someHash)
+		require.ErrorIs(t, err, expectedErr)
+	})
+}
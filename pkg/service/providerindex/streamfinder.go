@@ -0,0 +1,329 @@
+package providerindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ipni/go-libipni/apierror"
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const ndjsonMediaType = "application/x-ndjson"
+
+// StreamFinder is implemented by IPNI find clients that are able to return
+// provider results as they are received, instead of buffering the entire
+// response.
+type StreamFinder interface {
+	ipnifind.Finder
+	// FindStream queries for provider results for a single multihash, yielding
+	// each result as it is received. Iteration may be stopped early, in which
+	// case the underlying request is cancelled.
+	FindStream(ctx context.Context, digest mh.Multihash) iter.Seq2[model.ProviderResult, error]
+}
+
+// findStream returns a stream of provider results from the finder, using
+// [StreamFinder.FindStream] if the finder supports streaming.
+func findStream(ctx context.Context, finder ipnifind.Finder, digest mh.Multihash) iter.Seq2[model.ProviderResult, error] {
+	if sf, ok := finder.(StreamFinder); ok {
+		return sf.FindStream(ctx, digest)
+	}
+	return func(yield func(model.ProviderResult, error) bool) {
+		res, err := finder.Find(ctx, digest)
+		if err != nil {
+			yield(model.ProviderResult{}, err)
+			return
+		}
+		for _, mhres := range res.MultihashResults {
+			for _, result := range mhres.ProviderResults {
+				if !yield(result, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// collectStream gathers all the results from a stream into a find response.
+func collectStream(digest mh.Multihash, stream iter.Seq2[model.ProviderResult, error]) (*model.FindResponse, error) {
+	var results []model.ProviderResult
+	for result, err := range stream {
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return &model.FindResponse{}, nil
+	}
+	return &model.FindResponse{
+		MultihashResults: []model.MultihashResult{
+			{
+				Multihash:       digest,
+				ProviderResults: results,
+			},
+		},
+	}, nil
+}
+
+// NDJSONFinder is an IPNI find client that requests streaming NDJSON find
+// responses, decoding provider results one at a time as they are received.
+// IPNI nodes that do not support NDJSON responses are handled transparently by
+// decoding the regular JSON response.
+type NDJSONFinder struct {
+	client  *http.Client
+	findURL *url.URL
+}
+
+var _ StreamFinder = (*NDJSONFinder)(nil)
+
+// NewNDJSONFinder creates a new [NDJSONFinder] for the IPNI node at the given
+// URL.
+func NewNDJSONFinder(baseURL string, httpClient *http.Client) (*NDJSONFinder, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url must have http or https scheme: %s", baseURL)
+	}
+	u.Path = ""
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &NDJSONFinder{client: httpClient, findURL: u.JoinPath("multihash")}, nil
+}
+
+// Find queries for provider results for a single multihash. If no results are
+// found then an empty response without error is returned.
+func (f *NDJSONFinder) Find(ctx context.Context, digest mh.Multihash) (*model.FindResponse, error) {
+	return collectStream(digest, f.FindStream(ctx, digest))
+}
+
+// FindStream queries for provider results for a single multihash, yielding
+// each result as it is decoded from the response.
+func (f *NDJSONFinder) FindStream(ctx context.Context, digest mh.Multihash) iter.Seq2[model.ProviderResult, error] {
+	return func(yield func(model.ProviderResult, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		u := f.findURL.JoinPath(digest.B58String())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			yield(model.ProviderResult{}, err)
+			return
+		}
+		req.Header.Set("Accept", ndjsonMediaType)
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			yield(model.ProviderResult{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode == http.StatusNotFound {
+				return
+			}
+			yield(model.ProviderResult{}, apierror.FromResponse(resp.StatusCode, body))
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType != ndjsonMediaType {
+			// the IPNI node does not support streaming, decode the whole response
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				yield(model.ProviderResult{}, err)
+				return
+			}
+			res, err := model.UnmarshalFindResponse(body)
+			if err != nil {
+				yield(model.ProviderResult{}, err)
+				return
+			}
+			for _, mhres := range res.MultihashResults {
+				for _, result := range mhres.ProviderResults {
+					if !yield(result, nil) {
+						return
+					}
+				}
+			}
+			return
+		}
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var result model.ProviderResult
+			err := dec.Decode(&result)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(model.ProviderResult{}, fmt.Errorf("decoding provider result: %w", err))
+				return
+			}
+			if !yield(result, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TieredStreamFinder is a [StreamFinder] that tries each tier in order until
+// one returns results, in the same way as [ipniclient.TieredFinder], but
+// streams the results of the tier being queried.
+type TieredStreamFinder struct {
+	tiers   []ipnifind.Finder
+	timeout time.Duration
+}
+
+var _ StreamFinder = (*TieredStreamFinder)(nil)
+
+// NewTieredStreamFinder creates a new [TieredStreamFinder] with the provided
+// tiers and timeout for each individual tier. The tiers should be
+// [StreamFinder]s in order to benefit from streaming.
+func NewTieredStreamFinder(tiers []ipnifind.Finder, timeout time.Duration) *TieredStreamFinder {
+	return &TieredStreamFinder{tiers: tiers, timeout: timeout}
+}
+
+// Find queries for provider results for a single multihash. If no results are
+// found then an empty response without error is returned.
+func (t *TieredStreamFinder) Find(ctx context.Context, digest mh.Multihash) (*model.FindResponse, error) {
+	return collectStream(digest, t.FindStream(ctx, digest))
+}
+
+// FindStream streams results from the first tier that returns any. A tier
+// that fails before returning results causes the next tier to be tried. If
+// all tiers fail, the errors from each tier are combined.
+func (t *TieredStreamFinder) FindStream(ctx context.Context, digest mh.Multihash) iter.Seq2[model.ProviderResult, error] {
+	return func(yield func(model.ProviderResult, error) bool) {
+		var findErr error
+		for _, tier := range t.tiers {
+			if t.findTier(ctx, tier, digest, yield, &findErr) {
+				return
+			}
+		}
+		if findErr != nil {
+			yield(model.ProviderResult{}, findErr)
+		}
+	}
+}
+
+// findTier streams results from a single tier, returning true if results were
+// found or iteration was stopped.
+func (t *TieredStreamFinder) findTier(ctx context.Context, tier ipnifind.Finder, digest mh.Multihash, yield func(model.ProviderResult, error) bool, findErr *error) bool {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	found := false
+	for result, err := range findStream(ctx, tier, digest) {
+		if err != nil {
+			if found {
+				// results from this tier have already been yielded
+				yield(model.ProviderResult{}, err)
+				return true
+			}
+			*findErr = errors.Join(*findErr, err)
+			return false
+		}
+		found = true
+		if !yield(result, nil) {
+			return true
+		}
+	}
+	return found
+}
+
+// FindStream queries all IPNI nodes in parallel, yielding deduplicated results
+// as soon as they are received from any node. An error is yielded only if all
+// nodes fail.
+func (f *MergingFinder) FindStream(ctx context.Context, digest mh.Multihash) iter.Seq2[model.ProviderResult, error] {
+	return func(yield func(model.ProviderResult, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type nodeResult struct {
+			result model.ProviderResult
+			err    error
+		}
+
+		ch := make(chan nodeResult)
+		var wg sync.WaitGroup
+		for _, node := range f.nodes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				nodeCtx, cancel := context.WithTimeout(ctx, f.timeout)
+				defer cancel()
+
+				for result, err := range findStream(nodeCtx, node.Finder, digest) {
+					if err != nil {
+						err = fmt.Errorf("finding in %s: %w", node.Endpoint, err)
+					}
+					select {
+					case ch <- nodeResult{result: result, err: err}:
+					case <-ctx.Done():
+						return
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(ch)
+		}()
+
+		var findErr error
+		var failed int
+		var found bool
+		seen := map[string]struct{}{}
+		// like Find, record the merged results unless all nodes failed,
+		// including when the caller stops early
+		defer func() {
+			if failed > 0 && failed == len(f.nodes) {
+				return
+			}
+			f.mergedResults.Record(ctx, int64(len(seen)))
+		}()
+		for nr := range ch {
+			if nr.err != nil {
+				findErr = errors.Join(findErr, nr.err)
+				failed++
+				continue
+			}
+			key := resultKey(nr.result)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			found = true
+			if !yield(nr.result, nil) {
+				return
+			}
+		}
+
+		if findErr != nil {
+			if !found && failed == len(f.nodes) {
+				yield(model.ProviderResult{}, findErr)
+				return
+			}
+			f.log.Warnf("finding %s in some IPNI nodes: %s", digest.B58String(), findErr)
+		}
+	}
+}
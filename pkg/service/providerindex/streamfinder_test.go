@@ -0,0 +1,214 @@
+package providerindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ipnifind "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/multiformats/go-multicodec"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/service/providerindex/legacy"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONFinder(t *testing.T) {
+	someHash := testutil.RandomMultihash(t)
+	results := []model.ProviderResult{
+		testutil.RandomLocationCommitmentProviderResult(t),
+		testutil.RandomIndexClaimProviderResult(t),
+	}
+
+	t.Run("decodes streaming response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/multihash/"+someHash.B58String(), r.URL.Path)
+			require.Equal(t, ndjsonMediaType, r.Header.Get("Accept"))
+			w.Header().Set("Content-Type", ndjsonMediaType)
+			enc := json.NewEncoder(w)
+			for _, result := range results {
+				require.NoError(t, enc.Encode(result))
+			}
+		}))
+		defer srv.Close()
+
+		finder, err := NewNDJSONFinder(srv.URL, srv.Client())
+		require.NoError(t, err)
+
+		var received []model.ProviderResult
+		for result, err := range finder.FindStream(context.Background(), someHash) {
+			require.NoError(t, err)
+			received = append(received, result)
+		}
+		require.Equal(t, results, received)
+	})
+
+	t.Run("decodes regular JSON response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			b, err := model.MarshalFindResponse(&model.FindResponse{
+				MultihashResults: []model.MultihashResult{{Multihash: someHash, ProviderResults: results}},
+			})
+			require.NoError(t, err)
+			w.Write(b)
+		}))
+		defer srv.Close()
+
+		finder, err := NewNDJSONFinder(srv.URL, srv.Client())
+		require.NoError(t, err)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Len(t, res.MultihashResults, 1)
+		require.Equal(t, results, res.MultihashResults[0].ProviderResults)
+	})
+
+	t.Run("returns no results when not found", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		finder, err := NewNDJSONFinder(srv.URL, srv.Client())
+		require.NoError(t, err)
+
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Empty(t, res.MultihashResults)
+	})
+
+	t.Run("stops reading when iteration stops", func(t *testing.T) {
+		done := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer close(done)
+			w.Header().Set("Content-Type", ndjsonMediaType)
+			enc := json.NewEncoder(w)
+			require.NoError(t, enc.Encode(results[0]))
+			w.(http.Flusher).Flush()
+			// block until the client goes away
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		finder, err := NewNDJSONFinder(srv.URL, srv.Client())
+		require.NoError(t, err)
+
+		for result, err := range finder.FindStream(context.Background(), someHash) {
+			require.NoError(t, err)
+			require.Equal(t, results[0], result)
+			break
+		}
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("request was not cancelled")
+		}
+	})
+}
+
+func TestTieredStreamFinder(t *testing.T) {
+	someHash := testutil.RandomMultihash(t)
+	result := testutil.RandomLocationCommitmentProviderResult(t)
+	findResponse := &model.FindResponse{
+		MultihashResults: []model.MultihashResult{{Multihash: someHash, ProviderResults: []model.ProviderResult{result}}},
+	}
+
+	t.Run("tries next tier when a tier fails or has no results", func(t *testing.T) {
+		tier1 := extmocks.NewMockIpniFinder(t)
+		tier2 := extmocks.NewMockIpniFinder(t)
+		tier3 := extmocks.NewMockIpniFinder(t)
+
+		tier1.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, errors.New("something went wrong"))
+		tier2.EXPECT().Find(extmocks.AnyContext, someHash).Return(&model.FindResponse{}, nil)
+		tier3.EXPECT().Find(extmocks.AnyContext, someHash).Return(findResponse, nil)
+
+		finder := NewTieredStreamFinder([]ipnifind.Finder{tier1, tier2, tier3}, time.Second)
+		res, err := finder.Find(context.Background(), someHash)
+		require.NoError(t, err)
+		require.Equal(t, findResponse, res)
+	})
+
+	t.Run("returns combined error when all tiers fail", func(t *testing.T) {
+		tier1 := extmocks.NewMockIpniFinder(t)
+		tier2 := extmocks.NewMockIpniFinder(t)
+
+		err1 := errors.New("tier one failed")
+		err2 := errors.New("tier two failed")
+		tier1.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, err1)
+		tier2.EXPECT().Find(extmocks.AnyContext, someHash).Return(nil, err2)
+
+		finder := NewTieredStreamFinder([]ipnifind.Finder{tier1, tier2}, time.Second)
+		_, err := finder.Find(context.Background(), someHash)
+		require.ErrorIs(t, err, err1)
+		require.ErrorIs(t, err, err2)
+	})
+}
+
+func TestStreamingFind(t *testing.T) {
+	someHash := testutil.RandomMultihash(t)
+	targetClaims := []multicodec.Code{metadata.LocationCommitmentID}
+
+	var streamed []model.ProviderResult
+	for range 2 {
+		streamed = append(streamed, testutil.RandomIndexClaimProviderResult(t))
+		streamed = append(streamed, testutil.RandomLocationCommitmentProviderResult(t))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ndjsonMediaType)
+		enc := json.NewEncoder(w)
+		for _, result := range streamed {
+			if err := enc.Encode(result); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	finder, err := NewNDJSONFinder(srv.URL, srv.Client())
+	require.NoError(t, err)
+
+	t.Run("filters by codec and stops once the limit is reached", func(t *testing.T) {
+		mockStore := types.NewMockProviderStore(t)
+		mockNoProviderStore := types.NewMockNoProviderStore(t)
+		mockIpniPublisher := extmocks.NewMockIpniPublisher(t)
+		mockLegacyClaims := legacy.NewMockClaimsFinder(t)
+
+		providerIndex := New(mockStore, mockNoProviderStore, finder, mockIpniPublisher, mockLegacyClaims, WithStreamingFind(1))
+
+		mockStore.EXPECT().Members(extmocks.AnyContext, someHash).Return(nil, types.ErrKeyNotFound)
+		mockNoProviderStore.EXPECT().Members(extmocks.AnyContext, someHash).Return(nil, types.ErrKeyNotFound)
+		mockLegacyClaims.EXPECT().Find(extmocks.AnyContext, someHash, targetClaims).Return(nil, nil)
+		mockStore.EXPECT().Add(extmocks.AnyContext, someHash, streamed[1]).Return(1, nil)
+		mockStore.EXPECT().SetExpirable(extmocks.AnyContext, someHash, true).Return(nil)
+
+		results, err := providerIndex.getProviderResults(context.Background(), someHash, targetClaims)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{streamed[1]}, results)
+	})
+
+	t.Run("returns all matching results below the limit", func(t *testing.T) {
+		mockStore := types.NewMockProviderStore(t)
+		mockNoProviderStore := types.NewMockNoProviderStore(t)
+		mockIpniPublisher := extmocks.NewMockIpniPublisher(t)
+		mockLegacyClaims := legacy.NewMockClaimsFinder(t)
+
+		providerIndex := New(mockStore, mockNoProviderStore, finder, mockIpniPublisher, mockLegacyClaims, WithStreamingFind(0))
+
+		mockStore.EXPECT().Members(extmocks.AnyContext, someHash).Return(nil, types.ErrKeyNotFound)
+		mockNoProviderStore.EXPECT().Members(extmocks.AnyContext, someHash).Return(nil, types.ErrKeyNotFound)
+		mockLegacyClaims.EXPECT().Find(extmocks.AnyContext, someHash, targetClaims).Return(nil, nil)
+		mockStore.EXPECT().Add(extmocks.AnyContext, someHash, streamed[1], streamed[3]).Return(2, nil)
+		mockStore.EXPECT().SetExpirable(extmocks.AnyContext, someHash, true).Return(nil)
+
+		results, err := providerIndex.getProviderResults(context.Background(), someHash, targetClaims)
+		require.NoError(t, err)
+		require.Equal(t, []model.ProviderResult{streamed[1], streamed[3]}, results)
+	})
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// WithProviderRegistry restricts caching claims to the active storage
+// providers in the registry. A `claim/cache` invocation must be issued by a
+// registered provider, for one of its peer IDs, using only its registered
+// endpoints. Results from suspended providers are excluded from queries.
+func WithProviderRegistry(registry types.ProviderRegistry) Option {
+	return func(is *IndexingService) {
+		is.providerRegistry = registry
+	}
+}
+
+// authorizeProvider ensures the issuer of the claim is a storage provider
+// allowed to cache it for the passed peer.
+func (is *IndexingService) authorizeProvider(ctx context.Context, provider peer.AddrInfo, claim delegation.Delegation) error {
+	if is.providerRegistry == nil {
+		return nil
+	}
+	sp, err := is.providerRegistry.Get(ctx, claim.Issuer().DID())
+	if err != nil {
+		if errors.Is(err, types.ErrKeyNotFound) {
+			return fmt.Errorf("%w: provider %s is not registered", types.ErrUnauthorizedProvider, claim.Issuer().DID())
+		}
+		return fmt.Errorf("getting storage provider: %w", err)
+	}
+	return providerregistry.Authorize(sp, provider)
+}
+
+// isSuspended determines if the passed peer belongs to a suspended storage
+// provider. Peers that are not registered are not suspended.
+func (is *IndexingService) isSuspended(ctx context.Context, peerID peer.ID) bool {
+	if is.providerRegistry == nil {
+		return false
+	}
+	sp, err := is.providerRegistry.Find(ctx, peerID)
+	if err != nil {
+		if !errors.Is(err, types.ErrKeyNotFound) {
+			log.Warnw("failed to find storage provider", "peer", peerID, "err", err)
+		}
+		return false
+	}
+	return sp.Status == types.ProviderStatusSuspended
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/ipld"
+	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/providerregistry"
+	"github.com/storacha/indexing-service/pkg/service/blobindexlookup"
+	"github.com/storacha/indexing-service/pkg/service/contentclaims"
+	"github.com/storacha/indexing-service/pkg/service/providerindex"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	claimsAddr := testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fclaims%2F%7Bclaim%7D"))(t)
+	newProviderAddr := func(t *testing.T) *peer.AddrInfo {
+		return &peer.AddrInfo{ID: testutil.RandomPeer(t), Addrs: []ma.Multiaddr{claimsAddr}}
+	}
+	newRegistry := func(t *testing.T, providers ...types.StorageProvider) types.ProviderRegistry {
+		registry := providerregistry.NewDatastoreRegistry(datastore.NewMapDatastore())
+		for _, sp := range providers {
+			require.NoError(t, registry.Put(t.Context(), sp))
+		}
+		return registry
+	}
+
+	t.Run("caches claims from active providers", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		providerAddr := newProviderAddr(t)
+		registry := newRegistry(t, types.StorageProvider{
+			DID:       testutil.Alice.DID(),
+			PeerIDs:   []peer.ID{providerAddr.ID},
+			Endpoints: []string{claimsAddr.String()},
+			Status:    types.ProviderStatusActive,
+		})
+
+		_, locationDelegation, _ := buildTestLocationClaim(t, testutil.RandomCID(t).(cidlink.Link), providerAddr, testutil.RandomDID(t), rand.Uint64N(5000))
+		mockClaimsService.EXPECT().Cache(extmocks.AnyContext, locationDelegation).Return(nil)
+		mockProviderIndex.EXPECT().Cache(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithProviderRegistry(registry))
+		require.NoError(t, service.Cache(t.Context(), *providerAddr, locationDelegation))
+	})
+
+	t.Run("rejects claims from unauthorized providers", func(t *testing.T) {
+		providerAddr := newProviderAddr(t)
+		otherAddr := testutil.Must(ma.NewMultiaddr("/dns/example.com/tls/http/http-path/%2Fclaims%2F%7Bclaim%7D"))(t)
+
+		testCases := []struct {
+			name     string
+			provider *types.StorageProvider
+			addr     peer.AddrInfo
+		}{
+			{name: "unregistered provider", addr: *providerAddr},
+			{
+				name:     "suspended provider",
+				provider: &types.StorageProvider{DID: testutil.Alice.DID(), PeerIDs: []peer.ID{providerAddr.ID}, Status: types.ProviderStatusSuspended},
+				addr:     *providerAddr,
+			},
+			{
+				name:     "unregistered peer",
+				provider: &types.StorageProvider{DID: testutil.Alice.DID(), PeerIDs: []peer.ID{testutil.RandomPeer(t)}, Status: types.ProviderStatusActive},
+				addr:     *providerAddr,
+			},
+			{
+				name:     "unregistered endpoint",
+				provider: &types.StorageProvider{DID: testutil.Alice.DID(), PeerIDs: []peer.ID{providerAddr.ID}, Endpoints: []string{claimsAddr.String()}, Status: types.ProviderStatusActive},
+				addr:     peer.AddrInfo{ID: providerAddr.ID, Addrs: []ma.Multiaddr{otherAddr}},
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var registry types.ProviderRegistry
+				if tc.provider != nil {
+					registry = newRegistry(t, *tc.provider)
+				} else {
+					registry = newRegistry(t)
+				}
+				_, locationDelegation, _ := buildTestLocationClaim(t, testutil.RandomCID(t).(cidlink.Link), providerAddr, testutil.RandomDID(t), rand.Uint64N(5000))
+
+				service := NewIndexingService(testutil.Service, blobindexlookup.NewMockBlobIndexLookup(t), contentclaims.NewMockContentClaimsService(t), peer.AddrInfo{ID: testutil.RandomPeer(t)}, providerindex.NewMockProviderIndex(t), WithProviderRegistry(registry))
+				err := service.Cache(t.Context(), tc.addr, locationDelegation)
+				require.ErrorIs(t, err, types.ErrUnauthorizedProvider)
+			})
+		}
+	})
+
+	t.Run("excludes results from suspended providers", func(t *testing.T) {
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		activeAddr := newProviderAddr(t)
+		suspendedAddr := newProviderAddr(t)
+		registry := newRegistry(t,
+			types.StorageProvider{DID: testutil.Alice.DID(), PeerIDs: []peer.ID{activeAddr.ID}, Status: types.ProviderStatusActive},
+			types.StorageProvider{DID: testutil.Bob.DID(), PeerIDs: []peer.ID{suspendedAddr.ID}, Status: types.ProviderStatusSuspended},
+		)
+
+		contentLink := testutil.RandomCID(t).(cidlink.Link)
+		activeDelegationCid, activeDelegation, activeResult := buildTestLocationClaim(t, contentLink, activeAddr, testutil.RandomDID(t), rand.Uint64N(5000))
+		_, _, suspendedResult := buildTestLocationClaim(t, contentLink, suspendedAddr, testutil.RandomDID(t), rand.Uint64N(5000))
+
+		mockProviderIndex.EXPECT().Find(extmocks.AnyContext, providerindex.QueryKey{
+			Hash:         contentLink.Hash(),
+			TargetClaims: []multicodec.Code{metadata.LocationCommitmentID},
+		}).Return([]model.ProviderResult{suspendedResult, activeResult}, nil)
+		activeClaimURL := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", activeDelegationCid)))(t)
+		mockClaimsService.EXPECT().Find(extmocks.AnyContext, activeDelegationCid, activeClaimURL).Return(activeDelegation, nil)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, WithProviderRegistry(registry))
+		result, err := service.Query(t.Context(), types.Query{
+			Type:   types.QueryTypeLocation,
+			Hashes: []mh.Multihash{contentLink.Hash()},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []ipld.Link{activeDelegation.Link()}, result.Claims())
+	})
+}
@@ -13,6 +13,10 @@ var (
 	//go:embed queryresult.ipldsch
 	queryResultBytes []byte
 	queryResultType  schema.Type
+
+	//go:embed locations.ipldsch
+	locationsBytes []byte
+	locationsType  schema.Type
 )
 
 func init() {
@@ -21,6 +25,12 @@ func init() {
 		panic(fmt.Errorf("failed to load schema: %w", err))
 	}
 	queryResultType = typeSystem.TypeByName("QueryResult")
+
+	typeSystem, err = ipld.LoadSchemaBytes(locationsBytes)
+	if err != nil {
+		panic(fmt.Errorf("failed to load schema: %w", err))
+	}
+	locationsType = typeSystem.TypeByName("Locations")
 }
 
 // QueryResultType is the schema for a QueryResult
@@ -28,6 +38,11 @@ func QueryResultType() schema.Type {
 	return queryResultType
 }
 
+// LocationsType is the schema for the resolved locations of blocks
+func LocationsType() schema.Type {
+	return locationsType
+}
+
 // QueryResultModel is the golang structure for encoding query results
 type QueryResultModel struct {
 	Result0_1 *QueryResultModel0_1
@@ -44,3 +59,27 @@ type IndexesModel struct {
 	Keys   []string
 	Values map[string]ipld.Link
 }
+
+// LocationsModel lists the locations blocks can be retrieved from
+type LocationsModel struct {
+	Blocks []BlockLocationsModel
+}
+
+// BlockLocationsModel lists the locations of a single block, identified by
+// its CID and base58btc encoded multihash
+type BlockLocationsModel struct {
+	Cid       string
+	Multihash string
+	Locations []BlockLocationModel
+}
+
+// BlockLocationModel is a URL a block can be retrieved from and the byte range
+// of the block at that URL
+type BlockLocationModel struct {
+	Url        string
+	Offset     int64
+	Length     *int64
+	Range      *string
+	Shard      *string
+	Expiration *int64
+}
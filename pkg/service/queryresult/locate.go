@@ -0,0 +1,171 @@
+package queryresult
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/go-ucanto/validator"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// BlockLocation is a URL a block can be retrieved from, along with the byte
+// range of the block in the resource at that URL.
+type BlockLocation struct {
+	URL    url.URL
+	Offset uint64
+	// Length is nil if the block extends to the end of the resource.
+	Length *uint64
+	// Shard is the multihash of the blob holding the block, or nil if the
+	// location was claimed for the block itself.
+	Shard mh.Multihash
+	// Expiration is the expiry of the location claim the location was derived
+	// from, or nil if it never expires.
+	Expiration *ucan.UTCUnixTimestamp
+	// Space is the space the claimed content was stored in, if known.
+	Space did.DID
+	// Issuer is the issuer of the location claim, typically the storage node
+	// serving the content.
+	Issuer did.DID
+}
+
+// Range returns the value of the HTTP Range header to send to retrieve the
+// block from the location. It returns an empty string for empty blocks, since
+// HTTP byte ranges cannot be empty.
+func (bl BlockLocation) Range() string {
+	if bl.Length == nil {
+		return fmt.Sprintf("bytes=%d-", bl.Offset)
+	}
+	if *bl.Length == 0 {
+		return ""
+	}
+	return fmt.Sprintf("bytes=%d-%d", bl.Offset, bl.Offset+*bl.Length-1)
+}
+
+type locationClaim struct {
+	caveats    assert.LocationCaveats
+	expiration *ucan.UTCUnixTimestamp
+	issuer     did.DID
+}
+
+// Locate resolves the locations of blocks from the location claims and
+// indexes in a query result. Blocks are located by location claims for the
+// blocks themselves, and by location claims for the shards the indexes place
+// them in, offsetting their position in the shard the same way
+// [BuildCompressed] does. It returns the locations of each block, in the order
+// of the digests.
+func Locate(qr types.QueryResult, digests []mh.Multihash) ([][]BlockLocation, error) {
+	blocks, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(qr.Blocks()))
+	if err != nil {
+		return nil, fmt.Errorf("reading blocks from query result: %w", err)
+	}
+
+	var claims []locationClaim
+	for _, root := range qr.Claims() {
+		claim, err := delegation.NewDelegationView(root, blocks)
+		if err != nil {
+			return nil, fmt.Errorf("decoding claim %s: %w", root, err)
+		}
+		if len(claim.Capabilities()) == 0 {
+			continue
+		}
+		match, err := assert.Location.Match(validator.NewSource(claim.Capabilities()[0], claim))
+		if err != nil {
+			continue
+		}
+		claims = append(claims, locationClaim{
+			caveats:    match.Value().Nb(),
+			expiration: claim.Expiration(),
+			issuer:     claim.Issuer().DID(),
+		})
+	}
+
+	var indexes []blobindex.ShardedDagIndexView
+	for _, root := range qr.Indexes() {
+		blk, ok, err := blocks.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("getting index %s: %w", root, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing index block: %s", root)
+		}
+		index, err := blobindex.Extract(bytes.NewReader(blk.Bytes()))
+		if err != nil {
+			return nil, fmt.Errorf("decoding index %s: %w", root, err)
+		}
+		indexes = append(indexes, index)
+	}
+
+	locations := make([][]BlockLocation, 0, len(digests))
+	for _, digest := range digests {
+		var locs []BlockLocation
+		for _, claim := range claims {
+			if !bytes.Equal(claim.caveats.Content.Hash(), digest) {
+				continue
+			}
+			offset := uint64(0)
+			var length *uint64
+			if claim.caveats.Range != nil {
+				offset = claim.caveats.Range.Offset
+				length = claim.caveats.Range.Length
+			}
+			locs = appendLocations(locs, claim, offset, length, nil)
+		}
+
+		for _, index := range indexes {
+			for shard, slices := range index.Shards().Iterator() {
+				if !slices.Has(digest) {
+					continue
+				}
+				pos := slices.Get(digest)
+				for _, claim := range claims {
+					if !bytes.Equal(claim.caveats.Content.Hash(), shard) {
+						continue
+					}
+					offset := pos.Offset
+					if claim.caveats.Range != nil {
+						offset = claim.caveats.Range.Offset + pos.Offset
+					}
+					length := pos.Length
+					locs = appendLocations(locs, claim, offset, &length, shard)
+				}
+			}
+		}
+		locations = append(locations, locs)
+	}
+	return locations, nil
+}
+
+// appendLocations appends a location for each URL in the claim, skipping
+// locations that were already found.
+func appendLocations(locs []BlockLocation, claim locationClaim, offset uint64, length *uint64, shard mh.Multihash) []BlockLocation {
+	for _, u := range claim.caveats.Location {
+		loc := BlockLocation{
+			URL:        u,
+			Offset:     offset,
+			Length:     length,
+			Shard:      shard,
+			Expiration: claim.expiration,
+			Space:      claim.caveats.Space,
+			Issuer:     claim.issuer,
+		}
+		duplicate := false
+		for _, l := range locs {
+			if l.URL.String() == loc.URL.String() && l.Range() == loc.Range() {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
@@ -0,0 +1,88 @@
+package queryresult
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocate(t *testing.T) {
+	principal := testutil.RandomSigner(t)
+	exp := int(time.Now().Add(time.Hour).Unix())
+	space := testutil.RandomPrincipal(t).DID()
+
+	blockMh := testutil.RandomMultihash(t)
+	shardMh := testutil.RandomMultihash(t)
+	index := blobindex.NewShardedDagIndexView(testutil.RandomCID(t), 1)
+	index.SetSlice(shardMh, blockMh, blobindex.Position{Offset: 100, Length: 50})
+	index.SetSlice(shardMh, testutil.RandomMultihash(t), blobindex.Position{Offset: 150, Length: 20})
+
+	// the shard is available at two URLs, one of them holding it at an offset
+	shardURL := testutil.Must(url.Parse("https://storage.example.com/blob"))(t)
+	packURL := testutil.Must(url.Parse("https://storage.example.com/pack"))(t)
+	packLength := uint64(1000)
+	shardClaim := testutil.Must(assert.Location.Delegate(principal, principal, principal.DID().String(), assert.LocationCaveats{
+		Content:  ctypes.FromHash(shardMh),
+		Location: []url.URL{*shardURL},
+		Space:    space,
+	}, delegation.WithExpiration(exp)))(t)
+	packClaim := testutil.Must(assert.Location.Delegate(principal, principal, principal.DID().String(), assert.LocationCaveats{
+		Content:  ctypes.FromHash(shardMh),
+		Location: []url.URL{*packURL},
+		Range:    &assert.Range{Offset: 1000, Length: &packLength},
+	}, delegation.WithNoExpiration()))(t)
+
+	// another block has its own location claim
+	otherMh := testutil.RandomMultihash(t)
+	otherURL := testutil.Must(url.Parse("https://other.example.com/car"))(t)
+	otherLength := uint64(10)
+	otherClaim := testutil.Must(assert.Location.Delegate(principal, principal, principal.DID().String(), assert.LocationCaveats{
+		Content:  ctypes.FromHash(otherMh),
+		Location: []url.URL{*otherURL},
+		Range:    &assert.Range{Offset: 5, Length: &otherLength},
+	}, delegation.WithNoExpiration()))(t)
+
+	indexClaim := testutil.RandomIndexDelegation(t)
+	noCapsClaim := testutil.Must(delegation.Delegate(principal, principal, []ucan.Capability[ucan.NoCaveats]{}))(t)
+	claims := map[cid.Cid]delegation.Delegation{
+		link.ToCID(shardClaim.Link()): shardClaim,
+		link.ToCID(packClaim.Link()):  packClaim,
+		link.ToCID(otherClaim.Link()): otherClaim,
+		// claims other than location claims are ignored
+		link.ToCID(indexClaim.Link()):  indexClaim,
+		link.ToCID(noCapsClaim.Link()): noCapsClaim,
+	}
+	indexes := bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](1)
+	indexes.Set(types.EncodedContextID("index"), index)
+	qr := testutil.Must(Build(claims, indexes))(t)
+
+	locations, err := Locate(qr, []mh.Multihash{blockMh, otherMh, testutil.RandomMultihash(t)})
+	require.NoError(t, err)
+	require.Len(t, locations, 3)
+
+	length := uint64(50)
+	require.ElementsMatch(t, []BlockLocation{
+		{URL: *shardURL, Offset: 100, Length: &length, Shard: shardMh, Expiration: &exp, Space: space, Issuer: principal.DID()},
+		{URL: *packURL, Offset: 1100, Length: &length, Shard: shardMh, Issuer: principal.DID()},
+	}, locations[0])
+	require.Equal(t, []BlockLocation{{URL: *otherURL, Offset: 5, Length: &otherLength, Issuer: principal.DID()}}, locations[1])
+	require.Empty(t, locations[2])
+
+	require.Equal(t, "bytes=1100-1149", BlockLocation{Offset: 1100, Length: &length}.Range())
+	require.Equal(t, "bytes=1100-", BlockLocation{Offset: 1100}.Range())
+	empty := uint64(0)
+	require.Empty(t, BlockLocation{Offset: 1100, Length: &empty}.Range())
+}
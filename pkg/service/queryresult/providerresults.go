@@ -0,0 +1,155 @@
+package queryresult
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/indexing-service/pkg/internal/blobaddr"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// ProviderResults converts the location, index and equals claims in a query
+// result for a digest into IPNI provider results, each carrying the metadata
+// of its claim. Results are attributed to the passed provider, whose addresses
+// are expected to include the URL claims can be fetched from. Location results
+// are instead attributed to the peer ID of the claim issuer, falling back to
+// the passed provider for issuers without one (e.g. did:web). They are also
+// given the locations in their claim, with the claimed digest replaced by the
+// "{blob}" placeholder, and the CID of the claimed content as shard if it is
+// not the digest itself. Other claims are ignored.
+func ProviderResults(qr types.QueryResult, digest mh.Multihash, provider peer.AddrInfo) ([]model.ProviderResult, error) {
+	blocks, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(qr.Blocks()))
+	if err != nil {
+		return nil, fmt.Errorf("reading blocks from query result: %w", err)
+	}
+
+	var results []model.ProviderResult
+	for _, root := range qr.Claims() {
+		claim, err := delegation.NewDelegationView(root, blocks)
+		if err != nil {
+			return nil, fmt.Errorf("decoding claim %s: %w", root, err)
+		}
+		caps := claim.Capabilities()
+		if len(caps) == 0 {
+			continue
+		}
+
+		var exp int64
+		if claim.Expiration() != nil {
+			exp = int64(*claim.Expiration())
+		}
+		claimCid := link.ToCID(claim.Link())
+
+		var result model.ProviderResult
+		switch caps[0].Can() {
+		case assert.LocationAbility:
+			caveats, rerr := assert.LocationCaveatsReader.Read(caps[0].Nb())
+			if rerr != nil {
+				return nil, fmt.Errorf("reading location claim %s: %w", root, rerr)
+			}
+			issuer := provider
+			if id, perr := principalparser.ToPeerID(claim.Issuer()); perr == nil {
+				issuer.ID = id
+			}
+			result, err = locationProviderResult(caveats, digest, claimCid, exp, issuer)
+			if err != nil {
+				return nil, fmt.Errorf("converting location claim %s: %w", root, err)
+			}
+		case assert.IndexAbility:
+			caveats, rerr := assert.IndexCaveatsReader.Read(caps[0].Nb())
+			if rerr != nil {
+				return nil, fmt.Errorf("reading index claim %s: %w", root, rerr)
+			}
+			meta := metadata.IndexClaimMetadata{
+				Index:      link.ToCID(caveats.Index),
+				Expiration: exp,
+				Claim:      claimCid,
+			}
+			result, err = providerResult([]byte(caveats.Index.Binary()), &meta, provider)
+			if err != nil {
+				return nil, fmt.Errorf("converting index claim %s: %w", root, err)
+			}
+		case assert.EqualsAbility:
+			caveats, rerr := assert.EqualsCaveatsReader.Read(caps[0].Nb())
+			if rerr != nil {
+				return nil, fmt.Errorf("reading equals claim %s: %w", root, rerr)
+			}
+			meta := metadata.EqualsClaimMetadata{
+				Equals:     link.ToCID(caveats.Equals),
+				Expiration: exp,
+				Claim:      claimCid,
+			}
+			result, err = providerResult(caveats.Content.Hash(), &meta, provider)
+			if err != nil {
+				return nil, fmt.Errorf("converting equals claim %s: %w", root, err)
+			}
+		default:
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func locationProviderResult(caveats assert.LocationCaveats, digest mh.Multihash, claimCid cid.Cid, exp int64, provider peer.AddrInfo) (model.ProviderResult, error) {
+	contextID := types.ContextID{Hash: caveats.Content.Hash()}
+	if caveats.Space != did.Undef {
+		space := caveats.Space
+		contextID.Space = &space
+	}
+	encodedContextID, err := contextID.ToEncoded()
+	if err != nil {
+		return model.ProviderResult{}, fmt.Errorf("encoding context ID: %w", err)
+	}
+
+	meta := metadata.LocationCommitmentMetadata{
+		Expiration: exp,
+		Claim:      claimCid,
+	}
+	if !bytes.Equal(caveats.Content.Hash(), digest) {
+		shard := cid.NewCidV1(cid.Raw, caveats.Content.Hash())
+		meta.Shard = &shard
+	}
+	if caveats.Range != nil {
+		meta.Range = &metadata.Range{Offset: caveats.Range.Offset, Length: caveats.Range.Length}
+	}
+
+	addrs := make([]ma.Multiaddr, 0, len(caveats.Location)+len(provider.Addrs))
+	for _, l := range caveats.Location {
+		// generalize the location URL so the URL of the claimed content can be
+		// reconstructed for fetching
+		addr, err := blobaddr.FromLocation(l, caveats.Content.Hash())
+		if err != nil {
+			return model.ProviderResult{}, fmt.Errorf("converting location URL: %w", err)
+		}
+		addrs = append(addrs, addr)
+	}
+	addrs = append(addrs, provider.Addrs...)
+
+	return providerResult(encodedContextID, &meta, peer.AddrInfo{ID: provider.ID, Addrs: addrs})
+}
+
+func providerResult(contextID []byte, meta encoding.BinaryMarshaler, provider peer.AddrInfo) (model.ProviderResult, error) {
+	metaBytes, err := meta.MarshalBinary()
+	if err != nil {
+		return model.ProviderResult{}, fmt.Errorf("encoding metadata: %w", err)
+	}
+	return model.ProviderResult{
+		ContextID: contextID,
+		Metadata:  metaBytes,
+		Provider:  &provider,
+	}, nil
+}
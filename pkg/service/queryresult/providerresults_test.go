@@ -0,0 +1,107 @@
+package queryresult
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-libstoracha/bytemap"
+	"github.com/storacha/go-libstoracha/capabilities/assert"
+	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/metadata"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderResults(t *testing.T) {
+	principal := testutil.RandomSigner(t)
+	exp := int(time.Now().Add(time.Hour).Unix())
+	space := testutil.RandomDID(t)
+	claimsAddr := testutil.Must(maurl.FromURL(testutil.Must(url.Parse("https://indexer.example.com/claim/{claim}"))(t)))(t)
+	provider := peer.AddrInfo{ID: testutil.RandomPeer(t), Addrs: []multiaddr.Multiaddr{claimsAddr}}
+
+	digest := testutil.RandomMultihash(t)
+	blockURL := testutil.Must(url.Parse(fmt.Sprintf("https://storage.example.com/%s/%s.blob", digestutil.Format(digest), digestutil.Format(digest))))(t)
+	blockClaim := testutil.Must(assert.Location.Delegate(principal, principal, principal.DID().String(), assert.LocationCaveats{
+		Space:    space,
+		Content:  ctypes.FromHash(digest),
+		Location: []url.URL{*blockURL},
+	}, delegation.WithExpiration(exp)))(t)
+
+	shard := testutil.RandomMultihash(t)
+	shardURL := testutil.Must(url.Parse("https://storage.example.com/shard"))(t)
+	shardLength := uint64(100)
+	shardClaim := testutil.Must(assert.Location.Delegate(principal, principal, principal.DID().String(), assert.LocationCaveats{
+		Content:  ctypes.FromHash(shard),
+		Location: []url.URL{*shardURL},
+		Range:    &assert.Range{Offset: 10, Length: &shardLength},
+	}, delegation.WithNoExpiration()))(t)
+
+	indexClaim := testutil.RandomIndexDelegation(t)
+	equalsClaim := testutil.RandomEqualsDelegation(t)
+
+	claims := map[cid.Cid]delegation.Delegation{}
+	for _, claim := range []delegation.Delegation{blockClaim, shardClaim, indexClaim, equalsClaim} {
+		claims[link.ToCID(claim.Link())] = claim
+	}
+	qr := testutil.Must(Build(claims, bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](0)))(t)
+
+	results, err := ProviderResults(qr, digest, provider)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	byClaim := map[cid.Cid]metadata.HasClaim{}
+	issuer := testutil.Must(principalparser.ToPeerID(principal))(t)
+	for _, result := range results {
+		require.Contains(t, result.Provider.Addrs, claimsAddr)
+
+		md := metadata.MetadataContext.New()
+		require.NoError(t, md.UnmarshalBinary(result.Metadata))
+		require.Len(t, md.Protocols(), 1)
+		protocol, ok := md.Get(md.Protocols()[0]).(metadata.HasClaim)
+		require.True(t, ok)
+		byClaim[protocol.GetClaim()] = protocol
+
+		switch meta := protocol.(type) {
+		case *metadata.LocationCommitmentMetadata:
+			require.Equal(t, issuer, result.Provider.ID)
+			if meta.Claim == link.ToCID(blockClaim.Link()) {
+				contextID := testutil.Must(types.ContextID{Hash: digest, Space: &space}.ToEncoded())(t)
+				require.Equal(t, []byte(contextID), result.ContextID)
+				require.Nil(t, meta.Shard)
+				require.Nil(t, meta.Range)
+				require.Equal(t, int64(exp), meta.Expiration)
+				blobURL := testutil.Must(maurl.FromURL(testutil.Must(url.Parse("https://storage.example.com/{blob}/{blob}.blob"))(t)))(t)
+				require.Equal(t, []multiaddr.Multiaddr{blobURL, claimsAddr}, result.Provider.Addrs)
+			} else {
+				require.Equal(t, []byte(shard), result.ContextID)
+				require.Equal(t, cid.NewCidV1(cid.Raw, shard), *meta.Shard)
+				require.Equal(t, &metadata.Range{Offset: 10, Length: &shardLength}, meta.Range)
+				require.Equal(t, int64(0), meta.Expiration)
+			}
+		case *metadata.IndexClaimMetadata:
+			require.Equal(t, provider.ID, result.Provider.ID)
+			require.Equal(t, []byte(meta.Index.Bytes()), result.ContextID)
+			require.Equal(t, []multiaddr.Multiaddr{claimsAddr}, result.Provider.Addrs)
+		case *metadata.EqualsClaimMetadata:
+			require.Equal(t, provider.ID, result.Provider.ID)
+			require.Equal(t, []multiaddr.Multiaddr{claimsAddr}, result.Provider.Addrs)
+		default:
+			t.Fatalf("unexpected metadata: %T", meta)
+		}
+	}
+	for _, claim := range []delegation.Delegation{blockClaim, shardClaim, indexClaim, equalsClaim} {
+		require.Contains(t, byClaim, link.ToCID(claim.Link()))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -22,13 +23,15 @@ import (
 	"github.com/storacha/go-libstoracha/advertisement"
 	"github.com/storacha/go-libstoracha/capabilities/assert"
 	"github.com/storacha/go-libstoracha/capabilities/space/content"
+	"github.com/storacha/go-libstoracha/ipnipublisher/publisher"
 	"github.com/storacha/go-libstoracha/metadata"
 	"github.com/storacha/go-ucanto/core/dag/blockstore"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/core/invocation"
 	"github.com/storacha/go-ucanto/core/iterable"
 	"github.com/storacha/go-ucanto/did"
-	"github.com/storacha/go-ucanto/principal/ed25519/verifier"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/verifier"
 	"github.com/storacha/go-ucanto/ucan"
 	"github.com/storacha/go-ucanto/validator"
 	"go.opentelemetry.io/otel/attribute"
@@ -40,6 +43,7 @@ import (
 	"github.com/storacha/indexing-service/pkg/internal/jobwalker/parallelwalk"
 	"github.com/storacha/indexing-service/pkg/internal/jobwalker/singlewalk"
 	"github.com/storacha/indexing-service/pkg/internal/link"
+	"github.com/storacha/indexing-service/pkg/principalparser"
 	"github.com/storacha/indexing-service/pkg/service/blobindexlookup"
 	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 	"github.com/storacha/indexing-service/pkg/service/providerindex"
@@ -67,6 +71,13 @@ type IndexingService struct {
 	// provider is the peer info for this service, used when publishing claims.
 	provider  peer.AddrInfo
 	jobWalker jobwalker.JobWalker[job, queryState]
+	// privateSpaces are spaces whose claims are only returned to queries
+	// authorized for the space.
+	privateSpaces     map[did.DID]struct{}
+	principalResolver validator.PrincipalResolver
+	// providerRegistry, if set, restricts caching claims to registered storage
+	// providers.
+	providerRegistry types.ProviderRegistry
 }
 
 var _ types.Service = (*IndexingService)(nil)
@@ -104,6 +115,8 @@ type queryState struct {
 	q      *types.Query
 	qr     *queryResult
 	visits map[jobKey]struct{}
+	// authorized are the private spaces the query is authorized for.
+	authorized map[did.DID]struct{}
 }
 
 func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(job) error, state jobwalker.WrappedState[queryState]) error {
@@ -121,6 +134,7 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 	}) {
 		return nil
 	}
+	types.QueryStatsFromContext(mhCtx).AddJob()
 
 	// find provider records related to this multihash
 	s.AddEvent("finding relevant ProviderResults")
@@ -140,6 +154,11 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 	var lastIndexFetchErr error
 
 	for _, result := range results {
+		if result.Provider != nil && is.isSuspended(mhCtx, result.Provider.ID) {
+			s.AddEvent("skipping result from suspended provider")
+			continue
+		}
+
 		// unmarshall metadata for this provider
 		md := metadata.MetadataContext.New()
 		err = md.UnmarshalBinary(result.Metadata)
@@ -173,6 +192,7 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 
 			s.AddEvent("fetching claims")
 			claim, err := is.claims.Find(mhCtx, cidlink.Link{Cid: claimCid}, url)
+			recordFetch(mhCtx, claimFetches, result.Provider.ID.String(), err)
 			if err != nil {
 				telemetry.Error(s, err, "fetching claims")
 				return fmt.Errorf("fetching claims: %w", err)
@@ -202,8 +222,14 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 						return fmt.Errorf("queuing job for equals hash: %w", err)
 					}
 				} else {
-					// lookup was the equals hash, queue the content hash
-					if err := spawn(job{multihash.Multihash(result.ContextID), nil, nil, types.QueryTypeLocation}); err != nil {
+					// lookup was the equals hash, queue the content hash. The content
+					// hash is read from the claim, since the context ID is space scoped.
+					nb, err := assert.EqualsCaveatsReader.Read(claim.Capabilities()[0].Nb())
+					if err != nil {
+						telemetry.Error(s, err, "reading equals claim data")
+						return fmt.Errorf("reading equals claim data: %w", err)
+					}
+					if err := spawn(job{nb.Content.Hash(), nil, nil, types.QueryTypeLocation}); err != nil {
 						telemetry.Error(s, err, "queuing job for content hash")
 						return fmt.Errorf("queuing job for content hash: %w", err)
 					}
@@ -239,14 +265,20 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 
 					s.AddEvent("fetching index")
 					var auth *types.RetrievalAuth
-					match, err := assert.Location.Match(validator.NewSource(claim.Capabilities()[0], claim))
+					// validate the location commitment before trusting it, resolving
+					// issuers that are not a did:key, such as did:web storage nodes
+					match, err := validateLocationCommitment(mhCtx, claim, is.principalResolver)
 					if err != nil {
-						log.Warnw("failed to match claim to location commitment, will try next provider result if available", "err", err)
-						lastIndexFetchErr = fmt.Errorf("failed to match claim to location commitment: %w", err)
+						log.Warnw("failed to validate location commitment, will try next provider result if available", "err", err)
+						lastIndexFetchErr = fmt.Errorf("validating location commitment: %w", err)
 						continue
 					}
-					lcCaveats := match.Value().Nb()
+					lcCaveats := match.Capability().Nb()
 					space := lcCaveats.Space
+					if !is.isAccessible(space, state.Access().authorized) {
+						s.AddEvent("skipping index in private space")
+						continue
+					}
 					dlgs := state.Access().q.Delegations
 					// Authorized retrieval requires a space in the location claim, a
 					// delegation for the retrieval, and an absolute byte range to extract.
@@ -273,6 +305,7 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 					}
 					req := types.NewRetrievalRequest(url, typedProtocol.Range, auth)
 					index, err := is.blobIndexLookup.Find(mhCtx, result.ContextID, *j.indexProviderRecord, req)
+					recordFetch(mhCtx, indexFetches, result.Provider.ID.String(), err)
 					if err != nil {
 						telemetry.Error(s, err, "fetching index blob")
 						log.Warnw("failed to fetch index blob, will try next provider result if available", "provider", result.Provider.ID, "err", err)
@@ -321,14 +354,23 @@ func (is *IndexingService) jobHandler(mhCtx context.Context, j job, spawn func(j
 // 4. Query ProviderIndex for any location claims for any shards that contain the multihash based on the ShardedDagIndex
 // 5. Read the requisite claims from the ClaimLookup
 // 6. Return all discovered claims and sharded dag indexes
-func (is *IndexingService) Query(ctx context.Context, q types.Query) (types.QueryResult, error) {
+func (is *IndexingService) Query(ctx context.Context, q types.Query) (res types.QueryResult, err error) {
 	ctx, s := telemetry.StartSpan(ctx, "IndexingService.Query")
 	defer s.End()
 
+	start := time.Now()
+	defer func() { recordQuery(ctx, q.Type.String(), start, err) }()
+
 	if q.Type == types.QueryTypeStandardCompressed && len(q.Hashes) != 1 {
 		return nil, fmt.Errorf("invalid query: expected 1 hash for compressed query, got %d", len(q.Hashes))
 	}
 
+	authorized, err := is.authorizeQuery(ctx, q)
+	if err != nil {
+		telemetry.Error(s, err, "authorizing query")
+		return nil, err
+	}
+
 	initialJobs := make([]job, 0, len(q.Hashes))
 	for _, mh := range q.Hashes {
 		initialJobs = append(initialJobs, job{mh, nil, nil, q.Type})
@@ -339,11 +381,15 @@ func (is *IndexingService) Query(ctx context.Context, q types.Query) (types.Quer
 			Claims:  make(map[cid.Cid]delegation.Delegation),
 			Indexes: bytemap.NewByteMap[types.EncodedContextID, blobindex.ShardedDagIndexView](-1),
 		},
-		visits: map[jobKey]struct{}{},
+		visits:     map[jobKey]struct{}{},
+		authorized: authorized,
 	}, is.jobHandler)
 	if err != nil {
 		return nil, err
 	}
+	if len(is.privateSpaces) > 0 {
+		is.filterPrivateClaims(qs.qr.Claims, authorized)
+	}
 	if q.Type == types.QueryTypeStandardCompressed {
 		return queryresult.BuildCompressed(q.Hashes[0], is.id, qs.qr.Claims, qs.qr.Indexes)
 	}
@@ -405,6 +451,9 @@ func (is *IndexingService) Get(ctx context.Context, claim ipld.Link) (delegation
 // ideally however, IPNI would enable UCAN chains for publishing so that we could publish it directly from the storage service
 // it doesn't for now, so we let SPs publish themselves them direct cache with us
 func (is *IndexingService) Cache(ctx context.Context, provider peer.AddrInfo, claim delegation.Delegation) error {
+	if err := is.authorizeProvider(ctx, provider, claim); err != nil {
+		return err
+	}
 	return Cache(ctx, is.blobIndexLookup, is.claims, is.providerIndex, provider, claim)
 }
 
@@ -415,7 +464,20 @@ func (is *IndexingService) Cache(ctx context.Context, provider peer.AddrInfo, cl
 // The service should lookup the index cid location claim, and fetch the ShardedDagIndexView, then use the hashes inside
 // to assemble all the multihashes in the index advertisement
 func (is *IndexingService) Publish(ctx context.Context, claim delegation.Delegation) error {
-	return Publish(ctx, is.id, is.blobIndexLookup, is.claims, is.providerIndex, is.provider, claim)
+	return publish(ctx, is.id, is.blobIndexLookup, is.claims, is.providerIndex, is.provider, claim, claimSpace(claim), is.principalResolver)
+}
+
+// Republish publishes a previously published index or equals claim again,
+// scoped to the passed space. It is used to migrate claims that were published
+// before context IDs were space aware, or without a `space/content/retrieve`
+// delegation identifying their space, so that they are returned by queries
+// filtered by space.
+func (is *IndexingService) Republish(ctx context.Context, claim ipld.Link, space did.DID) error {
+	dlg, err := is.claims.Get(ctx, claim)
+	if err != nil {
+		return fmt.Errorf("getting claim: %w", err)
+	}
+	return publish(ctx, is.id, is.blobIndexLookup, is.claims, is.providerIndex, is.provider, dlg, space, is.principalResolver)
 }
 
 // Option configures an IndexingService
@@ -428,6 +490,15 @@ func WithConcurrency(concurrency int) Option {
 	}
 }
 
+// WithPrincipalResolver sets the resolver used to resolve the keys of claim
+// issuers and delegation signers that are not a did:key, such as storage nodes
+// identified by a did:web.
+func WithPrincipalResolver(resolver validator.PrincipalResolver) Option {
+	return func(is *IndexingService) {
+		is.principalResolver = resolver
+	}
+}
+
 // NewIndexingService returns a new indexing service
 func NewIndexingService(id ucan.Signer, blobIndexLookup blobindexlookup.BlobIndexLookup, claims contentclaims.Service, publicAddrInfo peer.AddrInfo, providerIndex providerindex.ProviderIndex, options ...Option) *IndexingService {
 	provider := peer.AddrInfo{ID: publicAddrInfo.ID}
@@ -461,7 +532,9 @@ func Cache(ctx context.Context, blobIndex blobindexlookup.BlobIndexLookup, claim
 	switch caps[0].Can() {
 	case assert.LocationAbility:
 		s.SetAttributes(attribute.KeyValue{Key: "claim", Value: attribute.StringValue("assert/location")})
-		return cacheLocationCommitment(ctx, claims, provIndex, provider, claim)
+		err := cacheLocationCommitment(ctx, claims, provIndex, provider, claim)
+		recordClaim(ctx, claimsCached, caps[0].Can(), err)
+		return err
 	default:
 		return ErrUnrecognizedClaim
 	}
@@ -521,7 +594,16 @@ func cacheLocationCommitment(ctx context.Context, claims contentclaims.Service,
 	return nil
 }
 
-func Publish(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.BlobIndexLookup, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation) error {
+// Publish caches and publishes an index or equals claim. If the claim carries a
+// `space/content/retrieve` delegation then it is published with a context ID
+// derived from the space, allowing results to be filtered by space. Location
+// commitments issued by principals other than did:key are resolved with the
+// passed resolver, or rejected if it is nil.
+func Publish(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.BlobIndexLookup, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation, resolver validator.PrincipalResolver) error {
+	return publish(ctx, id, blobIndex, claims, provIndex, provider, claim, claimSpace(claim), resolver)
+}
+
+func publish(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.BlobIndexLookup, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation, space did.DID, resolver validator.PrincipalResolver) error {
 	ctx, s := telemetry.StartSpan(ctx, "IndexingService.Publish")
 	defer s.End()
 
@@ -532,16 +614,20 @@ func Publish(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.Blob
 	switch caps[0].Can() {
 	case assert.EqualsAbility:
 		s.SetAttributes(attribute.KeyValue{Key: "claim", Value: attribute.StringValue("assert/equals")})
-		return publishEqualsClaim(ctx, claims, provIndex, provider, claim)
+		err := publishEqualsClaim(ctx, claims, provIndex, provider, claim, space)
+		recordClaim(ctx, claimsPublished, caps[0].Can(), err)
+		return err
 	case assert.IndexAbility:
 		s.SetAttributes(attribute.KeyValue{Key: "claim", Value: attribute.StringValue("assert/index")})
-		return publishIndexClaim(ctx, id, blobIndex, claims, provIndex, provider, claim)
+		err := publishIndexClaim(ctx, id, blobIndex, claims, provIndex, provider, claim, space, resolver)
+		recordClaim(ctx, claimsPublished, caps[0].Can(), err)
+		return err
 	default:
 		return ErrUnrecognizedClaim
 	}
 }
 
-func publishEqualsClaim(ctx context.Context, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation) error {
+func publishEqualsClaim(ctx context.Context, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation, space did.DID) error {
 	capability := claim.Capabilities()[0]
 	nb, rerr := assert.EqualsCaveatsReader.Read(capability.Nb())
 	if rerr != nil {
@@ -569,16 +655,24 @@ func publishEqualsClaim(ctx context.Context, claims contentclaims.Service, provI
 	var digests []multihash.Multihash
 	digests = append(digests, nb.Content.Hash())
 	digests = append(digests, nb.Equals.(cidlink.Link).Cid.Hash())
+	// claims not associated with a space keep the legacy (unscoped) context ID
 	contextID := string(nb.Content.Hash())
+	if space != did.Undef {
+		encoded, err := advertisement.EncodeContextID(space, nb.Equals.(cidlink.Link).Cid.Hash())
+		if err != nil {
+			return fmt.Errorf("encoding equals claim context ID: %w", err)
+		}
+		contextID = string(encoded)
+	}
 	err = provIndex.Publish(ctx, provider, contextID, slices.Values(digests), meta)
-	if err != nil {
+	if err != nil && !errors.Is(err, publisher.ErrAlreadyAdvertised) {
 		return fmt.Errorf("publishing equals claim: %w", err)
 	}
 
 	return nil
 }
 
-func publishIndexClaim(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.BlobIndexLookup, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation) error {
+func publishIndexClaim(ctx context.Context, id ucan.Signer, blobIndex blobindexlookup.BlobIndexLookup, claims contentclaims.Service, provIndex providerindex.ProviderIndex, provider peer.AddrInfo, claim delegation.Delegation, space did.DID, resolver validator.PrincipalResolver) error {
 	capability := claim.Capabilities()[0]
 	nb, rerr := assert.IndexCaveatsReader.Read(capability.Nb())
 	if rerr != nil {
@@ -604,7 +698,7 @@ func publishIndexClaim(ctx context.Context, id ucan.Signer, blobIndex blobindexl
 	var idx blobindex.ShardedDagIndex
 	var ferr error
 	for _, r := range results {
-		idx, ferr = fetchBlobIndex(ctx, id, blobIndex, claims, nb.Index, r, claim)
+		idx, ferr = fetchBlobIndex(ctx, id, blobIndex, claims, nb.Index, r, claim, resolver)
 		if ferr != nil {
 			continue
 		}
@@ -634,9 +728,17 @@ func publishIndexClaim(ctx context.Context, id ucan.Signer, blobIndex blobindexl
 		}
 	}
 
+	// claims not associated with a space keep the legacy (unscoped) context ID
 	contextID := nb.Index.Binary()
+	if space != did.Undef {
+		encoded, err := advertisement.EncodeContextID(space, link.ToCID(nb.Index).Hash())
+		if err != nil {
+			return fmt.Errorf("encoding index claim context ID: %w", err)
+		}
+		contextID = string(encoded)
+	}
 	err = provIndex.Publish(ctx, provider, contextID, digests.Keys(), meta)
-	if err != nil {
+	if err != nil && !errors.Is(err, publisher.ErrAlreadyAdvertised) {
 		return fmt.Errorf("publishing index claim: %w", err)
 	}
 
@@ -651,6 +753,7 @@ func fetchBlobIndex(
 	blobLink ipld.Link,
 	result model.ProviderResult,
 	cause invocation.Invocation, // supporting context (typically `assert/index`)
+	resolver validator.PrincipalResolver, // resolves non did:key claim issuers, may be nil
 ) (blobindex.ShardedDagIndex, error) {
 	meta := metadata.MetadataContext.New()
 	err := meta.UnmarshalBinary(result.Metadata)
@@ -673,7 +776,7 @@ func fetchBlobIndex(
 		return nil, fmt.Errorf("building retrieval URL: %w", err)
 	}
 
-	aud, err := peerToPrincipal(result.Provider.ID)
+	aud, err := principalparser.FromPeerID(result.Provider.ID)
 	if err != nil {
 		return nil, fmt.Errorf("converting provider peer ID to UCAN principal: %w", err)
 	}
@@ -696,7 +799,7 @@ func fetchBlobIndex(
 			return
 		}
 
-		_, err = validateLocationCommitment(ctx, dlg)
+		_, err = validateLocationCommitment(ctx, dlg, resolver)
 		if err != nil {
 			validateErr = err
 			return
@@ -739,14 +842,21 @@ func fetchBlobIndex(
 }
 
 // validateLocationCommitment ensures that the delegation is a valid UCAN (signed,
-// not expired etc.) and is a location commitment.
-func validateLocationCommitment(ctx context.Context, claim delegation.Delegation) (validator.Authorization[assert.LocationCaveats], error) {
+// not expired etc.) and is a location commitment. Issuers that are not a
+// did:key, such as did:web storage nodes, are resolved with the passed
+// resolver, which may be nil if resolution is not supported. A resolution
+// failure is returned as a [types.UnresolvedDIDError].
+func validateLocationCommitment(ctx context.Context, claim delegation.Delegation, resolver validator.PrincipalResolver) (validator.Authorization[assert.LocationCaveats], error) {
+	resolveDIDKey := validator.FailDIDKeyResolution
+	if resolver != nil {
+		resolveDIDKey = resolver.ResolveDIDKey
+	}
+
 	// We use the delegation issuer as the authority, since this should be a self
 	// issued UCAN to assert location.
-	// TODO: support verifiers for other key types?
-	vfr, err := verifier.Parse(claim.Issuer().DID().String())
+	vfr, err := issuerVerifier(ctx, claim.Issuer().DID(), resolveDIDKey)
 	if err != nil {
-		return nil, fmt.Errorf("parsing claim issuer DID: %w", err)
+		return nil, err
 	}
 
 	vctx := validator.NewValidationContext(
@@ -755,9 +865,9 @@ func validateLocationCommitment(ctx context.Context, claim delegation.Delegation
 		validator.IsSelfIssued,
 		// TODO: plug in revocation service?
 		func(ctx context.Context, auth validator.Authorization[any]) validator.Revoked { return nil },
-		validator.ProofUnavailable,     // probably don't want to resolve proofs...
-		verifier.Parse,                 // TODO: support verifiers for other key types?
-		validator.FailDIDKeyResolution, // probably don't want to resolve DID methods either
+		validator.ProofUnavailable, // probably don't want to resolve proofs...
+		principalparser.Parse,
+		resolveDIDKey,
 		validator.NotExpiredNotTooEarly,
 	)
 
@@ -769,22 +879,45 @@ func validateLocationCommitment(ctx context.Context, claim delegation.Delegation
 	return auth, nil
 }
 
-// peerToPrincipal converts a peer ID into a UCAN principal object. Currently
-// supports only ed25519 keys.
-func peerToPrincipal(peer peer.ID) (ucan.Principal, error) {
-	pk, err := peer.ExtractPublicKey()
+// issuerVerifier returns a verifier for the claim issuer. Issuers that are not
+// a did:key are resolved to one, and the verifier for the key is wrapped with
+// the issuer DID.
+func issuerVerifier(ctx context.Context, issuer did.DID, resolveDIDKey validator.PrincipalResolverFunc) (principal.Verifier, error) {
+	if strings.HasPrefix(issuer.String(), did.KeyPrefix) {
+		vfr, err := principalparser.Parse(issuer.String())
+		if err != nil {
+			return nil, fmt.Errorf("parsing claim issuer DID: %w", err)
+		}
+		return vfr, nil
+	}
+	key, uerr := resolveDIDKey(ctx, issuer)
+	if uerr != nil {
+		return nil, types.UnresolvedDIDError{DID: issuer, Cause: uerr}
+	}
+	vfr, err := principalparser.Parse(key.String())
+	if err != nil {
+		return nil, fmt.Errorf("parsing resolved key for claim issuer %s: %w", issuer, err)
+	}
+	wrapped, err := verifier.Wrap(vfr, issuer)
 	if err != nil {
-		return nil, fmt.Errorf("extracting public key from peer ID: %w", err)
+		return nil, fmt.Errorf("wrapping claim issuer verifier: %w", err)
 	}
-	pubBytes, err := pk.Raw()
+	return wrapped, nil
+}
+
+// claimSpace returns the space identified by the `space/content/retrieve`
+// delegation attached to the passed claim, or [did.Undef] if there is none.
+func claimSpace(claim invocation.Invocation) did.DID {
+	cap, _, err := extractContentRetrieveDelegation(claim)
 	if err != nil {
-		return nil, fmt.Errorf("extracting raw bytes of public key: %w", err)
+		return did.Undef
 	}
-	v, err := verifier.FromRaw(pubBytes)
+	space, err := did.Parse(cap.With())
 	if err != nil {
-		return nil, fmt.Errorf("decoding raw ed25519 public key: %w", err)
+		log.Warnw("parsing space DID of retrieval authorization", "err", err)
+		return did.Undef
 	}
-	return v, nil
+	return space
 }
 
 // extractContentRetrieveDelegation extracts a `space/content/retrieve`
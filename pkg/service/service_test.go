@@ -18,21 +18,27 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multicodec"
 	mh "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/advertisement"
 	"github.com/storacha/go-libstoracha/blobindex"
 	"github.com/storacha/go-libstoracha/bytemap"
 	cassert "github.com/storacha/go-libstoracha/capabilities/assert"
 	"github.com/storacha/go-libstoracha/capabilities/space/content"
 	ctypes "github.com/storacha/go-libstoracha/capabilities/types"
 	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/ipnipublisher/publisher"
 	"github.com/storacha/go-libstoracha/metadata"
 	"github.com/storacha/go-libstoracha/testutil"
 	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/core/ipld"
 	"github.com/storacha/go-ucanto/core/result/ok"
 	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
 	ed25519 "github.com/storacha/go-ucanto/principal/ed25519/signer"
+	rsasigner "github.com/storacha/go-ucanto/principal/rsa/signer"
+	"github.com/storacha/go-ucanto/principal/signer"
 	"github.com/storacha/go-ucanto/ucan"
 	"github.com/storacha/indexing-service/pkg/internal/extmocks"
+	"github.com/storacha/indexing-service/pkg/principalresolver"
 	"github.com/storacha/indexing-service/pkg/service/blobindexlookup"
 	"github.com/storacha/indexing-service/pkg/service/contentclaims"
 	"github.com/storacha/indexing-service/pkg/service/providerindex"
@@ -747,7 +753,7 @@ func TestPublishIndexClaim(t *testing.T) {
 			},
 		)
 		require.NoError(t, err)
-		err = Publish(t.Context(), testutil.Service, nil, nil, nil, peer.AddrInfo{}, claim)
+		err = Publish(t.Context(), testutil.Service, nil, nil, nil, peer.AddrInfo{}, claim, nil)
 		require.ErrorIs(t, err, ErrUnrecognizedClaim)
 	})
 
@@ -799,7 +805,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}
 		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.NoError(t, err)
 	})
 
@@ -880,9 +886,11 @@ func TestPublishIndexClaim(t *testing.T) {
 				digests.Set(d, struct{}{})
 			}
 		}
-		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		// the context ID is scoped to the space of the retrieval delegation
+		contextID := testutil.Must(advertisement.EncodeContextID(space.DID(), indexLink.Hash()))(t)
+		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, string(contextID), mock.Anything, mock.Anything).Return(nil)
 
-		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.NoError(t, err)
 	})
 
@@ -906,7 +914,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		require.NoError(t, err)
 
 		// Attempt to publish the claim
-		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, claim)
+		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, claim, nil)
 
 		// Expect an error indicating missing capabilities
 		require.Error(t, err)
@@ -930,7 +938,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		require.NoError(t, err)
 
 		// Attempt to publish the claim
-		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, faultyIndexClaim)
+		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, faultyIndexClaim, nil)
 
 		// Expect an error indicating a problem with reading the index claim caveats
 		require.Error(t, err)
@@ -956,7 +964,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		mockClaimsService.EXPECT().Publish(extmocks.AnyContext, indexDelegation).Return(fmt.Errorf("failed to cache claim"))
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating a problem with caching the claim
 		require.Error(t, err)
@@ -988,7 +996,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}).Return([]model.ProviderResult{}, nil) // no location commitments found
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating no location commitments found
 		require.Error(t, err)
@@ -1020,7 +1028,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}).Return([]model.ProviderResult{}, fmt.Errorf("failed to find location commitments"))
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating a problem with finding location commitments
 		require.Error(t, err)
@@ -1052,7 +1060,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}).Return([]model.ProviderResult{{}}, nil)
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating a problem with fetching the blob index
 		require.Error(t, err)
@@ -1084,7 +1092,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}).Return([]model.ProviderResult{indexResult}, nil) // this is the wrong claim type
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating a problem with the metadata type
 		require.Error(t, err)
@@ -1119,7 +1127,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		}).Return([]model.ProviderResult{locationResult}, nil)
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 
 		// Expect an error indicating a problem with building the retrieval URL
 		require.Error(t, err)
@@ -1160,7 +1168,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		mockBlobIndexLookup.EXPECT().Find(extmocks.AnyContext, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "fetching blob index: verifying claim: building claim URL: no {claim} endpoint found")
 	})
@@ -1202,7 +1210,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		mockClaimsService.EXPECT().Find(extmocks.AnyContext, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("failed to find claim"))
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "fetching blob index: verifying claim: failed to find claim")
 	})
@@ -1247,7 +1255,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("failed to publish claim"))
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "publishing index claim: failed to publish claim")
 	})
@@ -1292,7 +1300,7 @@ func TestPublishIndexClaim(t *testing.T) {
 		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("failed to publish claim"))
 
 		// Attempt to publish the claim
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, indexDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "publishing index claim: failed to publish claim")
 	})
@@ -1322,7 +1330,26 @@ func TestPublishEqualsClaim(t *testing.T) {
 		// Simulate a successful result from provIndex.Publish
 		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("already advertised equals claim", func(t *testing.T) {
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		contentLink := testutil.RandomCID(t)
+
+		providerAddr := &peer.AddrInfo{
+			Addrs: []ma.Multiaddr{
+				testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fclaims%2F%7Bclaim%7D"))(t),
+			},
+		}
+		_, equalsDelegation, _, _ := buildTestEqualsClaim(t, contentLink.(cidlink.Link), providerAddr)
+		mockClaimsService.EXPECT().Publish(extmocks.AnyContext, equalsDelegation).Return(nil)
+		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("publishing advert: %w", publisher.ErrAlreadyAdvertised))
+
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation, nil)
 		require.NoError(t, err)
 	})
 
@@ -1343,7 +1370,7 @@ func TestPublishEqualsClaim(t *testing.T) {
 		require.NoError(t, err)
 
 		// Attempt to publish the claim
-		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, faultyEqualsClaim)
+		err = Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, faultyEqualsClaim, nil)
 
 		// Expect an error indicating a problem with reading the claim caveats
 		require.Error(t, err)
@@ -1369,7 +1396,7 @@ func TestPublishEqualsClaim(t *testing.T) {
 		// Simulate a failure from claims.Publish
 		mockClaimsService.EXPECT().Publish(extmocks.AnyContext, equalsDelegation).Return(fmt.Errorf("failed to publish claim"))
 
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "caching equals claim with claim service: failed to publish claim")
 	})
@@ -1396,11 +1423,31 @@ func TestPublishEqualsClaim(t *testing.T) {
 		// Simulate a failure from provIndex.Publish
 		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, *providerAddr, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("failed to publish claim"))
 
-		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation)
+		err := Publish(t.Context(), testutil.Service, mockBlobIndexLookup, mockClaimsService, mockProviderIndex, *providerAddr, equalsDelegation, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "publishing equals claim: failed to publish claim")
 	})
 
+	t.Run("republishes the equals claim scoped to a space", func(t *testing.T) {
+		mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+		mockProviderIndex := providerindex.NewMockProviderIndex(t)
+		mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+		contentLink := testutil.RandomCID(t)
+		space := testutil.RandomDID(t)
+
+		// content will have an equals claim
+		equalsDelegationCid, equalsDelegation, _, equivalentCid := buildTestEqualsClaim(t, contentLink.(cidlink.Link), &peer.AddrInfo{})
+
+		mockClaimsService.EXPECT().Get(extmocks.AnyContext, equalsDelegationCid).Return(equalsDelegation, nil)
+		mockClaimsService.EXPECT().Publish(extmocks.AnyContext, equalsDelegation).Return(nil)
+
+		contextID := testutil.Must(advertisement.EncodeContextID(space, equivalentCid.Hash()))(t)
+		mockProviderIndex.EXPECT().Publish(extmocks.AnyContext, mock.Anything, string(contextID), mock.Anything, mock.Anything).Return(nil)
+
+		service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex)
+		err := service.Republish(t.Context(), equalsDelegationCid, space)
+		require.NoError(t, err)
+	})
 }
 
 func TestCacheClaim(t *testing.T) {
@@ -1762,3 +1809,148 @@ func TestUrlForResource(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateLocationCommitment(t *testing.T) {
+	for name, issuer := range map[string]principal.Signer{
+		"ed25519": testutil.Must(ed25519.Generate())(t),
+		"rsa":     testutil.Must(rsasigner.Generate())(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			claim := testutil.Must(cassert.Location.Delegate(
+				issuer,
+				issuer,
+				issuer.DID().String(),
+				cassert.LocationCaveats{
+					Content:  ctypes.FromHash(testutil.RandomMultihash(t)),
+					Location: []url.URL{*testutil.Must(url.Parse("https://storacha.network"))(t)},
+				},
+			))(t)
+
+			_, err := validateLocationCommitment(t.Context(), claim, nil)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateLocationCommitmentDIDWeb(t *testing.T) {
+	key := testutil.Must(ed25519.Generate())(t)
+	webDID := testutil.Must(did.Parse("did:web:storage.example.com"))(t)
+	issuer := testutil.Must(signer.Wrap(key, webDID))(t)
+
+	claim := testutil.Must(cassert.Location.Delegate(
+		issuer,
+		issuer,
+		issuer.DID().String(),
+		cassert.LocationCaveats{
+			Content:  ctypes.FromHash(testutil.RandomMultihash(t)),
+			Location: []url.URL{*testutil.Must(url.Parse("https://storage.example.com"))(t)},
+		},
+	))(t)
+
+	t.Run("resolved", func(t *testing.T) {
+		resolver := testutil.Must(principalresolver.New(map[string]string{webDID.String(): key.DID().String()}))(t)
+		_, err := validateLocationCommitment(t.Context(), claim, resolver)
+		require.NoError(t, err)
+	})
+
+	t.Run("no resolver", func(t *testing.T) {
+		_, err := validateLocationCommitment(t.Context(), claim, nil)
+		require.ErrorIs(t, err, types.ErrUnresolvedDID)
+		var uerr types.UnresolvedDIDError
+		require.ErrorAs(t, err, &uerr)
+		require.Equal(t, webDID, uerr.DID)
+	})
+
+	t.Run("unknown DID", func(t *testing.T) {
+		resolver := testutil.Must(principalresolver.New(map[string]string{}))(t)
+		_, err := validateLocationCommitment(t.Context(), claim, resolver)
+		require.ErrorIs(t, err, types.ErrUnresolvedDID)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := testutil.Must(ed25519.Generate())(t)
+		resolver := testutil.Must(principalresolver.New(map[string]string{webDID.String(): other.DID().String()}))(t)
+		_, err := validateLocationCommitment(t.Context(), claim, resolver)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, types.ErrUnresolvedDID)
+	})
+}
+
+func TestQueryDIDWebIndexLocation(t *testing.T) {
+	key := testutil.Must(ed25519.Generate())(t)
+	webDID := testutil.Must(did.Parse("did:web:storage.example.com"))(t)
+	issuer := testutil.Must(signer.Wrap(key, webDID))(t)
+
+	for name, resolve := range map[string]bool{"resolved": true, "not resolved": false} {
+		t.Run(name, func(t *testing.T) {
+			mockBlobIndexLookup := blobindexlookup.NewMockBlobIndexLookup(t)
+			mockClaimsService := contentclaims.NewMockContentClaimsService(t)
+			mockProviderIndex := providerindex.NewMockProviderIndex(t)
+			providerAddr := &peer.AddrInfo{
+				Addrs: []ma.Multiaddr{
+					testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fclaims%2F%7Bclaim%7D"))(t),
+					testutil.Must(ma.NewMultiaddr("/dns/storacha.network/tls/http/http-path/%2Fblobs%2F%7Bblob%7D"))(t),
+				},
+			}
+
+			contentLink := testutil.RandomCID(t).(cidlink.Link)
+			indexDelegationCid, indexDelegation, indexResult, indexCid, index := buildTestIndexClaim(t, contentLink, providerAddr)
+
+			mockProviderIndex.EXPECT().Find(extmocks.AnyContext, providerindex.QueryKey{
+				Hash:         contentLink.Hash(),
+				TargetClaims: []multicodec.Code{metadata.IndexClaimID, metadata.LocationCommitmentID},
+			}).Return([]model.ProviderResult{indexResult}, nil)
+			indexClaimUrl := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", indexDelegationCid.String())))(t)
+			mockClaimsService.EXPECT().Find(extmocks.AnyContext, indexDelegationCid, indexClaimUrl).Return(indexDelegation, nil)
+
+			// the index location commitment is issued by a did:web storage node
+			indexSize := rand.Uint64N(5000)
+			indexLocationDelegation := testutil.Must(cassert.Location.Delegate(
+				issuer,
+				issuer,
+				issuer.DID().String(),
+				cassert.LocationCaveats{
+					Content:  ctypes.FromHash(indexCid.Hash()),
+					Location: []url.URL{*testutil.Must(url.Parse("https://storacha.network"))(t)},
+					Range:    &cassert.Range{Length: &indexSize},
+				},
+			))(t)
+			indexLocationDelegationCid := testutil.RandomCID(t).(cidlink.Link)
+			indexLocationProviderResult := model.ProviderResult{
+				ContextID: testutil.Must(types.ContextID{Hash: indexCid.Hash()}.ToEncoded())(t),
+				Metadata: testutil.Must((&metadata.LocationCommitmentMetadata{
+					Shard:      &indexCid.Cid,
+					Claim:      indexLocationDelegationCid.Cid,
+					Range:      &metadata.Range{Length: &indexSize},
+					Expiration: time.Now().Add(time.Hour).Unix(),
+				}).MarshalBinary())(t),
+				Provider: providerAddr,
+			}
+			mockProviderIndex.EXPECT().Find(extmocks.AnyContext, providerindex.QueryKey{
+				Hash:         indexCid.Hash(),
+				TargetClaims: []multicodec.Code{metadata.LocationCommitmentID},
+			}).Return([]model.ProviderResult{indexLocationProviderResult}, nil)
+			indexLocationClaimUrl := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/claims/%s", indexLocationDelegationCid.String())))(t)
+			mockClaimsService.EXPECT().Find(extmocks.AnyContext, indexLocationDelegationCid, indexLocationClaimUrl).Return(indexLocationDelegation, nil)
+
+			var opts []Option
+			if resolve {
+				resolver := testutil.Must(principalresolver.New(map[string]string{webDID.String(): key.DID().String()}))(t)
+				opts = append(opts, WithPrincipalResolver(resolver))
+
+				indexBlobUrl := testutil.Must(url.Parse(fmt.Sprintf("https://storacha.network/blobs/%s", digestutil.Format(indexCid.Hash()))))(t)
+				retrievalReq := types.NewRetrievalRequest(indexBlobUrl, &metadata.Range{Length: &indexSize}, nil)
+				mockBlobIndexLookup.EXPECT().Find(extmocks.AnyContext, types.EncodedContextID(indexLocationProviderResult.ContextID), indexResult, retrievalReq).Return(index, nil)
+			}
+
+			service := NewIndexingService(testutil.Service, mockBlobIndexLookup, mockClaimsService, peer.AddrInfo{ID: testutil.RandomPeer(t)}, mockProviderIndex, opts...)
+			result, err := service.Query(t.Context(), types.Query{Type: types.QueryTypeIndexOrLocation, Hashes: []mh.Multihash{contentLink.Hash()}})
+			if !resolve {
+				require.ErrorIs(t, err, types.ErrUnresolvedDID)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result.Indexes(), 1)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// AllocationsTable is an allocations store backed by a SQL table with a
+// multihash column holding base58btc encoded blob multihashes:
+//
+//	CREATE TABLE allocations (
+//		space TEXT NOT NULL,
+//		multihash TEXT NOT NULL,
+//		PRIMARY KEY (space, multihash)
+//	);
+//	CREATE INDEX allocations_multihash ON allocations (multihash);
+type AllocationsTable struct {
+	db    *sql.DB
+	query string
+}
+
+var _ types.AllocationsStore = (*AllocationsTable)(nil)
+
+func (t *AllocationsTable) Has(ctx context.Context, digest multihash.Multihash) (bool, error) {
+	found, err := exists(ctx, t.db, t.query, digestutil.Format(digest))
+	if err != nil {
+		return false, fmt.Errorf("querying allocations table: %w", err)
+	}
+	return found, nil
+}
+
+func NewAllocationsTable(db *sql.DB, tableName string) *AllocationsTable {
+	return &AllocationsTable{
+		db:    db,
+		query: fmt.Sprintf(`SELECT 1 FROM %s WHERE multihash = $1 LIMIT 1`, quoteIdentifier(tableName)),
+	}
+}
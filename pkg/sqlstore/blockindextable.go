@@ -0,0 +1,104 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// blockIndexQueryLimit is the maximum number of CAR positions returned for a
+// block, the same limit applied to the legacy DynamoDB block index table.
+const blockIndexQueryLimit = 25
+
+// BlockIndexTable is a block index store backed by a SQL table with the
+// following layout, where block multihashes are base58btc encoded:
+//
+//	CREATE TABLE blocks_cars_position (
+//		blockmultihash TEXT NOT NULL,
+//		carpath TEXT NOT NULL,
+//		"offset" BIGINT NOT NULL,
+//		length BIGINT NOT NULL,
+//		PRIMARY KEY (blockmultihash, carpath)
+//	);
+//
+// Listing the blocks of a shard queries the table by carpath, which should be
+// indexed.
+type BlockIndexTable struct {
+	db         *sql.DB
+	query      string
+	shardQuery string
+}
+
+var (
+	_ types.BlockIndexStore      = (*BlockIndexTable)(nil)
+	_ types.ShardBlockIndexStore = (*BlockIndexTable)(nil)
+)
+
+func (t *BlockIndexTable) Query(ctx context.Context, digest multihash.Multihash) ([]types.BlockIndexRecord, error) {
+	rows, err := t.db.QueryContext(ctx, t.query, digestutil.Format(digest))
+	if err != nil {
+		return nil, fmt.Errorf("querying block index table: %w", err)
+	}
+	defer rows.Close()
+
+	records := []types.BlockIndexRecord{}
+	for rows.Next() {
+		var r types.BlockIndexRecord
+		if err := rows.Scan(&r.CarPath, &r.Offset, &r.Length); err != nil {
+			return nil, fmt.Errorf("scanning block index row: %w", err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading block index rows: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+
+	return records, nil
+}
+
+func (t *BlockIndexTable) QueryShard(ctx context.Context, carPath string) ([]types.ShardBlockRecord, error) {
+	rows, err := t.db.QueryContext(ctx, t.shardQuery, carPath)
+	if err != nil {
+		return nil, fmt.Errorf("querying block index table: %w", err)
+	}
+	defer rows.Close()
+
+	records := []types.ShardBlockRecord{}
+	for rows.Next() {
+		var digest string
+		var r types.ShardBlockRecord
+		if err := rows.Scan(&digest, &r.Offset, &r.Length); err != nil {
+			return nil, fmt.Errorf("scanning block index row: %w", err)
+		}
+		r.Digest, err = digestutil.Parse(digest)
+		if err != nil {
+			return nil, fmt.Errorf("parsing block multihash %q: %w", digest, err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading block index rows: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+
+	return records, nil
+}
+
+func NewBlockIndexTable(db *sql.DB, tableName string) *BlockIndexTable {
+	return &BlockIndexTable{
+		db:         db,
+		query:      fmt.Sprintf(`SELECT carpath, "offset", length FROM %s WHERE blockmultihash = $1 LIMIT %d`, quoteIdentifier(tableName), blockIndexQueryLimit),
+		shardQuery: fmt.Sprintf(`SELECT blockmultihash, "offset", length FROM %s WHERE carpath = $1 ORDER BY "offset"`, quoteIdentifier(tableName)),
+	}
+}
@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/indexing-service/pkg/types"
+)
+
+// MigratedShardChecker checks whether a legacy shard has been migrated by
+// looking it up in SQL copies of the legacy store and blob registry tables,
+// falling back to the allocations store:
+//
+//	CREATE TABLE store (
+//		space TEXT NOT NULL,
+//		link TEXT NOT NULL,
+//		PRIMARY KEY (space, link)
+//	);
+//	CREATE INDEX store_link ON store (link);
+//
+//	CREATE TABLE blob_registry (
+//		space TEXT NOT NULL,
+//		digest TEXT NOT NULL,
+//		PRIMARY KEY (space, digest)
+//	);
+//	CREATE INDEX blob_registry_digest ON blob_registry (digest);
+//
+// Store links are shard CID strings and blob registry digests are base58
+// encoded multihashes, without a multibase prefix.
+type MigratedShardChecker struct {
+	db                *sql.DB
+	storeQuery        string
+	blobRegistryQuery string
+	allocationsStore  types.AllocationsStore
+}
+
+var _ types.MigratedShardChecker = (*MigratedShardChecker)(nil)
+
+func (c *MigratedShardChecker) ShardMigrated(ctx context.Context, shard ipld.Link) (bool, error) {
+	cl, ok := shard.(cidlink.Link)
+	if !ok {
+		return false, fmt.Errorf("shard is not a CID link: %T", shard)
+	}
+	if migrated, err := exists(ctx, c.db, c.storeQuery, shard.String()); err != nil {
+		return false, fmt.Errorf("querying store table: %w", err)
+	} else if migrated {
+		return true, nil
+	}
+	if migrated, err := exists(ctx, c.db, c.blobRegistryQuery, cl.Cid.Hash().B58String()); err != nil {
+		return false, fmt.Errorf("querying blob registry table: %w", err)
+	} else if migrated {
+		return true, nil
+	}
+	return c.allocationsStore.Has(ctx, cl.Cid.Hash())
+}
+
+func NewMigratedShardChecker(db *sql.DB, storeTableName string, blobRegistryTableName string, allocationsStore types.AllocationsStore) *MigratedShardChecker {
+	return &MigratedShardChecker{
+		db:                db,
+		storeQuery:        fmt.Sprintf(`SELECT 1 FROM %s WHERE link = $1 LIMIT 1`, quoteIdentifier(storeTableName)),
+		blobRegistryQuery: fmt.Sprintf(`SELECT 1 FROM %s WHERE digest = $1 LIMIT 1`, quoteIdentifier(blobRegistryTableName)),
+		allocationsStore:  allocationsStore,
+	}
+}
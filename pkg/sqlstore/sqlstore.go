@@ -0,0 +1,39 @@
+// Package sqlstore implements the stores used by the legacy claims mappers on
+// top of a SQL database accessed through database/sql. Tables mirror the
+// layout of the legacy DynamoDB tables, so their contents can be exported as
+// is.
+//
+// Queries use double quoted identifiers and $N placeholders, as supported by
+// PostgreSQL and SQLite.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// quoteIdentifier quotes a possibly schema qualified table name so it can be
+// safely interpolated in a query.
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// exists runs a query selecting at most one row and reports whether a row was
+// found.
+func exists(ctx context.Context, db *sql.DB, query string, args ...any) (bool, error) {
+	var found int
+	err := db.QueryRowContext(ctx, query, args...).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,193 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/multiformats/go-multicodec"
+	"github.com/storacha/go-libstoracha/digestutil"
+	"github.com/storacha/go-libstoracha/testutil"
+	"github.com/storacha/indexing-service/pkg/types"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+const schema = `
+CREATE TABLE blocks_cars_position (
+	blockmultihash TEXT NOT NULL,
+	carpath TEXT NOT NULL,
+	"offset" BIGINT NOT NULL,
+	length BIGINT NOT NULL,
+	PRIMARY KEY (blockmultihash, carpath)
+);
+CREATE TABLE allocations (
+	space TEXT NOT NULL,
+	multihash TEXT NOT NULL,
+	PRIMARY KEY (space, multihash)
+);
+CREATE TABLE store (
+	space TEXT NOT NULL,
+	link TEXT NOT NULL,
+	PRIMARY KEY (space, link)
+);
+CREATE TABLE blob_registry (
+	space TEXT NOT NULL,
+	digest TEXT NOT NULL,
+	PRIMARY KEY (space, digest)
+);
+`
+
+func TestBlockIndexTable(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	db := createPostgres(t)
+	table := NewBlockIndexTable(db, "blocks_cars_position")
+
+	t.Run("returns the CAR positions of a block", func(t *testing.T) {
+		digest := testutil.RandomMultihash(t)
+		_, err := db.ExecContext(ctx, `INSERT INTO blocks_cars_position VALUES ($1, $2, $3, $4), ($1, $5, $6, $7)`,
+			digestutil.Format(digest),
+			"us-west-2/dotstorage-prod-1/raw/bafy/1.car", 10, 20,
+			"https://example.com/2.car", 30, 40,
+		)
+		require.NoError(t, err)
+
+		records, err := table.Query(ctx, digest)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []types.BlockIndexRecord{
+			{CarPath: "us-west-2/dotstorage-prod-1/raw/bafy/1.car", Offset: 10, Length: 20},
+			{CarPath: "https://example.com/2.car", Offset: 30, Length: 40},
+		}, records)
+	})
+
+	t.Run("returns not found for unknown blocks", func(t *testing.T) {
+		_, err := table.Query(ctx, testutil.RandomMultihash(t))
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+
+	t.Run("returns the blocks of a CAR", func(t *testing.T) {
+		first, second := testutil.RandomMultihash(t), testutil.RandomMultihash(t)
+		_, err := db.ExecContext(ctx, `INSERT INTO blocks_cars_position VALUES ($1, $3, $4, $5), ($2, $3, $6, $7)`,
+			digestutil.Format(second),
+			digestutil.Format(first),
+			"us-west-2/dotstorage-prod-1/raw/bafy/3.car",
+			50, 60,
+			10, 20,
+		)
+		require.NoError(t, err)
+
+		records, err := table.QueryShard(ctx, "us-west-2/dotstorage-prod-1/raw/bafy/3.car")
+		require.NoError(t, err)
+		require.Equal(t, []types.ShardBlockRecord{
+			{Digest: first, Offset: 10, Length: 20},
+			{Digest: second, Offset: 50, Length: 60},
+		}, records)
+	})
+
+	t.Run("returns not found for unknown CARs", func(t *testing.T) {
+		_, err := table.QueryShard(ctx, "us-west-2/dotstorage-prod-1/raw/bafy/missing.car")
+		require.ErrorIs(t, err, types.ErrKeyNotFound)
+	})
+}
+
+func TestAllocationsTable(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	db := createPostgres(t)
+	table := NewAllocationsTable(db, "public.allocations")
+
+	digest := testutil.RandomMultihash(t)
+	space := testutil.RandomPrincipal(t).DID().String()
+	_, err := db.ExecContext(ctx, `INSERT INTO allocations VALUES ($1, $2)`, space, digestutil.Format(digest))
+	require.NoError(t, err)
+
+	has, err := table.Has(ctx, digest)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	has, err = table.Has(ctx, testutil.RandomMultihash(t))
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestMigratedShardChecker(t *testing.T) {
+	if os.Getenv("CI") != "" && runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+	db := createPostgres(t)
+	checker := NewMigratedShardChecker(db, "store", "blob_registry", NewAllocationsTable(db, "allocations"))
+	space := testutil.RandomPrincipal(t).DID().String()
+
+	randomShard := func() cidlink.Link {
+		return cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.Car), testutil.RandomMultihash(t))}
+	}
+
+	t.Run("shard in store table", func(t *testing.T) {
+		shard := randomShard()
+		_, err := db.ExecContext(ctx, `INSERT INTO store VALUES ($1, $2)`, space, shard.String())
+		require.NoError(t, err)
+
+		migrated, err := checker.ShardMigrated(ctx, shard)
+		require.NoError(t, err)
+		require.True(t, migrated)
+	})
+
+	t.Run("shard in blob registry table", func(t *testing.T) {
+		shard := randomShard()
+		_, err := db.ExecContext(ctx, `INSERT INTO blob_registry VALUES ($1, $2)`, space, shard.Cid.Hash().B58String())
+		require.NoError(t, err)
+
+		migrated, err := checker.ShardMigrated(ctx, shard)
+		require.NoError(t, err)
+		require.True(t, migrated)
+	})
+
+	t.Run("shard in allocations table", func(t *testing.T) {
+		shard := randomShard()
+		_, err := db.ExecContext(ctx, `INSERT INTO allocations VALUES ($1, $2)`, space, digestutil.Format(shard.Cid.Hash()))
+		require.NoError(t, err)
+
+		migrated, err := checker.ShardMigrated(ctx, shard)
+		require.NoError(t, err)
+		require.True(t, migrated)
+	})
+
+	t.Run("shard not migrated", func(t *testing.T) {
+		migrated, err := checker.ShardMigrated(ctx, randomShard())
+		require.NoError(t, err)
+		require.False(t, migrated)
+	})
+}
+
+func createPostgres(t *testing.T) *sql.DB {
+	ctx := context.Background()
+	container, err := tcpostgres.Run(ctx, "postgres:16-alpine", tcpostgres.BasicWaitStrategies())
+	testcontainers.CleanupContainer(t, container)
+	require.NoError(t, err)
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(t, err)
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.ExecContext(ctx, schema)
+	require.NoError(t, err)
+	return db
+}
@@ -0,0 +1,40 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// SetupMetrics configures the OpenTelemetry SDK by setting up a global meter
+// provider that exports metrics in the Prometheus format. It returns an HTTP
+// handler that serves the metrics, and a function that shuts down the meter
+// provider.
+//
+// Instruments created from the global meter provider before this function is
+// called are also exported.
+func SetupMetrics() (http.Handler, func(context.Context), error) {
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating prometheus exporter: %w", err)
+	}
+
+	mp := metricsdk.NewMeterProvider(metricsdk.WithReader(exporter))
+	otel.SetMeterProvider(mp)
+
+	shutdownFunc := func(ctx context.Context) {
+		err := mp.Shutdown(ctx)
+		if err != nil {
+			log.Errorf("error shutting down meter provider: %s", err)
+		}
+	}
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), shutdownFunc, nil
+}
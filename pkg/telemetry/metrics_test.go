@@ -0,0 +1,28 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupMetrics(t *testing.T) {
+	handler, shutdown, err := SetupMetrics()
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	counter, err := otel.Meter("test").Int64Counter("test.requests")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "test_requests_total{")
+	require.Contains(t, string(body), "} 3\n")
+}
@@ -3,10 +3,10 @@ package telemetry
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	logging "github.com/ipfs/go-log/v2"
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	ecsdetector "go.opentelemetry.io/contrib/detectors/aws/ecs"
@@ -21,6 +21,8 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+var log = logging.Logger("telemetry")
+
 type config struct {
 	baseSampler tracesdk.Sampler
 }
@@ -81,7 +83,7 @@ func SetupTelemetry(ctx context.Context, cfg *aws.Config, opts ...TelemetryOptio
 	shutdownFunc := func(ctx context.Context) {
 		err := tp.Shutdown(ctx)
 		if err != nil {
-			fmt.Printf("error shutting down tracer provider: %v", err)
+			log.Errorf("error shutting down tracer provider: %s", err)
 		}
 	}
 
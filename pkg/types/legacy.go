@@ -0,0 +1,49 @@
+package types
+
+import (
+	"context"
+
+	"github.com/ipld/go-ipld-prime"
+	multihash "github.com/multiformats/go-multihash"
+)
+
+// BlockIndexStore finds the positions of blocks in legacy CAR shards.
+type BlockIndexStore interface {
+	// Query returns the positions of the block with the given multihash, or
+	// ErrKeyNotFound if the block is not indexed.
+	Query(ctx context.Context, digest multihash.Multihash) ([]BlockIndexRecord, error)
+}
+
+// BlockIndexRecord is the position of a block in a legacy CAR shard.
+type BlockIndexRecord struct {
+	CarPath string
+	Offset  uint64
+	Length  uint64
+}
+
+// ShardBlockIndexStore lists the blocks of legacy CAR shards recorded in a
+// block index.
+type ShardBlockIndexStore interface {
+	// QueryShard returns the positions of the blocks in the CAR at the given
+	// path, or ErrKeyNotFound if no block of the CAR is indexed.
+	QueryShard(ctx context.Context, carPath string) ([]ShardBlockRecord, error)
+}
+
+// ShardBlockRecord is the position of a block in the legacy CAR shard it was
+// listed for.
+type ShardBlockRecord struct {
+	Digest multihash.Multihash
+	Offset uint64
+	Length uint64
+}
+
+// AllocationsStore reports whether a blob has been allocated in any space.
+type AllocationsStore interface {
+	Has(ctx context.Context, digest multihash.Multihash) (bool, error)
+}
+
+// MigratedShardChecker reports whether a legacy shard has been migrated to
+// current storage.
+type MigratedShardChecker interface {
+	ShardMigrated(ctx context.Context, shard ipld.Link) (bool, error)
+}
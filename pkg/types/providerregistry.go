@@ -0,0 +1,52 @@
+package types
+
+import (
+	"context"
+	"errors"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/storacha/go-ucanto/did"
+)
+
+// ProviderStatus is the status of a storage provider in the registry.
+type ProviderStatus string
+
+const (
+	// ProviderStatusActive providers may cache claims, and their results are
+	// returned by queries.
+	ProviderStatusActive ProviderStatus = "active"
+	// ProviderStatusSuspended providers may not cache claims, and their results
+	// are excluded from queries.
+	ProviderStatusSuspended ProviderStatus = "suspended"
+)
+
+// StorageProvider is an entry in the storage provider registry.
+type StorageProvider struct {
+	DID did.DID `json:"did"`
+	// PeerIDs are the peer IDs the provider may cache claims for.
+	PeerIDs []peer.ID `json:"peerIds"`
+	// Endpoints are the multiaddrs the provider may advertise when caching
+	// claims. Any address is allowed if empty.
+	Endpoints []string       `json:"endpoints,omitempty"`
+	Status    ProviderStatus `json:"status"`
+}
+
+// ErrUnauthorizedProvider indicates a storage provider is not registered, is
+// suspended, or is not allowed to use the peer ID or endpoints it supplied.
+var ErrUnauthorizedProvider = errors.New("unauthorized storage provider")
+
+// ProviderRegistry stores the storage providers allowed to cache claims with
+// the indexing service.
+type ProviderRegistry interface {
+	// Get returns the provider with the passed DID, or [ErrKeyNotFound].
+	Get(ctx context.Context, provider did.DID) (StorageProvider, error)
+	// Find returns the provider with the passed peer ID, or [ErrKeyNotFound].
+	Find(ctx context.Context, peerID peer.ID) (StorageProvider, error)
+	// List returns all the providers in the registry.
+	List(ctx context.Context) ([]StorageProvider, error)
+	// Put adds or replaces a provider.
+	Put(ctx context.Context, provider StorageProvider) error
+	// Delete removes a provider. Deleting a provider that does not exist is not
+	// an error.
+	Delete(ctx context.Context, provider did.DID) error
+}
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// QueryStats collects statistics about the work done to answer a query. It is
+// carried in the context of the query, so that the components involved can
+// record their work without it being threaded through their interfaces. All
+// methods are safe to call concurrently, and on a nil *QueryStats.
+type QueryStats struct {
+	jobs        atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+}
+
+type queryStatsKey struct{}
+
+// ContextWithQueryStats returns a context that carries the passed stats.
+func ContextWithQueryStats(ctx context.Context, stats *QueryStats) context.Context {
+	return context.WithValue(ctx, queryStatsKey{}, stats)
+}
+
+// QueryStatsFromContext returns the stats carried by the context, or nil if
+// stats are not being collected.
+func QueryStatsFromContext(ctx context.Context) *QueryStats {
+	stats, _ := ctx.Value(queryStatsKey{}).(*QueryStats)
+	return stats
+}
+
+// AddJob records that a job was processed for the query.
+func (s *QueryStats) AddJob() {
+	if s != nil {
+		s.jobs.Add(1)
+	}
+}
+
+// AddCacheLookup records a cache lookup, and whether the key was found.
+func (s *QueryStats) AddCacheLookup(hit bool) {
+	if s == nil {
+		return
+	}
+	if hit {
+		s.cacheHits.Add(1)
+	} else {
+		s.cacheMisses.Add(1)
+	}
+}
+
+// Jobs returns the number of jobs processed for the query.
+func (s *QueryStats) Jobs() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.jobs.Load()
+}
+
+// CacheHits returns the number of cache lookups that found the key.
+func (s *QueryStats) CacheHits() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.cacheHits.Load()
+}
+
+// CacheMisses returns the number of cache lookups that did not find the key.
+func (s *QueryStats) CacheMisses() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.cacheMisses.Load()
+}
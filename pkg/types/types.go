@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipni/go-libipni/find/model"
@@ -85,9 +86,93 @@ type ValueSetCacheBatcher[Key, Value any] interface {
 	Commit(ctx context.Context) error
 }
 
+// AddCounter is implemented by batchers that are able to report how many
+// values were newly added to sets by the last commit.
+type AddCounter interface {
+	Added() uint64
+}
+
 // ProviderStore caches queries to IPNI
 type ProviderStore BatchingValueSetCache[mh.Multihash, model.ProviderResult]
 
+// CachedIndexStore records indexes whose records have been fully cached for a
+// provider, so that caching them again can be skipped.
+type CachedIndexStore Cache[string, bool]
+
+// CachingCheckpoint records the progress of a provider caching job, as the
+// position of the next record to cache when shards and their slices are
+// iterated in order of their multihash.
+type CachingCheckpoint struct {
+	Shard int
+	Slice int
+}
+
+// CachingCheckpointStore stores the progress of provider caching jobs so that
+// interrupted jobs can be resumed.
+type CachingCheckpointStore Cache[string, CachingCheckpoint]
+
+// RateLimit is a token bucket limit, allowing Rate requests per second on
+// average, and bursts of up to Burst requests. A Rate of zero or less means
+// requests are not limited.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// RetryAfter is the time until a token is available, when not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore stores token buckets.
+type RateLimitStore interface {
+	// Take takes a token from the bucket identified by key, which is filled
+	// according to the passed limit.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// ErrRateLimited indicates a request was rejected because its client exceeded
+// its rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitedError is returned when a request is rate limited. It wraps
+// [ErrRateLimited].
+type RateLimitedError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e RateLimitedError) Error() string {
+	return fmt.Sprintf("%s for %s, retry after %s", ErrRateLimited, e.Key, e.RetryAfter)
+}
+
+func (e RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// ErrUnresolvedDID indicates a principal that is not a did:key, such as a
+// did:web claim issuer, could not be resolved to a did:key.
+var ErrUnresolvedDID = errors.New("unresolved DID")
+
+// UnresolvedDIDError is returned when a principal could not be resolved to a
+// did:key. It wraps [ErrUnresolvedDID] and the cause of the failure.
+type UnresolvedDIDError struct {
+	DID   did.DID
+	Cause error
+}
+
+func (e UnresolvedDIDError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrUnresolvedDID, e.DID, e.Cause)
+}
+
+func (e UnresolvedDIDError) Unwrap() []error {
+	return []error{ErrUnresolvedDID, e.Cause}
+}
+
 // NoProviderStore caches which queries for providers returned no results
 type NoProviderStore ValueSetCache[mh.Multihash, multicodec.Code]
 
@@ -146,6 +231,10 @@ func ParseQueryType(queryTypeStr string) (QueryType, error) {
 	}
 }
 
+// ErrUnauthorizedQuery indicates a query for a private space that did not carry
+// a valid delegation authorizing the indexing service to query the space.
+var ErrUnauthorizedQuery = errors.New("unauthorized query")
+
 // Query is a query for several multihashes
 type Query struct {
 	Type   QueryType
@@ -155,6 +244,8 @@ type Query struct {
 	// are typically `space/content/retrieve` delegations for each subject (space)
 	// in the [Match] paremeter.
 	//
+	// Delegations for private spaces also authorize the query itself.
+	//
 	// Delegations are sent in the `X-Agent-Message` HTTP header and MUST NOT
 	// exceed 4kb in size.
 	Delegations []delegation.Delegation